@@ -13,12 +13,17 @@ import (
 
 	"gbh-backend/internal/auth"
 	"gbh-backend/internal/cache"
+	"gbh-backend/internal/caldav"
 	"gbh-backend/internal/casestudies"
 	"gbh-backend/internal/config"
+	"gbh-backend/internal/cron"
 	"gbh-backend/internal/db"
+	"gbh-backend/internal/emailevents"
 	"gbh-backend/internal/handlers"
+	"gbh-backend/internal/meeting"
 	"gbh-backend/internal/middleware"
 	"gbh-backend/internal/notifications"
+	"gbh-backend/internal/rbac"
 	"gbh-backend/internal/references"
 	"gbh-backend/internal/rfp"
 	"gbh-backend/internal/validation"
@@ -86,13 +91,30 @@ func main() {
 		}
 	}
 
-	mailer := notifications.NewBrevoClient(cfg.BrevoAPIKey, cfg.BrevoSenderEmail, cfg.BrevoSenderName, cfg.BrevoSandbox)
+	emailEventsRepo := emailevents.NewRepository(cols.EmailEvents, cols.EmailSuppressions,
+		emailevents.BounceTarget{Col: cols.Appointments, EmailField: "email", FlagField: "emailBouncedAt"},
+		emailevents.BounceTarget{Col: cols.RFPLeads, EmailField: "email", FlagField: "email_bounced_at"},
+		emailevents.BounceTarget{Col: cols.Users, EmailField: "email", FlagField: "emailBouncedAt"},
+	)
+	emailEventsService := emailevents.NewService(emailEventsRepo, cfg.Timezone)
+	emailEventsHandler := emailevents.NewHandler(emailEventsService, logger, cfg.BrevoWebhookSecret)
+
+	mailer := notifications.NewBrevoClient(cfg.BrevoAPIKey, cfg.BrevoSenderEmail, cfg.BrevoSenderName, cfg.BrevoSandbox, cfg.Timezone)
 	if mailer == nil {
 		logger.Info("brevo mailer disabled")
 	} else {
+		mailer.UseSuppressionList(emailEventsService)
 		logger.Info("brevo mailer enabled", slog.String("sender", cfg.BrevoSenderEmail), slog.Bool("sandbox", cfg.BrevoSandbox))
 	}
 
+	var sms handlers.SMSSender
+	if smsClient := notifications.NewBrevoSMSClient(cfg.BrevoAPIKey, cfg.BrevoSMSSender); smsClient != nil {
+		sms = smsClient
+		logger.Info("brevo sms enabled", slog.String("sender", cfg.BrevoSMSSender))
+	} else {
+		logger.Info("brevo sms disabled")
+	}
+
 	var push handlers.AppointmentPusher
 	if cfg.FirebaseCredentialsBase64 != "" {
 		// Decode base64 credentials
@@ -120,14 +142,39 @@ func main() {
 		logger.Info("fcm push disabled")
 	}
 
+	var meetings meeting.Provider
+	switch cfg.MeetingProvider {
+	case meeting.ProviderJitsi:
+		jitsi, err := meeting.NewJitsiProvider(cfg.JitsiBaseURL, cfg.MeetingRoomPrefix)
+		if err != nil {
+			logger.Error("meeting provider init failed", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		meetings = jitsi
+	case meeting.ProviderHosted:
+		hosted, err := meeting.NewHostedProvider(cfg.MeetingAPIURL, cfg.MeetingAPIKey, nil)
+		if err != nil {
+			logger.Error("meeting provider init failed", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		meetings = hosted
+	}
+	if meetings == nil {
+		logger.Info("meeting links disabled")
+	} else {
+		logger.Info("meeting links enabled", slog.String("provider", meetings.Name()))
+	}
+
 	server := &handlers.Server{
-		Cfg:    cfg,
-		Cols:   cols,
-		Val:    validation.New(),
-		Log:    logger,
-		Cache:  cacheStore,
-		Mailer: mailer,
-		Push:   push,
+		Cfg:      cfg,
+		Cols:     cols,
+		Val:      validation.New(),
+		Log:      logger,
+		Cache:    cacheStore,
+		Mailer:   mailer,
+		Push:     push,
+		Meetings: meetings,
+		SMS:      sms,
 	}
 
 	rfpRepo := rfp.NewRepository(cols.RFPLeads)
@@ -142,6 +189,32 @@ func main() {
 	caseStudiesService := casestudies.NewService(caseStudiesRepo, cfg.Timezone)
 	caseStudiesHandler := casestudies.NewHandler(caseStudiesService, server.Val, logger)
 
+	calendarClients := make([]*caldav.Client, 0, len(cfg.CalDAVCalendarURLs))
+	for _, calendarURL := range cfg.CalDAVCalendarURLs {
+		client, err := caldav.NewClient(calendarURL, cfg.CalDAVUsername, cfg.CalDAVPassword, nil)
+		if err != nil {
+			logger.Error("caldav init failed", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		calendarClients = append(calendarClients, client)
+	}
+	calendarSyncRepo := caldav.NewRepository(cols.ExternalBusy, cols.CalDAVStates, cols.CalDAVConflicts, cols.Appointments, cols.Services)
+	calendarSyncService := caldav.NewService(calendarSyncRepo, calendarClients, cacheStore, cfg.Timezone, logger)
+	calendarSyncHandler := caldav.NewHandler(calendarSyncService, logger)
+	if calendarSyncService.Enabled() {
+		logger.Info("caldav sync enabled", slog.Int("calendars", len(calendarClients)))
+	} else {
+		logger.Info("caldav sync disabled")
+	}
+
+	// adminSession authenticates; adminAuth also enforces the 2FA policy and
+	// guards everything except the caller's own profile and 2FA enrolment.
+	adminSession := middleware.AdminAuth(cfg.AdminAPIKey, jwtManager, server)
+	adminAuth := func(next http.Handler) http.Handler {
+		return adminSession(server.RequireTwoFactor(next))
+	}
+	can := middleware.RequirePermission
+
 	r := chi.NewRouter()
 	r.Use(chiMiddleware.RealIP)
 	r.Use(chiMiddleware.Recoverer)
@@ -152,6 +225,7 @@ func main() {
 
 	appointmentsLimiter := middleware.NewRateLimiter(cfg.RateLimitAppointments, time.Duration(cfg.RateLimitWindowSec)*time.Second)
 	contactLimiter := middleware.NewRateLimiter(cfg.RateLimitContact, time.Duration(cfg.RateLimitWindowSec)*time.Second)
+	loginLimiter := middleware.NewRateLimiter(cfg.RateLimitLogin, time.Duration(cfg.RateLimitWindowSec)*time.Second)
 
 	registerCoreRoutes := func(api chi.Router) {
 		api.Get("/services", server.GetServices)
@@ -159,38 +233,127 @@ func main() {
 		api.Get("/services/{id}/testimonials", server.GetServiceTestimonials)
 		api.With(contactLimiter.Middleware).Post("/services/{id}/testimonials", server.CreateServiceTestimonial)
 		api.Group(func(protected chi.Router) {
-			protected.Use(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager))
-			protected.Post("/services", server.AdminCreateService)
-			protected.Put("/services/{id}", server.AdminUpdateService)
+			protected.Use(adminAuth)
+			protected.With(can(rbac.ServicesWrite)).Post("/services", server.AdminCreateService)
+			protected.With(can(rbac.ServicesWrite)).Put("/services/{id}", server.AdminUpdateService)
 		})
+		api.Get("/locations", server.ListLocations)
+		api.Get("/locations/{id}", server.GetLocation)
 		api.Get("/availability", server.GetAvailability)
 		api.Get("/availability/next", server.GetNextAvailability)
+		api.Get("/availability/range", server.GetAvailabilityRange)
 		api.With(appointmentsLimiter.Middleware).Post("/appointments", server.CreateAppointment)
+		api.With(appointmentsLimiter.Middleware).Post("/intake-files", server.UploadIntakeFile)
 		api.Post("/appointments/lookup", server.LookupAppointment)
+		api.With(appointmentsLimiter.Middleware).Post("/appointments/cancel", server.CancelAppointment)
+		api.With(appointmentsLimiter.Middleware).Post("/appointments/reschedule", server.RescheduleAppointment)
+		api.With(appointmentsLimiter.Middleware).Post("/appointments/series", server.CreateAppointmentSeries)
+		api.With(appointmentsLimiter.Middleware).Post("/appointments/series/{id}/cancel", server.CancelAppointmentSeries)
+		api.With(appointmentsLimiter.Middleware).Post("/appointments/series/{id}/reschedule", server.RescheduleAppointmentSeries)
+		api.With(appointmentsLimiter.Middleware).Post("/waitlist", server.JoinWaitlist)
+		api.With(appointmentsLimiter.Middleware).Post("/waitlist/leave", server.LeaveWaitlist)
+		api.Get("/waitlist/offers/{token}", server.GetWaitlistOffer)
+		api.With(appointmentsLimiter.Middleware).Post("/waitlist/claim", server.ClaimWaitlistOffer)
+		api.Get("/group-sessions", server.ListGroupSessions)
+		api.Get("/group-sessions/{id}", server.GetGroupSession)
+		api.With(appointmentsLimiter.Middleware).Post("/group-sessions/{id}/register", server.RegisterGroupSession)
+		api.With(appointmentsLimiter.Middleware).Post("/group-sessions/registrations/cancel", server.CancelGroupSessionRegistration)
 		api.Get("/appointments/{id}", server.GetAppointment)
 		api.With(contactLimiter.Middleware).Post("/contact", server.CreateContact)
 		api.Post("/payments/intent", server.CreatePaymentIntent)
+		api.Post("/webhooks/brevo", emailEventsHandler.BrevoWebhook)
+		api.Get("/calendar/{token}.ics", server.GetCalendarFeed)
 
 		api.Route("/admin", func(admin chi.Router) {
 			admin.Post("/register", server.AdminRegister)
-			admin.Post("/login", server.AdminLogin)
+			admin.With(loginLimiter.Middleware).Post("/login", server.AdminLogin)
+			admin.With(loginLimiter.Middleware).Post("/login/2fa", server.AdminLoginTwoFactor)
 			admin.Post("/refresh", server.AdminRefresh)
 			admin.Post("/logout", server.AdminLogout)
+			admin.With(loginLimiter.Middleware).Post("/password/forgot", server.AdminForgotPassword)
+			admin.With(loginLimiter.Middleware).Post("/password/reset", server.AdminResetPassword)
+			admin.Get("/invitations/accept", server.AdminPreviewInvitation)
+			admin.With(loginLimiter.Middleware).Post("/invitations/accept", server.AdminAcceptInvitation)
 
 			// Important (chi): middlewares must be attached before defining routes.
 			// We keep login/refresh/logout public, and protect the rest via a sub-router.
+			admin.Group(func(self chi.Router) {
+				self.Use(adminSession)
+				self.Get("/me", server.AdminMe)
+				self.Patch("/me", server.AdminUpdateMe)
+				self.Post("/2fa/setup", server.AdminTwoFactorSetup)
+				self.Post("/2fa/enable", server.AdminTwoFactorEnable)
+				self.Post("/2fa/disable", server.AdminTwoFactorDisable)
+				self.Post("/2fa/recovery-codes", server.AdminTwoFactorRecoveryCodes)
+			})
 			admin.Group(func(protected chi.Router) {
-				protected.Use(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager))
-				protected.Post("/services", server.AdminCreateService)
-				protected.Put("/services/{id}", server.AdminUpdateService)
-				protected.Delete("/services/{id}", server.AdminDeleteService)
-				protected.Post("/blocks", server.AdminCreateBlock)
-				protected.Delete("/blocks/{id}", server.AdminDeleteBlock)
-				protected.Post("/users", server.AdminCreateUser)
-				protected.Patch("/users/{id}/password", server.AdminUpdateUserPassword)
-				protected.Get("/appointments", server.AdminListAppointments)
-				protected.Patch("/appointments/{id}/status", server.AdminUpdateAppointmentStatus)
-				protected.Get("/contacts", server.AdminListContacts)
+				protected.Use(adminAuth)
+				protected.With(can(rbac.UsersRead)).Get("/sessions", server.AdminListSessions)
+				protected.With(can(rbac.UsersWrite)).Delete("/sessions/{id}", server.AdminRevokeSession)
+				protected.With(can(rbac.ServicesWrite)).Post("/services", server.AdminCreateService)
+				protected.With(can(rbac.ServicesWrite)).Put("/services/{id}", server.AdminUpdateService)
+				protected.With(can(rbac.ServicesWrite)).Delete("/services/{id}", server.AdminDeleteService)
+				protected.With(can(rbac.ServicesWrite)).Put("/services/{id}/intake", server.AdminUpdateServiceIntake)
+				protected.With(can(rbac.AppointmentsRead)).Get("/locations", server.AdminListLocations)
+				protected.With(can(rbac.ServicesWrite)).Post("/locations", server.AdminCreateLocation)
+				protected.With(can(rbac.ServicesWrite)).Put("/locations/{id}", server.AdminUpdateLocation)
+				protected.With(can(rbac.ServicesWrite)).Delete("/locations/{id}", server.AdminDeleteLocation)
+				protected.With(can(rbac.AvailabilityWrite)).Post("/blocks", server.AdminCreateBlock)
+				protected.With(can(rbac.AvailabilityWrite)).Delete("/blocks/{id}", server.AdminDeleteBlock)
+				protected.With(can(rbac.UsersRead)).Get("/users", server.AdminListUsers)
+				protected.With(can(rbac.UsersWrite)).Post("/users", server.AdminCreateUser)
+				protected.With(can(rbac.UsersRead)).Get("/users/{id}", server.AdminGetUser)
+				protected.With(can(rbac.UsersWrite)).Patch("/users/{id}", server.AdminUpdateUser)
+				protected.With(can(rbac.UsersWrite)).Delete("/users/{id}", server.AdminDeleteUser)
+				protected.With(can(rbac.UsersWrite)).Post("/users/{id}/disable", server.AdminDisableUser)
+				protected.With(can(rbac.UsersWrite)).Post("/users/{id}/enable", server.AdminEnableUser)
+				protected.With(can(rbac.UsersWrite)).Patch("/users/{id}/password", server.AdminUpdateUserPassword)
+				protected.With(can(rbac.UsersWrite)).Patch("/users/{id}/notifications", server.AdminUpdateUserNotifications)
+				protected.With(can(rbac.RolesWrite)).Patch("/users/{id}/role", server.AdminUpdateUserRole)
+				protected.With(can(rbac.UsersWrite)).Post("/users/{id}/calendar-feed", server.AdminCreateCalendarFeed)
+				protected.With(can(rbac.UsersWrite)).Delete("/users/{id}/calendar-feed", server.AdminRevokeCalendarFeed)
+				protected.With(can(rbac.UsersWrite)).Delete("/users/{id}/sessions", server.AdminRevokeUserSessions)
+				protected.With(can(rbac.UsersWrite)).Delete("/users/{id}/2fa", server.AdminResetUserTwoFactor)
+				protected.With(can(rbac.UsersWrite)).Post("/users/{id}/unlock", server.AdminUnlockUser)
+				protected.With(can(rbac.UsersRead)).Get("/login-history", server.AdminListLoginHistory)
+				protected.With(can(rbac.UsersRead)).Get("/invitations", server.AdminListInvitations)
+				protected.With(can(rbac.UsersWrite)).Post("/invitations", server.AdminCreateInvitation)
+				protected.With(can(rbac.UsersWrite)).Post("/invitations/{id}/resend", server.AdminResendInvitation)
+				protected.With(can(rbac.UsersWrite)).Delete("/invitations/{id}", server.AdminRevokeInvitation)
+				protected.With(can(rbac.APIKeysRead)).Get("/api-keys", server.AdminListAPIKeys)
+				protected.With(can(rbac.APIKeysWrite)).Post("/api-keys", server.AdminCreateAPIKey)
+				protected.With(can(rbac.APIKeysWrite)).Post("/api-keys/{id}/rotate", server.AdminRotateAPIKey)
+				protected.With(can(rbac.APIKeysWrite)).Delete("/api-keys/{id}", server.AdminRevokeAPIKey)
+				protected.With(can(rbac.UsersRead)).Get("/permissions", server.AdminListPermissions)
+				protected.With(can(rbac.UsersRead)).Get("/roles", server.AdminListRoles)
+				protected.With(can(rbac.RolesWrite)).Post("/roles", server.AdminCreateRole)
+				protected.With(can(rbac.RolesWrite)).Put("/roles/{name}", server.AdminUpdateRole)
+				protected.With(can(rbac.RolesWrite)).Delete("/roles/{name}", server.AdminDeleteRole)
+				protected.With(can(rbac.AppointmentsRead)).Get("/appointments", server.AdminListAppointments)
+				protected.With(can(rbac.AppointmentsRead)).Get("/appointments/export", server.AdminExportAppointments)
+				protected.With(can(rbac.AppointmentsWrite)).Post("/appointments", server.AdminCreateAppointment)
+				protected.With(can(rbac.AppointmentsWrite)).Patch("/appointments/{id}", server.AdminUpdateAppointment)
+				protected.With(can(rbac.AppointmentsWrite)).Patch("/appointments/{id}/status", server.AdminUpdateAppointmentStatus)
+				protected.With(can(rbac.AppointmentsWrite)).Post("/appointments/{id}/meeting", server.AdminRegenerateMeetingLink)
+				protected.With(can(rbac.AppointmentsRead)).Get("/intake-files/{id}", server.AdminGetIntakeFile)
+				protected.With(can(rbac.AppointmentsRead)).Get("/appointment-series/{id}", server.AdminGetAppointmentSeries)
+				protected.With(can(rbac.AppointmentsWrite)).Patch("/appointment-series/{id}", server.AdminUpdateAppointmentSeries)
+				protected.With(can(rbac.AppointmentsWrite)).Post("/appointment-series/{id}/cancel", server.AdminCancelAppointmentSeries)
+				protected.With(can(rbac.AppointmentsRead)).Get("/waitlist", server.AdminListWaitlist)
+				protected.With(can(rbac.AppointmentsWrite)).Delete("/waitlist/{id}", server.AdminDeleteWaitlistEntry)
+				protected.With(can(rbac.AppointmentsRead)).Get("/group-sessions", server.AdminListGroupSessions)
+				protected.With(can(rbac.AppointmentsWrite)).Post("/group-sessions", server.AdminCreateGroupSession)
+				protected.With(can(rbac.AppointmentsWrite)).Patch("/group-sessions/{id}", server.AdminUpdateGroupSession)
+				protected.With(can(rbac.AppointmentsWrite)).Delete("/group-sessions/{id}", server.AdminCancelGroupSession)
+				protected.With(can(rbac.AppointmentsRead)).Get("/group-sessions/{id}/attendees", server.AdminListGroupSessionAttendees)
+				protected.With(can(rbac.ContactsRead)).Get("/contacts", server.AdminListContacts)
+				protected.With(can(rbac.ContactsWrite)).Patch("/contacts/{id}/answered", server.AdminMarkContactAnswered)
+				protected.With(can(rbac.EmailRead)).Get("/email-events", emailEventsHandler.AdminListEvents)
+				protected.With(can(rbac.EmailRead)).Get("/email-suppressions", emailEventsHandler.AdminListSuppressions)
+				protected.With(can(rbac.EmailWrite)).Delete("/email-suppressions/{email}", emailEventsHandler.AdminDeleteSuppression)
+				protected.With(can(rbac.CalendarRead)).Get("/calendar-sync", calendarSyncHandler.AdminStatus)
+				protected.With(can(rbac.CalendarWrite)).Post("/calendar-sync/run", calendarSyncHandler.AdminRun)
+				protected.With(can(rbac.CalendarRead)).Get("/calendar-sync/conflicts", calendarSyncHandler.AdminListConflicts)
 			})
 		})
 	}
@@ -201,19 +364,19 @@ func main() {
 		api.Get("/case-studies", caseStudiesHandler.PublicList)
 		api.Get("/case-studies/{slug}", caseStudiesHandler.PublicGetBySlug)
 
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Get("/admin/rfp", rfpHandler.AdminList)
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Get("/admin/rfp/{id}", rfpHandler.AdminGetByID)
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Patch("/admin/rfp/{id}", rfpHandler.AdminUpdateStatus)
+		api.With(adminAuth, can(rbac.RFPRead)).Get("/admin/rfp", rfpHandler.AdminList)
+		api.With(adminAuth, can(rbac.RFPRead)).Get("/admin/rfp/{id}", rfpHandler.AdminGetByID)
+		api.With(adminAuth, can(rbac.RFPWrite)).Patch("/admin/rfp/{id}", rfpHandler.AdminUpdateStatus)
 
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Get("/admin/references", referencesHandler.AdminList)
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Post("/admin/references", referencesHandler.AdminCreate)
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Put("/admin/references/{id}", referencesHandler.AdminUpdate)
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Delete("/admin/references/{id}", referencesHandler.AdminDelete)
+		api.With(adminAuth, can(rbac.ContentRead)).Get("/admin/references", referencesHandler.AdminList)
+		api.With(adminAuth, can(rbac.ContentWrite)).Post("/admin/references", referencesHandler.AdminCreate)
+		api.With(adminAuth, can(rbac.ContentWrite)).Put("/admin/references/{id}", referencesHandler.AdminUpdate)
+		api.With(adminAuth, can(rbac.ContentWrite)).Delete("/admin/references/{id}", referencesHandler.AdminDelete)
 
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Get("/admin/case-studies", caseStudiesHandler.AdminList)
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Post("/admin/case-studies", caseStudiesHandler.AdminCreate)
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Put("/admin/case-studies/{id}", caseStudiesHandler.AdminUpdate)
-		api.With(middleware.AdminAuth(cfg.AdminAPIKey, jwtManager)).Delete("/admin/case-studies/{id}", caseStudiesHandler.AdminDelete)
+		api.With(adminAuth, can(rbac.ContentRead)).Get("/admin/case-studies", caseStudiesHandler.AdminList)
+		api.With(adminAuth, can(rbac.ContentWrite)).Post("/admin/case-studies", caseStudiesHandler.AdminCreate)
+		api.With(adminAuth, can(rbac.ContentWrite)).Put("/admin/case-studies/{id}", caseStudiesHandler.AdminUpdate)
+		api.With(adminAuth, can(rbac.ContentWrite)).Delete("/admin/case-studies/{id}", caseStudiesHandler.AdminDelete)
 	}
 
 	registerV1Routes := func(api chi.Router) {
@@ -239,23 +402,42 @@ func main() {
 		}
 	}()
 
+	// Background jobs live until SIGINT/SIGTERM; ctx above only bounds startup.
+	runCtx, stopRun := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopRun()
+
 	// Appointment reminder cron (notifies admins of upcoming appointments)
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				server.SendUpcomingAppointmentReminders(context.Background(), 30*time.Minute)
-			}
+	go cron.Every(runCtx, 5*time.Minute, func(ctx context.Context) {
+		server.SendUpcomingAppointmentReminders(ctx, 30*time.Minute)
+	})
+
+	// Waitlist cron (expired offers go to the next customers)
+	go cron.Every(runCtx, time.Minute, func(ctx context.Context) {
+		server.ExpireWaitlistOffers(ctx, time.Now())
+	})
+
+	// Daily digest cron (summary email for admins in digest mode)
+	go cron.Every(runCtx, 5*time.Minute, func(ctx context.Context) {
+		server.SendDailyAdminDigest(ctx, time.Now())
+	})
+
+	// CalDAV sync cron (external busy time in, booked appointments out)
+	if calendarSyncService.Enabled() {
+		interval := time.Duration(cfg.CalDAVSyncIntervalSec) * time.Second
+		if interval < time.Minute {
+			interval = time.Minute
 		}
-	}()
+		go cron.Every(runCtx, interval, func(ctx context.Context) {
+			syncCtx, cancel := context.WithTimeout(ctx, interval)
+			defer cancel()
+			if _, err := calendarSyncService.Sync(syncCtx, time.Now()); err != nil {
+				logger.Warn("caldav sync failed", slog.String("error", err.Error()))
+			}
+		})
+	}
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
+	<-runCtx.Done()
+	stopRun()
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
@@ -14,17 +14,55 @@ type Manager struct {
 	Issuer     string
 }
 
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// TokenTypeChallenge proves the password step of a two-factor login.
+	TokenTypeChallenge = "challenge"
+	// TokenTypeInvitation is the link mailed to an invited admin.
+	TokenTypeInvitation = "invitation"
+)
+
+// Identity is the user a token is issued to and the login session it
+// belongs to.
+type Identity struct {
+	UserID    string
+	Username  string
+	Role      string
+	SessionID string
+}
+
+// Claims carry the user ID as the standard subject and a unique token ID.
+// Type separates access from refresh tokens so one cannot stand in for the
+// other.
 type Claims struct {
-	Role string `json:"role"`
+	Role      string `json:"role"`
+	Username  string `json:"username,omitempty"`
+	Type      string `json:"typ"`
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func (m *Manager) newToken(role string, ttl time.Duration) (string, error) {
+func (c *Claims) Identity() Identity {
+	return Identity{
+		UserID:    c.Subject,
+		Username:  c.Username,
+		Role:      c.Role,
+		SessionID: c.SessionID,
+	}
+}
+
+func (m *Manager) newToken(identity Identity, tokenType, tokenID string, ttl time.Duration) (string, error) {
 	now := time.Now()
 	claims := Claims{
-		Role: role,
+		Role:      identity.Role,
+		Username:  identity.Username,
+		Type:      tokenType,
+		SessionID: identity.SessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    m.Issuer,
+			Subject:   identity.UserID,
+			ID:        tokenID,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		},
@@ -32,22 +70,62 @@ func (m *Manager) newToken(role string, ttl time.Duration) (string, error) {
 	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.Secret)
 }
 
-func (m *Manager) NewAccessToken(role string) (string, error) {
-	return m.newToken(role, m.AccessTTL)
+func (m *Manager) NewAccessToken(identity Identity) (string, error) {
+	tokenID, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
+	return m.newToken(identity, TokenTypeAccess, tokenID, m.AccessTTL)
 }
 
-func (m *Manager) NewRefreshToken(role string) (string, error) {
-	return m.newToken(role, m.RefreshTTL)
+// NewRefreshToken returns the signed token and its ID, which the caller
+// stores to detect reuse once the token has been rotated.
+func (m *Manager) NewRefreshToken(identity Identity) (string, string, error) {
+	tokenID, err := NewTokenID()
+	if err != nil {
+		return "", "", err
+	}
+	token, err := m.newToken(identity, TokenTypeRefresh, tokenID, m.RefreshTTL)
+	if err != nil {
+		return "", "", err
+	}
+	return token, tokenID, nil
+}
+
+// NewChallengeToken returns a short-lived token that only the second login
+// step accepts.
+func (m *Manager) NewChallengeToken(identity Identity, ttl time.Duration) (string, error) {
+	tokenID, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
+	return m.newToken(identity, TokenTypeChallenge, tokenID, ttl)
+}
+
+// NewInvitationToken signs an invitation link. The subject is the invitation
+// ID; tokenID is stored with the invitation so resending it voids older links.
+func (m *Manager) NewInvitationToken(invitationID, tokenID string, ttl time.Duration) (string, error) {
+	return m.newToken(Identity{UserID: invitationID}, TokenTypeInvitation, tokenID, ttl)
 }
 
 func (m *Manager) Parse(tokenStr string) (*Claims, error) {
+	return m.parse(tokenStr)
+}
+
+// ParseIgnoringExpiry checks the signature but accepts expired tokens. It is
+// only meant for revoking the session a stale token points at.
+func (m *Manager) ParseIgnoringExpiry(tokenStr string) (*Claims, error) {
+	return m.parse(tokenStr, jwt.WithoutClaimsValidation())
+}
+
+func (m *Manager) parse(tokenStr string, opts ...jwt.ParserOption) (*Claims, error) {
 	claims := &Claims{}
 	parsed, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
 		if t.Method != jwt.SigningMethodHS256 {
 			return nil, errors.New("unexpected signing method")
 		}
 		return m.Secret, nil
-	})
+	}, opts...)
 	if err != nil {
 		return nil, err
 	}
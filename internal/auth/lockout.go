@@ -0,0 +1,27 @@
+package auth
+
+import "time"
+
+const (
+	// loginFreeFailures is how many consecutive failures are tolerated
+	// before attempts are slowed down.
+	loginFreeFailures = 3
+	loginMaxBackoff   = time.Minute
+)
+
+// LoginBackoff returns how long an account must wait after its last failed
+// login before the next attempt is examined: nothing for the first few
+// failures, then one second doubling up to a minute.
+func LoginBackoff(failures int) time.Duration {
+	if failures < loginFreeFailures {
+		return 0
+	}
+	delay := time.Second
+	for i := loginFreeFailures; i < failures; i++ {
+		delay *= 2
+		if delay >= loginMaxBackoff {
+			return loginMaxBackoff
+		}
+	}
+	return delay
+}
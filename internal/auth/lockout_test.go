@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Second},
+		{failures: 4, want: 2 * time.Second},
+		{failures: 6, want: 8 * time.Second},
+		{failures: 9, want: time.Minute},
+		{failures: 100, want: time.Minute},
+	}
+	for _, tt := range tests {
+		if got := LoginBackoff(tt.failures); got != tt.want {
+			t.Errorf("LoginBackoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
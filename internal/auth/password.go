@@ -2,10 +2,29 @@ package auth
 
 import (
 	"errors"
+	"strings"
+	"unicode"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Password strength rules. bcrypt ignores everything past 72 bytes, so longer
+// passwords are refused rather than silently truncated.
+const (
+	PasswordMinLength = 12
+	PasswordMaxBytes  = 72
+	// passwordMinClasses is how many of lower, upper, digit and symbol a
+	// password must mix.
+	passwordMinClasses = 3
+)
+
+var (
+	ErrPasswordTooShort  = errors.New("password too short")
+	ErrPasswordTooLong   = errors.New("password too long")
+	ErrPasswordTooSimple = errors.New("password too simple")
+	ErrPasswordPersonal  = errors.New("password contains personal information")
+)
+
 func HashPassword(password string) (string, error) {
 	if password == "" {
 		return "", errors.New("empty password")
@@ -23,3 +42,51 @@ func ComparePassword(hash, password string) error {
 	}
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
+
+// ValidatePasswordStrength checks password against the strength rules.
+// personal lists values the password must not contain, such as the username
+// or the local part of the email address.
+func ValidatePasswordStrength(password string, personal ...string) error {
+	if len([]rune(password)) < PasswordMinLength {
+		return ErrPasswordTooShort
+	}
+	if len(password) > PasswordMaxBytes {
+		return ErrPasswordTooLong
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < passwordMinClasses {
+		return ErrPasswordTooSimple
+	}
+
+	folded := strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			value = local
+		}
+		// Very short values (initials, "a@...") would reject too much.
+		if len(value) >= 3 && strings.Contains(folded, value) {
+			return ErrPasswordPersonal
+		}
+	}
+	return nil
+}
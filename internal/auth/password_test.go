@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidatePasswordStrength(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		want     error
+	}{
+		{name: "strong", password: "Correct-Horse-42", want: nil},
+		{name: "passphrase without symbols", password: "Correcthorse42battery", want: nil},
+		{name: "too short", password: "Ab1-short", want: ErrPasswordTooShort},
+		{name: "too long", password: "Aa1-" + strings.Repeat("x", 70), want: ErrPasswordTooLong},
+		{name: "single class", password: "correcthorsebattery", want: ErrPasswordTooSimple},
+		{name: "two classes", password: "correcthorse2024", want: ErrPasswordTooSimple},
+		{name: "contains username", password: "Jean.Dupont-2024", personal: []string{"jean.dupont"}, want: ErrPasswordPersonal},
+		{name: "contains email local part", password: "Xx-Mukendi-2024", personal: []string{"", "mukendi@gbh.sarl"}, want: ErrPasswordPersonal},
+		{name: "short personal value ignored", password: "Correct-Horse-42", personal: []string{"co"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePasswordStrength(tt.password, tt.personal...)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ValidatePasswordStrength(%q) = %v, want %v", tt.password, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890".
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFCVectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := TOTPStep(now)
+	previous, _ := TOTPCode(rfcSecret, step-1)
+
+	matched, ok := VerifyTOTP(rfcSecret, previous, now, 0)
+	if !ok || matched != step-1 {
+		t.Fatalf("expected previous step to be accepted, got %d %v", matched, ok)
+	}
+	if _, ok := VerifyTOTP(rfcSecret, previous, now, matched); ok {
+		t.Fatalf("expected replayed code to be refused")
+	}
+	old, _ := TOTPCode(rfcSecret, step-3)
+	if _, ok := VerifyTOTP(rfcSecret, old, now, 0); ok {
+		t.Fatalf("expected code outside the window to be refused")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("NewRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != 10 || len(hashes) != 10 {
+		t.Fatalf("expected 10 codes, got %d/%d", len(codes), len(hashes))
+	}
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if HashRecoveryCode(typed) != hashes[0] {
+		t.Fatalf("expected typed code %q to match", typed)
+	}
+}
@@ -0,0 +1,128 @@
+// Package booking defines the appointment lifecycle: which status changes
+// are allowed and who may make them.
+package booking
+
+import (
+	"errors"
+
+	"gbh-backend/internal/models"
+)
+
+// Actors that change an appointment's status.
+const (
+	ActorAdmin    = "admin"
+	ActorCustomer = "customer"
+	ActorSystem   = "system"
+)
+
+var (
+	ErrUnknownStatus     = errors.New("unknown status")
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrNotAllowed        = errors.New("status change not allowed for this actor")
+)
+
+// Statuses lists every lifecycle state, in the order they are usually reached.
+var Statuses = []string{
+	models.AppointmentStatusPending,
+	models.AppointmentStatusBooked,
+	models.AppointmentStatusConfirmed,
+	models.AppointmentStatusCheckedIn,
+	models.AppointmentStatusCompleted,
+	models.AppointmentStatusNoShow,
+	models.AppointmentStatusCanceledByCustomer,
+	models.AppointmentStatusCanceledByAdmin,
+}
+
+var transitions = map[string][]string{
+	models.AppointmentStatusPending: {
+		models.AppointmentStatusBooked,
+		models.AppointmentStatusConfirmed,
+		models.AppointmentStatusCanceledByCustomer,
+		models.AppointmentStatusCanceledByAdmin,
+	},
+	models.AppointmentStatusBooked: {
+		models.AppointmentStatusConfirmed,
+		models.AppointmentStatusCheckedIn,
+		models.AppointmentStatusNoShow,
+		models.AppointmentStatusCanceledByCustomer,
+		models.AppointmentStatusCanceledByAdmin,
+	},
+	models.AppointmentStatusConfirmed: {
+		models.AppointmentStatusCheckedIn,
+		models.AppointmentStatusNoShow,
+		models.AppointmentStatusCanceledByCustomer,
+		models.AppointmentStatusCanceledByAdmin,
+	},
+	models.AppointmentStatusCheckedIn: {
+		models.AppointmentStatusCompleted,
+	},
+	// A customer marked absent who turns up late can still be checked in.
+	models.AppointmentStatusNoShow: {
+		models.AppointmentStatusCheckedIn,
+	},
+	models.AppointmentStatusCompleted:          {},
+	models.AppointmentStatusCanceledByCustomer: {},
+	models.AppointmentStatusCanceledByAdmin:    {},
+	models.AppointmentStatusLegacyCanceled:     {},
+}
+
+// Known reports whether status is a lifecycle state.
+func Known(status string) bool {
+	for _, known := range Statuses {
+		if known == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Next lists the statuses reachable from status.
+func Next(status string) []string {
+	return append([]string{}, transitions[status]...)
+}
+
+// Terminal reports whether no change is possible from status.
+func Terminal(status string) bool {
+	next, ok := transitions[status]
+	return ok && len(next) == 0
+}
+
+// Upcoming reports whether an appointment with status has not taken place
+// yet, so it can still be moved or canceled.
+func Upcoming(status string) bool {
+	return status == models.AppointmentStatusPending ||
+		status == models.AppointmentStatusBooked ||
+		status == models.AppointmentStatusConfirmed
+}
+
+// CheckTransition validates a status change made by actor. Customers can
+// only cancel; canceling on a customer's behalf is recorded as
+// canceled_by_customer by the admin.
+func CheckTransition(from, to, actor string) error {
+	if !Known(to) {
+		return ErrUnknownStatus
+	}
+	allowed := false
+	for _, next := range transitions[from] {
+		if next == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return ErrInvalidTransition
+	}
+	switch actor {
+	case ActorAdmin:
+		return nil
+	case ActorCustomer:
+		if to == models.AppointmentStatusCanceledByCustomer {
+			return nil
+		}
+	case ActorSystem:
+		if to != models.AppointmentStatusCanceledByCustomer {
+			return nil
+		}
+	}
+	return ErrNotAllowed
+}
@@ -0,0 +1,62 @@
+package booking
+
+import (
+	"errors"
+	"testing"
+
+	"gbh-backend/internal/models"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		actor    string
+		want     error
+	}{
+		{name: "admin confirms", from: models.AppointmentStatusBooked, to: models.AppointmentStatusConfirmed, actor: ActorAdmin},
+		{name: "check in then complete", from: models.AppointmentStatusCheckedIn, to: models.AppointmentStatusCompleted, actor: ActorAdmin},
+		{name: "late arrival after no-show", from: models.AppointmentStatusNoShow, to: models.AppointmentStatusCheckedIn, actor: ActorAdmin},
+		{name: "customer cancels", from: models.AppointmentStatusConfirmed, to: models.AppointmentStatusCanceledByCustomer, actor: ActorCustomer},
+		{name: "admin records customer cancellation", from: models.AppointmentStatusBooked, to: models.AppointmentStatusCanceledByCustomer, actor: ActorAdmin},
+		{name: "customer cannot confirm", from: models.AppointmentStatusBooked, to: models.AppointmentStatusConfirmed, actor: ActorCustomer, want: ErrNotAllowed},
+		{name: "customer cannot cancel as admin", from: models.AppointmentStatusBooked, to: models.AppointmentStatusCanceledByAdmin, actor: ActorCustomer, want: ErrNotAllowed},
+		{name: "canceled is terminal", from: models.AppointmentStatusCanceledByAdmin, to: models.AppointmentStatusBooked, actor: ActorAdmin, want: ErrInvalidTransition},
+		{name: "legacy canceled is terminal", from: models.AppointmentStatusLegacyCanceled, to: models.AppointmentStatusBooked, actor: ActorAdmin, want: ErrInvalidTransition},
+		{name: "cannot complete without check-in", from: models.AppointmentStatusBooked, to: models.AppointmentStatusCompleted, actor: ActorAdmin, want: ErrInvalidTransition},
+		{name: "same status", from: models.AppointmentStatusBooked, to: models.AppointmentStatusBooked, actor: ActorAdmin, want: ErrInvalidTransition},
+		{name: "unknown target", from: models.AppointmentStatusBooked, to: "archived", actor: ActorAdmin, want: ErrUnknownStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckTransition(tt.from, tt.to, tt.actor); !errors.Is(err, tt.want) {
+				t.Fatalf("CheckTransition(%s, %s, %s) = %v, want %v", tt.from, tt.to, tt.actor, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEveryStatusHasTransitions(t *testing.T) {
+	for _, status := range Statuses {
+		if _, ok := transitions[status]; !ok {
+			t.Errorf("status %q missing from the transition table", status)
+		}
+		for _, next := range Next(status) {
+			if !Known(next) {
+				t.Errorf("%q leads to unknown status %q", status, next)
+			}
+		}
+	}
+	if !Terminal(models.AppointmentStatusCompleted) || Terminal(models.AppointmentStatusBooked) {
+		t.Fatalf("unexpected terminal states")
+	}
+}
+
+func TestUpcomingMatchesCustomerCancel(t *testing.T) {
+	for _, status := range Statuses {
+		cancelable := CheckTransition(status, models.AppointmentStatusCanceledByCustomer, ActorCustomer) == nil
+		if Upcoming(status) != cancelable {
+			t.Errorf("Upcoming(%q) = %v, customer cancel allowed = %v", status, Upcoming(status), cancelable)
+		}
+	}
+}
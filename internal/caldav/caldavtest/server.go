@@ -0,0 +1,285 @@
+// Package caldavtest provides an in-memory CalDAV calendar collection for
+// tests. It implements the subset the sync relies on: GET, PUT and DELETE
+// with etag preconditions, and the sync-collection and calendar-multiget
+// REPORTs. Recurrence expansion is not performed.
+package caldavtest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	CollectionPath = "/calendars/test/"
+	tokenPrefix    = "http://caldavtest.invalid/sync/"
+)
+
+type resource struct {
+	data string
+	etag string
+	rev  int
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	rev        int
+	minRev     int
+	items      map[string]resource
+	tombstones map[string]int
+}
+
+// NewServer starts a server; callers must Close it.
+func NewServer() *Server {
+	s := &Server{
+		items:      make(map[string]resource),
+		tombstones: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// CalendarURL is the collection URL to hand to a client.
+func (s *Server) CalendarURL() string {
+	return s.URL + CollectionPath
+}
+
+// PutEvent stores a resource as another CalDAV client would and returns its href.
+func (s *Server) PutEvent(name, data string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	href := CollectionPath + name
+	s.write(href, data)
+	return href
+}
+
+func (s *Server) DeleteEvent(href string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(href)
+}
+
+func (s *Server) Event(href string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[href]
+	return item.data, ok
+}
+
+func (s *Server) Hrefs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hrefs := make([]string, 0, len(s.items))
+	for href := range s.items {
+		hrefs = append(hrefs, href)
+	}
+	sort.Strings(hrefs)
+	return hrefs
+}
+
+// ExpireSyncTokens makes every token handed out so far invalid.
+func (s *Server) ExpireSyncTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rev++
+	s.minRev = s.rev
+}
+
+func (s *Server) write(href, data string) resource {
+	s.rev++
+	item := resource{data: data, etag: fmt.Sprintf(`"%d"`, s.rev), rev: s.rev}
+	s.items[href] = item
+	delete(s.tombstones, href)
+	return item
+}
+
+func (s *Server) remove(href string) {
+	if _, ok := s.items[href]; !ok {
+		return
+	}
+	s.rev++
+	delete(s.items, href)
+	s.tombstones[href] = s.rev
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.URL.Path, CollectionPath) {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.get(w, r)
+	case http.MethodPut:
+		s.put(w, r)
+	case http.MethodDelete:
+		s.delete(w, r)
+	case "REPORT":
+		s.report(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.items[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", item.etag)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	_, _ = io.WriteString(w, item.data)
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request) {
+	current, exists := s.items[r.URL.Path]
+	if !preconditionsHold(r, current, exists) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil || !strings.HasPrefix(string(body), "BEGIN:VCALENDAR") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	item := s.write(r.URL.Path, string(body))
+	w.Header().Set("ETag", item.etag)
+	if exists {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	current, exists := s.items[r.URL.Path]
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	if !preconditionsHold(r, current, exists) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	s.remove(r.URL.Path)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func preconditionsHold(r *http.Request, current resource, exists bool) bool {
+	if match := r.Header.Get("If-Match"); match != "" {
+		return exists && match == current.etag
+	}
+	if r.Header.Get("If-None-Match") == "*" {
+		return !exists
+	}
+	return true
+}
+
+type reportRequest struct {
+	XMLName   xml.Name
+	SyncToken string   `xml:"DAV: sync-token"`
+	Hrefs     []string `xml:"DAV: href"`
+}
+
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	var req reportRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var out strings.Builder
+	out.WriteString(xml.Header)
+	out.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`)
+	switch req.XMLName.Local {
+	case "sync-collection":
+		since, ok := s.parseToken(req.SyncToken)
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, xml.Header+`<D:error xmlns:D="DAV:"><D:valid-sync-token/></D:error>`)
+			return
+		}
+		for _, href := range sortedKeys(s.items) {
+			if item := s.items[href]; item.rev > since {
+				writeResponse(&out, href, item.etag, "")
+			}
+		}
+		if since > 0 {
+			for _, href := range sortedKeys(s.tombstones) {
+				if s.tombstones[href] > since {
+					out.WriteString(`<D:response><D:href>` + escape(href) + `</D:href><D:status>HTTP/1.1 404 Not Found</D:status></D:response>`)
+				}
+			}
+		}
+		out.WriteString(`<D:sync-token>` + tokenPrefix + strconv.Itoa(s.rev) + `</D:sync-token>`)
+	case "calendar-multiget":
+		for _, href := range req.Hrefs {
+			item, ok := s.items[href]
+			if !ok {
+				out.WriteString(`<D:response><D:href>` + escape(href) + `</D:href><D:status>HTTP/1.1 404 Not Found</D:status></D:response>`)
+				continue
+			}
+			writeResponse(&out, href, item.etag, item.data)
+		}
+	default:
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	out.WriteString(`</D:multistatus>`)
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, out.String())
+}
+
+func (s *Server) parseToken(token string) (int, bool) {
+	if token == "" {
+		return 0, true
+	}
+	raw, ok := strings.CutPrefix(token, tokenPrefix)
+	if !ok {
+		return 0, false
+	}
+	rev, err := strconv.Atoi(raw)
+	if err != nil || rev < s.minRev || rev > s.rev {
+		return 0, false
+	}
+	return rev, true
+}
+
+func writeResponse(out *strings.Builder, href, etag, data string) {
+	out.WriteString(`<D:response><D:href>` + escape(href) + `</D:href><D:propstat><D:prop>`)
+	out.WriteString(`<D:getetag>` + escape(etag) + `</D:getetag>`)
+	if data != "" {
+		out.WriteString(`<C:calendar-data>` + escape(data) + `</C:calendar-data>`)
+	}
+	out.WriteString(`</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escape(value string) string {
+	var buf strings.Builder
+	_ = xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
@@ -0,0 +1,114 @@
+package caldav
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gbh-backend/internal/httpx"
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/transport"
+)
+
+type Handler struct {
+	service *Service
+	log     *slog.Logger
+}
+
+func NewHandler(service *Service, log *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		log:     log,
+	}
+}
+
+func (h *Handler) AdminStatus(w http.ResponseWriter, r *http.Request) {
+	log := h.logWithRequest(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	states, err := h.service.ListStates(ctx)
+	if err != nil {
+		log.Error("admin calendar sync status: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":   h.service.Enabled(),
+		"calendars": states,
+	})
+}
+
+// AdminRun triggers a sync outside of the periodic job.
+func (h *Handler) AdminRun(w http.ResponseWriter, r *http.Request) {
+	log := h.logWithRequest(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	report, err := h.service.Sync(ctx, time.Now())
+	switch {
+	case errors.Is(err, ErrNotConfigured):
+		log.Warn("admin calendar sync run: not configured")
+		transport.WriteError(w, http.StatusServiceUnavailable, "calendar sync not configured", nil)
+		return
+	case errors.Is(err, ErrSyncInProgress):
+		log.Warn("admin calendar sync run: already running")
+		transport.WriteError(w, http.StatusConflict, "calendar sync already running", nil)
+		return
+	case err != nil:
+		log.Error("admin calendar sync run: sync error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadGateway, "calendar sync error", map[string]string{"cause": err.Error()})
+		return
+	}
+
+	log.Info("admin calendar sync run: ok",
+		slog.Int("changed", report.Changed),
+		slog.Int("removed", report.Removed),
+		slog.Int("pushed", report.Pushed),
+		slog.Int("deleted", report.Deleted),
+		slog.Int("conflicts", report.Conflicts),
+	)
+	transport.WriteJSON(w, http.StatusOK, report)
+}
+
+func (h *Handler) AdminListConflicts(w http.ResponseWriter, r *http.Request) {
+	log := h.logWithRequest(r)
+	limit, offset, err := httpx.ParseLimitOffset(r.URL.Query(), 50, 200)
+	if err != nil {
+		log.Warn("admin calendar sync conflicts: invalid query", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	items, total, err := h.service.ListConflicts(ctx, limit, offset)
+	if err != nil {
+		log.Error("admin calendar sync conflicts: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+	})
+}
+
+func (h *Handler) logWithRequest(r *http.Request) *slog.Logger {
+	if r == nil {
+		return h.log
+	}
+	if id := middleware.RequestIDFromContext(r.Context()); id != "" {
+		return h.log.With(slog.String("request_id", id))
+	}
+	return h.log
+}
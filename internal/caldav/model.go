@@ -0,0 +1,54 @@
+package caldav
+
+import "time"
+
+const (
+	// ConflictRemoteModified: an event we pushed was edited or removed on the
+	// CalDAV side. The booking system wins and the event is overwritten.
+	ConflictRemoteModified = "remote_modified"
+	// ConflictOverlap: an external busy block overlaps a booked appointment.
+	ConflictOverlap = "overlap"
+)
+
+// BusyBlock is one day's slice of an external event. It is read by the
+// availability computation alongside appointments and reservation blocks.
+type BusyBlock struct {
+	ID        string    `bson:"_id,omitempty" json:"id"`
+	Source    string    `bson:"source" json:"source"`
+	Href      string    `bson:"href" json:"href"`
+	UID       string    `bson:"uid" json:"uid"`
+	Date      string    `bson:"date" json:"date"`
+	Time      string    `bson:"time" json:"time"`
+	Duration  int       `bson:"duration" json:"duration"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// SyncState keeps the last sync token of a calendar collection and the
+// first day of the window its events were expanded over.
+type SyncState struct {
+	ID          string    `bson:"_id" json:"calendar"`
+	SyncToken   string    `bson:"sync_token" json:"-"`
+	WindowStart string    `bson:"window_start,omitempty" json:"window_start,omitempty"`
+	LastSyncAt  time.Time `bson:"last_sync_at" json:"last_sync_at"`
+	LastError   string    `bson:"last_error,omitempty" json:"last_error,omitempty"`
+}
+
+type Conflict struct {
+	ID            string    `bson:"_id" json:"id"`
+	Kind          string    `bson:"kind" json:"kind"`
+	Source        string    `bson:"source" json:"source"`
+	Href          string    `bson:"href" json:"href"`
+	AppointmentID string    `bson:"appointment_id" json:"appointment_id"`
+	Date          string    `bson:"date,omitempty" json:"date,omitempty"`
+	Time          string    `bson:"time,omitempty" json:"time,omitempty"`
+	DetectedAt    time.Time `bson:"detected_at" json:"detected_at"`
+}
+
+// Report summarises one sync run.
+type Report struct {
+	Changed   int `json:"changed"`
+	Removed   int `json:"removed"`
+	Pushed    int `json:"pushed"`
+	Deleted   int `json:"deleted"`
+	Conflicts int `json:"conflicts"`
+}
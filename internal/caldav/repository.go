@@ -0,0 +1,211 @@
+package caldav
+
+import (
+	"context"
+
+	"gbh-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	GetState(ctx context.Context, source string) (SyncState, error)
+	SaveState(ctx context.Context, state SyncState) error
+	InsertBusy(ctx context.Context, blocks []BusyBlock) error
+	DeleteBusy(ctx context.Context, source, href string) ([]string, error)
+	ClearBusy(ctx context.Context, source string) error
+	ActiveAppointments(ctx context.Context, date string) ([]models.Appointment, error)
+	PendingAppointments(ctx context.Context, fromDate string, limit int64) ([]models.Appointment, error)
+	MarkPushed(ctx context.Context, id, href, etag string, sequence int) error
+	MarkRemoved(ctx context.Context, id string, sequence int) error
+	ServiceNames(ctx context.Context) (map[string]string, error)
+	UpsertConflict(ctx context.Context, conflict Conflict) (bool, error)
+	ListConflicts(ctx context.Context, limit, offset int64) ([]Conflict, error)
+	CountConflicts(ctx context.Context) (int64, error)
+	ListStates(ctx context.Context) ([]SyncState, error)
+}
+
+type MongoRepository struct {
+	busy         *mongo.Collection
+	states       *mongo.Collection
+	conflicts    *mongo.Collection
+	appointments *mongo.Collection
+	services     *mongo.Collection
+}
+
+func NewRepository(busy, states, conflicts, appointments, services *mongo.Collection) *MongoRepository {
+	return &MongoRepository{
+		busy:         busy,
+		states:       states,
+		conflicts:    conflicts,
+		appointments: appointments,
+		services:     services,
+	}
+}
+
+func (r *MongoRepository) GetState(ctx context.Context, source string) (SyncState, error) {
+	var state SyncState
+	err := r.states.FindOne(ctx, bson.M{"_id": source}).Decode(&state)
+	if err == mongo.ErrNoDocuments {
+		return SyncState{ID: source}, nil
+	}
+	return state, err
+}
+
+func (r *MongoRepository) SaveState(ctx context.Context, state SyncState) error {
+	_, err := r.states.ReplaceOne(ctx, bson.M{"_id": state.ID}, state, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *MongoRepository) ListStates(ctx context.Context) ([]SyncState, error) {
+	cursor, err := r.states.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]SyncState, 0)
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *MongoRepository) InsertBusy(ctx context.Context, blocks []BusyBlock) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(blocks))
+	for _, block := range blocks {
+		docs = append(docs, block)
+	}
+	_, err := r.busy.InsertMany(ctx, docs)
+	return err
+}
+
+// DeleteBusy removes the blocks of one resource and returns the dates they
+// covered so cached availability can be refreshed.
+func (r *MongoRepository) DeleteBusy(ctx context.Context, source, href string) ([]string, error) {
+	filter := bson.M{"source": source, "href": href}
+	dates, err := r.busy.Distinct(ctx, "date", filter)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.busy.DeleteMany(ctx, filter); err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(dates))
+	for _, d := range dates {
+		if date, ok := d.(string); ok {
+			out = append(out, date)
+		}
+	}
+	return out, nil
+}
+
+func (r *MongoRepository) ClearBusy(ctx context.Context, source string) error {
+	_, err := r.busy.DeleteMany(ctx, bson.M{"source": source})
+	return err
+}
+
+func (r *MongoRepository) ActiveAppointments(ctx context.Context, date string) ([]models.Appointment, error) {
+	filter := bson.M{"date": date, "status": bson.M{"$in": models.ActiveAppointmentStatuses}}
+	cursor, err := r.appointments.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]models.Appointment, 0)
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// PendingAppointments returns upcoming appointments whose calendar sequence
+// has not been pushed yet. New appointments have no synced sequence at all.
+func (r *MongoRepository) PendingAppointments(ctx context.Context, fromDate string, limit int64) ([]models.Appointment, error) {
+	filter := bson.M{
+		"date": bson.M{"$gte": fromDate},
+		"$or": bson.A{
+			bson.M{"caldavSyncedSequence": bson.M{"$exists": false}},
+			bson.M{"$expr": bson.M{"$ne": bson.A{"$caldavSyncedSequence", bson.M{"$ifNull": bson.A{"$calendarSequence", 0}}}}},
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "time", Value: 1}}).SetLimit(limit)
+	cursor, err := r.appointments.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]models.Appointment, 0)
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *MongoRepository) MarkPushed(ctx context.Context, id, href, etag string, sequence int) error {
+	update := bson.M{"$set": bson.M{
+		"caldavHref":           href,
+		"caldavEtag":           etag,
+		"caldavSyncedSequence": sequence,
+	}}
+	_, err := r.appointments.UpdateByID(ctx, id, update)
+	return err
+}
+
+func (r *MongoRepository) MarkRemoved(ctx context.Context, id string, sequence int) error {
+	update := bson.M{
+		"$set":   bson.M{"caldavSyncedSequence": sequence},
+		"$unset": bson.M{"caldavHref": "", "caldavEtag": ""},
+	}
+	_, err := r.appointments.UpdateByID(ctx, id, update)
+	return err
+}
+
+func (r *MongoRepository) ServiceNames(ctx context.Context) (map[string]string, error) {
+	cursor, err := r.services.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var services []models.Service
+	if err := cursor.All(ctx, &services); err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(services))
+	for _, service := range services {
+		names[service.ID] = service.Name
+	}
+	return names, nil
+}
+
+// UpsertConflict records a conflict once; it reports whether it is new.
+func (r *MongoRepository) UpsertConflict(ctx context.Context, conflict Conflict) (bool, error) {
+	res, err := r.conflicts.UpdateOne(ctx,
+		bson.M{"_id": conflict.ID},
+		bson.M{"$setOnInsert": conflict},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+func (r *MongoRepository) ListConflicts(ctx context.Context, limit, offset int64) ([]Conflict, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "detected_at", Value: -1}}).
+		SetLimit(limit).
+		SetSkip(offset)
+	cursor, err := r.conflicts.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Conflict, 0)
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *MongoRepository) CountConflicts(ctx context.Context) (int64, error) {
+	return r.conflicts.CountDocuments(ctx, bson.M{})
+}
@@ -0,0 +1,438 @@
+package caldav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"gbh-backend/internal/cache"
+	"gbh-backend/internal/calendar"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/notifications"
+	"gbh-backend/internal/schedule"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// External events are stored this many days ahead; the window moves
+	// forward with every sync.
+	busyHorizonDays = 120
+	multigetBatch   = 50
+	pushBatch       = 200
+)
+
+var (
+	ErrNotConfigured  = errors.New("caldav sync not configured")
+	ErrSyncInProgress = errors.New("caldav sync already running")
+)
+
+// Service pulls busy time from every configured calendar and pushes booked
+// appointments to the first one.
+type Service struct {
+	repo      Repository
+	calendars []*Client
+	cache     cache.Cache
+	location  *time.Location
+	log       *slog.Logger
+	running   sync.Mutex
+}
+
+func NewService(repo Repository, calendars []*Client, c cache.Cache, location *time.Location, log *slog.Logger) *Service {
+	if c == nil {
+		c = cache.NewNoop()
+	}
+	return &Service{
+		repo:      repo,
+		calendars: calendars,
+		cache:     c,
+		location:  location,
+		log:       log,
+	}
+}
+
+func (s *Service) Enabled() bool {
+	return len(s.calendars) > 0
+}
+
+// Sync runs one pull of every calendar followed by a push of pending
+// appointments. A failing calendar does not stop the others.
+func (s *Service) Sync(ctx context.Context, now time.Time) (Report, error) {
+	if !s.Enabled() {
+		return Report{}, ErrNotConfigured
+	}
+	if !s.running.TryLock() {
+		return Report{}, ErrSyncInProgress
+	}
+	defer s.running.Unlock()
+
+	now = now.In(s.location)
+	var report Report
+	var errs []error
+	for _, client := range s.calendars {
+		err := s.pull(ctx, client, now, &report)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pull %s: %w", client.URL(), err))
+			s.saveError(ctx, client.URL(), now, err)
+		}
+	}
+	if err := s.push(ctx, s.calendars[0], now, &report); err != nil {
+		errs = append(errs, fmt.Errorf("push %s: %w", s.calendars[0].URL(), err))
+	}
+	return report, errors.Join(errs...)
+}
+
+func (s *Service) ListStates(ctx context.Context) ([]SyncState, error) {
+	return s.repo.ListStates(ctx)
+}
+
+func (s *Service) ListConflicts(ctx context.Context, limit, offset int64) ([]Conflict, int64, error) {
+	items, err := s.repo.ListConflicts(ctx, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.repo.CountConflicts(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (s *Service) pull(ctx context.Context, client *Client, now time.Time, report *Report) error {
+	source := client.URL()
+	state, err := s.repo.GetState(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	fullListing := state.SyncToken == ""
+	result, err := client.SyncCollection(ctx, state.SyncToken)
+	if errors.Is(err, ErrInvalidSyncToken) && state.SyncToken != "" {
+		// The server forgot our token: start over from a full listing.
+		s.log.Warn("caldav sync: sync token rejected, full resync", slog.String("calendar", source))
+		if err := s.repo.ClearBusy(ctx, source); err != nil {
+			return err
+		}
+		_ = s.cache.DeletePrefix(ctx, "availability:")
+		result, err = client.SyncCollection(ctx, "")
+		fullListing = true
+	}
+	if err != nil {
+		return err
+	}
+	start, end := busyWindow(now)
+	windowStart := start.Format("2006-01-02")
+	changed := result.Changed
+	if !fullListing && state.WindowStart != windowStart {
+		// The window moved since the events were expanded: unchanged
+		// resources (recurring events, events past the old horizon) must
+		// be fetched again. The delta's token is kept so that deletions
+		// made meanwhile are still reported next time.
+		listing, err := client.SyncCollection(ctx, "")
+		if err != nil {
+			return err
+		}
+		changed = listing.Changed
+	}
+
+	touched := make(map[string]struct{})
+	for _, href := range result.Deleted {
+		dates, err := s.repo.DeleteBusy(ctx, source, href)
+		if err != nil {
+			return err
+		}
+		addDates(touched, dates)
+		report.Removed++
+	}
+
+	hrefs := make([]string, 0, len(changed))
+	for _, item := range changed {
+		hrefs = append(hrefs, item.Href)
+	}
+	for len(hrefs) > 0 {
+		batch := hrefs[:min(multigetBatch, len(hrefs))]
+		hrefs = hrefs[len(batch):]
+
+		resources, err := client.Multiget(ctx, batch, start, end)
+		if err != nil {
+			return err
+		}
+		for _, resource := range resources {
+			dates, err := s.repo.DeleteBusy(ctx, source, resource.Href)
+			if err != nil {
+				return err
+			}
+			addDates(touched, dates)
+			if resource.Data == "" {
+				report.Removed++
+				continue
+			}
+
+			blocks, err := s.busyBlocks(source, resource, start, end, now)
+			if err != nil {
+				s.log.Warn("caldav sync: skipped resource", slog.String("href", resource.Href), slog.String("error", err.Error()))
+				continue
+			}
+			if err := s.repo.InsertBusy(ctx, blocks); err != nil {
+				return err
+			}
+			for _, block := range blocks {
+				touched[block.Date] = struct{}{}
+			}
+			report.Changed++
+			if err := s.detectOverlaps(ctx, blocks, now, report); err != nil {
+				return err
+			}
+		}
+	}
+
+	for date := range touched {
+		_ = s.cache.DeletePrefix(ctx, "availability:"+date+":")
+	}
+
+	state.ID = source
+	state.SyncToken = result.Token
+	state.WindowStart = windowStart
+	state.LastSyncAt = now
+	state.LastError = ""
+	return s.repo.SaveState(ctx, state)
+}
+
+// busyBlocks turns the busy events of a resource into per-day blocks clipped
+// to the sync window. Events we pushed ourselves are ignored: the
+// appointments already block their slots.
+func (s *Service) busyBlocks(source string, resource Resource, start, end, now time.Time) ([]BusyBlock, error) {
+	events, err := calendar.ParseEvents(resource.Data, s.location)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]BusyBlock, 0)
+	for _, event := range events {
+		if calendar.IsAppointmentUID(event.UID) || !event.Busy() {
+			continue
+		}
+		from, to := event.Start, event.End
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		for day := startOfDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+			a, b := from, to
+			if a.Before(day) {
+				a = day
+			}
+			if next := day.AddDate(0, 0, 1); b.After(next) {
+				b = next
+			}
+			if !b.After(a) {
+				continue
+			}
+			blocks = append(blocks, BusyBlock{
+				ID:        primitive.NewObjectID().Hex(),
+				Source:    source,
+				Href:      resource.Href,
+				UID:       event.UID,
+				Date:      day.Format("2006-01-02"),
+				Time:      a.Format("15:04"),
+				Duration:  int(math.Ceil(b.Sub(a).Minutes())),
+				CreatedAt: now,
+			})
+		}
+	}
+	return blocks, nil
+}
+
+// detectOverlaps records blocks that land on an already booked appointment.
+// Nothing is moved automatically; the conflict is left for an admin.
+func (s *Service) detectOverlaps(ctx context.Context, blocks []BusyBlock, now time.Time, report *Report) error {
+	byDate := make(map[string][]models.Appointment)
+	for _, block := range blocks {
+		appointments, ok := byDate[block.Date]
+		if !ok {
+			var err error
+			appointments, err = s.repo.ActiveAppointments(ctx, block.Date)
+			if err != nil {
+				return err
+			}
+			byDate[block.Date] = appointments
+		}
+
+		blockStart, err := schedule.ParseClockToMinutes(block.Time)
+		if err != nil {
+			continue
+		}
+		for _, appt := range appointments {
+			apptStart, err := schedule.ParseClockToMinutes(appt.Time)
+			if err != nil {
+				continue
+			}
+			duration := appt.Duration
+			if duration <= 0 {
+				duration = schedule.SlotMinutes
+			}
+			if apptStart >= blockStart+block.Duration || blockStart >= apptStart+duration {
+				continue
+			}
+			created, err := s.repo.UpsertConflict(ctx, Conflict{
+				ID:            ConflictOverlap + "|" + appt.ID + "|" + block.Source + block.Href,
+				Kind:          ConflictOverlap,
+				Source:        block.Source,
+				Href:          block.Href,
+				AppointmentID: appt.ID,
+				Date:          block.Date,
+				Time:          block.Time,
+				DetectedAt:    now,
+			})
+			if err != nil {
+				return err
+			}
+			if created {
+				report.Conflicts++
+				s.log.Warn("caldav sync: external event overlaps appointment",
+					slog.String("appointment_id", appt.ID),
+					slog.String("href", block.Href),
+					slog.String("date", block.Date),
+				)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Service) push(ctx context.Context, client *Client, now time.Time, report *Report) error {
+	appointments, err := s.repo.PendingAppointments(ctx, now.Format("2006-01-02"), pushBatch)
+	if err != nil || len(appointments) == 0 {
+		return err
+	}
+	names, err := s.repo.ServiceNames(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, appt := range appointments {
+		if err := s.pushAppointment(ctx, client, appt, names[appt.ServiceID], now, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushAppointment writes the appointment's current state to the calendar.
+// If the event was changed on the CalDAV side since our last write, the
+// booking system wins: the conflict is recorded and the event overwritten.
+func (s *Service) pushAppointment(ctx context.Context, client *Client, appt models.Appointment, serviceName string, now time.Time, report *Report) error {
+	source := client.URL()
+	sequence := appt.CalendarSequence
+
+	if models.AppointmentCanceled(appt.Status) {
+		if appt.CalDAVHref != "" {
+			err := client.Delete(ctx, appt.CalDAVHref, appt.CalDAVETag)
+			if errors.Is(err, ErrPreconditionFailed) {
+				if err := s.recordRemoteConflict(ctx, source, appt, now, report); err != nil {
+					return err
+				}
+				err = client.Delete(ctx, appt.CalDAVHref, "")
+			}
+			if err != nil {
+				return err
+			}
+			report.Deleted++
+		}
+		return s.repo.MarkRemoved(ctx, appt.ID, sequence)
+	}
+
+	event, err := notifications.AppointmentCalendarEvent(appt, models.Service{ID: appt.ServiceID, Name: serviceName}, s.location)
+	if err != nil {
+		s.log.Warn("caldav sync: skipped appointment", slog.String("appointment_id", appt.ID), slog.String("error", err.Error()))
+		return s.repo.MarkRemoved(ctx, appt.ID, sequence)
+	}
+	data := calendar.Calendar{Location: s.location, Events: []calendar.Event{event}}.Render(now)
+
+	href := appt.CalDAVHref
+	if href == "" {
+		href = client.EventHref(event.UID)
+	}
+	etag, err := client.Put(ctx, href, data, appt.CalDAVETag)
+	if errors.Is(err, ErrPreconditionFailed) {
+		if err := s.recordRemoteConflict(ctx, source, appt, now, report); err != nil {
+			return err
+		}
+		match := ""
+		current, getErr := client.Get(ctx, href)
+		switch {
+		case getErr == nil:
+			match = current.ETag
+		case !errors.Is(getErr, ErrNotFound):
+			return getErr
+		}
+		etag, err = client.Put(ctx, href, data, match)
+	}
+	if err != nil {
+		return err
+	}
+	if etag == "" {
+		// Some servers omit the etag when they rewrite the body on PUT.
+		if current, err := client.Get(ctx, href); err == nil {
+			etag = current.ETag
+		}
+	}
+
+	report.Pushed++
+	return s.repo.MarkPushed(ctx, appt.ID, href, etag, sequence)
+}
+
+func (s *Service) recordRemoteConflict(ctx context.Context, source string, appt models.Appointment, now time.Time, report *Report) error {
+	created, err := s.repo.UpsertConflict(ctx, Conflict{
+		ID:            fmt.Sprintf("%s|%s|%d", ConflictRemoteModified, appt.ID, appt.CalendarSequence),
+		Kind:          ConflictRemoteModified,
+		Source:        source,
+		Href:          appt.CalDAVHref,
+		AppointmentID: appt.ID,
+		Date:          appt.Date,
+		Time:          appt.Time,
+		DetectedAt:    now,
+	})
+	if err != nil {
+		return err
+	}
+	if created {
+		report.Conflicts++
+		s.log.Warn("caldav sync: pushed event changed remotely, overwriting",
+			slog.String("appointment_id", appt.ID),
+			slog.String("href", appt.CalDAVHref),
+		)
+	}
+	return nil
+}
+
+func (s *Service) saveError(ctx context.Context, source string, now time.Time, cause error) {
+	state, err := s.repo.GetState(ctx, source)
+	if err != nil {
+		return
+	}
+	state.ID = source
+	state.LastSyncAt = now
+	state.LastError = cause.Error()
+	_ = s.repo.SaveState(ctx, state)
+}
+
+func busyWindow(now time.Time) (time.Time, time.Time) {
+	start := startOfDay(now)
+	return start, start.AddDate(0, 0, busyHorizonDays)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func addDates(set map[string]struct{}, dates []string) {
+	for _, date := range dates {
+		set[date] = struct{}{}
+	}
+}
@@ -0,0 +1,363 @@
+package caldav
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/caldav/caldavtest"
+	"gbh-backend/internal/calendar"
+	"gbh-backend/internal/models"
+)
+
+type memRepository struct {
+	states       map[string]SyncState
+	busy         []BusyBlock
+	conflicts    map[string]Conflict
+	appointments map[string]models.Appointment
+}
+
+func newMemRepository() *memRepository {
+	return &memRepository{
+		states:       make(map[string]SyncState),
+		conflicts:    make(map[string]Conflict),
+		appointments: make(map[string]models.Appointment),
+	}
+}
+
+func (m *memRepository) GetState(ctx context.Context, source string) (SyncState, error) {
+	if state, ok := m.states[source]; ok {
+		return state, nil
+	}
+	return SyncState{ID: source}, nil
+}
+
+func (m *memRepository) SaveState(ctx context.Context, state SyncState) error {
+	m.states[state.ID] = state
+	return nil
+}
+
+func (m *memRepository) ListStates(ctx context.Context) ([]SyncState, error) {
+	items := make([]SyncState, 0, len(m.states))
+	for _, state := range m.states {
+		items = append(items, state)
+	}
+	return items, nil
+}
+
+func (m *memRepository) InsertBusy(ctx context.Context, blocks []BusyBlock) error {
+	m.busy = append(m.busy, blocks...)
+	return nil
+}
+
+func (m *memRepository) DeleteBusy(ctx context.Context, source, href string) ([]string, error) {
+	kept := m.busy[:0]
+	dates := make([]string, 0)
+	for _, block := range m.busy {
+		if block.Source == source && block.Href == href {
+			dates = append(dates, block.Date)
+			continue
+		}
+		kept = append(kept, block)
+	}
+	m.busy = kept
+	return dates, nil
+}
+
+func (m *memRepository) ClearBusy(ctx context.Context, source string) error {
+	kept := m.busy[:0]
+	for _, block := range m.busy {
+		if block.Source != source {
+			kept = append(kept, block)
+		}
+	}
+	m.busy = kept
+	return nil
+}
+
+func (m *memRepository) ActiveAppointments(ctx context.Context, date string) ([]models.Appointment, error) {
+	items := make([]models.Appointment, 0)
+	for _, appt := range m.appointments {
+		if appt.Date == date && !models.AppointmentCanceled(appt.Status) {
+			items = append(items, appt)
+		}
+	}
+	return items, nil
+}
+
+func (m *memRepository) PendingAppointments(ctx context.Context, fromDate string, limit int64) ([]models.Appointment, error) {
+	items := make([]models.Appointment, 0)
+	for _, appt := range m.appointments {
+		if appt.Date < fromDate {
+			continue
+		}
+		if appt.CalDAVSyncedSequence == nil || *appt.CalDAVSyncedSequence != appt.CalendarSequence {
+			items = append(items, appt)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, nil
+}
+
+func (m *memRepository) MarkPushed(ctx context.Context, id, href, etag string, sequence int) error {
+	appt := m.appointments[id]
+	appt.CalDAVHref = href
+	appt.CalDAVETag = etag
+	appt.CalDAVSyncedSequence = &sequence
+	m.appointments[id] = appt
+	return nil
+}
+
+func (m *memRepository) MarkRemoved(ctx context.Context, id string, sequence int) error {
+	appt := m.appointments[id]
+	appt.CalDAVHref = ""
+	appt.CalDAVETag = ""
+	appt.CalDAVSyncedSequence = &sequence
+	m.appointments[id] = appt
+	return nil
+}
+
+func (m *memRepository) ServiceNames(ctx context.Context) (map[string]string, error) {
+	return map[string]string{"svc-1": "Consultation"}, nil
+}
+
+func (m *memRepository) UpsertConflict(ctx context.Context, conflict Conflict) (bool, error) {
+	if _, ok := m.conflicts[conflict.ID]; ok {
+		return false, nil
+	}
+	m.conflicts[conflict.ID] = conflict
+	return true, nil
+}
+
+func (m *memRepository) ListConflicts(ctx context.Context, limit, offset int64) ([]Conflict, error) {
+	items := make([]Conflict, 0, len(m.conflicts))
+	for _, conflict := range m.conflicts {
+		items = append(items, conflict)
+	}
+	return items, nil
+}
+
+func (m *memRepository) CountConflicts(ctx context.Context) (int64, error) {
+	return int64(len(m.conflicts)), nil
+}
+
+func newTestService(t *testing.T) (*Service, *memRepository, *caldavtest.Server, time.Time) {
+	t.Helper()
+	loc, err := time.LoadLocation("Africa/Kinshasa")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	server := caldavtest.NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.CalendarURL(), "user", "secret", server.Client())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	repo := newMemRepository()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := time.Date(2026, 5, 4, 8, 0, 0, 0, loc)
+	return NewService(repo, []*Client{client}, nil, loc, log), repo, server, now
+}
+
+func externalEvent(uid, start, end string) string {
+	return strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Other//EN",
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"DTSTART;TZID=Africa/Kinshasa:" + start,
+		"DTEND;TZID=Africa/Kinshasa:" + end,
+		"SUMMARY:Rendez-vous externe",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+}
+
+func TestSyncPullsBusyBlocksIncrementally(t *testing.T) {
+	service, repo, server, now := newTestService(t)
+	ctx := context.Background()
+
+	href := server.PutEvent("meeting.ics", externalEvent("ext-1", "20260505T100000", "20260505T113000"))
+	report, err := service.Sync(ctx, now)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if report.Changed != 1 || len(repo.busy) != 1 {
+		t.Fatalf("expected one busy block, got report %+v and %d blocks", report, len(repo.busy))
+	}
+	block := repo.busy[0]
+	if block.Date != "2026-05-05" || block.Time != "10:00" || block.Duration != 90 {
+		t.Fatalf("unexpected block %+v", block)
+	}
+
+	// Nothing changed: the stored token yields an empty delta.
+	report, err = service.Sync(ctx, now)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if report.Changed != 0 || report.Removed != 0 {
+		t.Fatalf("expected empty delta, got %+v", report)
+	}
+
+	server.DeleteEvent(href)
+	report, err = service.Sync(ctx, now)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if report.Removed != 1 || len(repo.busy) != 0 {
+		t.Fatalf("expected block removed, got report %+v and %d blocks", report, len(repo.busy))
+	}
+}
+
+func TestSyncRecoversFromExpiredToken(t *testing.T) {
+	service, repo, server, now := newTestService(t)
+	ctx := context.Background()
+
+	server.PutEvent("a.ics", externalEvent("ext-a", "20260505T090000", "20260505T100000"))
+	if _, err := service.Sync(ctx, now); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	server.ExpireSyncTokens()
+	server.PutEvent("b.ics", externalEvent("ext-b", "20260506T090000", "20260506T100000"))
+	if _, err := service.Sync(ctx, now); err != nil {
+		t.Fatalf("Sync() after expiry error = %v", err)
+	}
+	if len(repo.busy) != 2 {
+		t.Fatalf("expected full resync to keep both blocks, got %d", len(repo.busy))
+	}
+}
+
+func TestSyncReexpandsWhenWindowMoves(t *testing.T) {
+	service, repo, server, now := newTestService(t)
+	ctx := context.Background()
+
+	// Past the horizon at the first sync, inside it two weeks later.
+	server.PutEvent("far.ics", externalEvent("ext-far", "20260910T090000", "20260910T100000"))
+	if _, err := service.Sync(ctx, now); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if len(repo.busy) != 0 {
+		t.Fatalf("expected no block beyond the window, got %+v", repo.busy)
+	}
+
+	// Same day: the delta is empty and nothing is fetched again.
+	report, err := service.Sync(ctx, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if report.Changed != 0 {
+		t.Fatalf("expected empty delta on the same day, got %+v", report)
+	}
+
+	if _, err := service.Sync(ctx, now.AddDate(0, 0, 14)); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if len(repo.busy) != 1 || repo.busy[0].Date != "2026-09-10" {
+		t.Fatalf("expected the event expanded once the window moved, got %+v", repo.busy)
+	}
+}
+
+func TestSyncPushesAppointmentsAndRemovesCanceled(t *testing.T) {
+	service, repo, server, now := newTestService(t)
+	ctx := context.Background()
+
+	repo.appointments["RDV-1"] = models.Appointment{
+		ID: "RDV-1", ServiceID: "svc-1", Name: "Jean", Date: "2026-05-05", Time: "14:00", Duration: 45,
+		Status: models.AppointmentStatusBooked,
+	}
+	report, err := service.Sync(ctx, now)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	appt := repo.appointments["RDV-1"]
+	if report.Pushed != 1 || appt.CalDAVHref == "" || appt.CalDAVETag == "" {
+		t.Fatalf("expected appointment pushed, got report %+v and %+v", report, appt)
+	}
+	data, ok := server.Event(appt.CalDAVHref)
+	if !ok || !strings.Contains(data, "UID:"+calendar.AppointmentUID("RDV-1")) || strings.Contains(data, "METHOD:") {
+		t.Fatalf("unexpected remote event %q", data)
+	}
+
+	// Our own event comes back through the pull but must not block its slot.
+	if _, err := service.Sync(ctx, now); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if len(repo.busy) != 0 {
+		t.Fatalf("own event should not create busy blocks, got %+v", repo.busy)
+	}
+
+	appt.Status = models.AppointmentStatusCanceledByAdmin
+	appt.CalendarSequence++
+	repo.appointments["RDV-1"] = appt
+	report, err = service.Sync(ctx, now)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if report.Deleted != 1 || len(server.Hrefs()) != 0 {
+		t.Fatalf("expected remote event deleted, got report %+v and %v", report, server.Hrefs())
+	}
+}
+
+func TestSyncOverwritesRemotelyEditedEvent(t *testing.T) {
+	service, repo, server, now := newTestService(t)
+	ctx := context.Background()
+
+	repo.appointments["RDV-2"] = models.Appointment{
+		ID: "RDV-2", ServiceID: "svc-1", Name: "Awa", Date: "2026-05-06", Time: "10:00", Duration: 45,
+		Status: models.AppointmentStatusBooked,
+	}
+	if _, err := service.Sync(ctx, now); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	href := repo.appointments["RDV-2"].CalDAVHref
+	server.PutEvent(strings.TrimPrefix(href, caldavtest.CollectionPath), externalEvent(calendar.AppointmentUID("RDV-2"), "20260506T160000", "20260506T170000"))
+
+	appt := repo.appointments["RDV-2"]
+	appt.Time = "11:00"
+	appt.CalendarSequence++
+	repo.appointments["RDV-2"] = appt
+	report, err := service.Sync(ctx, now)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if report.Conflicts != 1 || report.Pushed != 1 {
+		t.Fatalf("expected one conflict and a push, got %+v", report)
+	}
+	data, _ := server.Event(href)
+	if !strings.Contains(data, "DTSTART;TZID=Africa/Kinshasa:20260506T110000") {
+		t.Fatalf("expected booking to win, got %q", data)
+	}
+}
+
+func TestSyncRecordsOverlapWithAppointment(t *testing.T) {
+	service, repo, server, now := newTestService(t)
+	ctx := context.Background()
+
+	sequence := 0
+	repo.appointments["RDV-3"] = models.Appointment{
+		ID: "RDV-3", ServiceID: "svc-1", Date: "2026-05-07", Time: "09:00", Duration: 45,
+		Status: models.AppointmentStatusBooked, CalDAVSyncedSequence: &sequence,
+	}
+	server.PutEvent("clash.ics", externalEvent("ext-clash", "20260507T093000", "20260507T103000"))
+
+	report, err := service.Sync(ctx, now)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if report.Conflicts != 1 {
+		t.Fatalf("expected overlap conflict, got %+v", report)
+	}
+	for _, conflict := range repo.conflicts {
+		if conflict.Kind != ConflictOverlap || conflict.AppointmentID != "RDV-3" {
+			t.Fatalf("unexpected conflict %+v", conflict)
+		}
+	}
+}
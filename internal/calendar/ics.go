@@ -0,0 +1,245 @@
+// Package calendar renders iCalendar (RFC 5545) documents for appointments.
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	MethodPublish = "PUBLISH"
+	MethodRequest = "REQUEST"
+	MethodCancel  = "CANCEL"
+
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+
+	ProdID = "-//GBH//gbh-backend//FR"
+
+	uidDomain       = "gbh-backend"
+	maxLineOctets   = 75
+	localTimeLayout = "20060102T150405"
+	utcTimeLayout   = "20060102T150405Z"
+)
+
+type Person struct {
+	Name  string
+	Email string
+}
+
+type Event struct {
+	UID         string
+	Sequence    int
+	Status      string
+	Summary     string
+	Description string
+	Location    string
+	URL         string
+	Start       time.Time
+	End         time.Time
+	Organizer   *Person
+	Attendees   []Person
+}
+
+type Calendar struct {
+	Method string
+	// Name is shown by subscribing clients as the calendar title.
+	Name     string
+	Location *time.Location
+	Events   []Event
+}
+
+// AppointmentUID returns the stable UID used for every invitation about one appointment.
+func AppointmentUID(appointmentID string) string {
+	return "appointment-" + appointmentID + "@" + uidDomain
+}
+
+// IsAppointmentUID reports whether uid was produced by AppointmentUID.
+func IsAppointmentUID(uid string) bool {
+	return strings.HasPrefix(uid, "appointment-") && strings.HasSuffix(uid, "@"+uidDomain)
+}
+
+// Render serialises the calendar with CRLF line endings and folded lines.
+func (c Calendar) Render(now time.Time) string {
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	w := &writer{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + ProdID)
+	w.line("CALSCALE:GREGORIAN")
+	if c.Method != "" {
+		w.line("METHOD:" + c.Method)
+	}
+	if c.Name != "" {
+		w.line("X-WR-CALNAME:" + escapeText(c.Name))
+	}
+	if loc != time.UTC {
+		w.line("X-WR-TIMEZONE:" + loc.String())
+		from, to := c.span(now)
+		writeTimezone(w, loc, from, to)
+	}
+
+	stamp := now.UTC().Format(utcTimeLayout)
+	for _, event := range c.Events {
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + event.UID)
+		w.line("DTSTAMP:" + stamp)
+		w.line(formatDateTime("DTSTART", event.Start, loc))
+		w.line(formatDateTime("DTEND", event.End, loc))
+		w.line(fmt.Sprintf("SEQUENCE:%d", event.Sequence))
+		if event.Status != "" {
+			w.line("STATUS:" + event.Status)
+		}
+		w.line("SUMMARY:" + escapeText(event.Summary))
+		if event.Description != "" {
+			w.line("DESCRIPTION:" + escapeText(event.Description))
+		}
+		if event.Location != "" {
+			w.line("LOCATION:" + escapeText(event.Location))
+		}
+		if event.URL != "" {
+			w.line("URL:" + event.URL)
+		}
+		if event.Organizer != nil && event.Organizer.Email != "" {
+			w.line("ORGANIZER" + commonName(event.Organizer.Name) + ":mailto:" + event.Organizer.Email)
+		}
+		for _, attendee := range event.Attendees {
+			if attendee.Email == "" {
+				continue
+			}
+			w.line("ATTENDEE" + commonName(attendee.Name) + ";ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=FALSE:mailto:" + attendee.Email)
+		}
+		w.line("END:VEVENT")
+	}
+	w.line("END:VCALENDAR")
+	return w.String()
+}
+
+// span returns the time covered by the events, or now for an empty calendar.
+func (c Calendar) span(now time.Time) (time.Time, time.Time) {
+	if len(c.Events) == 0 {
+		return now, now
+	}
+	from, to := c.Events[0].Start, c.Events[0].End
+	for _, event := range c.Events {
+		if event.Start.Before(from) {
+			from = event.Start
+		}
+		if event.End.After(to) {
+			to = event.End
+		}
+	}
+	return from, to
+}
+
+// writeTimezone emits a VTIMEZONE valid from from to to: the offset in
+// effect at from, then one observance per transition up to to. A zone
+// without daylight saving time, like our default Africa/Kinshasa, gets a
+// single STANDARD component.
+func writeTimezone(w *writer, loc *time.Location, from, to time.Time) {
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + loc.String())
+	t := from.In(loc)
+	_, offset := t.Zone()
+	writeObservance(w, t, "19700101T000000", offset)
+	for {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.After(to) {
+			break
+		}
+		// DTSTART is the wall-clock time of the change before it happens.
+		_, before := t.Zone()
+		onset := end.UTC().Add(time.Duration(before) * time.Second).Format(localTimeLayout)
+		t = end.In(loc)
+		writeObservance(w, t, onset, before)
+	}
+	w.line("END:VTIMEZONE")
+}
+
+// writeObservance describes the offset in effect at t, starting at onset.
+func writeObservance(w *writer, t time.Time, onset string, offsetFrom int) {
+	kind := "STANDARD"
+	if t.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	name, offset := t.Zone()
+	w.line("BEGIN:" + kind)
+	w.line("DTSTART:" + onset)
+	w.line("TZOFFSETFROM:" + formatOffset(offsetFrom))
+	w.line("TZOFFSETTO:" + formatOffset(offset))
+	w.line("TZNAME:" + name)
+	w.line("END:" + kind)
+}
+
+func formatDateTime(prop string, t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return prop + ":" + t.UTC().Format(utcTimeLayout)
+	}
+	return prop + ";TZID=" + loc.String() + ":" + t.In(loc).Format(localTimeLayout)
+}
+
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, (seconds%3600)/60)
+}
+
+func commonName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	return `;CN="` + strings.NewReplacer(`"`, "'", "\r", "", "\n", " ").Replace(name) + `"`
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+func escapeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+type writer struct {
+	b strings.Builder
+}
+
+// line writes one content line, folding it at 75 octets without splitting
+// UTF-8 sequences (RFC 5545 section 3.1).
+func (w *writer) line(value string) {
+	limit := maxLineOctets
+	for len(value) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(value[cut]) {
+			cut--
+		}
+		w.b.WriteString(value[:cut])
+		w.b.WriteString("\r\n ")
+		value = value[cut:]
+		// Continuation lines start with a space, which counts toward the limit.
+		limit = maxLineOctets - 1
+	}
+	w.b.WriteString(value)
+	w.b.WriteString("\r\n")
+}
+
+func (w *writer) String() string {
+	return w.b.String()
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,107 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLoadLoc(t *testing.T) *time.Location {
+	loc, err := time.LoadLocation("Africa/Kinshasa")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	return loc
+}
+
+func TestRenderRequestUsesTimezoneAndStableUID(t *testing.T) {
+	loc := mustLoadLoc(t)
+	start := time.Date(2026, 4, 23, 10, 0, 0, 0, loc)
+	cal := Calendar{
+		Method:   MethodRequest,
+		Location: loc,
+		Events: []Event{{
+			UID:       AppointmentUID("RDV-001"),
+			Sequence:  2,
+			Status:    StatusConfirmed,
+			Summary:   "Consultation; GBH, Kinshasa",
+			Start:     start,
+			End:       start.Add(45 * time.Minute),
+			Organizer: &Person{Name: "GBH", Email: "contact@gbh.sarl"},
+			Attendees: []Person{{Name: "Jean", Email: "jean@example.com"}},
+		}},
+	}
+
+	out := cal.Render(time.Date(2026, 4, 20, 8, 0, 0, 0, time.UTC))
+
+	for _, want := range []string{
+		"METHOD:REQUEST\r\n",
+		"UID:appointment-RDV-001@gbh-backend\r\n",
+		"DTSTART;TZID=Africa/Kinshasa:20260423T100000\r\n",
+		"DTEND;TZID=Africa/Kinshasa:20260423T104500\r\n",
+		"TZOFFSETTO:+0100\r\n",
+		"SEQUENCE:2\r\n",
+		`SUMMARY:Consultation\; GBH\, Kinshasa` + "\r\n",
+		"DTSTAMP:20260420T080000Z\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in calendar, got %q", want, out)
+		}
+	}
+}
+
+func TestRenderTimezoneTransitions(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	spring := time.Date(2026, 3, 2, 10, 0, 0, 0, paris)
+	autumn := time.Date(2026, 11, 16, 10, 0, 0, 0, paris)
+	cal := Calendar{
+		Method:   MethodPublish,
+		Location: paris,
+		Events: []Event{
+			{UID: "b@y", Summary: "Automne", Start: autumn, End: autumn.Add(time.Hour)},
+			{UID: "a@y", Summary: "Printemps", Start: spring, End: spring.Add(time.Hour)},
+		},
+	}
+	out := cal.Render(spring)
+	want := "BEGIN:STANDARD\r\nDTSTART:19700101T000000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0100\r\nTZNAME:CET\r\nEND:STANDARD\r\n" +
+		"BEGIN:DAYLIGHT\r\nDTSTART:20260329T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST\r\nEND:DAYLIGHT\r\n" +
+		"BEGIN:STANDARD\r\nDTSTART:20261025T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nTZNAME:CET\r\nEND:STANDARD\r\n" +
+		"END:VTIMEZONE\r\n"
+	if !strings.Contains(out, want) {
+		t.Fatalf("expected both transitions of 2026 in %q", out)
+	}
+
+	kinshasa := mustLoadLoc(t)
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, kinshasa)
+	out = Calendar{Location: kinshasa, Events: []Event{{UID: "c@y", Start: start, End: start.AddDate(0, 9, 0)}}}.Render(start)
+	if strings.Count(out, "BEGIN:STANDARD") != 1 || strings.Contains(out, "DAYLIGHT") {
+		t.Fatalf("expected a single STANDARD observance, got %q", out)
+	}
+}
+
+func TestRenderFoldsLongLines(t *testing.T) {
+	cal := Calendar{
+		Method: MethodCancel,
+		Events: []Event{{
+			UID:         "x@y",
+			Summary:     "Annulation",
+			Description: strings.Repeat("é", 100),
+			Start:       time.Date(2026, 4, 23, 9, 0, 0, 0, time.UTC),
+			End:         time.Date(2026, 4, 23, 10, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	out := cal.Render(time.Now())
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("line exceeds 75 octets (%d): %q", len(line), line)
+		}
+	}
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	if !strings.Contains(unfolded, "DESCRIPTION:"+strings.Repeat("é", 100)+"\r\n") {
+		t.Fatalf("folded description does not unfold to the original value")
+	}
+}
@@ -0,0 +1,221 @@
+package calendar
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCalendar = errors.New("invalid calendar data")
+
+// ParsedEvent is the subset of a VEVENT needed to compute busy time.
+type ParsedEvent struct {
+	UID         string
+	Summary     string
+	Status      string
+	Transparent bool
+	AllDay      bool
+	Start       time.Time
+	End         time.Time
+}
+
+// Busy reports whether the event should block availability.
+func (e ParsedEvent) Busy() bool {
+	return !e.Transparent && e.Status != StatusCancelled && e.End.After(e.Start)
+}
+
+// ParseEvents extracts the VEVENTs of an iCalendar document. Times without a
+// TZID (floating) or with an unknown TZID are read in loc. Recurrence rules
+// are not expanded; callers should ask the server for expanded data.
+func ParseEvents(data string, loc *time.Location) ([]ParsedEvent, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	lines := unfold(data)
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, ErrInvalidCalendar
+	}
+
+	events := make([]ParsedEvent, 0)
+	var current *ParsedEvent
+	var duration time.Duration
+	hasEnd := false
+	depth := 0
+	for _, line := range lines {
+		name, params, value := splitContentLine(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			current = &ParsedEvent{}
+			duration = 0
+			hasEnd = false
+			depth = 0
+			continue
+		case current == nil:
+			continue
+		case name == "BEGIN":
+			// Nested components such as VALARM carry their own properties.
+			depth++
+			continue
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if current.Start.IsZero() {
+				return nil, ErrInvalidCalendar
+			}
+			if !hasEnd {
+				switch {
+				case duration > 0:
+					current.End = current.Start.Add(duration)
+				case current.AllDay:
+					current.End = current.Start.AddDate(0, 0, 1)
+				default:
+					current.End = current.Start
+				}
+			}
+			events = append(events, *current)
+			current = nil
+			continue
+		case name == "END":
+			depth--
+			continue
+		case depth > 0:
+			continue
+		}
+
+		switch name {
+		case "UID":
+			current.UID = value
+		case "SUMMARY":
+			current.Summary = unescapeText(value)
+		case "STATUS":
+			current.Status = strings.ToUpper(value)
+		case "TRANSP":
+			current.Transparent = strings.EqualFold(value, "TRANSPARENT")
+		case "DTSTART":
+			start, allDay, err := parseDateTime(value, params, loc)
+			if err != nil {
+				return nil, err
+			}
+			current.Start = start
+			current.AllDay = allDay
+		case "DTEND":
+			end, _, err := parseDateTime(value, params, loc)
+			if err != nil {
+				return nil, err
+			}
+			current.End = end
+			hasEnd = true
+		case "DURATION":
+			d, err := parseDuration(value)
+			if err != nil {
+				return nil, err
+			}
+			duration = d
+		}
+	}
+	return events, nil
+}
+
+func unfold(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	raw := strings.Split(data, "\n")
+	lines := make([]string, 0, len(raw))
+	for _, line := range raw {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitContentLine splits "NAME;PARAM=x:value" into its parts. Parameter
+// values may be quoted and contain ':' or ';'.
+func splitContentLine(line string) (string, map[string]string, string) {
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+	head, value := line[:colon], line[colon+1:]
+	parts := strings.Split(head, ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+func parseDateTime(value string, params map[string]string, loc *time.Location) (time.Time, bool, error) {
+	if strings.EqualFold(params["VALUE"], "DATE") || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, false, ErrInvalidCalendar
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcTimeLayout, value)
+		if err != nil {
+			return time.Time{}, false, ErrInvalidCalendar
+		}
+		return t.In(loc), false, nil
+	}
+	zone := loc
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			zone = l
+		}
+	}
+	t, err := time.ParseInLocation(localTimeLayout, value, zone)
+	if err != nil {
+		return time.Time{}, false, ErrInvalidCalendar
+	}
+	return t.In(loc), false, nil
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseDuration(value string) (time.Duration, error) {
+	m := durationPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if m == nil {
+		return 0, ErrInvalidCalendar
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var total time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, ErrInvalidCalendar
+		}
+		total += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		total = -total
+	}
+	return total, nil
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescapeText(value string) string {
+	return textUnescaper.Replace(value)
+}
@@ -0,0 +1,54 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEvents(t *testing.T) {
+	loc, err := time.LoadLocation("Africa/Kinshasa")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:utc",
+		"DTSTART:20260505T080000Z",
+		"DURATION:PT1H30M",
+		"SUMMARY:Point\\, equipe",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"DURATION:PT5M",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:all-day",
+		"DTSTART;VALUE=DATE:20260506",
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := ParseEvents(data, loc)
+	if err != nil {
+		t.Fatalf("ParseEvents() error = %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	first := events[0]
+	if got := first.Start.Format("2006-01-02 15:04"); got != "2026-05-05 09:00" {
+		t.Fatalf("expected start in local time, got %s", got)
+	}
+	if first.End.Sub(first.Start) != 90*time.Minute || first.Summary != "Point, equipe" || !first.Busy() {
+		t.Fatalf("unexpected event %+v", first)
+	}
+
+	second := events[1]
+	if !second.AllDay || second.End.Sub(second.Start) != 24*time.Hour || second.Busy() {
+		t.Fatalf("unexpected all-day event %+v", second)
+	}
+}
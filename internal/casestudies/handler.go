@@ -10,6 +10,7 @@ import (
 
 	"gbh-backend/internal/httpx"
 	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/rbac"
 	"gbh-backend/internal/transport"
 	"gbh-backend/internal/validation"
 	"github.com/go-chi/chi/v5"
@@ -126,6 +127,16 @@ func (h *Handler) AdminCreate(w http.ResponseWriter, r *http.Request) {
 		transport.WriteError(w, http.StatusBadRequest, "validation error", httpx.ValidationDetails(h.val.ValidationErrors(err)))
 		return
 	}
+	// Without content:publish, new items start hidden.
+	if !middleware.HasPermission(r.Context(), rbac.ContentPublish) {
+		if req.IsPublished != nil && *req.IsPublished {
+			log.Warn("admin case studies create: publish not allowed")
+			transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": rbac.ContentPublish})
+			return
+		}
+		hidden := false
+		req.IsPublished = &hidden
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
 	defer cancel()
@@ -171,6 +182,11 @@ func (h *Handler) AdminUpdate(w http.ResponseWriter, r *http.Request) {
 		transport.WriteError(w, http.StatusBadRequest, "validation error", httpx.ValidationDetails(h.val.ValidationErrors(err)))
 		return
 	}
+	if req.IsPublished != nil && !middleware.HasPermission(r.Context(), rbac.ContentPublish) {
+		log.Warn("admin case studies update: publish not allowed", slog.String("case_study_id", id))
+		transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": rbac.ContentPublish})
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
 	defer cancel()
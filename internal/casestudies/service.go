@@ -70,6 +70,8 @@ func (s *Service) Create(ctx context.Context, req UpsertRequest) (CaseStudy, err
 	return item, nil
 }
 
+// Update replaces the case study fields. Publication only changes when
+// is_published is sent.
 func (s *Service) Update(ctx context.Context, id string, req UpsertRequest) (CaseStudy, error) {
 	id = strings.TrimSpace(id)
 	slug := normalizeSlug(req.Slug, req.Title)
@@ -77,26 +79,24 @@ func (s *Service) Update(ctx context.Context, id string, req UpsertRequest) (Cas
 		return CaseStudy{}, ErrInvalidSlug
 	}
 
-	isPublished := false
-	if req.IsPublished != nil {
-		isPublished = *req.IsPublished
-	}
 	sortOrder := 0
 	if req.SortOrder != nil {
 		sortOrder = *req.SortOrder
 	}
 
 	set := bson.M{
-		"slug":         slug,
-		"title":        strings.TrimSpace(req.Title),
-		"category":     strings.TrimSpace(req.Category),
-		"client_name":  strings.TrimSpace(req.ClientName),
-		"problem":      strings.TrimSpace(req.Problem),
-		"solution":     strings.TrimSpace(req.Solution),
-		"result":       strings.TrimSpace(req.Result),
-		"is_published": isPublished,
-		"sort_order":   sortOrder,
-		"updated_at":   time.Now().In(s.location),
+		"slug":        slug,
+		"title":       strings.TrimSpace(req.Title),
+		"category":    strings.TrimSpace(req.Category),
+		"client_name": strings.TrimSpace(req.ClientName),
+		"problem":     strings.TrimSpace(req.Problem),
+		"solution":    strings.TrimSpace(req.Solution),
+		"result":      strings.TrimSpace(req.Result),
+		"sort_order":  sortOrder,
+		"updated_at":  time.Now().In(s.location),
+	}
+	if req.IsPublished != nil {
+		set["is_published"] = *req.IsPublished
 	}
 
 	updated, err := s.repo.Update(ctx, id, set)
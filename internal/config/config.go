@@ -18,6 +18,7 @@ type Config struct {
 	RateLimitAppointments int
 	RateLimitContact      int
 	RateLimitWindowSec    int
+	RateLimitLogin        int
 	RedisURL              string
 	RedisAddr             string
 	RedisPassword         string
@@ -36,6 +37,56 @@ type Config struct {
 	BrevoSenderEmail      string
 	BrevoSenderName       string
 	BrevoSandbox          bool
+	// Shared secret expected on Brevo webhook calls (token query param or X-Webhook-Token header).
+	BrevoWebhookSecret string
+	// Local hour (0-23) after which the daily admin digest is sent.
+	AdminDigestHour int
+	// Public URL of this API (e.g. https://api.gbh.sarl), used in links we hand out.
+	PublicBaseURL string
+	// CalDAV collections to sync (comma-separated URLs). Busy time is pulled
+	// from all of them; appointments are pushed to the first one.
+	CalDAVCalendarURLs []string
+	CalDAVUsername     string
+	CalDAVPassword     string
+	// Seconds between two CalDAV sync runs.
+	CalDAVSyncIntervalSec int
+	// Meeting links for online appointments: "jitsi" (default), "hosted" or "none".
+	MeetingProvider   string
+	JitsiBaseURL      string
+	MeetingRoomPrefix string
+	// Endpoint and bearer key of the hosted meeting provider.
+	MeetingAPIURL string
+	MeetingAPIKey string
+	// Admin2FARequired blocks admin routes (except enrolment) for users
+	// without TOTP two-factor authentication.
+	Admin2FARequired bool
+	// Issuer shown in authenticator apps.
+	TOTPIssuer string
+	// Back-office page that receives password reset links; the token is
+	// appended as the "token" query parameter.
+	AdminResetURL string
+	// Minutes a password reset link stays valid.
+	PasswordResetTTLMinutes int
+	// Back-office page that receives invitation links ("token" query
+	// parameter), and how many hours a link stays valid.
+	AdminInviteURL     string
+	InvitationTTLHours int
+	// Consecutive failed logins that lock an admin account, and for how
+	// many minutes.
+	LoginLockoutThreshold int
+	LoginLockoutMinutes   int
+	// Failed logins from one IP, within LoginLockoutMinutes, after which the
+	// IP is refused.
+	LoginIPMaxFailures int
+	// Page that receives waitlist claim links ("token" query parameter).
+	WaitlistClaimURL string
+	// Minutes a waitlist offer can be claimed, and how many waiting
+	// customers are offered the same freed slot at once.
+	WaitlistOfferMinutes int
+	WaitlistOfferBatch   int
+	// Brevo SMS sender name (alphanumeric, 11 characters max); empty
+	// disables SMS.
+	BrevoSMSSender string
 
 	// Firebase (FCM) service account JSON path.
 	// If empty, the app will use GOOGLE_APPLICATION_CREDENTIALS if set.
@@ -91,6 +142,7 @@ func Load() (*Config, error) {
 		RateLimitAppointments:     getEnvInt("RATE_LIMIT_APPOINTMENTS", 10),
 		RateLimitContact:          getEnvInt("RATE_LIMIT_CONTACT", 5),
 		RateLimitWindowSec:        getEnvInt("RATE_LIMIT_WINDOW_SEC", 60),
+		RateLimitLogin:            getEnvInt("RATE_LIMIT_LOGIN", 10),
 		RedisURL:                  getEnv("REDIS_URL", ""),
 		RedisAddr:                 getEnv("REDIS_ADDR", ""),
 		RedisPassword:             getEnv("REDIS_PASSWORD", ""),
@@ -104,11 +156,36 @@ func Load() (*Config, error) {
 		AccessTTLMinutes:          getEnvInt("ACCESS_TTL_MINUTES", 15),
 		RefreshTTLMinutes:         getEnvInt("REFRESH_TTL_MINUTES", 43200),
 		CookieSecure:              getEnv("COOKIE_SECURE", "false") == "true",
+		Admin2FARequired:          getEnv("ADMIN_2FA_REQUIRED", "false") == "true",
+		TOTPIssuer:                getEnv("TOTP_ISSUER", "GBH"),
+		AdminResetURL:             getEnv("ADMIN_RESET_URL", ""),
+		PasswordResetTTLMinutes:   getEnvInt("PASSWORD_RESET_TTL_MINUTES", 60),
+		AdminInviteURL:            getEnv("ADMIN_INVITE_URL", ""),
+		InvitationTTLHours:        getEnvInt("INVITATION_TTL_HOURS", 72),
+		LoginLockoutThreshold:     getEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LoginLockoutMinutes:       getEnvInt("LOGIN_LOCKOUT_MINUTES", 15),
+		LoginIPMaxFailures:        getEnvInt("LOGIN_IP_MAX_FAILURES", 30),
+		WaitlistClaimURL:          getEnv("WAITLIST_CLAIM_URL", ""),
+		WaitlistOfferMinutes:      getEnvInt("WAITLIST_OFFER_MINUTES", 120),
+		WaitlistOfferBatch:        getEnvInt("WAITLIST_OFFER_BATCH", 3),
+		BrevoSMSSender:            getEnv("BREVO_SMS_SENDER", ""),
 		Timezone:                  loc,
 		BrevoAPIKey:               getEnv("BREVO_API_KEY", ""),
 		BrevoSenderEmail:          getEnv("BREVO_SENDER_EMAIL", ""),
 		BrevoSenderName:           getEnv("BREVO_SENDER_NAME", ""),
 		BrevoSandbox:              getEnv("BREVO_SANDBOX", "false") == "true",
+		BrevoWebhookSecret:        getEnv("BREVO_WEBHOOK_SECRET", ""),
+		AdminDigestHour:           getEnvInt("ADMIN_DIGEST_HOUR", 18),
+		PublicBaseURL:             strings.TrimRight(getEnv("PUBLIC_BASE_URL", ""), "/"),
+		CalDAVCalendarURLs:        splitList(getEnv("CALDAV_CALENDAR_URLS", "")),
+		CalDAVUsername:            getEnv("CALDAV_USERNAME", ""),
+		CalDAVPassword:            getEnv("CALDAV_PASSWORD", ""),
+		CalDAVSyncIntervalSec:     getEnvInt("CALDAV_SYNC_INTERVAL_SEC", 300),
+		MeetingProvider:           strings.ToLower(getEnv("MEETING_PROVIDER", "jitsi")),
+		JitsiBaseURL:              getEnv("JITSI_BASE_URL", "https://meet.jit.si"),
+		MeetingRoomPrefix:         getEnv("MEETING_ROOM_PREFIX", "gbh"),
+		MeetingAPIURL:             getEnv("MEETING_API_URL", ""),
+		MeetingAPIKey:             getEnv("MEETING_API_KEY", ""),
 		FirebaseCredentialsFile:   getEnv("FIREBASE_CREDENTIALS_FILE", getEnv("GOOGLE_APPLICATION_CREDENTIALS", "")),
 		FirebaseCredentialsBase64: getEnv("FIREBASE_CREDENTIALS_BASE64", ""),
 	}
@@ -132,6 +209,16 @@ func parseOrigins(value string) []string {
 	return out
 }
 
+func splitList(value string) []string {
+	out := make([]string, 0)
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
 func mongoDBFromURI(uri string) string {
 	u, err := url.Parse(uri)
 	if err != nil {
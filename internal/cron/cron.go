@@ -0,0 +1,23 @@
+// Package cron runs the periodic background jobs of the API.
+package cron
+
+import (
+	"context"
+	"time"
+)
+
+// Every calls job each interval until ctx is done, then returns. The job gets
+// ctx, so work in flight is cancelled at shutdown too. Every blocks: start it
+// in its own goroutine.
+func Every(ctx context.Context, interval time.Duration, job func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job(ctx)
+		}
+	}
+}
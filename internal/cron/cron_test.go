@@ -0,0 +1,44 @@
+package cron
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEveryTicksUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var ticks atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		Every(ctx, 10*time.Millisecond, func(jobCtx context.Context) {
+			if jobCtx.Err() != nil {
+				t.Error("job called with a cancelled context")
+			}
+			ticks.Add(1)
+		})
+		close(done)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for ticks.Load() < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("job ran %d times in 2s, want at least 3", ticks.Load())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Every did not return after cancel")
+	}
+	after := ticks.Load()
+	time.Sleep(50 * time.Millisecond)
+	if ticks.Load() != after {
+		t.Fatalf("job ran after cancel: %d then %d", after, ticks.Load())
+	}
+}
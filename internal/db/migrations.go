@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"slices"
+
+	"gbh-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// legacySlotIndex made (date, time) unique across every appointment, so
+	// a canceled appointment kept its slot forever.
+	legacySlotIndex = "date_1_time_1"
+	// singleRoomSlotIndex predates locations: one appointment per start time
+	// across every room.
+	singleRoomSlotIndex = "date_1_time_1_active"
+	// appointmentSlotIndex only covers appointments that hold their slot,
+	// once per room. Appointments without a location share the null room.
+	appointmentSlotIndex = "date_1_time_1_room_1_active"
+)
+
+func appointmentSlotIndexModel() mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys: bson.D{{Key: "date", Value: 1}, {Key: "time", Value: 1}, {Key: "location.roomId", Value: 1}},
+		Options: options.Index().
+			SetName(appointmentSlotIndex).
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": bson.M{"$in": models.ActiveAppointmentStatuses}}),
+	}
+}
+
+// migrateAppointmentSlots prepares existing data for the partial slot index.
+// It is idempotent and runs before the appointment indexes are created.
+func migrateAppointmentSlots(ctx context.Context, cols *Collections) error {
+	// Appointments stored before statuses were set on every booking are
+	// regular bookings.
+	missing := bson.M{"$or": []bson.M{
+		{"status": bson.M{"$exists": false}},
+		{"status": ""},
+	}}
+	if _, err := cols.Appointments.UpdateMany(ctx, missing, bson.M{"$set": bson.M{"status": models.AppointmentStatusBooked}}); err != nil {
+		return err
+	}
+
+	// The single "canceled" status predates the lifecycle; only admins
+	// could set it.
+	legacy := bson.M{"status": models.AppointmentStatusLegacyCanceled}
+	if _, err := cols.Appointments.UpdateMany(ctx, legacy, bson.M{"$set": bson.M{"status": models.AppointmentStatusCanceledByAdmin}}); err != nil {
+		return err
+	}
+
+	cursor, err := cols.Appointments.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+	var specs []struct {
+		Name                    string   `bson:"name"`
+		PartialFilterExpression bson.Raw `bson:"partialFilterExpression"`
+	}
+	if err := cursor.All(ctx, &specs); err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		drop := spec.Name == legacySlotIndex || spec.Name == singleRoomSlotIndex
+		// The active statuses changed since the index was built.
+		if spec.Name == appointmentSlotIndex && !slotIndexCurrent(spec.PartialFilterExpression) {
+			drop = true
+		}
+		if !drop {
+			continue
+		}
+		if _, err := cols.Appointments.Indexes().DropOne(ctx, spec.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func slotIndexCurrent(filter bson.Raw) bool {
+	var expr struct {
+		Status struct {
+			In []string `bson:"$in"`
+		} `bson:"status"`
+	}
+	if filter == nil || bson.Unmarshal(filter, &expr) != nil {
+		return false
+	}
+	want := slices.Clone(models.ActiveAppointmentStatuses)
+	got := slices.Clone(expr.Status.In)
+	slices.Sort(want)
+	slices.Sort(got)
+	return slices.Equal(want, got)
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"testing"
+
+	"gbh-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSlotIndexCurrent(t *testing.T) {
+	current, _ := bson.Marshal(bson.M{"status": bson.M{"$in": models.ActiveAppointmentStatuses}})
+	if !slotIndexCurrent(current) {
+		t.Fatalf("expected index built from the active statuses to be current")
+	}
+	stale, _ := bson.Marshal(bson.M{"status": bson.M{"$in": []string{models.AppointmentStatusBooked}}})
+	if slotIndexCurrent(stale) {
+		t.Fatalf("expected index with other statuses to be rebuilt")
+	}
+	if slotIndexCurrent(nil) {
+		t.Fatalf("expected index without partial filter to be rebuilt")
+	}
+}
@@ -10,15 +10,33 @@ import (
 )
 
 type Collections struct {
-	Services            *mongo.Collection
-	ServiceTestimonials *mongo.Collection
-	Appointments        *mongo.Collection
-	ContactMessages     *mongo.Collection
-	ReservationBlocks   *mongo.Collection
-	Users               *mongo.Collection
-	RFPLeads            *mongo.Collection
-	References          *mongo.Collection
-	CaseStudies         *mongo.Collection
+	Services             *mongo.Collection
+	ServiceTestimonials  *mongo.Collection
+	Appointments         *mongo.Collection
+	ContactMessages      *mongo.Collection
+	ReservationBlocks    *mongo.Collection
+	Users                *mongo.Collection
+	RFPLeads             *mongo.Collection
+	References           *mongo.Collection
+	CaseStudies          *mongo.Collection
+	EmailEvents          *mongo.Collection
+	EmailSuppressions    *mongo.Collection
+	AdminDigests         *mongo.Collection
+	ExternalBusy         *mongo.Collection
+	CalDAVStates         *mongo.Collection
+	CalDAVConflicts      *mongo.Collection
+	AdminSessions        *mongo.Collection
+	Roles                *mongo.Collection
+	APIKeys              *mongo.Collection
+	PasswordResets       *mongo.Collection
+	LoginHistory         *mongo.Collection
+	Invitations          *mongo.Collection
+	Waitlist             *mongo.Collection
+	GroupSessions        *mongo.Collection
+	SessionRegistrations *mongo.Collection
+	AppointmentSeries    *mongo.Collection
+	IntakeFiles          *mongo.Collection
+	Locations            *mongo.Collection
 }
 
 func Connect(ctx context.Context, uri, dbName string) (*mongo.Client, *Collections, error) {
@@ -34,15 +52,33 @@ func Connect(ctx context.Context, uri, dbName string) (*mongo.Client, *Collectio
 	db := client.Database(dbName)
 
 	cols := &Collections{
-		Services:            db.Collection("services"),
-		ServiceTestimonials: db.Collection("service_testimonials"),
-		Appointments:        db.Collection("appointments"),
-		ContactMessages:     db.Collection("contact_messages"),
-		ReservationBlocks:   db.Collection("reservation_blocks"),
-		Users:               db.Collection("users"),
-		RFPLeads:            db.Collection("rfp_leads"),
-		References:          db.Collection("references"),
-		CaseStudies:         db.Collection("case_studies"),
+		Services:             db.Collection("services"),
+		ServiceTestimonials:  db.Collection("service_testimonials"),
+		Appointments:         db.Collection("appointments"),
+		ContactMessages:      db.Collection("contact_messages"),
+		ReservationBlocks:    db.Collection("reservation_blocks"),
+		Users:                db.Collection("users"),
+		RFPLeads:             db.Collection("rfp_leads"),
+		References:           db.Collection("references"),
+		CaseStudies:          db.Collection("case_studies"),
+		EmailEvents:          db.Collection("email_events"),
+		EmailSuppressions:    db.Collection("email_suppressions"),
+		AdminDigests:         db.Collection("admin_digests"),
+		ExternalBusy:         db.Collection("external_busy"),
+		CalDAVStates:         db.Collection("caldav_states"),
+		CalDAVConflicts:      db.Collection("caldav_conflicts"),
+		AdminSessions:        db.Collection("admin_sessions"),
+		Roles:                db.Collection("roles"),
+		APIKeys:              db.Collection("api_keys"),
+		PasswordResets:       db.Collection("password_resets"),
+		LoginHistory:         db.Collection("login_history"),
+		Invitations:          db.Collection("invitations"),
+		Waitlist:             db.Collection("waitlist"),
+		GroupSessions:        db.Collection("group_sessions"),
+		SessionRegistrations: db.Collection("session_registrations"),
+		AppointmentSeries:    db.Collection("appointment_series"),
+		IntakeFiles:          db.Collection("intake_files"),
+		Locations:            db.Collection("locations"),
 	}
 
 	return client, cols, nil
@@ -62,13 +98,34 @@ func EnsureIndexes(ctx context.Context, cols *Collections) error {
 		return err
 	}
 
+	if err := migrateAppointmentSlots(indexTimeout, cols); err != nil {
+		return err
+	}
 	_, err = cols.Appointments.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		appointmentSlotIndexModel(),
 		{
-			Keys:    bson.D{{Key: "date", Value: 1}, {Key: "time", Value: 1}},
-			Options: options.Index().SetUnique(true),
+			Keys: bson.D{{Key: "date", Value: 1}},
 		},
+		// Admin search filters and sorts.
 		{
-			Keys: bson.D{{Key: "date", Value: 1}},
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "date", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "serviceId", Value: 1}, {Key: "date", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "email", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys:    bson.D{{Key: "seriesId", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "location.id", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetSparse(true),
 		},
 	})
 	if err != nil {
@@ -102,6 +159,10 @@ func EnsureIndexes(ctx context.Context, cols *Collections) error {
 			Keys:    bson.D{{Key: "email", Value: 1}},
 			Options: options.Index().SetUnique(true).SetSparse(true),
 		},
+		{
+			Keys:    bson.D{{Key: "calendarFeedTokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
 	})
 	if err != nil {
 		return err
@@ -141,5 +202,188 @@ func EnsureIndexes(ctx context.Context, cols *Collections) error {
 		return err
 	}
 
+	_, err = cols.EmailEvents.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "message_id", Value: 1}, {Key: "occurred_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "email", Value: 1}, {Key: "occurred_at", Value: -1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.ExternalBusy.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "date", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "source", Value: 1}, {Key: "href", Value: 1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.CalDAVConflicts.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "detected_at", Value: -1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.APIKeys.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "keyHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.AdminSessions.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "lastUsedAt", Value: -1}},
+		},
+		{
+			// Expired sessions are purged by Mongo; revoked ones linger until
+			// their natural expiry so reuse can still be traced.
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.PasswordResets.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.LoginHistory.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "ip", Value: 1}, {Key: "success", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			// Login history is kept for 90 days.
+			Keys:    bson.D{{Key: "createdAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(90 * 24 * 60 * 60),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.Invitations.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			// One pending invitation per address.
+			Keys: bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": "pending"}),
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.Waitlist.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			// Offers go to the oldest matching entries first.
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "from", Value: 1}, {Key: "createdAt", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "email", Value: 1}, {Key: "serviceId", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "offer.tokenHash", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.GroupSessions.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "date", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "serviceId", Value: 1}, {Key: "date", Value: 1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.SessionRegistrations.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			// Waitlisted registrations are promoted in arrival order.
+			Keys: bson.D{{Key: "sessionId", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: 1}},
+		},
+		{
+			// One active registration per person and session.
+			Keys: bson.D{{Key: "sessionId", Value: 1}, {Key: "email", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": bson.M{"$in": []string{"confirmed", "waitlisted"}}}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.IntakeFiles.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// Uploads never attached to an appointment are purged; attaching
+			// one unsets expiresAt.
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cols.Locations.Indexes().CreateMany(indexTimeout, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "active", Value: 1}, {Key: "name", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "rooms.id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
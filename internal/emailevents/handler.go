@@ -0,0 +1,224 @@
+package emailevents
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/httpx"
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxWebhookBodyBytes = 1 << 20
+
+type Handler struct {
+	service       *Service
+	log           *slog.Logger
+	webhookSecret string
+}
+
+func NewHandler(service *Service, log *slog.Logger, webhookSecret string) *Handler {
+	return &Handler{
+		service:       service,
+		log:           log,
+		webhookSecret: webhookSecret,
+	}
+}
+
+// BrevoWebhook receives transactional email events. Brevo may post a single
+// event object or a batch (array) of events.
+func (h *Handler) BrevoWebhook(w http.ResponseWriter, r *http.Request) {
+	log := h.logWithRequest(r)
+	if h.webhookSecret == "" {
+		log.Warn("brevo webhook: not configured")
+		transport.WriteError(w, http.StatusServiceUnavailable, "webhook not configured", nil)
+		return
+	}
+	if !h.authorized(r) {
+		log.Warn("brevo webhook: unauthorized")
+		transport.WriteError(w, http.StatusUnauthorized, "unauthorized", nil)
+		return
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		log.Warn("brevo webhook: read error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, "invalid body", nil)
+		return
+	}
+	payloads, err := decodeWebhookPayloads(raw)
+	if err != nil {
+		log.Warn("brevo webhook: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	recorded := 0
+	for _, payload := range payloads {
+		if _, err := h.service.Record(ctx, payload); err != nil {
+			if errors.Is(err, ErrInvalidEvent) || errors.Is(err, ErrMissingEmail) {
+				// Unknown or incomplete events are acknowledged so Brevo does not retry them.
+				log.Warn("brevo webhook: skipped event", slog.String("event", payload.Event), slog.String("error", err.Error()))
+				continue
+			}
+			log.Error("brevo webhook: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+			return
+		}
+		recorded++
+	}
+
+	log.Info("brevo webhook: ok", slog.Int("received", len(payloads)), slog.Int("recorded", recorded))
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"status":   "ok",
+		"recorded": recorded,
+	})
+}
+
+func (h *Handler) AdminListEvents(w http.ResponseWriter, r *http.Request) {
+	log := h.logWithRequest(r)
+	limit, offset, err := httpx.ParseLimitOffset(r.URL.Query(), 50, 200)
+	if err != nil {
+		log.Warn("admin email events list: invalid query", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	filter := ListFilter{
+		MessageID: r.URL.Query().Get("messageId"),
+		Email:     r.URL.Query().Get("email"),
+		Event:     r.URL.Query().Get("event"),
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	items, total, err := h.service.ListEvents(ctx, filter, limit, offset)
+	if err != nil {
+		if errors.Is(err, ErrInvalidEvent) {
+			transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"event": "oneof"})
+			return
+		}
+		log.Error("admin email events list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin email events list: ok", slog.Int("count", len(items)))
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+	})
+}
+
+func (h *Handler) AdminListSuppressions(w http.ResponseWriter, r *http.Request) {
+	log := h.logWithRequest(r)
+	limit, offset, err := httpx.ParseLimitOffset(r.URL.Query(), 50, 200)
+	if err != nil {
+		log.Warn("admin email suppressions list: invalid query", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	items, total, err := h.service.ListSuppressions(ctx, limit, offset)
+	if err != nil {
+		log.Error("admin email suppressions list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin email suppressions list: ok", slog.Int("count", len(items)))
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+	})
+}
+
+func (h *Handler) AdminDeleteSuppression(w http.ResponseWriter, r *http.Request) {
+	log := h.logWithRequest(r)
+	email := strings.TrimSpace(chi.URLParam(r, "email"))
+	if email == "" {
+		log.Warn("admin email suppressions delete: missing email")
+		transport.WriteError(w, http.StatusBadRequest, "missing email", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.DeleteSuppression(ctx, email); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			log.Warn("admin email suppressions delete: not found", slog.String("email", email))
+			transport.WriteError(w, http.StatusNotFound, "suppression not found", nil)
+			return
+		}
+		log.Error("admin email suppressions delete: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin email suppressions delete: ok", slog.String("email", email))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// authorized accepts the shared secret either as a "token" query parameter
+// (the form Brevo's webhook URL supports) or as an X-Webhook-Token header.
+func (h *Handler) authorized(r *http.Request) bool {
+	token := strings.TrimSpace(r.Header.Get("X-Webhook-Token"))
+	if token == "" {
+		token = strings.TrimSpace(r.URL.Query().Get("token"))
+	}
+	if token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.webhookSecret)) == 1
+}
+
+func decodeWebhookPayloads(raw []byte) ([]BrevoWebhookPayload, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, errors.New("empty body")
+	}
+	if raw[0] == '[' {
+		var batch []BrevoWebhookPayload
+		if err := json.Unmarshal(raw, &batch); err != nil {
+			return nil, err
+		}
+		return batch, nil
+	}
+	var single BrevoWebhookPayload
+	if err := json.Unmarshal(raw, &single); err != nil {
+		return nil, err
+	}
+	return []BrevoWebhookPayload{single}, nil
+}
+
+func (h *Handler) logWithRequest(r *http.Request) *slog.Logger {
+	if r == nil {
+		return h.log
+	}
+	if id := middleware.RequestIDFromContext(r.Context()); id != "" {
+		return h.log.With(slog.String("request_id", id))
+	}
+	return h.log
+}
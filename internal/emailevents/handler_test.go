@@ -0,0 +1,99 @@
+package emailevents
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHandler(repo *fakeRepository) *Handler {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewHandler(NewService(repo, time.UTC), log, "s3cret")
+}
+
+func TestBrevoWebhookAuthorization(t *testing.T) {
+	h := newTestHandler(newFakeRepository())
+	body := `{"event":"delivered","email":"jean@example.com"}`
+
+	cases := []struct {
+		name   string
+		target string
+		header string
+		want   int
+	}{
+		{name: "header", target: "/webhooks/brevo", header: "s3cret", want: http.StatusOK},
+		{name: "query", target: "/webhooks/brevo?token=s3cret", want: http.StatusOK},
+		{name: "missing", target: "/webhooks/brevo", want: http.StatusUnauthorized},
+		{name: "wrong header", target: "/webhooks/brevo?token=s3cret", header: "nope", want: http.StatusUnauthorized},
+		{name: "wrong query", target: "/webhooks/brevo?token=nope", want: http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(body))
+		if tc.header != "" {
+			req.Header.Set("X-Webhook-Token", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		h.BrevoWebhook(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s: status %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+
+	unconfigured := NewHandler(h.service, h.log, "")
+	rec := httptest.NewRecorder()
+	unconfigured.BrevoWebhook(rec, httptest.NewRequest(http.MethodPost, "/webhooks/brevo?token=", strings.NewReader(body)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("without a secret: status %d, want 503", rec.Code)
+	}
+}
+
+func TestBrevoWebhookBatch(t *testing.T) {
+	repo := newFakeRepository()
+	h := newTestHandler(repo)
+	body := `[
+		{"event":"delivered","email":"amina@example.com","message-id":"<m1>"},
+		{"event":"hard_bounce","email":"jean@example.com","message-id":"<m2>"},
+		{"event":"not_an_event","email":"jean@example.com"},
+		{"event":"opened"}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/brevo?token=s3cret", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.BrevoWebhook(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Recorded int `json:"recorded"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// Unknown and incomplete events are acknowledged but not stored.
+	if resp.Recorded != 2 || len(repo.events) != 2 {
+		t.Fatalf("recorded = %d, events = %+v", resp.Recorded, repo.events)
+	}
+	if _, ok := repo.suppressions["jean@example.com"]; !ok {
+		t.Fatalf("expected the hard bounce to suppress the address")
+	}
+}
+
+func TestDecodeWebhookPayloads(t *testing.T) {
+	single, err := decodeWebhookPayloads([]byte(` {"event":"delivered","email":"a@example.com"} `))
+	if err != nil || len(single) != 1 || single[0].Email != "a@example.com" {
+		t.Fatalf("single: %+v, %v", single, err)
+	}
+	batch, err := decodeWebhookPayloads([]byte(`[{"event":"delivered"},{"event":"opened"}]`))
+	if err != nil || len(batch) != 2 || batch[1].Event != "opened" {
+		t.Fatalf("batch: %+v, %v", batch, err)
+	}
+	for _, raw := range []string{"", "   ", "[", `{"event":`} {
+		if _, err := decodeWebhookPayloads([]byte(raw)); err == nil {
+			t.Fatalf("decodeWebhookPayloads(%q): expected an error", raw)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package emailevents
+
+import "time"
+
+const (
+	EventRequest      = "request"
+	EventDelivered    = "delivered"
+	EventOpened       = "opened"
+	EventUniqueOpened = "unique_opened"
+	EventClick        = "click"
+	EventDeferred     = "deferred"
+	EventSoftBounce   = "soft_bounce"
+	EventHardBounce   = "hard_bounce"
+	EventInvalidEmail = "invalid_email"
+	EventBlocked      = "blocked"
+	EventSpam         = "spam"
+	EventUnsubscribed = "unsubscribed"
+	EventError        = "error"
+)
+
+var validEvents = map[string]struct{}{
+	EventRequest:      {},
+	EventDelivered:    {},
+	EventOpened:       {},
+	EventUniqueOpened: {},
+	EventClick:        {},
+	EventDeferred:     {},
+	EventSoftBounce:   {},
+	EventHardBounce:   {},
+	EventInvalidEmail: {},
+	EventBlocked:      {},
+	EventSpam:         {},
+	EventUnsubscribed: {},
+	EventError:        {},
+}
+
+// suppressingEvents are the events after which we stop sending to the address.
+var suppressingEvents = map[string]struct{}{
+	EventHardBounce:   {},
+	EventInvalidEmail: {},
+	EventSpam:         {},
+	EventUnsubscribed: {},
+}
+
+func IsValidEvent(value string) bool {
+	_, ok := validEvents[value]
+	return ok
+}
+
+func IsSuppressingEvent(value string) bool {
+	_, ok := suppressingEvents[value]
+	return ok
+}
+
+type Event struct {
+	ID         string    `bson:"_id,omitempty" json:"id"`
+	MessageID  string    `bson:"message_id" json:"message_id"`
+	Event      string    `bson:"event" json:"event"`
+	Email      string    `bson:"email" json:"email"`
+	Subject    string    `bson:"subject,omitempty" json:"subject,omitempty"`
+	Reason     string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	Tag        string    `bson:"tag,omitempty" json:"tag,omitempty"`
+	OccurredAt time.Time `bson:"occurred_at" json:"occurred_at"`
+	CreatedAt  time.Time `bson:"created_at" json:"created_at"`
+}
+
+// Suppression is keyed by the lowercased email address.
+type Suppression struct {
+	Email     string    `bson:"_id" json:"email"`
+	Event     string    `bson:"event" json:"event"`
+	Reason    string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	MessageID string    `bson:"message_id,omitempty" json:"message_id,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// BrevoWebhookPayload is the body Brevo posts for transactional email events.
+type BrevoWebhookPayload struct {
+	Event     string `json:"event"`
+	Email     string `json:"email"`
+	MessageID string `json:"message-id"`
+	Subject   string `json:"subject"`
+	Reason    string `json:"reason"`
+	Tag       string `json:"tag"`
+	Date      string `json:"date"`
+	TsEvent   int64  `json:"ts_event"`
+	TsEpoch   int64  `json:"ts_epoch"`
+}
+
+type ListFilter struct {
+	MessageID string
+	Email     string
+	Event     string
+}
@@ -0,0 +1,182 @@
+package emailevents
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	CreateEvent(ctx context.Context, event Event) error
+	ListEvents(ctx context.Context, filter ListFilter, limit, offset int64) ([]Event, error)
+	CountEvents(ctx context.Context, filter ListFilter) (int64, error)
+	UpsertSuppression(ctx context.Context, suppression Suppression) error
+	IsSuppressed(ctx context.Context, email string) (bool, error)
+	ListSuppressions(ctx context.Context, limit, offset int64) ([]Suppression, error)
+	CountSuppressions(ctx context.Context) (int64, error)
+	DeleteSuppression(ctx context.Context, email string) (bool, error)
+	FlagBounced(ctx context.Context, email string, at time.Time) error
+}
+
+// BounceTarget is a collection whose documents carry an email address and
+// should be flagged when that address hard-bounces.
+type BounceTarget struct {
+	Col        *mongo.Collection
+	EmailField string
+	FlagField  string
+}
+
+type MongoRepository struct {
+	events       *mongo.Collection
+	suppressions *mongo.Collection
+	targets      []BounceTarget
+}
+
+func NewRepository(events, suppressions *mongo.Collection, targets ...BounceTarget) *MongoRepository {
+	return &MongoRepository{
+		events:       events,
+		suppressions: suppressions,
+		targets:      targets,
+	}
+}
+
+// CreateEvent stores event unless one with the same ID is already there, so
+// retried webhooks do not duplicate it.
+func (r *MongoRepository) CreateEvent(ctx context.Context, event Event) error {
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"message_id":  event.MessageID,
+			"event":       event.Event,
+			"email":       event.Email,
+			"subject":     event.Subject,
+			"reason":      event.Reason,
+			"tag":         event.Tag,
+			"occurred_at": event.OccurredAt,
+			"created_at":  event.CreatedAt,
+		},
+	}
+	_, err := r.events.UpdateOne(ctx, bson.M{"_id": event.ID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *MongoRepository) ListEvents(ctx context.Context, filter ListFilter, limit, offset int64) ([]Event, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "occurred_at", Value: -1}}).
+		SetLimit(limit).
+		SetSkip(offset)
+
+	cursor, err := r.events.Find(ctx, r.filterToBSON(filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]Event, 0)
+	for cursor.Next(ctx) {
+		var item Event
+		if err := cursor.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *MongoRepository) CountEvents(ctx context.Context, filter ListFilter) (int64, error) {
+	return r.events.CountDocuments(ctx, r.filterToBSON(filter))
+}
+
+func (r *MongoRepository) UpsertSuppression(ctx context.Context, suppression Suppression) error {
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"event":      suppression.Event,
+			"reason":     suppression.Reason,
+			"message_id": suppression.MessageID,
+			"created_at": suppression.CreatedAt,
+		},
+	}
+	_, err := r.suppressions.UpdateOne(ctx, bson.M{"_id": suppression.Email}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *MongoRepository) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	count, err := r.suppressions.CountDocuments(ctx, bson.M{"_id": email}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *MongoRepository) ListSuppressions(ctx context.Context, limit, offset int64) ([]Suppression, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(limit).
+		SetSkip(offset)
+
+	cursor, err := r.suppressions.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := make([]Suppression, 0)
+	for cursor.Next(ctx) {
+		var item Suppression
+		if err := cursor.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *MongoRepository) CountSuppressions(ctx context.Context) (int64, error) {
+	return r.suppressions.CountDocuments(ctx, bson.M{})
+}
+
+func (r *MongoRepository) DeleteSuppression(ctx context.Context, email string) (bool, error) {
+	res, err := r.suppressions.DeleteOne(ctx, bson.M{"_id": email})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+func (r *MongoRepository) FlagBounced(ctx context.Context, email string, at time.Time) error {
+	// Stored addresses are not always lowercased, so match case-insensitively.
+	opts := options.Update().SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	for _, target := range r.targets {
+		if target.Col == nil {
+			continue
+		}
+		filter := bson.M{target.EmailField: email}
+		update := bson.M{"$set": bson.M{target.FlagField: at}}
+		if _, err := target.Col.UpdateMany(ctx, filter, update, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MongoRepository) filterToBSON(filter ListFilter) bson.M {
+	query := bson.M{}
+	if filter.MessageID != "" {
+		query["message_id"] = filter.MessageID
+	}
+	if filter.Email != "" {
+		query["email"] = filter.Email
+	}
+	if filter.Event != "" {
+		query["event"] = filter.Event
+	}
+	return query
+}
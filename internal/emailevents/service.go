@@ -0,0 +1,172 @@
+package emailevents
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidEvent = errors.New("invalid event")
+	ErrMissingEmail = errors.New("missing email")
+	ErrNotFound     = errors.New("suppression not found")
+)
+
+type Service struct {
+	repo     Repository
+	location *time.Location
+}
+
+func NewService(repo Repository, location *time.Location) *Service {
+	return &Service{
+		repo:     repo,
+		location: location,
+	}
+}
+
+// Record stores a webhook event and, for hard failures, suppresses the address
+// and flags the appointments, leads and users that use it.
+func (s *Service) Record(ctx context.Context, payload BrevoWebhookPayload) (Event, error) {
+	eventType := strings.ToLower(strings.TrimSpace(payload.Event))
+	if !IsValidEvent(eventType) {
+		return Event{}, ErrInvalidEvent
+	}
+	email := normalizeEmail(payload.Email)
+	if email == "" {
+		return Event{}, ErrMissingEmail
+	}
+
+	now := time.Now().In(s.location)
+	event := Event{
+		ID:         eventID(payload, eventType, email),
+		MessageID:  strings.TrimSpace(payload.MessageID),
+		Event:      eventType,
+		Email:      email,
+		Subject:    strings.TrimSpace(payload.Subject),
+		Reason:     strings.TrimSpace(payload.Reason),
+		Tag:        strings.TrimSpace(payload.Tag),
+		OccurredAt: s.occurredAt(payload, now),
+		CreatedAt:  now,
+	}
+
+	if err := s.repo.CreateEvent(ctx, event); err != nil {
+		return Event{}, err
+	}
+
+	if IsSuppressingEvent(eventType) {
+		suppression := Suppression{
+			Email:     email,
+			Event:     eventType,
+			Reason:    event.Reason,
+			MessageID: event.MessageID,
+			CreatedAt: now,
+		}
+		if err := s.repo.UpsertSuppression(ctx, suppression); err != nil {
+			return event, err
+		}
+	}
+
+	if eventType == EventHardBounce || eventType == EventInvalidEmail {
+		if err := s.repo.FlagBounced(ctx, email, event.OccurredAt); err != nil {
+			return event, err
+		}
+	}
+
+	return event, nil
+}
+
+// IsSuppressed reports whether sends to email must be blocked.
+func (s *Service) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	email = normalizeEmail(email)
+	if email == "" {
+		return false, nil
+	}
+	return s.repo.IsSuppressed(ctx, email)
+}
+
+func (s *Service) ListEvents(ctx context.Context, filter ListFilter, limit, offset int64) ([]Event, int64, error) {
+	filter.MessageID = strings.TrimSpace(filter.MessageID)
+	filter.Email = normalizeEmail(filter.Email)
+	filter.Event = strings.ToLower(strings.TrimSpace(filter.Event))
+	if filter.Event != "" && !IsValidEvent(filter.Event) {
+		return nil, 0, ErrInvalidEvent
+	}
+
+	items, err := s.repo.ListEvents(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.repo.CountEvents(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (s *Service) ListSuppressions(ctx context.Context, limit, offset int64) ([]Suppression, int64, error) {
+	items, err := s.repo.ListSuppressions(ctx, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.repo.CountSuppressions(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (s *Service) DeleteSuppression(ctx context.Context, email string) error {
+	deleted, err := s.repo.DeleteSuppression(ctx, normalizeEmail(email))
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Service) occurredAt(payload BrevoWebhookPayload, fallback time.Time) time.Time {
+	switch {
+	case payload.TsEpoch > 0:
+		return time.UnixMilli(payload.TsEpoch).In(s.location)
+	case payload.TsEvent > 0:
+		return time.Unix(payload.TsEvent, 0).In(s.location)
+	}
+	if date := strings.TrimSpace(payload.Date); date != "" {
+		if parsed, err := time.ParseInLocation("2006-01-02 15:04:05", date, s.location); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// eventID identifies a webhook event by its message, type, recipient and
+// timestamp, so a delivery Brevo retries is stored only once. Events without
+// a message ID cannot be told apart and get a random ID.
+func eventID(payload BrevoWebhookPayload, eventType, email string) string {
+	messageID := strings.TrimSpace(payload.MessageID)
+	if messageID == "" {
+		return primitive.NewObjectID().Hex()
+	}
+	key := strings.Join([]string{
+		messageID,
+		eventType,
+		email,
+		strconv.FormatInt(payload.TsEpoch, 10),
+		strconv.FormatInt(payload.TsEvent, 10),
+		strings.TrimSpace(payload.Date),
+	}, "|")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
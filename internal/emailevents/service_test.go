@@ -0,0 +1,217 @@
+package emailevents
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRepository keeps events and suppressions in memory.
+type fakeRepository struct {
+	events       []Event
+	suppressions map[string]Suppression
+	bounced      map[string]time.Time
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{suppressions: map[string]Suppression{}, bounced: map[string]time.Time{}}
+}
+
+// CreateEvent keeps the first event of an ID, as the Mongo repository does
+// with $setOnInsert.
+func (r *fakeRepository) CreateEvent(ctx context.Context, event Event) error {
+	for _, existing := range r.events {
+		if existing.ID == event.ID {
+			return nil
+		}
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *fakeRepository) ListEvents(ctx context.Context, filter ListFilter, limit, offset int64) ([]Event, error) {
+	return r.events, nil
+}
+
+func (r *fakeRepository) CountEvents(ctx context.Context, filter ListFilter) (int64, error) {
+	return int64(len(r.events)), nil
+}
+
+// UpsertSuppression keeps the first suppression of an address, as the
+// Mongo repository does with $setOnInsert.
+func (r *fakeRepository) UpsertSuppression(ctx context.Context, suppression Suppression) error {
+	if _, ok := r.suppressions[suppression.Email]; !ok {
+		r.suppressions[suppression.Email] = suppression
+	}
+	return nil
+}
+
+func (r *fakeRepository) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	_, ok := r.suppressions[email]
+	return ok, nil
+}
+
+func (r *fakeRepository) ListSuppressions(ctx context.Context, limit, offset int64) ([]Suppression, error) {
+	items := make([]Suppression, 0, len(r.suppressions))
+	for _, item := range r.suppressions {
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (r *fakeRepository) CountSuppressions(ctx context.Context) (int64, error) {
+	return int64(len(r.suppressions)), nil
+}
+
+func (r *fakeRepository) DeleteSuppression(ctx context.Context, email string) (bool, error) {
+	_, ok := r.suppressions[email]
+	delete(r.suppressions, email)
+	return ok, nil
+}
+
+func (r *fakeRepository) FlagBounced(ctx context.Context, email string, at time.Time) error {
+	r.bounced[email] = at
+	return nil
+}
+
+func TestRecordHardBounceSuppressesAddress(t *testing.T) {
+	repo := newFakeRepository()
+	service := NewService(repo, time.UTC)
+	ctx := context.Background()
+
+	event, err := service.Record(ctx, BrevoWebhookPayload{
+		Event:     "Hard_Bounce",
+		Email:     " Jean@Example.com ",
+		MessageID: "<msg-1>",
+		Reason:    "mailbox does not exist",
+		TsEpoch:   1767261600000,
+	})
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if event.Event != EventHardBounce || event.Email != "jean@example.com" {
+		t.Fatalf("Record() = %+v", event)
+	}
+	if want := time.UnixMilli(1767261600000).UTC(); !event.OccurredAt.Equal(want) {
+		t.Fatalf("OccurredAt = %v, want %v", event.OccurredAt, want)
+	}
+	suppression, ok := repo.suppressions["jean@example.com"]
+	if !ok || suppression.Event != EventHardBounce || suppression.MessageID != "<msg-1>" {
+		t.Fatalf("suppressions = %+v", repo.suppressions)
+	}
+	if _, ok := repo.bounced["jean@example.com"]; !ok {
+		t.Fatalf("expected the address to be flagged as bounced")
+	}
+	if suppressed, _ := service.IsSuppressed(ctx, "JEAN@example.com"); !suppressed {
+		t.Fatalf("expected IsSuppressed() to ignore case")
+	}
+
+	// A later event keeps the original suppression.
+	if _, err := service.Record(ctx, BrevoWebhookPayload{Event: EventSpam, Email: "jean@example.com"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if got := repo.suppressions["jean@example.com"].Event; got != EventHardBounce {
+		t.Fatalf("suppression event = %q, want %q", got, EventHardBounce)
+	}
+}
+
+func TestRecordOnlySuppressesHardFailures(t *testing.T) {
+	repo := newFakeRepository()
+	service := NewService(repo, time.UTC)
+	ctx := context.Background()
+
+	for _, event := range []string{EventDelivered, EventSoftBounce, EventOpened} {
+		if _, err := service.Record(ctx, BrevoWebhookPayload{Event: event, Email: "amina@example.com"}); err != nil {
+			t.Fatalf("Record(%s) error = %v", event, err)
+		}
+	}
+	if len(repo.suppressions) != 0 || len(repo.bounced) != 0 {
+		t.Fatalf("suppressions = %+v, bounced = %+v", repo.suppressions, repo.bounced)
+	}
+	if _, err := service.Record(ctx, BrevoWebhookPayload{Event: EventUnsubscribed, Email: "amina@example.com"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if _, ok := repo.suppressions["amina@example.com"]; !ok {
+		t.Fatalf("expected an unsubscribe to suppress the address")
+	}
+	if len(repo.bounced) != 0 {
+		t.Fatalf("an unsubscribe is not a bounce, got %+v", repo.bounced)
+	}
+}
+
+func TestRecordStoresRetriedEventsOnce(t *testing.T) {
+	repo := newFakeRepository()
+	service := NewService(repo, time.UTC)
+	ctx := context.Background()
+	payload := BrevoWebhookPayload{Event: EventDelivered, Email: "amina@example.com", MessageID: "<msg-1>", TsEpoch: 1767261600000}
+
+	first, err := service.Record(ctx, payload)
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	// Brevo retries the same delivery, with the email cased differently.
+	payload.Email = "Amina@Example.com"
+	retried, err := service.Record(ctx, payload)
+	if err != nil {
+		t.Fatalf("Record() retry error = %v", err)
+	}
+	if retried.ID != first.ID || len(repo.events) != 1 {
+		t.Fatalf("retry: ID %q, first %q, events = %+v", retried.ID, first.ID, repo.events)
+	}
+
+	// The same message opened later is a new event.
+	opened := payload
+	opened.Event = EventOpened
+	if _, err := service.Record(ctx, opened); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	payload.TsEpoch += 1000
+	if _, err := service.Record(ctx, payload); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if len(repo.events) != 3 {
+		t.Fatalf("events = %+v, want 3", repo.events)
+	}
+}
+
+func TestRecordRejectsInvalidPayloads(t *testing.T) {
+	service := NewService(newFakeRepository(), time.UTC)
+	if _, err := service.Record(context.Background(), BrevoWebhookPayload{Event: "bounced", Email: "a@example.com"}); err != ErrInvalidEvent {
+		t.Fatalf("unknown event: error = %v, want ErrInvalidEvent", err)
+	}
+	if _, err := service.Record(context.Background(), BrevoWebhookPayload{Event: EventDelivered, Email: "  "}); err != ErrMissingEmail {
+		t.Fatalf("missing email: error = %v, want ErrMissingEmail", err)
+	}
+}
+
+func TestDeleteSuppression(t *testing.T) {
+	repo := newFakeRepository()
+	service := NewService(repo, time.UTC)
+	ctx := context.Background()
+	repo.suppressions["jean@example.com"] = Suppression{Email: "jean@example.com", Event: EventHardBounce}
+
+	if err := service.DeleteSuppression(ctx, "Jean@Example.com"); err != nil {
+		t.Fatalf("DeleteSuppression() error = %v", err)
+	}
+	if err := service.DeleteSuppression(ctx, "jean@example.com"); err != ErrNotFound {
+		t.Fatalf("DeleteSuppression() twice: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestOccurredAtFallsBackToDate(t *testing.T) {
+	loc := time.FixedZone("WAT", 3600)
+	service := NewService(newFakeRepository(), loc)
+	fallback := time.Date(2026, 1, 2, 0, 0, 0, 0, loc)
+
+	got := service.occurredAt(BrevoWebhookPayload{Date: "2026-01-01 10:30:00"}, fallback)
+	if want := time.Date(2026, 1, 1, 10, 30, 0, 0, loc); !got.Equal(want) {
+		t.Fatalf("occurredAt(date) = %v, want %v", got, want)
+	}
+	if got := service.occurredAt(BrevoWebhookPayload{TsEvent: 1767261600}, fallback); got.Unix() != 1767261600 {
+		t.Fatalf("occurredAt(ts_event) = %v", got)
+	}
+	if got := service.occurredAt(BrevoWebhookPayload{Date: strings.Repeat("x", 4)}, fallback); !got.Equal(fallback) {
+		t.Fatalf("occurredAt(invalid) = %v, want the fallback", got)
+	}
+}
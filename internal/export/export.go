@@ -0,0 +1,235 @@
+// Package export writes tabular data as CSV or XLSX spreadsheets.
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ContentType returns the MIME type of format.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Table is a header row followed by data rows. Cells may be strings,
+// integers, floats or times; anything else is formatted with %v.
+type Table struct {
+	Header []string
+	Rows   [][]interface{}
+}
+
+// Write writes t in format to w.
+func Write(w io.Writer, format string, t Table) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, t)
+	case FormatXLSX:
+		return WriteXLSX(w, "Export", t)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// WriteCSV writes t as CSV with a UTF-8 BOM so spreadsheet tools detect the
+// encoding of accented names.
+func WriteCSV(w io.Writer, t Table) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Header); err != nil {
+		return err
+	}
+	record := make([]string, 0, len(t.Header))
+	for _, row := range t.Rows {
+		record = record[:0]
+		for _, cell := range row {
+			record = append(record, csvCell(cell))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvCell(cell interface{}) string {
+	value := formatCell(cell)
+	if _, ok := cell.(string); ok && formulaLike(value) {
+		// Keep spreadsheet tools from evaluating customer input.
+		return "'" + value
+	}
+	return value
+}
+
+// formulaLike reports whether a spreadsheet would read value as a formula.
+// Phone numbers such as "+243 81..." are left alone.
+func formulaLike(value string) bool {
+	if value == "" {
+		return false
+	}
+	switch value[0] {
+	case '=', '@', '\t', '\r':
+		return true
+	case '+', '-':
+		for _, r := range value[1:] {
+			if !strings.ContainsRune("0123456789 ().-", r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// WriteXLSX writes t as a single-sheet workbook. Strings are stored inline,
+// so no shared string table is needed.
+func WriteXLSX(w io.Writer, sheet string, t Table) error {
+	zw := zip.NewWriter(w)
+	parts := []struct {
+		name string
+		body string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlEscape(sheet))},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	if err := writeSheet(f, t); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeSheet(w io.Writer, t Table) error {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	writeRow(&b, 1, stringsToCells(t.Header))
+	for i, row := range t.Rows {
+		writeRow(&b, i+2, row)
+		// Flush regularly so large exports are not held in memory twice.
+		if b.Len() > 64<<10 {
+			if _, err := io.WriteString(w, b.String()); err != nil {
+				return err
+			}
+			b.Reset()
+		}
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func stringsToCells(values []string) []interface{} {
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		cells[i] = v
+	}
+	return cells
+}
+
+func writeRow(b *strings.Builder, index int, cells []interface{}) {
+	fmt.Fprintf(b, `<row r="%d">`, index)
+	for col, cell := range cells {
+		ref := ColumnName(col) + strconv.Itoa(index)
+		switch v := cell.(type) {
+		case nil:
+			continue
+		case int, int64, float64:
+			fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, formatCell(v))
+		default:
+			value := formatCell(v)
+			if value == "" {
+				continue
+			}
+			fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(value))
+		}
+	}
+	b.WriteString(`</row>`)
+}
+
+// ColumnName returns the spreadsheet column letters for a zero-based index:
+// 0 is A, 25 is Z, 26 is AA.
+func ColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func xmlEscape(value string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+const xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
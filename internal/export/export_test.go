@@ -0,0 +1,90 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	table := Table{
+		Header: []string{"name", "phone", "total"},
+		Rows: [][]interface{}{
+			{"=HYPERLINK(\"x\")", "+243 81 000 0000", 25000},
+			{"Élodie", "-1+1", -5},
+		},
+	}
+	if err := WriteCSV(&buf, table); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\ufeff"))).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	want := [][]string{
+		{"name", "phone", "total"},
+		{"'=HYPERLINK(\"x\")", "+243 81 000 0000", "25000"},
+		{"Élodie", "'-1+1", "-5"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Fatalf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	table := Table{
+		Header: []string{"name", "total"},
+		Rows:   [][]interface{}{{"A & B", 1500}},
+	}
+	if err := WriteXLSX(&buf, "Rendez-vous", table); err != nil {
+		t.Fatalf("WriteXLSX() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("missing part %s", name)
+		}
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr"><is><t xml:space="preserve">name</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">A &amp; B</t></is></c>`,
+		`<c r="B2"><v>1500</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Fatalf("sheet missing %s:\n%s", want, sheet)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	cases := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"}
+	for index, want := range cases {
+		if got := ColumnName(index); got != want {
+			t.Fatalf("ColumnName(%d) = %q, want %q", index, got, want)
+		}
+	}
+}
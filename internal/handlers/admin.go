@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
+	"gbh-backend/internal/booking"
+	"gbh-backend/internal/middleware"
 	"gbh-backend/internal/models"
 	"gbh-backend/internal/schedule"
 	"gbh-backend/internal/transport"
@@ -25,6 +29,7 @@ type AdminServiceRequest struct {
 	Category         string   `json:"category" validate:"required"`
 	ForAudience      string   `json:"forAudience" validate:"required"`
 	Slug             string   `json:"slug"`
+	LocationIDs      []string `json:"locationIds" validate:"omitempty,max=20"`
 }
 
 type AdminBlockRequest struct {
@@ -34,11 +39,8 @@ type AdminBlockRequest struct {
 }
 
 type AdminStatusRequest struct {
-	Status string `json:"status" validate:"required,oneof=booked canceled"`
-}
-
-type AdminListQuery struct {
-	Date string `validate:"omitempty,date"`
+	Status string `json:"status" validate:"required"`
+	Reason string `json:"reason,omitempty" validate:"omitempty,max=500"`
 }
 
 func (s *Server) AdminCreateService(w http.ResponseWriter, r *http.Request) {
@@ -71,12 +73,25 @@ func (s *Server) AdminCreateService(w http.ResponseWriter, r *http.Request) {
 		ForAudience:      req.ForAudience,
 		Slug:             slug,
 		CreatedAt:        time.Now().In(s.Cfg.Timezone),
+		LocationIDs:      normalizeStringList(req.LocationIDs),
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	_, err := s.Cols.Services.InsertOne(ctx, service)
+	details, err := s.checkLocationIDs(ctx, service.LocationIDs)
+	if err != nil {
+		log.Error("admin services create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details != nil {
+		log.Warn("admin services create: unknown location")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	_, err = s.Cols.Services.InsertOne(ctx, service)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
 			log.Warn("admin services create: slug exists", slog.String("slug", slug))
@@ -132,12 +147,25 @@ func (s *Server) AdminUpdateService(w http.ResponseWriter, r *http.Request) {
 			"category":         req.Category,
 			"forAudience":      req.ForAudience,
 			"slug":             slug,
+			"locationIds":      normalizeStringList(req.LocationIDs),
 		},
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
+	details, err := s.checkLocationIDs(ctx, normalizeStringList(req.LocationIDs))
+	if err != nil {
+		log.Error("admin services update: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details != nil {
+		log.Warn("admin services update: unknown location")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
 	res, err := s.Cols.Services.UpdateOne(ctx, bson.M{"_id": id}, update)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
@@ -331,56 +359,13 @@ func (s *Server) AdminDeleteBlock(w http.ResponseWriter, r *http.Request) {
 		if s.Cache != nil {
 			_ = s.Cache.DeletePrefix(r.Context(), "availability:"+date+":")
 		}
+		s.offerFreedSlotsAsync(date, allCalendars)
 	}
 
 	log.Info("admin blocks delete: ok", slog.String("block_id", id))
 	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
-func (s *Server) AdminListAppointments(w http.ResponseWriter, r *http.Request) {
-	log := s.logWithRequest(r)
-	q := AdminListQuery{Date: r.URL.Query().Get("date")}
-	if err := s.Val.Struct(q); err != nil {
-		log.Warn("admin appointments list: invalid query")
-		details := validationDetails(s.Val.ValidationErrors(err))
-		transport.WriteError(w, http.StatusBadRequest, "invalid query", details)
-		return
-	}
-
-	filter := bson.M{}
-	if q.Date != "" {
-		filter["date"] = q.Date
-	}
-
-	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
-	defer cancel()
-
-	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "time", Value: 1}}).SetLimit(200)
-	cursor, err := s.Cols.Appointments.Find(ctx, filter, opts)
-	if err != nil {
-		log.Error("admin appointments list: database error", slog.String("error", err.Error()))
-		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	var items []map[string]interface{}
-	for cursor.Next(ctx) {
-		var doc bson.M
-		if err := cursor.Decode(&doc); err == nil {
-			items = append(items, normalizeID(doc))
-		}
-	}
-	if err := cursor.Err(); err != nil {
-		log.Error("admin appointments list: database error", slog.String("error", err.Error()))
-		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
-		return
-	}
-
-	log.Info("admin appointments list: ok", slog.Int("count", len(items)))
-	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{"appointments": items})
-}
-
 func (s *Server) AdminUpdateAppointmentStatus(w http.ResponseWriter, r *http.Request) {
 	log := s.logWithRequest(r)
 	id := chi.URLParam(r, "id")
@@ -406,8 +391,8 @@ func (s *Server) AdminUpdateAppointmentStatus(w http.ResponseWriter, r *http.Req
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	var doc bson.M
-	if err := s.Cols.Appointments.FindOne(ctx, bson.M{"_id": id}).Decode(&doc); err != nil {
+	var current models.Appointment
+	if err := s.Cols.Appointments.FindOne(ctx, bson.M{"_id": id}).Decode(&current); err != nil {
 		if err == mongo.ErrNoDocuments {
 			log.Warn("admin appointments status: not found", slog.String("appointment_id", id))
 			transport.WriteError(w, http.StatusNotFound, "appointment not found", nil)
@@ -418,22 +403,46 @@ func (s *Server) AdminUpdateAppointmentStatus(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	_, err := s.Cols.Appointments.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"status": req.Status}})
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	change := models.AppointmentStatusChange{
+		Actor:   principal.Username,
+		ActorID: principal.UserID,
+		Reason:  strings.TrimSpace(req.Reason),
+	}
+	appointment, err := s.transitionAppointment(ctx, current, req.Status, booking.ActorAdmin, change)
 	if err != nil {
-		log.Error("admin appointments status: database error", slog.String("error", err.Error()))
-		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		switch {
+		case errors.Is(err, booking.ErrUnknownStatus):
+			log.Warn("admin appointments status: unknown status", slog.String("status", req.Status))
+			transport.WriteError(w, http.StatusBadRequest, "unknown status", map[string]string{"status": "oneof"})
+		case errors.Is(err, booking.ErrInvalidTransition):
+			log.Warn("admin appointments status: invalid transition",
+				slog.String("appointment_id", id),
+				slog.String("from", current.Status),
+				slog.String("to", req.Status),
+			)
+			transport.WriteError(w, http.StatusConflict, "invalid status transition", map[string]string{
+				"from":    current.Status,
+				"allowed": strings.Join(booking.Next(current.Status), ","),
+			})
+		case errors.Is(err, errStatusChanged):
+			log.Warn("admin appointments status: concurrent change", slog.String("appointment_id", id))
+			transport.WriteError(w, http.StatusConflict, "appointment status changed", nil)
+		default:
+			log.Error("admin appointments status: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		}
 		return
 	}
 
-	if date, ok := doc["date"].(string); ok {
-		if s.Cache != nil {
-			_ = s.Cache.DeletePrefix(r.Context(), "availability:"+date+":")
-		}
-	}
+	s.afterAppointmentTransition(r.Context(), log, current, appointment)
 
-	doc["status"] = req.Status
-	log.Info("admin appointments status: ok", slog.String("appointment_id", id), slog.String("status", req.Status))
-	transport.WriteJSON(w, http.StatusOK, normalizeID(doc))
+	log.Info("admin appointments status: ok",
+		slog.String("appointment_id", id),
+		slog.String("from", current.Status),
+		slog.String("status", appointment.Status),
+	)
+	transport.WriteJSON(w, http.StatusOK, appointment)
 }
 
 func (s *Server) AdminListContacts(w http.ResponseWriter, r *http.Request) {
@@ -466,3 +475,33 @@ func (s *Server) AdminListContacts(w http.ResponseWriter, r *http.Request) {
 	log.Info("admin contacts list: ok", slog.Int("count", len(items)))
 	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{"contacts": items})
 }
+
+func (s *Server) AdminMarkContactAnswered(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		log.Warn("admin contacts answered: missing id")
+		transport.WriteError(w, http.StatusBadRequest, "missing id", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	update := bson.M{"$set": bson.M{"answeredAt": time.Now().In(s.Cfg.Timezone)}}
+	var doc bson.M
+	if err := s.Cols.ContactMessages.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin contacts answered: not found", slog.String("contact_id", id))
+			transport.WriteError(w, http.StatusNotFound, "contact not found", nil)
+			return
+		}
+		log.Error("admin contacts answered: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin contacts answered: ok", slog.String("contact_id", id))
+	transport.WriteJSON(w, http.StatusOK, normalizeID(doc))
+}
@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+)
+
+type AdminLoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode   string `json:"recoveryCode" validate:"required_without=Code"`
+}
+
+type AdminTwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type AdminTwoFactorDisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type AdminTwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauthUrl"`
+}
+
+type AdminRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// RequireTwoFactor refuses admin routes to users without TOTP when the
+// ADMIN_2FA_REQUIRED policy is on. API keys are not concerned.
+func (s *Server) RequireTwoFactor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Cfg.Admin2FARequired {
+			principal, ok := middleware.PrincipalFromContext(r.Context())
+			if ok && !principal.APIKey && !principal.TwoFactor {
+				transport.WriteError(w, http.StatusForbidden, "two-factor enrolment required", nil)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// startTwoFactorChallenge answers the password step of a login for a user
+// with TOTP enabled: no session yet, only a short-lived challenge token.
+func (s *Server) startTwoFactorChallenge(w http.ResponseWriter, user models.User) error {
+	manager := s.newAdminJWTManager()
+	token, err := manager.NewChallengeToken(auth.Identity{UserID: user.ID, Username: user.Username, Role: user.Role}, twoFactorChallengeTTL)
+	if err != nil {
+		return err
+	}
+	transport.WriteJSON(w, http.StatusOK, AdminLoginResponse{Status: "two_factor_required", ChallengeToken: token})
+	return nil
+}
+
+// AdminLoginTwoFactor completes a login with a TOTP or recovery code.
+func (s *Server) AdminLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminLoginTwoFactorRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin login 2fa: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin login 2fa: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if s.Cfg.JWTSecret == "" {
+		transport.WriteError(w, http.StatusServiceUnavailable, "admin auth not configured", nil)
+		return
+	}
+
+	manager := s.newAdminJWTManager()
+	claims, err := manager.Parse(req.ChallengeToken)
+	if err != nil || claims.Type != auth.TokenTypeChallenge || claims.Subject == "" {
+		log.Warn("admin login 2fa: invalid challenge")
+		transport.WriteError(w, http.StatusUnauthorized, "invalid challenge", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, found, err := s.LookupUser(ctx, claims.Subject)
+	if err != nil {
+		log.Error("admin login 2fa: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !found || user.DisabledAt != nil || user.TOTPEnabledAt == nil {
+		log.Warn("admin login 2fa: user not allowed", slog.String("user_id", claims.Subject))
+		transport.WriteError(w, http.StatusUnauthorized, "invalid challenge", nil)
+		return
+	}
+
+	if wait, locked := loginRetryAfter(user, time.Now().In(s.Cfg.Timezone)); wait > 0 {
+		log.Warn("admin login 2fa: attempt refused", slog.String("user_id", user.ID), slog.Bool("locked", locked))
+		s.refuseLoginAttempt(ctx, w, r, user, user.Username, wait, locked)
+		return
+	}
+	ok, err := s.consumeSecondFactor(ctx, user, req.Code, req.RecoveryCode)
+	if err != nil {
+		log.Error("admin login 2fa: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !ok {
+		log.Warn("admin login 2fa: invalid code", slog.String("user_id", user.ID))
+		s.registerLoginFailure(ctx, r, user, user.Username, models.LoginFailedTwoFactor)
+		s.registerIPFailure(ctx, r, user.Username)
+		transport.WriteError(w, http.StatusUnauthorized, "invalid code", nil)
+		return
+	}
+
+	if err := s.startAdminSession(ctx, w, r, user); err != nil {
+		log.Error("admin login 2fa: token error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+		return
+	}
+	if err := s.clearLoginFailures(ctx, user); err != nil {
+		log.Warn("admin login 2fa: failed to reset failures", slog.String("error", err.Error()))
+	}
+	s.recordLoginEvent(ctx, r, user.ID, user.Username, true, "")
+	log.Info("admin login 2fa: ok", slog.String("user_id", user.ID), slog.Bool("recovery_code", req.Code == ""))
+	transport.WriteJSON(w, http.StatusOK, AdminLoginResponse{Status: "ok"})
+}
+
+// consumeSecondFactor checks a TOTP code (once per time step) or burns a
+// recovery code. Both updates are conditional so concurrent requests cannot
+// use the same code twice.
+func (s *Server) consumeSecondFactor(ctx context.Context, user models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := auth.VerifyTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+		if !ok {
+			return false, nil
+		}
+		filter := bson.M{
+			"_id": user.ID,
+			"$or": []bson.M{
+				{"totpLastStep": bson.M{"$lt": step}},
+				{"totpLastStep": bson.M{"$exists": false}},
+			},
+		}
+		res, err := s.Cols.Users.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totpLastStep": step}})
+		if err != nil {
+			return false, err
+		}
+		return res.MatchedCount == 1, nil
+	}
+
+	hash := auth.HashRecoveryCode(recoveryCode)
+	filter := bson.M{"_id": user.ID, "recoveryCodeHashes": hash}
+	res, err := s.Cols.Users.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"recoveryCodeHashes": hash}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// AdminTwoFactorSetup starts enrolment for the caller: a new secret is kept
+// pending until confirmed with a code.
+func (s *Server) AdminTwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	user, ok := s.currentUser(w, r, "admin 2fa setup")
+	if !ok {
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		transport.WriteError(w, http.StatusConflict, "two-factor already enabled", nil)
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		log.Error("admin 2fa setup: secret error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"totpPendingSecret": secret, "updatedAt": time.Now().In(s.Cfg.Timezone)}}
+	if _, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+		log.Error("admin 2fa setup: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Username
+	}
+	transport.WriteJSON(w, http.StatusOK, AdminTwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURL: auth.TOTPProvisioningURI(s.Cfg.TOTPIssuer, account, secret),
+	})
+}
+
+// AdminTwoFactorEnable confirms enrolment with a code from the app and
+// returns the recovery codes, once.
+func (s *Server) AdminTwoFactorEnable(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminTwoFactorCodeRequest
+	if !s.decodeTwoFactorRequest(w, r, &req, "admin 2fa enable") {
+		return
+	}
+	user, ok := s.currentUser(w, r, "admin 2fa enable")
+	if !ok {
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		transport.WriteError(w, http.StatusConflict, "two-factor already enabled", nil)
+		return
+	}
+	if user.TOTPPendingSecret == "" {
+		transport.WriteError(w, http.StatusConflict, "two-factor setup not started", nil)
+		return
+	}
+	step, valid := auth.VerifyTOTP(user.TOTPPendingSecret, req.Code, time.Now(), 0)
+	if !valid {
+		log.Warn("admin 2fa enable: invalid code", slog.String("user_id", user.ID))
+		transport.WriteError(w, http.StatusBadRequest, "invalid code", nil)
+		return
+	}
+	codes, hashes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Error("admin 2fa enable: recovery codes error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now().In(s.Cfg.Timezone)
+	update := bson.M{
+		"$set": bson.M{
+			"totpSecret":         user.TOTPPendingSecret,
+			"totpEnabledAt":      now,
+			"totpLastStep":       step,
+			"recoveryCodeHashes": hashes,
+			"updatedAt":          now,
+		},
+		"$unset": bson.M{"totpPendingSecret": ""},
+	}
+	filter := bson.M{"_id": user.ID, "totpPendingSecret": user.TOTPPendingSecret}
+	res, err := s.Cols.Users.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Error("admin 2fa enable: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.MatchedCount == 0 {
+		transport.WriteError(w, http.StatusConflict, "two-factor setup changed, start again", nil)
+		return
+	}
+
+	log.Info("admin 2fa enable: ok", slog.String("user_id", user.ID))
+	transport.WriteJSON(w, http.StatusOK, AdminRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// AdminTwoFactorDisable turns 2FA off for the caller after checking both
+// the password and a current code. Refused while the policy requires 2FA.
+func (s *Server) AdminTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminTwoFactorDisableRequest
+	if !s.decodeTwoFactorRequest(w, r, &req, "admin 2fa disable") {
+		return
+	}
+	if s.Cfg.Admin2FARequired {
+		transport.WriteError(w, http.StatusConflict, "two-factor is required by policy", nil)
+		return
+	}
+	user, ok := s.currentUser(w, r, "admin 2fa disable")
+	if !ok {
+		return
+	}
+	if user.TOTPEnabledAt == nil {
+		transport.WriteError(w, http.StatusConflict, "two-factor not enabled", nil)
+		return
+	}
+	if err := auth.ComparePassword(user.PasswordHash, req.Password); err != nil {
+		log.Warn("admin 2fa disable: invalid password", slog.String("user_id", user.ID))
+		transport.WriteError(w, http.StatusUnauthorized, "invalid credentials", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	valid, err := s.consumeSecondFactor(ctx, user, req.Code, "")
+	if err != nil {
+		log.Error("admin 2fa disable: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !valid {
+		log.Warn("admin 2fa disable: invalid code", slog.String("user_id", user.ID))
+		transport.WriteError(w, http.StatusUnauthorized, "invalid code", nil)
+		return
+	}
+	if err := s.clearTwoFactor(ctx, user.ID); err != nil {
+		log.Error("admin 2fa disable: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin 2fa disable: ok", slog.String("user_id", user.ID))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "disabled"})
+}
+
+// AdminTwoFactorRecoveryCodes replaces the caller's recovery codes.
+func (s *Server) AdminTwoFactorRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminTwoFactorCodeRequest
+	if !s.decodeTwoFactorRequest(w, r, &req, "admin 2fa recovery codes") {
+		return
+	}
+	user, ok := s.currentUser(w, r, "admin 2fa recovery codes")
+	if !ok {
+		return
+	}
+	if user.TOTPEnabledAt == nil {
+		transport.WriteError(w, http.StatusConflict, "two-factor not enabled", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	valid, err := s.consumeSecondFactor(ctx, user, req.Code, "")
+	if err != nil {
+		log.Error("admin 2fa recovery codes: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !valid {
+		transport.WriteError(w, http.StatusUnauthorized, "invalid code", nil)
+		return
+	}
+	codes, hashes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Error("admin 2fa recovery codes: error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+		return
+	}
+	update := bson.M{"$set": bson.M{"recoveryCodeHashes": hashes, "updatedAt": time.Now().In(s.Cfg.Timezone)}}
+	if _, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+		log.Error("admin 2fa recovery codes: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin 2fa recovery codes: regenerated", slog.String("user_id", user.ID))
+	transport.WriteJSON(w, http.StatusOK, AdminRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// AdminResetUserTwoFactor removes another user's 2FA (lost phone and
+// recovery codes) and signs them out everywhere.
+func (s *Server) AdminResetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		log.Warn("admin users 2fa reset: missing id")
+		transport.WriteError(w, http.StatusBadRequest, "missing id", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin users 2fa reset") {
+		return
+	}
+
+	if _, found, err := s.LookupUser(ctx, id); err != nil {
+		log.Error("admin users 2fa reset: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	} else if !found {
+		transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+		return
+	}
+	if err := s.clearTwoFactor(ctx, id); err != nil {
+		log.Error("admin users 2fa reset: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if err := s.revokeAdminSessions(ctx, bson.M{"userId": id}, models.SessionRevokedByAdmin); err != nil {
+		log.Error("admin users 2fa reset: revoke sessions failed", slog.String("error", err.Error()))
+	}
+
+	log.Info("admin users 2fa reset: ok", slog.String("user_id", id))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "reset"})
+}
+
+func (s *Server) clearTwoFactor(ctx context.Context, userID string) error {
+	update := bson.M{
+		"$unset": bson.M{
+			"totpSecret":         "",
+			"totpPendingSecret":  "",
+			"totpEnabledAt":      "",
+			"totpLastStep":       "",
+			"recoveryCodeHashes": "",
+		},
+		"$set": bson.M{"updatedAt": time.Now().In(s.Cfg.Timezone)},
+	}
+	_, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": userID}, update)
+	return err
+}
+
+// currentUser loads the user behind the request's session. API keys have no
+// user and are refused.
+func (s *Server) currentUser(w http.ResponseWriter, r *http.Request, op string) (models.User, bool) {
+	log := s.logWithRequest(r)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok || principal.UserID == "" {
+		transport.WriteError(w, http.StatusForbidden, "user session required", nil)
+		return models.User{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, found, err := s.LookupUser(ctx, principal.UserID)
+	if err != nil {
+		log.Error(op+": database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return models.User{}, false
+	}
+	if !found {
+		transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+		return models.User{}, false
+	}
+	return user, true
+}
+
+func (s *Server) decodeTwoFactorRequest(w http.ResponseWriter, r *http.Request, req interface{}, op string) bool {
+	log := s.logWithRequest(r)
+	if err := decodeJSON(r, req); err != nil {
+		log.Warn(op + ": invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return false
+	}
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn(op + ": validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return false
+	}
+	return true
+}
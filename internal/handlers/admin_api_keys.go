@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	apiKeyPrefix = "gbh_"
+	// apiKeyTouchInterval throttles lastUsedAt writes for busy keys.
+	apiKeyTouchInterval = time.Minute
+	maxAPIKeyGrace      = 7 * 24 * time.Hour
+)
+
+type AdminAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=80"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type AdminAPIKeyRotateRequest struct {
+	// GraceMinutes keeps the old key working for a while so the integration
+	// can be redeployed. Zero revokes it immediately.
+	GraceMinutes int `json:"graceMinutes" validate:"min=0"`
+}
+
+// AdminAPIKeyCreatedResponse is the only time the raw key is returned.
+type AdminAPIKeyCreatedResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// AuthenticateAPIKey looks up an active key for the admin auth middleware
+// and records when and from where it was last used.
+func (s *Server) AuthenticateAPIKey(ctx context.Context, key, ip string) (models.APIKey, bool, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) || s.Cols == nil || s.Cols.APIKeys == nil {
+		return models.APIKey{}, false, nil
+	}
+	hash := auth.HashToken(key)
+
+	var apiKey models.APIKey
+	filter := bson.M{"keyHash": hash, "revokedAt": bson.M{"$exists": false}}
+	if err := s.Cols.APIKeys.FindOne(ctx, filter).Decode(&apiKey); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.APIKey{}, false, nil
+		}
+		return models.APIKey{}, false, err
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hash)) != 1 {
+		return models.APIKey{}, false, nil
+	}
+	now := time.Now().In(s.Cfg.Timezone)
+	if apiKey.ExpiresAt != nil && !now.Before(*apiKey.ExpiresAt) {
+		return models.APIKey{}, false, nil
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval || apiKey.LastUsedIP != ip {
+		update := bson.M{"$set": bson.M{"lastUsedAt": now, "lastUsedIp": ip}}
+		if _, err := s.Cols.APIKeys.UpdateOne(ctx, bson.M{"_id": apiKey.ID}, update); err != nil {
+			s.Log.Warn("api keys: touch failed", slog.String("api_key_id", apiKey.ID), slog.String("error", err.Error()))
+		}
+	}
+	return apiKey, true, nil
+}
+
+func (s *Server) AdminListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if r.URL.Query().Get("includeRevoked") != "true" {
+		filter["revokedAt"] = bson.M{"$exists": false}
+	}
+
+	var keys []models.APIKey
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	if err := s.findAll(ctx, s.Cols.APIKeys, filter, opts, &keys); err != nil {
+		log.Error("admin api keys list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{"items": keys})
+}
+
+func (s *Server) AdminCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminAPIKeyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin api keys create: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin api keys create: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if details := unknownPermissionDetails("scopes", req.Scopes); details != nil {
+		log.Warn("admin api keys create: invalid scopes")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if missing := ungrantableScope(r.Context(), req.Scopes); missing != "" {
+		log.Warn("admin api keys create: scope above caller", slog.String("scope", missing))
+		transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": missing})
+		return
+	}
+	now := time.Now().In(s.Cfg.Timezone)
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"expiresAt": "past"})
+		return
+	}
+
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	apiKey := models.APIKey{
+		ID:        primitive.NewObjectID().Hex(),
+		Name:      req.Name,
+		Scopes:    normalizePermissions(req.Scopes),
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: principal.Username,
+		CreatedAt: now,
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	raw, err := s.insertAPIKey(ctx, &apiKey)
+	if err != nil {
+		log.Error("admin api keys create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin api keys create: ok", slog.String("api_key_id", apiKey.ID), slog.String("name", apiKey.Name))
+	transport.WriteJSON(w, http.StatusCreated, AdminAPIKeyCreatedResponse{APIKey: apiKey, Key: raw})
+}
+
+// AdminRotateAPIKey issues a replacement key with the same name, scopes and
+// expiry, and retires the old one after the requested grace period.
+func (s *Server) AdminRotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	var req AdminAPIKeyRotateRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			log.Warn("admin api keys rotate: invalid json")
+			transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+			return
+		}
+	}
+	grace := time.Duration(req.GraceMinutes) * time.Minute
+	if req.GraceMinutes < 0 || grace > maxAPIKeyGrace {
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"graceMinutes": "range"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var old models.APIKey
+	if err := s.Cols.APIKeys.FindOne(ctx, bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}}).Decode(&old); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "api key not found", nil)
+			return
+		}
+		log.Error("admin api keys rotate: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if missing := ungrantableScope(r.Context(), old.Scopes); missing != "" {
+		transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": missing})
+		return
+	}
+
+	now := time.Now().In(s.Cfg.Timezone)
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	replacement := models.APIKey{
+		ID:          primitive.NewObjectID().Hex(),
+		Name:        old.Name,
+		Scopes:      old.Scopes,
+		ExpiresAt:   old.ExpiresAt,
+		RotatedFrom: old.ID,
+		CreatedBy:   principal.Username,
+		CreatedAt:   now,
+	}
+	raw, err := s.insertAPIKey(ctx, &replacement)
+	if err != nil {
+		log.Error("admin api keys rotate: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	retire := bson.M{"$set": bson.M{"revokedAt": now}}
+	if grace > 0 {
+		until := now.Add(grace)
+		if old.ExpiresAt == nil || until.Before(*old.ExpiresAt) {
+			retire = bson.M{"$set": bson.M{"expiresAt": until}}
+		} else {
+			retire = nil
+		}
+	}
+	if retire != nil {
+		if _, err := s.Cols.APIKeys.UpdateOne(ctx, bson.M{"_id": old.ID}, retire); err != nil {
+			log.Error("admin api keys rotate: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+			return
+		}
+	}
+
+	log.Info("admin api keys rotate: ok",
+		slog.String("api_key_id", replacement.ID),
+		slog.String("rotated_from", old.ID),
+		slog.Int("grace_minutes", req.GraceMinutes),
+	)
+	transport.WriteJSON(w, http.StatusCreated, AdminAPIKeyCreatedResponse{APIKey: replacement, Key: raw})
+}
+
+func (s *Server) AdminRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"revokedAt": time.Now().In(s.Cfg.Timezone)}}
+	res, err := s.Cols.APIKeys.UpdateOne(ctx, bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}}, update)
+	if err != nil {
+		log.Error("admin api keys revoke: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.MatchedCount == 0 {
+		transport.WriteError(w, http.StatusNotFound, "api key not found", nil)
+		return
+	}
+
+	log.Info("admin api keys revoke: ok", slog.String("api_key_id", id))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
+
+// insertAPIKey generates the secret for apiKey, stores it hashed and returns
+// the raw key.
+func (s *Server) insertAPIKey(ctx context.Context, apiKey *models.APIKey) (string, error) {
+	token, _, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	raw := apiKeyPrefix + token
+	apiKey.KeyHash = auth.HashToken(raw)
+	apiKey.Prefix = raw[:len(apiKeyPrefix)+8]
+	if _, err := s.Cols.APIKeys.InsertOne(ctx, apiKey); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/booking"
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/schedule"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AdminCreateAppointmentRequest books on behalf of a customer. Unlike the
+// public endpoint, past dates are accepted (e.g. recording a walk-in).
+// OverrideAvailability skips opening hours and overlap checks; two active
+// appointments can still not start at the same time. Notify defaults to
+// sending the confirmation email.
+type AdminCreateAppointmentRequest struct {
+	ServiceID            string `json:"serviceId" validate:"required"`
+	Name                 string `json:"name" validate:"required"`
+	Email                string `json:"email" validate:"required,email"`
+	Phone                string `json:"phone" validate:"required,phone"`
+	Type                 string `json:"type" validate:"required,oneof=online presentiel"`
+	Date                 string `json:"date" validate:"required,date"`
+	Time                 string `json:"time" validate:"required,clock"`
+	Duration             int    `json:"duration" validate:"omitempty,gte=15,lte=240,minutes15"`
+	PaymentMethod        string `json:"paymentMethod" validate:"required,oneof=online place"`
+	Price                int    `json:"price" validate:"gte=0"`
+	Status               string `json:"status" validate:"omitempty,oneof=pending booked confirmed"`
+	OverrideAvailability bool   `json:"overrideAvailability"`
+	Notify               *bool  `json:"notify,omitempty"`
+	LocationID           string `json:"locationId,omitempty"`
+	RoomID               string `json:"roomId,omitempty"`
+	// Intake answers the service questionnaire; required questions may be
+	// left out.
+	Intake map[string]interface{} `json:"intake,omitempty"`
+}
+
+// AdminUpdateAppointmentRequest edits an appointment; omitted fields are kept.
+// The status goes through PATCH /appointments/{id}/status instead. Notify
+// defaults to sending the updated invitation only when the date, time,
+// duration, type or service changed.
+type AdminUpdateAppointmentRequest struct {
+	ServiceID            *string `json:"serviceId,omitempty" validate:"omitempty,min=1"`
+	Name                 *string `json:"name,omitempty" validate:"omitempty,min=1"`
+	Email                *string `json:"email,omitempty" validate:"omitempty,email"`
+	Phone                *string `json:"phone,omitempty" validate:"omitempty,phone"`
+	Type                 *string `json:"type,omitempty" validate:"omitempty,oneof=online presentiel"`
+	Date                 *string `json:"date,omitempty" validate:"omitempty,date"`
+	Time                 *string `json:"time,omitempty" validate:"omitempty,clock"`
+	Duration             *int    `json:"duration,omitempty" validate:"omitempty,gte=15,lte=240,minutes15"`
+	PaymentMethod        *string `json:"paymentMethod,omitempty" validate:"omitempty,oneof=online place"`
+	Price                *int    `json:"price,omitempty" validate:"omitempty,gte=0"`
+	OverrideAvailability bool    `json:"overrideAvailability"`
+	Notify               *bool   `json:"notify,omitempty"`
+	LocationID           *string `json:"locationId,omitempty"`
+	RoomID               *string `json:"roomId,omitempty"`
+}
+
+// checkSlot validates a slot against opening hours and the busy time of its
+// day. It returns the HTTP status and message to send, or 0 when the slot is
+// usable. override only checks that the date and time parse.
+func (s *Server) checkSlot(ctx context.Context, date, clock string, duration int, override bool, excludeID string) (int, string, error) {
+	if _, err := schedule.ParseDateTime(date, clock, s.Cfg.Timezone); err != nil {
+		return http.StatusBadRequest, "invalid date", nil
+	}
+	if override {
+		return 0, "", nil
+	}
+	allowed, err := schedule.IsSlotAllowedWithDuration(date, clock, duration, s.Cfg.Timezone)
+	if err != nil {
+		return http.StatusBadRequest, "invalid time", nil
+	}
+	if !allowed {
+		return http.StatusBadRequest, "slot not available", nil
+	}
+
+	var exclude []string
+	if excludeID != "" {
+		exclude = append(exclude, excludeID)
+	}
+	reserved, err := s.reservedIntervals(ctx, date, exclude...)
+	if err != nil {
+		return 0, "", err
+	}
+	start, err := schedule.ParseClockToMinutes(clock)
+	if err != nil {
+		return http.StatusBadRequest, "invalid time", nil
+	}
+	current := schedule.Interval{Start: start, End: start + duration}
+	for _, interval := range reserved {
+		if schedule.Overlaps(current, interval) {
+			return http.StatusConflict, "slot not available", nil
+		}
+	}
+	return 0, "", nil
+}
+
+func (s *Server) AdminCreateAppointment(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminCreateAppointmentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin appointments create: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.TrimSpace(req.Email)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin appointments create: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	duration := req.Duration
+	if duration == 0 {
+		duration = schedule.SlotMinutes
+	}
+	status := req.Status
+	if status == "" {
+		status = models.AppointmentStatusBooked
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": req.ServiceID}).Decode(&service); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin appointments create: service not found", slog.String("service_id", req.ServiceID))
+			transport.WriteError(w, http.StatusBadRequest, "service not found", nil)
+			return
+		}
+		log.Error("admin appointments create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	// Staff booking on behalf of a customer may not have every answer yet.
+	answers, details, err := s.resolveIntake(ctx, service, req.Intake, false)
+	if err != nil {
+		log.Error("admin appointments create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details != nil {
+		log.Warn("admin appointments create: intake validation error")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	place, details, err := s.resolvePlace(ctx, service, req.Type, req.LocationID, req.RoomID)
+	if err != nil {
+		log.Error("admin appointments create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details != nil {
+		log.Warn("admin appointments create: invalid location")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	placement, code, msg, err := s.placeSlot(ctx, place, req.Date, req.Time, duration, req.OverrideAvailability, "")
+	if err != nil {
+		log.Error("admin appointments create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if code != 0 {
+		log.Warn("admin appointments create: slot refused", slog.String("date", req.Date), slog.String("time", req.Time), slog.String("reason", msg))
+		transport.WriteError(w, code, msg, nil)
+		return
+	}
+
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	now := time.Now().In(s.Cfg.Timezone)
+	appointment := models.Appointment{
+		ID:            primitive.NewObjectID().Hex(),
+		ServiceID:     req.ServiceID,
+		Name:          req.Name,
+		Email:         req.Email,
+		Phone:         req.Phone,
+		Type:          req.Type,
+		Date:          req.Date,
+		Time:          req.Time,
+		Duration:      duration,
+		Price:         req.Price,
+		Tax:           0,
+		Total:         req.Price,
+		Status:        status,
+		PaymentMethod: req.PaymentMethod,
+		CreatedAt:     now,
+		Intake:        answers,
+		Location:      placement,
+		StatusHistory: []models.AppointmentStatusChange{{
+			To:      status,
+			Actor:   principal.Username,
+			ActorID: principal.UserID,
+			At:      now,
+		}},
+	}
+	s.assignMeetingLink(ctx, log, &appointment, service)
+
+	if _, err := s.Cols.Appointments.InsertOne(ctx, appointment); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			log.Warn("admin appointments create: duplicate key", slog.String("date", req.Date), slog.String("time", req.Time))
+			transport.WriteError(w, http.StatusConflict, "slot already booked", nil)
+			return
+		}
+		log.Error("admin appointments create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	if err := s.attachIntakeFiles(ctx, appointment.Intake); err != nil {
+		log.Warn("admin appointments create: intake files not attached", slog.String("appointment_id", appointment.ID), slog.String("error", err.Error()))
+	}
+
+	if s.Cache != nil {
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:"+appointment.Date+":")
+	}
+	if notify := req.Notify == nil || *req.Notify; notify && s.Mailer != nil {
+		go s.sendAppointmentConfirmationEmail(log, appointment, service)
+	}
+
+	log.Info("admin appointments create: booked",
+		slog.String("appointment_id", appointment.ID),
+		slog.String("date", appointment.Date),
+		slog.String("time", appointment.Time),
+		slog.Bool("override", req.OverrideAvailability),
+	)
+	transport.WriteJSON(w, http.StatusCreated, appointment)
+}
+
+func (s *Server) AdminUpdateAppointment(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		log.Warn("admin appointments update: missing id")
+		transport.WriteError(w, http.StatusBadRequest, "missing id", nil)
+		return
+	}
+
+	var req AdminUpdateAppointmentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin appointments update: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	// Trim before validating, so a blank name cannot pass min=1.
+	for _, value := range []*string{req.ServiceID, req.Name, req.Email, req.Phone, req.Type, req.Date, req.Time, req.PaymentMethod, req.LocationID, req.RoomID} {
+		if value != nil {
+			*value = strings.TrimSpace(*value)
+		}
+	}
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin appointments update: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var current models.Appointment
+	if err := s.Cols.Appointments.FindOne(ctx, bson.M{"_id": id}).Decode(&current); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin appointments update: not found", slog.String("appointment_id", id))
+			transport.WriteError(w, http.StatusNotFound, "appointment not found", nil)
+			return
+		}
+		log.Error("admin appointments update: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	updated := current
+	set := bson.M{}
+	assign := func(field string, dst *string, value *string) {
+		if value != nil && *value != *dst {
+			*dst = *value
+			set[field] = *dst
+		}
+	}
+	assign("serviceId", &updated.ServiceID, req.ServiceID)
+	assign("name", &updated.Name, req.Name)
+	assign("email", &updated.Email, req.Email)
+	assign("phone", &updated.Phone, req.Phone)
+	assign("type", &updated.Type, req.Type)
+	assign("date", &updated.Date, req.Date)
+	assign("time", &updated.Time, req.Time)
+	assign("paymentMethod", &updated.PaymentMethod, req.PaymentMethod)
+	if req.Duration != nil && *req.Duration != updated.Duration {
+		updated.Duration = *req.Duration
+		set["duration"] = updated.Duration
+	}
+	if req.Price != nil && *req.Price != updated.Price {
+		updated.Price = *req.Price
+		updated.Total = updated.Price + updated.Tax
+		set["price"] = updated.Price
+		set["total"] = updated.Total
+	}
+	currentLocationID, currentRoomID := "", ""
+	if current.Location != nil {
+		currentLocationID, currentRoomID = current.Location.ID, current.Location.RoomID
+	}
+	relocate := (req.LocationID != nil && *req.LocationID != currentLocationID) ||
+		(req.RoomID != nil && *req.RoomID != currentRoomID)
+	if len(set) == 0 && !relocate && (req.Notify == nil || !*req.Notify) {
+		transport.WriteJSON(w, http.StatusOK, current)
+		return
+	}
+
+	moved := updated.Date != current.Date || updated.Time != current.Time || updated.Duration != current.Duration
+	rescheduled := moved || relocate || updated.Type != current.Type || updated.ServiceID != current.ServiceID
+	if rescheduled && booking.Terminal(current.Status) {
+		log.Warn("admin appointments update: closed appointment", slog.String("appointment_id", id), slog.String("status", current.Status))
+		transport.WriteError(w, http.StatusConflict, "appointment can no longer be rescheduled", nil)
+		return
+	}
+
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": updated.ServiceID}).Decode(&service); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin appointments update: service not found", slog.String("service_id", updated.ServiceID))
+			transport.WriteError(w, http.StatusBadRequest, "service not found", nil)
+			return
+		}
+		log.Error("admin appointments update: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	unset := bson.M{}
+	if rescheduled && models.AppointmentHoldsSlot(current.Status) {
+		// A presentiel appointment stays at its location, and in its room
+		// while it is free, unless another one is given.
+		locationID, roomID := "", ""
+		if updated.Type == models.ConsultationPresentiel {
+			locationID, roomID = currentLocationID, currentRoomID
+		}
+		if req.LocationID != nil && *req.LocationID != locationID {
+			locationID, roomID = *req.LocationID, ""
+		}
+		if req.RoomID != nil {
+			roomID = *req.RoomID
+		}
+		place, details, err := s.resolvePlace(ctx, service, updated.Type, locationID, roomID)
+		if err != nil {
+			log.Error("admin appointments update: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+			return
+		}
+		if details != nil {
+			log.Warn("admin appointments update: invalid location", slog.String("appointment_id", id))
+			transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+			return
+		}
+		place.PreferRoom = req.RoomID == nil
+
+		// A type or service change on the general calendar keeps its slot.
+		if moved || place.Location != nil || current.Location != nil {
+			placement, code, msg, err := s.placeSlot(ctx, place, updated.Date, updated.Time, updated.Duration, req.OverrideAvailability, current.ID)
+			if err != nil {
+				log.Error("admin appointments update: database error", slog.String("error", err.Error()))
+				transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+				return
+			}
+			if code != 0 {
+				log.Warn("admin appointments update: slot refused", slog.String("appointment_id", id), slog.String("reason", msg))
+				transport.WriteError(w, code, msg, nil)
+				return
+			}
+			switch {
+			case placement != nil:
+				updated.Location = placement
+				set["location"] = placement
+			case current.Location != nil:
+				updated.Location = nil
+				unset["location"] = ""
+			}
+		}
+	}
+
+	if rescheduled {
+		switch {
+		case updated.Type != models.ConsultationOnline:
+			if updated.Meeting != nil {
+				updated.Meeting = nil
+				unset["meeting"] = ""
+			}
+		case moved || current.Type != models.ConsultationOnline:
+			// The link is tied to the start time, so a moved call gets a new one.
+			previous := updated.Meeting
+			s.assignMeetingLink(ctx, log, &updated, service)
+			if updated.Meeting != previous {
+				set["meeting"] = updated.Meeting
+			}
+		}
+	}
+
+	notify := rescheduled
+	if req.Notify != nil {
+		notify = *req.Notify
+	}
+	notify = notify && !models.AppointmentCanceled(updated.Status)
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if rescheduled || notify {
+		// Calendars only replace the event when the sequence grows.
+		update["$inc"] = bson.M{"calendarSequence": 1}
+	}
+
+	var saved models.Appointment
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.Appointments.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&saved); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			log.Warn("admin appointments update: slot taken", slog.String("appointment_id", id))
+			transport.WriteError(w, http.StatusConflict, "slot already booked", nil)
+			return
+		}
+		log.Error("admin appointments update: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	if rescheduled && s.Cache != nil {
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:"+current.Date+":")
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:"+saved.Date+":")
+	}
+	if moved && models.AppointmentHoldsSlot(saved.Status) {
+		s.offerFreedSlotsAsync(current.Date, appointmentCalendar(current))
+	}
+	if notify && s.Mailer != nil {
+		go s.sendAppointmentRescheduledEmail(log, saved, service)
+	}
+
+	log.Info("admin appointments update: ok",
+		slog.String("appointment_id", id),
+		slog.Bool("moved", moved),
+		slog.Bool("notified", notify),
+	)
+	transport.WriteJSON(w, http.StatusOK, saved)
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/booking"
+	"gbh-backend/internal/export"
+	"gbh-backend/internal/intake"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/transport"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	appointmentSearchDefaultLimit = 50
+	appointmentSearchMaxLimit     = 200
+	// appointmentExportMaxRows bounds an export; narrower filters are needed
+	// beyond it.
+	appointmentExportMaxRows = 10000
+)
+
+// appointmentSortKeys maps a sort name to the fields it orders by. _id is
+// always appended so the order, and therefore the cursor, is total.
+var appointmentSortKeys = map[string][]string{
+	"date":      {"date", "time"},
+	"createdAt": {"createdAt"},
+	"name":      {"name"},
+	"total":     {"total"},
+}
+
+type AdminAppointmentQuery struct {
+	Date          string `validate:"omitempty,date"`
+	From          string `validate:"omitempty,date"`
+	To            string `validate:"omitempty,date"`
+	Type          string `validate:"omitempty,oneof=online presentiel"`
+	PaymentMethod string `validate:"omitempty,oneof=online place"`
+	Email         string `validate:"omitempty,max=254"`
+	Phone         string `validate:"omitempty,max=40"`
+	Search        string `validate:"omitempty,max=100"`
+	Sort          string `validate:"omitempty,oneof=date -date createdAt -createdAt name -name total -total"`
+}
+
+// appointmentSearch is a parsed admin query: the filter and the sort order.
+type appointmentSearch struct {
+	filter bson.M
+	sort   string
+	keys   []string
+	desc   bool
+}
+
+// appointmentCursor points after the last item of a page. Sort is checked so
+// a cursor cannot be replayed against another order.
+type appointmentCursor struct {
+	Sort   string `bson:"s"`
+	Values bson.A `bson:"v"`
+}
+
+func (s *Server) parseAppointmentSearch(values url.Values) (appointmentSearch, map[string]string) {
+	q := AdminAppointmentQuery{
+		Date:          strings.TrimSpace(values.Get("date")),
+		From:          strings.TrimSpace(values.Get("from")),
+		To:            strings.TrimSpace(values.Get("to")),
+		Type:          strings.TrimSpace(values.Get("type")),
+		PaymentMethod: strings.TrimSpace(values.Get("paymentMethod")),
+		Email:         strings.TrimSpace(values.Get("email")),
+		Phone:         strings.TrimSpace(values.Get("phone")),
+		Search:        strings.TrimSpace(values.Get("q")),
+		Sort:          strings.TrimSpace(values.Get("sort")),
+	}
+	if err := s.Val.Struct(q); err != nil {
+		return appointmentSearch{}, validationDetails(s.Val.ValidationErrors(err))
+	}
+	if q.From != "" && q.To != "" && q.From > q.To {
+		return appointmentSearch{}, map[string]string{"To": "gtefield"}
+	}
+
+	filter := bson.M{}
+	switch {
+	case q.Date != "":
+		filter["date"] = q.Date
+	case q.From != "" || q.To != "":
+		dates := bson.M{}
+		if q.From != "" {
+			dates["$gte"] = q.From
+		}
+		if q.To != "" {
+			dates["$lte"] = q.To
+		}
+		filter["date"] = dates
+	}
+	if services := splitQueryList(values.Get("service")); len(services) > 0 {
+		filter["serviceId"] = bson.M{"$in": services}
+	}
+	if locations := splitQueryList(values.Get("location")); len(locations) > 0 {
+		filter["location.id"] = bson.M{"$in": locations}
+	}
+	if statuses := splitQueryList(values.Get("status")); len(statuses) > 0 {
+		for _, status := range statuses {
+			if !booking.Known(status) {
+				return appointmentSearch{}, map[string]string{"Status": "oneof"}
+			}
+		}
+		filter["status"] = bson.M{"$in": statuses}
+	}
+	if q.Type != "" {
+		filter["type"] = q.Type
+	}
+	if q.PaymentMethod != "" {
+		filter["paymentMethod"] = q.PaymentMethod
+	}
+	if q.Email != "" {
+		filter["email"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(q.Email) + "$", Options: "i"}
+	}
+	if q.Phone != "" {
+		filter["phone"] = primitive.Regex{Pattern: regexp.QuoteMeta(q.Phone), Options: ""}
+	}
+	if q.Search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q.Search), Options: "i"}
+		filter["$or"] = []bson.M{
+			{"_id": q.Search},
+			{"name": pattern},
+			{"email": pattern},
+			{"phone": pattern},
+		}
+	}
+
+	search := appointmentSearch{filter: filter, sort: "date"}
+	if q.Sort != "" {
+		search.sort = q.Sort
+	}
+	name := strings.TrimPrefix(search.sort, "-")
+	search.desc = name != search.sort
+	search.keys = append(append([]string{}, appointmentSortKeys[name]...), "_id")
+	return search, nil
+}
+
+func (a appointmentSearch) sortSpec() bson.D {
+	direction := 1
+	if a.desc {
+		direction = -1
+	}
+	spec := make(bson.D, 0, len(a.keys))
+	for _, key := range a.keys {
+		spec = append(spec, bson.E{Key: key, Value: direction})
+	}
+	return spec
+}
+
+// after restricts the filter to documents that sort after the cursor.
+func (a appointmentSearch) after(cursor appointmentCursor) bson.M {
+	op := "$gt"
+	if a.desc {
+		op = "$lt"
+	}
+	branches := make([]bson.M, 0, len(a.keys))
+	for i, key := range a.keys {
+		branch := bson.M{}
+		for j := 0; j < i; j++ {
+			branch[a.keys[j]] = cursor.Values[j]
+		}
+		branch[key] = bson.M{op: cursor.Values[i]}
+		branches = append(branches, branch)
+	}
+	return bson.M{"$and": []bson.M{a.filter, {"$or": branches}}}
+}
+
+func (a appointmentSearch) encodeCursor(doc bson.M) (string, error) {
+	values := make(bson.A, 0, len(a.keys))
+	for _, key := range a.keys {
+		values = append(values, doc[key])
+	}
+	raw, err := bson.Marshal(appointmentCursor{Sort: a.sort, Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func (a appointmentSearch) decodeCursor(value string) (appointmentCursor, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return appointmentCursor{}, false
+	}
+	var cursor appointmentCursor
+	if err := bson.Unmarshal(raw, &cursor); err != nil {
+		return appointmentCursor{}, false
+	}
+	if cursor.Sort != a.sort || len(cursor.Values) != len(a.keys) {
+		return appointmentCursor{}, false
+	}
+	return cursor, true
+}
+
+// AdminListAppointments searches appointments. Pages are chained with the
+// opaque nextCursor; total counts every match regardless of the page.
+func (s *Server) AdminListAppointments(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	values := r.URL.Query()
+	search, details := s.parseAppointmentSearch(values)
+	if details != nil {
+		log.Warn("admin appointments list: invalid query")
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", details)
+		return
+	}
+
+	limit := int64(appointmentSearchDefaultLimit)
+	if raw := strings.TrimSpace(values.Get("limit")); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed <= 0 {
+			log.Warn("admin appointments list: invalid limit")
+			transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"limit": "invalid"})
+			return
+		}
+		limit = min(parsed, appointmentSearchMaxLimit)
+	}
+
+	filter := search.filter
+	if raw := strings.TrimSpace(values.Get("cursor")); raw != "" {
+		cursor, ok := search.decodeCursor(raw)
+		if !ok {
+			log.Warn("admin appointments list: invalid cursor")
+			transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"cursor": "invalid"})
+			return
+		}
+		filter = search.after(cursor)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	total, err := s.Cols.Appointments.CountDocuments(ctx, search.filter)
+	if err != nil {
+		log.Error("admin appointments list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	// One extra document tells whether another page exists.
+	var docs []bson.M
+	opts := options.Find().SetSort(search.sortSpec()).SetLimit(limit + 1)
+	if err := s.findAll(ctx, s.Cols.Appointments, filter, opts, &docs); err != nil {
+		log.Error("admin appointments list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	var nextCursor string
+	if int64(len(docs)) > limit {
+		docs = docs[:limit]
+		nextCursor, err = search.encodeCursor(docs[len(docs)-1])
+		if err != nil {
+			log.Error("admin appointments list: cursor error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+			return
+		}
+	}
+
+	items := make([]map[string]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		items = append(items, normalizeID(doc))
+	}
+
+	log.Info("admin appointments list: ok", slog.Int("count", len(items)), slog.Int64("total", total))
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"appointments": items,
+		"limit":        limit,
+		"total":        total,
+		"nextCursor":   nextCursor,
+	})
+}
+
+func appointmentPlaceLabel(location *models.AppointmentLocation) string {
+	if location == nil {
+		return ""
+	}
+	return location.Name + " - " + location.RoomName
+}
+
+var appointmentExportHeader = []string{
+	"reference", "date", "heure", "durée (min)", "service", "client", "email", "téléphone",
+	"type", "paiement", "statut", "prix", "total", "créé le", "questionnaire", "lieu",
+}
+
+// AdminExportAppointments streams the result of the same query as
+// AdminListAppointments as CSV (default) or XLSX.
+func (s *Server) AdminExportAppointments(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	values := r.URL.Query()
+	search, details := s.parseAppointmentSearch(values)
+	if details != nil {
+		log.Warn("admin appointments export: invalid query")
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", details)
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(values.Get("format")))
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if format != export.FormatCSV && format != export.FormatXLSX {
+		log.Warn("admin appointments export: invalid format", slog.String("format", format))
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"format": "oneof"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	total, err := s.Cols.Appointments.CountDocuments(ctx, search.filter)
+	if err != nil {
+		log.Error("admin appointments export: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if total > appointmentExportMaxRows {
+		log.Warn("admin appointments export: too many rows", slog.Int64("total", total))
+		transport.WriteError(w, http.StatusBadRequest, "too many results", map[string]string{"max": strconv.Itoa(appointmentExportMaxRows)})
+		return
+	}
+
+	var appointments []models.Appointment
+	opts := options.Find().SetSort(search.sortSpec()).SetLimit(appointmentExportMaxRows)
+	if err := s.findAll(ctx, s.Cols.Appointments, search.filter, opts, &appointments); err != nil {
+		log.Error("admin appointments export: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var services []models.Service
+	if err := s.findAll(ctx, s.Cols.Services, bson.M{}, options.Find().SetProjection(bson.M{"name": 1}), &services); err != nil {
+		log.Error("admin appointments export: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	serviceNames := make(map[string]string, len(services))
+	for _, service := range services {
+		serviceNames[service.ID] = service.Name
+	}
+
+	table := export.Table{Header: appointmentExportHeader, Rows: make([][]interface{}, 0, len(appointments))}
+	for _, a := range appointments {
+		table.Rows = append(table.Rows, []interface{}{
+			a.ID, a.Date, a.Time, a.Duration, serviceNames[a.ServiceID], a.Name, a.Email, a.Phone,
+			a.Type, a.PaymentMethod, a.Status, a.Price, a.Total, a.CreatedAt.In(s.Cfg.Timezone),
+			strings.Join(intake.Summary(a.Intake), "; "), appointmentPlaceLabel(a.Location),
+		})
+	}
+
+	filename := fmt.Sprintf("rendez-vous-%s.%s", time.Now().In(s.Cfg.Timezone).Format("20060102-1504"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	if err := export.Write(w, format, table); err != nil {
+		log.Error("admin appointments export: write failed", slog.String("error", err.Error()))
+		return
+	}
+	log.Info("admin appointments export: ok", slog.String("format", format), slog.Int("count", len(appointments)))
+}
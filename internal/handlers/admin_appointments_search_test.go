@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+
+	"gbh-backend/internal/validation"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAppointmentSearchCursorRoundTrip(t *testing.T) {
+	s := &Server{Val: validation.New()}
+	search, details := s.parseAppointmentSearch(url.Values{"sort": {"-date"}, "status": {"booked,confirmed"}})
+	if details != nil {
+		t.Fatalf("parseAppointmentSearch() details = %v", details)
+	}
+	if !search.desc || len(search.keys) != 3 || search.keys[2] != "_id" {
+		t.Fatalf("unexpected sort: desc=%v keys=%v", search.desc, search.keys)
+	}
+
+	raw, err := search.encodeCursor(bson.M{"_id": "a1", "date": "2026-05-04", "time": "10:00"})
+	if err != nil {
+		t.Fatalf("encodeCursor() error = %v", err)
+	}
+	cursor, ok := search.decodeCursor(raw)
+	if !ok {
+		t.Fatalf("decodeCursor() rejected its own cursor")
+	}
+	filter := search.after(cursor)
+	branches := filter["$and"].([]bson.M)[1]["$or"].([]bson.M)
+	if len(branches) != 3 {
+		t.Fatalf("got %d keyset branches, want 3", len(branches))
+	}
+	last := branches[2]
+	if last["date"] != "2026-05-04" || last["time"] != "10:00" {
+		t.Fatalf("last branch = %v", last)
+	}
+	if op, ok := last["_id"].(bson.M)["$lt"]; !ok || op != "a1" {
+		t.Fatalf("last branch _id = %v, want $lt a1", last["_id"])
+	}
+
+	other, _ := s.parseAppointmentSearch(url.Values{"sort": {"name"}})
+	if _, ok := other.decodeCursor(raw); ok {
+		t.Fatalf("cursor accepted for a different sort")
+	}
+}
+
+func TestAppointmentSearchRejectsInvalidQuery(t *testing.T) {
+	s := &Server{Val: validation.New()}
+	for _, values := range []url.Values{
+		{"status": {"canceled"}},
+		{"from": {"2026-05-10"}, "to": {"2026-05-01"}},
+		{"sort": {"phone"}},
+		{"type": {"visio"}},
+	} {
+		if _, details := s.parseAppointmentSearch(values); details == nil {
+			t.Fatalf("parseAppointmentSearch(%v) accepted an invalid query", values)
+		}
+	}
+}
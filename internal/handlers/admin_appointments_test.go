@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/rbac"
+)
+
+// waitForMail waits for the mailer goroutines to have sent n emails and
+// returns the recipients, in order.
+func waitForMail(t *testing.T, m *recordingMailer, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		m.mu.Lock()
+		sent := slices.Clone(m.sent)
+		m.mu.Unlock()
+		if len(sent) >= n || time.Now().After(deadline) {
+			return sent
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func adminCreateAppointment(s *Server, req AdminCreateAppointmentRequest) (int, models.Appointment) {
+	rec := httptest.NewRecorder()
+	s.AdminCreateAppointment(rec, adminRequest(http.MethodPost, "/api/admin/appointments", "", req, rbac.Wildcard))
+	var appointment models.Appointment
+	_ = json.Unmarshal(rec.Body.Bytes(), &appointment)
+	return rec.Code, appointment
+}
+
+func adminUpdateAppointment(s *Server, id string, body interface{}) (int, models.Appointment) {
+	rec := httptest.NewRecorder()
+	s.AdminUpdateAppointment(rec, adminRequest(http.MethodPatch, "/api/admin/appointments/"+id, id, body, rbac.Wildcard))
+	var appointment models.Appointment
+	_ = json.Unmarshal(rec.Body.Bytes(), &appointment)
+	return rec.Code, appointment
+}
+
+func newAdminAppointmentRequest(date, clock, email string) AdminCreateAppointmentRequest {
+	return AdminCreateAppointmentRequest{
+		ServiceID:     "svc-test",
+		Name:          "Jean Test",
+		Email:         email,
+		Phone:         "+243810000000",
+		Type:          models.ConsultationOnline,
+		Date:          date,
+		Time:          clock,
+		PaymentMethod: models.PaymentPlace,
+	}
+}
+
+func TestAdminCreateAppointmentOverride(t *testing.T) {
+	s := newMongoTestServer(t)
+	if _, err := s.Cols.Services.InsertOne(context.Background(), models.Service{ID: "svc-test", Name: "Consultation"}); err != nil {
+		t.Fatalf("insert service: %v", err)
+	}
+	date := nextWeekday(s.Cfg.Timezone)
+
+	late := newAdminAppointmentRequest(date, "21:00", "late@example.com")
+	if code, _ := adminCreateAppointment(s, late); code != http.StatusBadRequest {
+		t.Fatalf("outside opening hours: status %d, want 400", code)
+	}
+	late.OverrideAvailability = true
+	if code, _ := adminCreateAppointment(s, late); code != http.StatusCreated {
+		t.Fatalf("outside opening hours with override: status %d, want 201", code)
+	}
+
+	first := newAdminAppointmentRequest(date, "09:45", "first@example.com")
+	if code, _ := adminCreateAppointment(s, first); code != http.StatusCreated {
+		t.Fatalf("first booking: status %d, want 201", code)
+	}
+	overlap := newAdminAppointmentRequest(date, "09:00", "overlap@example.com")
+	overlap.Duration = 90
+	if code, _ := adminCreateAppointment(s, overlap); code != http.StatusConflict {
+		t.Fatalf("overlapping booking: status %d, want 409", code)
+	}
+	overlap.OverrideAvailability = true
+	if code, _ := adminCreateAppointment(s, overlap); code != http.StatusCreated {
+		t.Fatalf("overlapping booking with override: status %d, want 201", code)
+	}
+	// The override never allows two appointments starting together.
+	same := newAdminAppointmentRequest(date, "09:45", "same@example.com")
+	same.OverrideAvailability = true
+	if code, _ := adminCreateAppointment(s, same); code != http.StatusConflict {
+		t.Fatalf("same start with override: status %d, want 409", code)
+	}
+}
+
+func TestAdminUpdateAppointmentMoveAndNotify(t *testing.T) {
+	s := newMongoTestServer(t)
+	mailer := &recordingMailer{}
+	s.Mailer = mailer
+	if _, err := s.Cols.Services.InsertOne(context.Background(), models.Service{ID: "svc-test", Name: "Consultation"}); err != nil {
+		t.Fatalf("insert service: %v", err)
+	}
+	date := nextWeekday(s.Cfg.Timezone)
+	quiet := false
+
+	// Creation confirms by default, unless notify is false.
+	silent := newAdminAppointmentRequest(date, "09:00", "silent@example.com")
+	silent.Notify = &quiet
+	code, first := adminCreateAppointment(s, silent)
+	if code != http.StatusCreated {
+		t.Fatalf("create first: status %d", code)
+	}
+	code, second := adminCreateAppointment(s, newAdminAppointmentRequest(date, "10:30", "second@example.com"))
+	if code != http.StatusCreated {
+		t.Fatalf("create second: status %d", code)
+	}
+	if sent := waitForMail(t, mailer, 1); !slices.Equal(sent, []string{"second@example.com"}) {
+		t.Fatalf("confirmations sent to %v, want second@example.com only", sent)
+	}
+
+	// A move only conflicts with other appointments, not with itself.
+	if code, _ := adminUpdateAppointment(s, second.ID, map[string]string{"time": "09:00"}); code != http.StatusConflict {
+		t.Fatalf("move onto the first appointment: status %d, want 409", code)
+	}
+	code, moved := adminUpdateAppointment(s, first.ID, map[string]int{"duration": 90})
+	if code != http.StatusOK || moved.Duration != 90 {
+		t.Fatalf("lengthen over its own slot: status %d, duration %d", code, moved.Duration)
+	}
+	if sent := waitForMail(t, mailer, 2); len(sent) != 2 || sent[1] != "silent@example.com" {
+		t.Fatalf("a move notifies by default, sent to %v", sent)
+	}
+
+	// Other edits only notify when asked to.
+	code, renamed := adminUpdateAppointment(s, first.ID, map[string]string{"name": " Amina Test "})
+	if code != http.StatusOK || renamed.Name != "Amina Test" {
+		t.Fatalf("rename: status %d, name %q", code, renamed.Name)
+	}
+	if code, _ := adminUpdateAppointment(s, first.ID, map[string]interface{}{"time": "14:00", "notify": false}); code != http.StatusOK {
+		t.Fatalf("silent move: status %d", code)
+	}
+	if code, _ := adminUpdateAppointment(s, second.ID, map[string]interface{}{"phone": "+243820000000", "notify": true}); code != http.StatusOK {
+		t.Fatalf("notified edit: status %d", code)
+	}
+	if sent := waitForMail(t, mailer, 3); len(sent) != 3 || sent[2] != "second@example.com" {
+		t.Fatalf("expected only the asked-for notification, sent to %v", sent)
+	}
+}
+
+func TestAdminUpdateAppointmentTrimsBeforeValidation(t *testing.T) {
+	s := newMongoTestServer(t)
+	if _, err := s.Cols.Services.InsertOne(context.Background(), models.Service{ID: "svc-test", Name: "Consultation"}); err != nil {
+		t.Fatalf("insert service: %v", err)
+	}
+	code, appointment := adminCreateAppointment(s, newAdminAppointmentRequest(nextWeekday(s.Cfg.Timezone), "09:00", "jean@example.com"))
+	if code != http.StatusCreated {
+		t.Fatalf("create: status %d", code)
+	}
+
+	for _, body := range []map[string]string{
+		{"name": "   "},
+		{"serviceId": " "},
+		{"email": " not-an-email "},
+	} {
+		if code, _ := adminUpdateAppointment(s, appointment.ID, body); code != http.StatusBadRequest {
+			t.Fatalf("update %v: status %d, want 400", body, code)
+		}
+	}
+	code, updated := adminUpdateAppointment(s, appointment.ID, map[string]string{"email": " amina@example.com "})
+	if code != http.StatusOK || updated.Email != "amina@example.com" || updated.Name != "Jean Test" {
+		t.Fatalf("update email: status %d, %+v", code, updated)
+	}
+}
@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"gbh-backend/internal/auth"
+	"gbh-backend/internal/middleware"
 	"gbh-backend/internal/models"
 	"gbh-backend/internal/transport"
 	"go.mongodb.org/mongo-driver/bson"
@@ -20,8 +23,12 @@ type AdminLoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// AdminLoginResponse.Status is "ok", "two_factor_required" (send the
+// challenge token and a code to /login/2fa) or "two_factor_enrolment_required"
+// (signed in, but only /me and /2fa/* work until TOTP is set up).
 type AdminLoginResponse struct {
-	Status string `json:"status"`
+	Status         string `json:"status"`
+	ChallengeToken string `json:"challengeToken,omitempty"`
 }
 
 func (s *Server) AdminLogin(w http.ResponseWriter, r *http.Request) {
@@ -54,9 +61,22 @@ func (s *Server) AdminLogin(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
+	now := time.Now().In(s.Cfg.Timezone)
+	ip := middleware.ClientIP(r)
+	if blocked, err := s.loginIPBlocked(ctx, ip, now); err != nil {
+		log.Error("admin login: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	} else if blocked {
+		log.Warn("admin login: ip blocked", slog.String("ip", ip), slog.String("username", req.Username))
+		s.recordLoginEvent(ctx, r, "", req.Username, false, models.LoginBlockedIP)
+		w.Header().Set("Retry-After", strconv.Itoa(int(s.loginLockoutWindow().Seconds())))
+		transport.WriteError(w, http.StatusTooManyRequests, "too many attempts", nil)
+		return
+	}
+
 	var user models.User
 	filter := bson.M{
-		"role": models.UserRoleAdmin,
 		"$or": []bson.M{
 			{"username": req.Username},
 			{"email": req.Username},
@@ -66,6 +86,8 @@ func (s *Server) AdminLogin(w http.ResponseWriter, r *http.Request) {
 	if err := s.Cols.Users.FindOne(ctx, filter, findOpts).Decode(&user); err != nil {
 		if err == mongo.ErrNoDocuments {
 			log.Warn("admin login: invalid credentials", slog.String("username", req.Username))
+			s.recordLoginEvent(ctx, r, "", req.Username, false, models.LoginFailedCredentials)
+			s.registerIPFailure(ctx, r, req.Username)
 			transport.WriteError(w, http.StatusUnauthorized, "invalid credentials", nil)
 			return
 		}
@@ -74,20 +96,50 @@ func (s *Server) AdminLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if wait, locked := loginRetryAfter(user, now); wait > 0 {
+		log.Warn("admin login: attempt refused", slog.String("user_id", user.ID), slog.Bool("locked", locked))
+		s.refuseLoginAttempt(ctx, w, r, user, req.Username, wait, locked)
+		return
+	}
 	if err := auth.ComparePassword(user.PasswordHash, req.Password); err != nil {
 		log.Warn("admin login: invalid credentials", slog.String("username", req.Username))
+		s.registerLoginFailure(ctx, r, user, req.Username, models.LoginFailedCredentials)
+		s.registerIPFailure(ctx, r, req.Username)
 		transport.WriteError(w, http.StatusUnauthorized, "invalid credentials", nil)
 		return
 	}
+	if user.DisabledAt != nil {
+		log.Warn("admin login: account disabled", slog.String("user_id", user.ID))
+		s.recordLoginEvent(ctx, r, user.ID, req.Username, false, models.LoginFailedDisabled)
+		transport.WriteError(w, http.StatusForbidden, "account disabled", nil)
+		return
+	}
 
-	_, _, err := s.issueAdminSession(w)
-	if err != nil {
+	if user.TOTPEnabledAt != nil {
+		if err := s.startTwoFactorChallenge(w, user); err != nil {
+			log.Error("admin login: token error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+			return
+		}
+		log.Info("admin login: two-factor challenge", slog.String("username", req.Username))
+		return
+	}
+
+	if err := s.startAdminSession(ctx, w, r, user); err != nil {
 		log.Error("admin login: token error", slog.String("error", err.Error()))
 		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
 		return
 	}
+	if err := s.clearLoginFailures(ctx, user); err != nil {
+		log.Warn("admin login: failed to reset failures", slog.String("error", err.Error()))
+	}
+	s.recordLoginEvent(ctx, r, user.ID, req.Username, true, "")
+	status := "ok"
+	if s.Cfg.Admin2FARequired {
+		status = "two_factor_enrolment_required"
+	}
 	log.Info("admin login: ok", slog.String("username", req.Username))
-	transport.WriteJSON(w, http.StatusOK, AdminLoginResponse{Status: "ok"})
+	transport.WriteJSON(w, http.StatusOK, AdminLoginResponse{Status: status})
 }
 
 func (s *Server) AdminRefresh(w http.ResponseWriter, r *http.Request) {
@@ -108,29 +160,117 @@ func (s *Server) AdminRefresh(w http.ResponseWriter, r *http.Request) {
 	manager := s.newAdminJWTManager()
 
 	claims, err := manager.Parse(refreshToken)
-	if err != nil || claims.Role != models.UserRoleAdmin {
+	if err != nil || claims.Type != auth.TokenTypeRefresh || claims.Subject == "" || claims.SessionID == "" {
 		log.Warn("admin refresh: invalid refresh token")
 		transport.WriteError(w, http.StatusUnauthorized, "invalid refresh token", nil)
 		return
 	}
 
-	_, _, err = s.issueAdminSession(w)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, found, err := s.LookupUser(ctx, claims.Subject)
 	if err != nil {
-		log.Error("admin refresh: token error", slog.String("error", err.Error()))
-		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+		log.Error("admin refresh: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
 		return
 	}
-	log.Info("admin refresh: ok")
+	if !found || user.DisabledAt != nil {
+		log.Warn("admin refresh: user not allowed", slog.String("user_id", claims.Subject))
+		transport.WriteError(w, http.StatusUnauthorized, "invalid refresh token", nil)
+		return
+	}
+
+	if err := s.rotateAdminSession(ctx, w, r, claims, user); err != nil {
+		switch {
+		case errors.Is(err, errSessionReused):
+			log.Warn("admin refresh: refresh token reuse, session revoked",
+				slog.String("user_id", claims.Subject),
+				slog.String("session_id", claims.SessionID),
+			)
+			clearAuthCookies(w, s.Cfg.CookieSecure)
+			transport.WriteError(w, http.StatusUnauthorized, "invalid refresh token", nil)
+		case errors.Is(err, errSessionInvalid):
+			log.Warn("admin refresh: session not active", slog.String("session_id", claims.SessionID))
+			clearAuthCookies(w, s.Cfg.CookieSecure)
+			transport.WriteError(w, http.StatusUnauthorized, "invalid refresh token", nil)
+		default:
+			log.Error("admin refresh: session error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		}
+		return
+	}
+	log.Info("admin refresh: ok", slog.String("user_id", user.ID))
 	transport.WriteJSON(w, http.StatusOK, AdminLoginResponse{Status: "ok"})
 }
 
+// AdminLogout revokes the session behind the refresh token (or, failing
+// that, the access token) and clears the cookies.
 func (s *Server) AdminLogout(w http.ResponseWriter, r *http.Request) {
 	log := s.logWithRequest(r)
+	if sessionID := s.requestSessionID(r); sessionID != "" {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		if err := s.revokeAdminSessions(ctx, bson.M{"_id": sessionID}, models.SessionRevokedLogout); err != nil {
+			log.Error("admin logout: database error", slog.String("error", err.Error()))
+		}
+	}
 	clearAuthCookies(w, s.Cfg.CookieSecure)
 	log.Info("admin logout: ok")
 	transport.WriteJSON(w, http.StatusOK, AdminLoginResponse{Status: "ok"})
 }
 
+type AdminMeResponse struct {
+	models.User
+	Permissions []string `json:"permissions"`
+}
+
+// AdminMe returns the authenticated admin and what they may do.
+func (s *Server) AdminMe(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		transport.WriteError(w, http.StatusUnauthorized, "unauthorized", nil)
+		return
+	}
+	if principal.APIKey {
+		transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"username":    principal.Username,
+			"role":        principal.Role,
+			"permissions": principal.Permissions,
+			"apiKey":      true,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, found, err := s.LookupUser(ctx, principal.UserID)
+	if err != nil {
+		log.Error("admin me: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !found {
+		transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+		return
+	}
+	transport.WriteJSON(w, http.StatusOK, AdminMeResponse{User: user, Permissions: principal.Permissions})
+}
+
+// LookupUser loads a user by ID for the admin auth middleware.
+func (s *Server) LookupUser(ctx context.Context, id string) (models.User, bool, error) {
+	var user models.User
+	if err := s.Cols.Users.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.User{}, false, nil
+		}
+		return models.User{}, false, err
+	}
+	return user, true, nil
+}
+
 func (s *Server) newAdminJWTManager() auth.Manager {
 	return auth.Manager{
 		Secret:     []byte(s.Cfg.JWTSecret),
@@ -140,20 +280,27 @@ func (s *Server) newAdminJWTManager() auth.Manager {
 	}
 }
 
-func (s *Server) issueAdminSession(w http.ResponseWriter) (string, string, error) {
+// requestSessionID returns the session of the refresh or access token the
+// request carries. Expired tokens still identify their session.
+func (s *Server) requestSessionID(r *http.Request) string {
+	if s.Cfg.JWTSecret == "" {
+		return ""
+	}
 	manager := s.newAdminJWTManager()
-
-	accessToken, err := manager.NewAccessToken(models.UserRoleAdmin)
-	if err != nil {
-		return "", "", err
+	candidates := []string{extractRefreshToken(r)}
+	if cookie, err := r.Cookie("gbh_access"); err == nil {
+		candidates = append(candidates, cookie.Value)
 	}
-	refreshToken, err := manager.NewRefreshToken(models.UserRoleAdmin)
-	if err != nil {
-		return "", "", err
+	for _, token := range candidates {
+		if token == "" {
+			continue
+		}
+		claims, err := manager.ParseIgnoringExpiry(token)
+		if err == nil && claims.SessionID != "" {
+			return claims.SessionID
+		}
 	}
-
-	setAuthCookies(w, accessToken, refreshToken, manager.AccessTTL, manager.RefreshTTL, s.Cfg.CookieSecure)
-	return accessToken, refreshToken, nil
+	return ""
 }
 
 func setAuthCookies(w http.ResponseWriter, access, refresh string, accessTTL, refreshTTL time.Duration, secure bool) {
@@ -0,0 +1,503 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/httpx"
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/notifications"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AdminInvitationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required"`
+}
+
+type AdminInvitationAcceptRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Username string `json:"username" validate:"required,max=80"`
+	Password string `json:"password" validate:"required"`
+}
+
+// AdminInvitationResponse reports whether the email went out; a failed send
+// can be retried with the resend endpoint.
+type AdminInvitationResponse struct {
+	models.Invitation
+	EmailSent bool `json:"emailSent"`
+}
+
+// AdminInvitationPreview is what the sign-up page shows before the invitee
+// picks a username and password.
+type AdminInvitationPreview struct {
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (s *Server) invitationTTL() time.Duration {
+	hours := s.Cfg.InvitationTTLHours
+	if hours <= 0 {
+		hours = 72
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func (s *Server) invitationsConfigured() bool {
+	return s.Cols != nil && s.Cols.Invitations != nil && s.Cols.Users != nil &&
+		s.Mailer != nil && s.Cfg.AdminInviteURL != "" && s.Cfg.JWTSecret != ""
+}
+
+// AdminCreateInvitation invites an email address to join with a role. The
+// caller can only hand out a role whose permissions they hold themselves.
+func (s *Server) AdminCreateInvitation(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminInvitationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin invitations create: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	_, req.Email = normalizeAdminUserIdentity("", req.Email)
+	req.Role = strings.TrimSpace(req.Role)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin invitations create: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if !s.invitationsConfigured() {
+		log.Warn("admin invitations create: not configured")
+		transport.WriteError(w, http.StatusServiceUnavailable, "invitations not configured", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	perms, found, err := s.RolePermissions(ctx, req.Role)
+	if err != nil {
+		log.Error("admin invitations create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !found {
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"role": "unknown"})
+		return
+	}
+	if missing := ungrantableScope(r.Context(), perms); missing != "" {
+		log.Warn("admin invitations create: role above caller", slog.String("role", req.Role))
+		transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": missing})
+		return
+	}
+
+	findOpts := options.FindOne().SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	if err := s.Cols.Users.FindOne(ctx, bson.M{"email": req.Email}, findOpts).Err(); err == nil {
+		transport.WriteError(w, http.StatusConflict, "a user with this email already exists", nil)
+		return
+	} else if err != mongo.ErrNoDocuments {
+		log.Error("admin invitations create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	// A pending invitation that has expired no longer blocks a new one.
+	now := time.Now().In(s.Cfg.Timezone)
+	expired := bson.M{"email": req.Email, "status": models.InvitationStatusPending, "expiresAt": bson.M{"$lte": now}}
+	if _, err := s.Cols.Invitations.UpdateMany(ctx, expired, bson.M{"$set": bson.M{"status": models.InvitationStatusRevoked, "revokedAt": now}}); err != nil {
+		log.Error("admin invitations create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	tokenID, err := auth.NewTokenID()
+	if err != nil {
+		log.Error("admin invitations create: token error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	invitation := models.Invitation{
+		ID:             primitive.NewObjectID().Hex(),
+		Email:          req.Email,
+		Role:           req.Role,
+		Status:         models.InvitationStatusPending,
+		CurrentTokenID: tokenID,
+		InvitedBy:      principal.Username,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(s.invitationTTL()),
+	}
+	if _, err := s.Cols.Invitations.InsertOne(ctx, invitation); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			transport.WriteError(w, http.StatusConflict, "invitation already pending", nil)
+			return
+		}
+		log.Error("admin invitations create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	sent := s.sendInvitation(ctx, log, &invitation)
+	log.Info("admin invitations create: ok", slog.String("invitation_id", invitation.ID), slog.String("role", invitation.Role), slog.Bool("email_sent", sent))
+	transport.WriteJSON(w, http.StatusCreated, AdminInvitationResponse{Invitation: invitation, EmailSent: sent})
+}
+
+// AdminListInvitations lists invitations, newest first. status is
+// "pending" (default), "expired", "accepted", "revoked" or "all".
+func (s *Server) AdminListInvitations(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	limit, offset, err := httpx.ParseLimitOffset(r.URL.Query(), 50, 200)
+	if err != nil {
+		log.Warn("admin invitations list: invalid query", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	now := time.Now()
+	filter := bson.M{}
+	switch r.URL.Query().Get("status") {
+	case "", models.InvitationStatusPending:
+		filter["status"] = models.InvitationStatusPending
+		filter["expiresAt"] = bson.M{"$gt": now}
+	case "expired":
+		filter["status"] = models.InvitationStatusPending
+		filter["expiresAt"] = bson.M{"$lte": now}
+	case models.InvitationStatusAccepted:
+		filter["status"] = models.InvitationStatusAccepted
+	case models.InvitationStatusRevoked:
+		filter["status"] = models.InvitationStatusRevoked
+	case "all":
+	default:
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"status": "oneof"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	total, err := s.Cols.Invitations.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Error("admin invitations list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var items []models.Invitation
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	if err := s.findAll(ctx, s.Cols.Invitations, filter, opts, &items); err != nil {
+		log.Error("admin invitations list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if items == nil {
+		items = []models.Invitation{}
+	}
+
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+	})
+}
+
+// AdminResendInvitation mails a fresh link, which also restarts the expiry.
+// Links sent before stop working.
+func (s *Server) AdminResendInvitation(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if !s.invitationsConfigured() {
+		log.Warn("admin invitations resend: not configured")
+		transport.WriteError(w, http.StatusServiceUnavailable, "invitations not configured", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	tokenID, err := auth.NewTokenID()
+	if err != nil {
+		log.Error("admin invitations resend: token error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+		return
+	}
+	now := time.Now().In(s.Cfg.Timezone)
+	update := bson.M{"$set": bson.M{
+		"currentTokenId": tokenID,
+		"expiresAt":      now.Add(s.invitationTTL()),
+	}}
+	var invitation models.Invitation
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	filter := bson.M{"_id": id, "status": models.InvitationStatusPending}
+	if err := s.Cols.Invitations.FindOneAndUpdate(ctx, filter, update, opts).Decode(&invitation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "invitation not found", nil)
+			return
+		}
+		log.Error("admin invitations resend: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	sent := s.sendInvitation(ctx, log, &invitation)
+	log.Info("admin invitations resend: ok", slog.String("invitation_id", invitation.ID), slog.Bool("email_sent", sent))
+	transport.WriteJSON(w, http.StatusOK, AdminInvitationResponse{Invitation: invitation, EmailSent: sent})
+}
+
+func (s *Server) AdminRevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"status":    models.InvitationStatusRevoked,
+		"revokedAt": time.Now().In(s.Cfg.Timezone),
+	}}
+	res, err := s.Cols.Invitations.UpdateOne(ctx, bson.M{"_id": id, "status": models.InvitationStatusPending}, update)
+	if err != nil {
+		log.Error("admin invitations revoke: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.MatchedCount == 0 {
+		transport.WriteError(w, http.StatusNotFound, "invitation not found", nil)
+		return
+	}
+
+	log.Info("admin invitations revoke: ok", slog.String("invitation_id", id))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
+
+// AdminPreviewInvitation checks an invitation link for the sign-up page.
+func (s *Server) AdminPreviewInvitation(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	if s.Cols == nil || s.Cols.Invitations == nil || s.Cfg.JWTSecret == "" {
+		transport.WriteError(w, http.StatusServiceUnavailable, "invitations not configured", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	invitation, ok, err := s.invitationFromToken(ctx, r.URL.Query().Get("token"))
+	if err != nil {
+		log.Error("admin invitations preview: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !ok {
+		transport.WriteError(w, http.StatusBadRequest, "invalid or expired invitation", nil)
+		return
+	}
+	transport.WriteJSON(w, http.StatusOK, AdminInvitationPreview{
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		ExpiresAt: invitation.ExpiresAt,
+	})
+}
+
+// AdminAcceptInvitation creates the invitee's account with the invited email
+// and role, burns the invitation and signs them in.
+func (s *Server) AdminAcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminInvitationAcceptRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin invitations accept: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	req.Username, _ = normalizeAdminUserIdentity(req.Username, "")
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin invitations accept: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if s.Cols == nil || s.Cols.Invitations == nil || s.Cols.Users == nil || s.Cfg.JWTSecret == "" {
+		log.Warn("admin invitations accept: not configured")
+		transport.WriteError(w, http.StatusServiceUnavailable, "invitations not configured", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	invitation, ok, err := s.invitationFromToken(ctx, req.Token)
+	if err != nil {
+		log.Error("admin invitations accept: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !ok {
+		log.Warn("admin invitations accept: invalid token")
+		transport.WriteError(w, http.StatusBadRequest, "invalid or expired invitation", nil)
+		return
+	}
+	if details := passwordStrengthDetails(req.Password, req.Username, invitation.Email); details != nil {
+		log.Warn("admin invitations accept: weak password", slog.String("invitation_id", invitation.ID))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if _, found, err := s.RolePermissions(ctx, invitation.Role); err != nil {
+		log.Error("admin invitations accept: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	} else if !found {
+		log.Warn("admin invitations accept: role removed", slog.String("role", invitation.Role))
+		transport.WriteError(w, http.StatusConflict, "invited role no longer exists", nil)
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		log.Error("admin invitations accept: hash error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "password error", nil)
+		return
+	}
+
+	// Claim the invitation first so the same link cannot create two accounts;
+	// the claim is released if the account cannot be created.
+	now := time.Now().In(s.Cfg.Timezone)
+	user := models.User{
+		ID:           primitive.NewObjectID().Hex(),
+		Username:     req.Username,
+		Email:        invitation.Email,
+		PasswordHash: hash,
+		Role:         invitation.Role,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	claimFilter := bson.M{
+		"_id":            invitation.ID,
+		"status":         models.InvitationStatusPending,
+		"currentTokenId": invitation.CurrentTokenID,
+	}
+	claim := bson.M{"$set": bson.M{
+		"status":     models.InvitationStatusAccepted,
+		"acceptedAt": now,
+		"userId":     user.ID,
+	}}
+	res, err := s.Cols.Invitations.UpdateOne(ctx, claimFilter, claim)
+	if err != nil {
+		log.Error("admin invitations accept: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.ModifiedCount == 0 {
+		transport.WriteError(w, http.StatusBadRequest, "invalid or expired invitation", nil)
+		return
+	}
+
+	if _, err := s.Cols.Users.InsertOne(ctx, user); err != nil {
+		release := bson.M{
+			"$set":   bson.M{"status": models.InvitationStatusPending},
+			"$unset": bson.M{"acceptedAt": "", "userId": ""},
+		}
+		if _, rerr := s.Cols.Invitations.UpdateOne(ctx, bson.M{"_id": invitation.ID}, release); rerr != nil {
+			log.Error("admin invitations accept: release failed", slog.String("error", rerr.Error()))
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			log.Warn("admin invitations accept: duplicate", slog.String("username", req.Username))
+			transport.WriteError(w, http.StatusConflict, "username or email already exists", nil)
+			return
+		}
+		log.Error("admin invitations accept: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin invitations accept: ok", slog.String("invitation_id", invitation.ID), slog.String("user_id", user.ID))
+	if err := s.startAdminSession(ctx, w, r, user); err != nil {
+		log.Error("admin invitations accept: token error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+		return
+	}
+	status := "ok"
+	if s.Cfg.Admin2FARequired {
+		status = "two_factor_enrolment_required"
+	}
+	transport.WriteJSON(w, http.StatusCreated, AdminLoginResponse{Status: status})
+}
+
+// invitationFromToken verifies an invitation link and returns the pending,
+// unexpired invitation it belongs to.
+func (s *Server) invitationFromToken(ctx context.Context, token string) (models.Invitation, bool, error) {
+	if token == "" {
+		return models.Invitation{}, false, nil
+	}
+	manager := s.newAdminJWTManager()
+	claims, err := manager.Parse(token)
+	if err != nil || claims.Type != auth.TokenTypeInvitation || claims.Subject == "" || claims.ID == "" {
+		return models.Invitation{}, false, nil
+	}
+	var invitation models.Invitation
+	filter := bson.M{
+		"_id":            claims.Subject,
+		"status":         models.InvitationStatusPending,
+		"currentTokenId": claims.ID,
+		"expiresAt":      bson.M{"$gt": time.Now()},
+	}
+	if err := s.Cols.Invitations.FindOne(ctx, filter).Decode(&invitation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.Invitation{}, false, nil
+		}
+		return models.Invitation{}, false, err
+	}
+	return invitation, true, nil
+}
+
+// sendInvitation mails the current link of invitation and records the send.
+// Failures are logged; the caller reports them.
+func (s *Server) sendInvitation(ctx context.Context, log *slog.Logger, invitation *models.Invitation) bool {
+	ttl := time.Until(invitation.ExpiresAt)
+	manager := s.newAdminJWTManager()
+	token, err := manager.NewInvitationToken(invitation.ID, invitation.CurrentTokenID, ttl)
+	if err != nil {
+		log.Error("admin invitations: token error", slog.String("error", err.Error()))
+		return false
+	}
+	link, err := tokenLink(s.Cfg.AdminInviteURL, token)
+	if err != nil {
+		log.Error("admin invitations: invalid invite url", slog.String("error", err.Error()))
+		return false
+	}
+	htmlBody, err := notifications.BuildInvitationHTML(notifications.InvitationEmail{
+		InvitedBy: invitation.InvitedBy,
+		Role:      invitation.Role,
+		AcceptURL: link,
+		ExpiresAt: invitation.ExpiresAt.In(s.Cfg.Timezone).Format("02/01/2006 a 15:04"),
+	})
+	if err != nil {
+		log.Error("admin invitations: template error", slog.String("error", err.Error()))
+		return false
+	}
+	if _, err := s.Mailer.SendEmail(ctx, invitation.Email, "", "Invitation a l'espace d'administration GBH", htmlBody); err != nil {
+		log.Error("admin invitations: send failed", slog.String("invitation_id", invitation.ID), slog.String("error", err.Error()))
+		return false
+	}
+
+	now := time.Now().In(s.Cfg.Timezone)
+	update := bson.M{"$set": bson.M{"lastSentAt": now}, "$inc": bson.M{"sendCount": 1}}
+	if _, err := s.Cols.Invitations.UpdateOne(ctx, bson.M{"_id": invitation.ID}, update); err != nil {
+		log.Warn("admin invitations: failed to record send", slog.String("error", err.Error()))
+	}
+	invitation.LastSentAt = &now
+	invitation.SendCount++
+	return true
+}
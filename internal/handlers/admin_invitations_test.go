@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/rbac"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func newInvitationTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := newMongoTestServer(t)
+	s.Cfg.JWTSecret = "test-secret"
+	s.Cfg.AccessTTLMinutes = 15
+	s.Cfg.RefreshTTLMinutes = 60
+	s.Cfg.AdminInviteURL = "https://example.com/admin/invitation"
+	s.Mailer = &recordingMailer{}
+	return s
+}
+
+// invitationToken mints the link token currently valid for an invitation,
+// as sendInvitation would mail it.
+func invitationToken(t *testing.T, s *Server, id string) string {
+	t.Helper()
+	var invitation models.Invitation
+	if err := s.Cols.Invitations.FindOne(context.Background(), bson.M{"_id": id}).Decode(&invitation); err != nil {
+		t.Fatalf("find invitation %s: %v", id, err)
+	}
+	manager := s.newAdminJWTManager()
+	token, err := manager.NewInvitationToken(invitation.ID, invitation.CurrentTokenID, time.Hour)
+	if err != nil {
+		t.Fatalf("NewInvitationToken() error = %v", err)
+	}
+	return token
+}
+
+func TestInvitationLifecycle(t *testing.T) {
+	s := newInvitationTestServer(t)
+	ctx := context.Background()
+	// The caller may manage users and holds every sales permission, but
+	// not the admin ones.
+	perms := []string{rbac.UsersWrite, "rfp:*", "contacts:*"}
+
+	invite := func(email, role string) (int, AdminInvitationResponse) {
+		rec := httptest.NewRecorder()
+		s.AdminCreateInvitation(rec, adminRequest(http.MethodPost, "/api/admin/invitations", "", AdminInvitationRequest{Email: email, Role: role}, perms...))
+		var resp AdminInvitationResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+	accept := func(token, username string) int {
+		rec := httptest.NewRecorder()
+		body, _ := json.Marshal(AdminInvitationAcceptRequest{Token: token, Username: username, Password: "Correct-Horse-42!"})
+		s.AdminAcceptInvitation(rec, httptest.NewRequest(http.MethodPost, "/api/admin/invitations/accept", strings.NewReader(string(body))))
+		return rec.Code
+	}
+	call := func(handler http.HandlerFunc, id string) int {
+		rec := httptest.NewRecorder()
+		handler(rec, adminRequest(http.MethodPost, "/api/admin/invitations/"+id, id, nil, perms...))
+		return rec.Code
+	}
+
+	if code, _ := invite("boss@example.com", rbac.RoleAdmin); code != http.StatusForbidden {
+		t.Fatalf("invite as admin: status %d, want 403", code)
+	}
+	code, created := invite("Seller@Example.com", rbac.RoleSales)
+	if code != http.StatusCreated || !created.EmailSent || created.Email != "seller@example.com" {
+		t.Fatalf("invite as sales: status %d, %+v", code, created)
+	}
+	if code, _ := invite("seller@example.com", rbac.RoleSales); code != http.StatusConflict {
+		t.Fatalf("second pending invitation: status %d, want 409", code)
+	}
+
+	// The link is single use.
+	token := invitationToken(t, s, created.ID)
+	if code := accept(token, "seller"); code != http.StatusCreated {
+		t.Fatalf("accept: status %d, want 201", code)
+	}
+	if code := accept(token, "seller-again"); code != http.StatusBadRequest {
+		t.Fatalf("accept twice: status %d, want 400", code)
+	}
+	var user models.User
+	if err := s.Cols.Users.FindOne(ctx, bson.M{"username": "seller"}).Decode(&user); err != nil {
+		t.Fatalf("find invited user: %v", err)
+	}
+	if user.Email != "seller@example.com" || user.Role != rbac.RoleSales {
+		t.Fatalf("invited user = %+v", user)
+	}
+	if code, _ := invite("seller@example.com", rbac.RoleSales); code != http.StatusConflict {
+		t.Fatalf("invite an existing user: status %d, want 409", code)
+	}
+
+	// Resending replaces the link; revoking kills the current one.
+	_, second := invite("second@example.com", rbac.RoleSales)
+	stale := invitationToken(t, s, second.ID)
+	if code := call(s.AdminResendInvitation, second.ID); code != http.StatusOK {
+		t.Fatalf("resend: status %d, want 200", code)
+	}
+	if code := accept(stale, "second"); code != http.StatusBadRequest {
+		t.Fatalf("accept with a resent link: status %d, want 400", code)
+	}
+	fresh := invitationToken(t, s, second.ID)
+	if code := call(s.AdminRevokeInvitation, second.ID); code != http.StatusOK {
+		t.Fatalf("revoke: status %d, want 200", code)
+	}
+	if code := accept(fresh, "second"); code != http.StatusBadRequest {
+		t.Fatalf("accept a revoked invitation: status %d, want 400", code)
+	}
+	if code := call(s.AdminRevokeInvitation, second.ID); code != http.StatusNotFound {
+		t.Fatalf("revoke twice: status %d, want 404", code)
+	}
+	if code := call(s.AdminResendInvitation, second.ID); code != http.StatusNotFound {
+		t.Fatalf("resend a revoked invitation: status %d, want 404", code)
+	}
+
+	// An expired invitation cannot be accepted and no longer blocks a new one.
+	_, third := invite("third@example.com", rbac.RoleSales)
+	expire := bson.M{"$set": bson.M{"expiresAt": time.Now().Add(-time.Minute)}}
+	if _, err := s.Cols.Invitations.UpdateOne(ctx, bson.M{"_id": third.ID}, expire); err != nil {
+		t.Fatalf("expire invitation: %v", err)
+	}
+	if code := accept(invitationToken(t, s, third.ID), "third"); code != http.StatusBadRequest {
+		t.Fatalf("accept an expired invitation: status %d, want 400", code)
+	}
+	if code, _ := invite("third@example.com", rbac.RoleSales); code != http.StatusCreated {
+		t.Fatalf("invite again after expiry: status %d, want 201", code)
+	}
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/httpx"
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *Server) loginLockoutWindow() time.Duration {
+	minutes := s.Cfg.LoginLockoutMinutes
+	if minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// loginIPBlocked reports whether ip has failed too many logins recently.
+func (s *Server) loginIPBlocked(ctx context.Context, ip string, now time.Time) (bool, error) {
+	if s.Cfg.LoginIPMaxFailures <= 0 || s.Cols.LoginHistory == nil {
+		return false, nil
+	}
+	filter := bson.M{
+		"ip":        ip,
+		"success":   false,
+		"createdAt": bson.M{"$gte": now.Add(-s.loginLockoutWindow())},
+	}
+	count, err := s.Cols.LoginHistory.CountDocuments(ctx, filter, options.Count().SetLimit(int64(s.Cfg.LoginIPMaxFailures)))
+	if err != nil {
+		return false, err
+	}
+	return count >= int64(s.Cfg.LoginIPMaxFailures), nil
+}
+
+// loginRetryAfter returns how long user must wait before a login attempt is
+// examined, and whether that is because the account is locked rather than
+// merely slowed down.
+func loginRetryAfter(user models.User, now time.Time) (time.Duration, bool) {
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return user.LockedUntil.Sub(now), true
+	}
+	if user.LastFailedLoginAt != nil {
+		if next := user.LastFailedLoginAt.Add(auth.LoginBackoff(user.FailedLogins)); now.Before(next) {
+			return next.Sub(now), false
+		}
+	}
+	return 0, false
+}
+
+// refuseLoginAttempt answers an attempt made while the account is locked or
+// throttled, without looking at the credentials.
+func (s *Server) refuseLoginAttempt(ctx context.Context, w http.ResponseWriter, r *http.Request, user models.User, identifier string, wait time.Duration, locked bool) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	if locked {
+		s.recordLoginEvent(ctx, r, user.ID, identifier, false, models.LoginBlockedLocked)
+		transport.WriteError(w, http.StatusLocked, "account locked", nil)
+		return
+	}
+	s.recordLoginEvent(ctx, r, user.ID, identifier, false, models.LoginBlockedThrottled)
+	transport.WriteError(w, http.StatusTooManyRequests, "too many attempts", nil)
+}
+
+// registerLoginFailure counts a failed password or second factor against
+// user, locks the account once the threshold is reached and alerts the other
+// admins when it does.
+func (s *Server) registerLoginFailure(ctx context.Context, r *http.Request, user models.User, identifier, reason string) {
+	log := s.logWithRequest(r)
+	now := time.Now().In(s.Cfg.Timezone)
+	s.recordLoginEvent(ctx, r, user.ID, identifier, false, reason)
+
+	var updated models.User
+	update := bson.M{
+		"$inc": bson.M{"failedLogins": 1},
+		"$set": bson.M{"lastFailedLoginAt": now},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.Users.FindOneAndUpdate(ctx, bson.M{"_id": user.ID}, update, opts).Decode(&updated); err != nil {
+		log.Error("admin login: failed to count failure", slog.String("user_id", user.ID), slog.String("error", err.Error()))
+		return
+	}
+	if s.Cfg.LoginLockoutThreshold <= 0 || updated.FailedLogins < s.Cfg.LoginLockoutThreshold {
+		return
+	}
+
+	// The counter restarts so the account gets the same allowance once the
+	// lock expires.
+	until := now.Add(s.loginLockoutWindow())
+	lock := bson.M{
+		"$set":   bson.M{"lockedUntil": until},
+		"$unset": bson.M{"failedLogins": "", "lastFailedLoginAt": ""},
+	}
+	if _, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": user.ID}, lock); err != nil {
+		log.Error("admin login: failed to lock account", slog.String("user_id", user.ID), slog.String("error", err.Error()))
+		return
+	}
+	log.Warn("admin login: account locked", slog.String("user_id", user.ID), slog.Time("locked_until", until))
+
+	ip := middleware.ClientIP(r)
+	go func(user models.User, ip string, until time.Time) {
+		subject := "Compte admin verrouille"
+		body := fmt.Sprintf("<p>Le compte <strong>%s</strong> a ete verrouille apres %d tentatives de connexion echouees (derniere depuis l'adresse %s).</p><p>Il sera deverrouille automatiquement le %s, ou plus tot depuis la gestion des utilisateurs.</p>",
+			html.EscapeString(user.Username), s.Cfg.LoginLockoutThreshold, html.EscapeString(ip), until.Format("02/01/2006 a 15:04"))
+		s.notifySecurityAlert(context.Background(), user.Email, subject, body)
+	}(user, ip, until)
+}
+
+// registerIPFailure alerts the admins the first time an IP crosses the
+// failure limit within the window.
+func (s *Server) registerIPFailure(ctx context.Context, r *http.Request, identifier string) {
+	if s.Cfg.LoginIPMaxFailures <= 0 || s.Cols.LoginHistory == nil {
+		return
+	}
+	ip := middleware.ClientIP(r)
+	now := time.Now().In(s.Cfg.Timezone)
+	filter := bson.M{
+		"ip":        ip,
+		"success":   false,
+		"createdAt": bson.M{"$gte": now.Add(-s.loginLockoutWindow())},
+	}
+	count, err := s.Cols.LoginHistory.CountDocuments(ctx, filter)
+	if err != nil || count != int64(s.Cfg.LoginIPMaxFailures) {
+		return
+	}
+	s.logWithRequest(r).Warn("admin login: ip blocked", slog.String("ip", ip))
+	go func(ip, identifier string) {
+		subject := "Tentatives de connexion suspectes"
+		body := fmt.Sprintf("<p>L'adresse <strong>%s</strong> a echoue %d connexions admin en moins de %d minutes (dernier identifiant essaye : %s). Elle est bloquee temporairement.</p>",
+			html.EscapeString(ip), s.Cfg.LoginIPMaxFailures, int(s.loginLockoutWindow().Minutes()), html.EscapeString(identifier))
+		s.notifySecurityAlert(context.Background(), "", subject, body)
+	}(ip, identifier)
+}
+
+// clearLoginFailures resets the failure counter after a successful login.
+func (s *Server) clearLoginFailures(ctx context.Context, user models.User) error {
+	if user.FailedLogins == 0 && user.LastFailedLoginAt == nil && user.LockedUntil == nil {
+		return nil
+	}
+	update := bson.M{"$unset": bson.M{"failedLogins": "", "lastFailedLoginAt": "", "lockedUntil": ""}}
+	_, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": user.ID}, update)
+	return err
+}
+
+func (s *Server) recordLoginEvent(ctx context.Context, r *http.Request, userID, identifier string, success bool, reason string) {
+	if s.Cols == nil || s.Cols.LoginHistory == nil {
+		return
+	}
+	event := models.LoginEvent{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    userID,
+		Username:  identifier,
+		IP:        middleware.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Success:   success,
+		Reason:    reason,
+		CreatedAt: time.Now().In(s.Cfg.Timezone),
+	}
+	if _, err := s.Cols.LoginHistory.InsertOne(ctx, event); err != nil {
+		s.logWithRequest(r).Warn("admin login: history write failed", slog.String("error", err.Error()))
+	}
+}
+
+// notifySecurityAlert emails a security alert to every admin but the one it
+// is about (exceptEmail), digest mode included: a locked account or a
+// blocked IP cannot wait for the daily summary.
+func (s *Server) notifySecurityAlert(ctx context.Context, exceptEmail, subject, htmlBody string) {
+	if s == nil || s.Mailer == nil {
+		return
+	}
+	emails, err := s.allAdminEmails(ctx)
+	if err != nil {
+		s.Log.Warn("notify admins: failed to list admins", slog.String("error", err.Error()))
+		return
+	}
+	for _, email := range emails {
+		if email == "" || email == exceptEmail {
+			continue
+		}
+		if _, err := s.Mailer.SendEmail(ctx, email, "Admin", subject, htmlBody); err != nil {
+			s.Log.Warn("notify admins: send failed", slog.String("email", email), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// AdminUnlockUser lifts a login lockout before it expires.
+func (s *Server) AdminUnlockUser(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin users unlock") {
+		return
+	}
+
+	update := bson.M{"$unset": bson.M{"failedLogins": "", "lastFailedLoginAt": "", "lockedUntil": ""}}
+	res, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		log.Error("admin users unlock: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.MatchedCount == 0 {
+		log.Warn("admin users unlock: not found", slog.String("user_id", id))
+		transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+		return
+	}
+
+	log.Info("admin users unlock: ok", slog.String("user_id", id))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "unlocked"})
+}
+
+// AdminListLoginHistory lists sign-in attempts, newest first, optionally
+// filtered by userId, ip or success.
+func (s *Server) AdminListLoginHistory(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	limit, offset, err := httpx.ParseLimitOffset(r.URL.Query(), 50, 200)
+	if err != nil {
+		log.Warn("admin login history: invalid query", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	filter := bson.M{}
+	q := r.URL.Query()
+	if userID := q.Get("userId"); userID != "" {
+		filter["userId"] = userID
+	}
+	if ip := q.Get("ip"); ip != "" {
+		filter["ip"] = ip
+	}
+	switch q.Get("success") {
+	case "":
+	case "true":
+		filter["success"] = true
+	case "false":
+		filter["success"] = false
+	default:
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"success": "boolean"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	total, err := s.Cols.LoginHistory.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Error("admin login history: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var items []models.LoginEvent
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	if err := s.findAll(ctx, s.Cols.LoginHistory, filter, opts, &items); err != nil {
+		log.Error("admin login history: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if items == nil {
+		items = []models.LoginEvent{}
+	}
+
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+	})
+}
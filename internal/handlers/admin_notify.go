@@ -3,15 +3,42 @@ package handlers
 import (
 	"context"
 
+	"gbh-backend/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"log/slog"
 )
 
+// AdminEmails lists the admins who receive one email per event. Admins in
+// digest mode are left out and only get the daily summary.
 func (s *Server) AdminEmails(ctx context.Context) ([]string, error) {
+	return s.adminEmailsByMode(ctx, bson.M{"$ne": models.NotificationModeDigest})
+}
+
+func (s *Server) digestAdminEmails(ctx context.Context) ([]string, error) {
+	return s.adminEmailsByMode(ctx, models.NotificationModeDigest)
+}
+
+// allAdminEmails lists every admin whatever their notification mode, for
+// alerts that cannot wait for the daily summary.
+func (s *Server) allAdminEmails(ctx context.Context) ([]string, error) {
+	return s.adminEmailsByMode(ctx, nil)
+}
+
+// adminEmailsByMode lists the admins whose notification mode matches mode;
+// a nil mode matches them all.
+func (s *Server) adminEmailsByMode(ctx context.Context, mode interface{}) ([]string, error) {
 	if s == nil || s.Cols == nil || s.Cols.Users == nil {
 		return nil, nil
 	}
-	cursor, err := s.Cols.Users.Find(ctx, bson.M{"role": "admin", "email": bson.M{"$ne": ""}}, nil)
+	filter := bson.M{
+		"role":       "admin",
+		"email":      bson.M{"$ne": ""},
+		"disabledAt": bson.M{"$exists": false},
+	}
+	if mode != nil {
+		filter["notificationMode"] = mode
+	}
+	cursor, err := s.Cols.Users.Find(ctx, filter, nil)
 	if err != nil {
 		return nil, err
 	}
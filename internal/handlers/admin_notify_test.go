@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/rbac"
+)
+
+func TestSecurityAlertsReachDigestAdmins(t *testing.T) {
+	s := newMongoTestServer(t)
+	mailer := &recordingMailer{}
+	s.Mailer = mailer
+	disabledAt := time.Now()
+	insertTestUsers(t, s,
+		models.User{ID: "u-live", Username: "live", Email: "live@example.com", Role: rbac.RoleAdmin},
+		models.User{ID: "u-digest", Username: "digest", Email: "digest@example.com", Role: rbac.RoleAdmin, NotificationMode: models.NotificationModeDigest},
+		models.User{ID: "u-locked", Username: "locked", Email: "locked@example.com", Role: rbac.RoleAdmin},
+		models.User{ID: "u-gone", Username: "gone", Email: "gone@example.com", Role: rbac.RoleAdmin, DisabledAt: &disabledAt},
+	)
+	ctx := context.Background()
+
+	s.NotifyAdmins(ctx, "Nouveau rendez-vous", "<p>test</p>")
+	if want := []string{"live@example.com", "locked@example.com"}; !slices.Equal(sortedSent(mailer), want) {
+		t.Fatalf("NotifyAdmins() sent to %v, want %v", mailer.sent, want)
+	}
+
+	mailer.sent = nil
+	s.notifySecurityAlert(ctx, "locked@example.com", "Compte admin verrouille", "<p>test</p>")
+	if want := []string{"digest@example.com", "live@example.com"}; !slices.Equal(sortedSent(mailer), want) {
+		t.Fatalf("notifySecurityAlert() sent to %v, want %v", mailer.sent, want)
+	}
+}
+
+func TestDailyDigestSkipsEmptyDays(t *testing.T) {
+	s := newMongoTestServer(t)
+	mailer := &recordingMailer{}
+	s.Mailer = mailer
+	insertTestUsers(t, s, models.User{ID: "u-digest", Username: "digest", Email: "digest@example.com", Role: rbac.RoleAdmin, NotificationMode: models.NotificationModeDigest})
+
+	s.SendDailyAdminDigest(context.Background(), time.Date(2026, 4, 22, 23, 0, 0, 0, s.Cfg.Timezone))
+	if len(mailer.sent) != 0 {
+		t.Fatalf("expected no digest for an empty day, sent to %v", mailer.sent)
+	}
+}
+
+func sortedSent(m *recordingMailer) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sent := slices.Clone(m.sent)
+	slices.Sort(sent)
+	return sent
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/notifications"
+	"gbh-backend/internal/transport"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AdminPasswordForgotRequest struct {
+	// Email accepts the username as well, like the login form.
+	Email string `json:"email" validate:"required,max=254"`
+}
+
+type AdminPasswordResetRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// AdminForgotPassword mails a reset link to the matching admin. It answers
+// the same way whether or not the account exists, and does the lookup in the
+// background so response times do not reveal it either.
+func (s *Server) AdminForgotPassword(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminPasswordForgotRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin password forgot: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if strings.Contains(req.Email, "@") {
+		req.Email = strings.ToLower(req.Email)
+	}
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin password forgot: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if s.Cols == nil || s.Cols.Users == nil || s.Cols.PasswordResets == nil || s.Mailer == nil || s.Cfg.AdminResetURL == "" {
+		log.Warn("admin password forgot: not configured")
+		transport.WriteError(w, http.StatusServiceUnavailable, "password reset not configured", nil)
+		return
+	}
+
+	go s.sendPasswordReset(log, req.Email, middleware.ClientIP(r))
+
+	transport.WriteJSON(w, http.StatusAccepted, map[string]string{"status": "sent"})
+}
+
+func (s *Server) sendPasswordReset(log *slog.Logger, identifier, ip string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var user models.User
+	filter := bson.M{
+		"$or": []bson.M{
+			{"username": identifier},
+			{"email": identifier},
+		},
+	}
+	findOpts := options.FindOne().SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	if err := s.Cols.Users.FindOne(ctx, filter, findOpts).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Info("admin password forgot: unknown account", slog.String("identifier", identifier))
+			return
+		}
+		log.Error("admin password forgot: database error", slog.String("error", err.Error()))
+		return
+	}
+	if user.Email == "" || user.DisabledAt != nil {
+		log.Info("admin password forgot: account not eligible", slog.String("user_id", user.ID))
+		return
+	}
+
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		log.Error("admin password forgot: token error", slog.String("error", err.Error()))
+		return
+	}
+	now := time.Now().In(s.Cfg.Timezone)
+	ttl := s.Cfg.PasswordResetTTLMinutes
+	if ttl <= 0 {
+		ttl = 60
+	}
+	reset := models.PasswordReset{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    user.ID,
+		TokenHash: hash,
+		IP:        ip,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Duration(ttl) * time.Minute),
+	}
+
+	// Only the latest link works.
+	if _, err := s.Cols.PasswordResets.DeleteMany(ctx, bson.M{"userId": user.ID, "usedAt": bson.M{"$exists": false}}); err != nil {
+		log.Error("admin password forgot: database error", slog.String("error", err.Error()))
+		return
+	}
+	if _, err := s.Cols.PasswordResets.InsertOne(ctx, reset); err != nil {
+		log.Error("admin password forgot: database error", slog.String("error", err.Error()))
+		return
+	}
+
+	link, err := tokenLink(s.Cfg.AdminResetURL, token)
+	if err != nil {
+		log.Error("admin password forgot: invalid reset url", slog.String("error", err.Error()))
+		return
+	}
+	htmlBody, err := notifications.BuildPasswordResetHTML(notifications.PasswordResetEmail{
+		Username:     user.Username,
+		ResetURL:     link,
+		ValidMinutes: ttl,
+	})
+	if err != nil {
+		log.Error("admin password forgot: template error", slog.String("error", err.Error()))
+		return
+	}
+	if _, err := s.Mailer.SendEmail(ctx, user.Email, user.Username, "Reinitialisation de votre mot de passe", htmlBody); err != nil {
+		log.Error("admin password forgot: send failed", slog.String("user_id", user.ID), slog.String("error", err.Error()))
+		return
+	}
+	log.Info("admin password forgot: sent", slog.String("user_id", user.ID), slog.String("reset_id", reset.ID))
+}
+
+// AdminResetPassword sets a new password from a reset token. The token is
+// burnt on success, and every session of the account is revoked.
+func (s *Server) AdminResetPassword(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminPasswordResetRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin password reset: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin password reset: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if s.Cols == nil || s.Cols.Users == nil || s.Cols.PasswordResets == nil {
+		log.Warn("admin password reset: not configured")
+		transport.WriteError(w, http.StatusServiceUnavailable, "password reset not configured", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now().In(s.Cfg.Timezone)
+	active := bson.M{
+		"tokenHash": auth.HashToken(req.Token),
+		"usedAt":    bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": now},
+	}
+	var reset models.PasswordReset
+	if err := s.Cols.PasswordResets.FindOne(ctx, active).Decode(&reset); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin password reset: invalid token")
+			transport.WriteError(w, http.StatusBadRequest, "invalid or expired token", nil)
+			return
+		}
+		log.Error("admin password reset: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	var user models.User
+	if err := s.Cols.Users.FindOne(ctx, bson.M{"_id": reset.UserID}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin password reset: user not found", slog.String("user_id", reset.UserID))
+			transport.WriteError(w, http.StatusBadRequest, "invalid or expired token", nil)
+			return
+		}
+		log.Error("admin password reset: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if user.DisabledAt != nil {
+		log.Warn("admin password reset: account disabled", slog.String("user_id", user.ID))
+		transport.WriteError(w, http.StatusBadRequest, "invalid or expired token", nil)
+		return
+	}
+
+	// Checked before the token is burnt so a weak choice can be corrected.
+	if details := passwordStrengthDetails(req.Password, user.Username, user.Email); details != nil {
+		log.Warn("admin password reset: weak password", slog.String("user_id", user.ID))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		log.Error("admin password reset: hash error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "password error", nil)
+		return
+	}
+
+	// Claim the token atomically so two concurrent requests cannot both use it.
+	claim := bson.M{"$set": bson.M{"usedAt": now}}
+	res, err := s.Cols.PasswordResets.UpdateOne(ctx, bson.M{"_id": reset.ID, "usedAt": bson.M{"$exists": false}}, claim)
+	if err != nil {
+		log.Error("admin password reset: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.ModifiedCount == 0 {
+		log.Warn("admin password reset: token already used", slog.String("reset_id", reset.ID))
+		transport.WriteError(w, http.StatusBadRequest, "invalid or expired token", nil)
+		return
+	}
+
+	// Proving control of the mailbox also lifts a login lockout.
+	update := bson.M{
+		"$set":   bson.M{"passwordHash": hash, "updatedAt": now},
+		"$unset": bson.M{"failedLogins": "", "lastFailedLoginAt": "", "lockedUntil": ""},
+	}
+	if _, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+		log.Error("admin password reset: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if err := s.revokeAdminSessions(ctx, bson.M{"userId": user.ID}, models.SessionRevokedPasswordChanged); err != nil {
+		log.Error("admin password reset: revoke sessions failed", slog.String("error", err.Error()))
+	}
+	if _, err := s.Cols.PasswordResets.DeleteMany(ctx, bson.M{"userId": user.ID, "usedAt": bson.M{"$exists": false}}); err != nil {
+		log.Warn("admin password reset: cleanup failed", slog.String("error", err.Error()))
+	}
+
+	log.Info("admin password reset: ok", slog.String("user_id", user.ID), slog.String("reset_id", reset.ID))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+// tokenLink appends token to a back-office URL as the "token" query parameter.
+func tokenLink(base, token string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// passwordStrengthDetails returns the validation details for a password that
+// breaks the strength rules, or nil.
+func passwordStrengthDetails(password, username, email string) map[string]string {
+	err := auth.ValidatePasswordStrength(password, username, email)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, auth.ErrPasswordTooShort):
+		return map[string]string{"password": "min"}
+	case errors.Is(err, auth.ErrPasswordTooLong):
+		return map[string]string{"password": "max"}
+	case errors.Is(err, auth.ErrPasswordPersonal):
+		return map[string]string{"password": "personal"}
+	default:
+		return map[string]string{"password": "weak"}
+	}
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/rbac"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+type AdminRoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description" validate:"max=200"`
+	Permissions []string `json:"permissions" validate:"required,min=1"`
+}
+
+type AdminUserRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type AdminRoleResponse struct {
+	models.Role
+	Builtin bool `json:"builtin"`
+}
+
+// RolePermissions resolves a role name to its permissions: built-in roles
+// first, then the custom roles collection.
+func (s *Server) RolePermissions(ctx context.Context, role string) ([]string, bool, error) {
+	if perms, ok := rbac.Builtin(role); ok {
+		return perms, true, nil
+	}
+	if role == "" || s.Cols == nil || s.Cols.Roles == nil {
+		return nil, false, nil
+	}
+	var doc models.Role
+	if err := s.Cols.Roles.FindOne(ctx, bson.M{"_id": role}).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return doc.Permissions, true, nil
+}
+
+// AdminListPermissions returns the permission catalogue.
+func (s *Server) AdminListPermissions(w http.ResponseWriter, r *http.Request) {
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{"items": rbac.Permissions})
+}
+
+// AdminListRoles returns the built-in roles followed by the custom ones.
+func (s *Server) AdminListRoles(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var custom []models.Role
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if err := s.findAll(ctx, s.Cols.Roles, bson.M{}, opts, &custom); err != nil {
+		log.Error("admin roles list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	items := make([]AdminRoleResponse, 0, len(custom)+len(rbac.BuiltinRoles()))
+	for _, name := range rbac.BuiltinRoles() {
+		perms, _ := rbac.Builtin(name)
+		items = append(items, AdminRoleResponse{Role: models.Role{Name: name, Permissions: perms}, Builtin: true})
+	}
+	for _, role := range custom {
+		items = append(items, AdminRoleResponse{Role: role})
+	}
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+func (s *Server) AdminCreateRole(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminRoleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin roles create: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Name = strings.ToLower(strings.TrimSpace(req.Name))
+	if details := s.validateRoleRequest(req); details != nil {
+		log.Warn("admin roles create: validation error")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if !roleNamePattern.MatchString(req.Name) {
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"name": "invalid"})
+		return
+	}
+	if _, builtin := rbac.Builtin(req.Name); builtin {
+		transport.WriteError(w, http.StatusConflict, "role already exists", nil)
+		return
+	}
+	if missing := ungrantableScope(r.Context(), req.Permissions); missing != "" {
+		log.Warn("admin roles create: role above caller", slog.String("role", req.Name))
+		transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": missing})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now().In(s.Cfg.Timezone)
+	role := models.Role{
+		Name:        req.Name,
+		Description: strings.TrimSpace(req.Description),
+		Permissions: normalizePermissions(req.Permissions),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := s.Cols.Roles.InsertOne(ctx, role); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			transport.WriteError(w, http.StatusConflict, "role already exists", nil)
+			return
+		}
+		log.Error("admin roles create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin roles create: ok", slog.String("role", role.Name))
+	transport.WriteJSON(w, http.StatusCreated, AdminRoleResponse{Role: role})
+}
+
+// AdminUpdateRole replaces the description and permissions of a custom role.
+// Users holding the role get the new permissions on their next request.
+func (s *Server) AdminUpdateRole(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	name := chi.URLParam(r, "name")
+	if _, builtin := rbac.Builtin(name); builtin {
+		transport.WriteError(w, http.StatusConflict, "built-in roles cannot be modified", nil)
+		return
+	}
+
+	var req AdminRoleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin roles update: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	if details := s.validateRoleRequest(req); details != nil {
+		log.Warn("admin roles update: validation error")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if missing := ungrantableScope(r.Context(), req.Permissions); missing != "" {
+		log.Warn("admin roles update: role above caller", slog.String("role", name))
+		transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": missing})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"description": strings.TrimSpace(req.Description),
+			"permissions": normalizePermissions(req.Permissions),
+			"updatedAt":   time.Now().In(s.Cfg.Timezone),
+		},
+	}
+	var role models.Role
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.Roles.FindOneAndUpdate(ctx, bson.M{"_id": name}, update, opts).Decode(&role); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "role not found", nil)
+			return
+		}
+		log.Error("admin roles update: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin roles update: ok", slog.String("role", name))
+	transport.WriteJSON(w, http.StatusOK, AdminRoleResponse{Role: role})
+}
+
+// AdminDeleteRole deletes a custom role that no user holds anymore.
+func (s *Server) AdminDeleteRole(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	name := chi.URLParam(r, "name")
+	if _, builtin := rbac.Builtin(name); builtin {
+		transport.WriteError(w, http.StatusConflict, "built-in roles cannot be deleted", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	holders, err := s.Cols.Users.CountDocuments(ctx, bson.M{"role": name})
+	if err != nil {
+		log.Error("admin roles delete: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if holders > 0 {
+		log.Warn("admin roles delete: role in use", slog.String("role", name), slog.Int64("users", holders))
+		transport.WriteError(w, http.StatusConflict, "role is assigned to users", nil)
+		return
+	}
+
+	res, err := s.Cols.Roles.DeleteOne(ctx, bson.M{"_id": name})
+	if err != nil {
+		log.Error("admin roles delete: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.DeletedCount == 0 {
+		transport.WriteError(w, http.StatusNotFound, "role not found", nil)
+		return
+	}
+
+	log.Info("admin roles delete: ok", slog.String("role", name))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// AdminUpdateUserRole assigns a role to a user. The last enabled admin
+// cannot be given another role.
+func (s *Server) AdminUpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		log.Warn("admin users role: missing id")
+		transport.WriteError(w, http.StatusBadRequest, "missing id", nil)
+		return
+	}
+
+	var req AdminUserRoleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin users role: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Role = strings.TrimSpace(req.Role)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin users role: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin users role") {
+		return
+	}
+
+	perms, found, err := s.RolePermissions(ctx, req.Role)
+	if err != nil {
+		log.Error("admin users role: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !found {
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"role": "unknown"})
+		return
+	}
+	if missing := ungrantableScope(r.Context(), perms); missing != "" {
+		log.Warn("admin users role: role above caller", slog.String("role", req.Role))
+		transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": missing})
+		return
+	}
+
+	now := time.Now().In(s.Cfg.Timezone)
+	update := bson.M{
+		"$set": bson.M{
+			"role":      req.Role,
+			"updatedAt": now,
+		},
+	}
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	if err := s.Cols.Users.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin users role: not found", slog.String("user_id", id))
+			transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+			return
+		}
+		log.Error("admin users role: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if user.Role == models.UserRoleAdmin && user.DisabledAt == nil && req.Role != models.UserRoleAdmin {
+		restore := bson.M{"$set": bson.M{"role": models.UserRoleAdmin}}
+		if !s.keepLastAdmin(ctx, w, r, id, restore, "admin users role") {
+			return
+		}
+	}
+	user.Role, user.UpdatedAt = req.Role, now
+
+	log.Info("admin users role: ok", slog.String("user_id", id), slog.String("role", req.Role))
+	transport.WriteJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) validateRoleRequest(req AdminRoleRequest) map[string]string {
+	if err := s.Val.Struct(req); err != nil {
+		return validationDetails(s.Val.ValidationErrors(err))
+	}
+	return unknownPermissionDetails("permissions", req.Permissions)
+}
+
+func unknownPermissionDetails(field string, perms []string) map[string]string {
+	for _, p := range perms {
+		if !rbac.Known(strings.TrimSpace(p)) {
+			return map[string]string{field: "unknown permission " + p}
+		}
+	}
+	return nil
+}
+
+func normalizePermissions(perms []string) []string {
+	out := normalizeStringList(perms)
+	sort.Strings(out)
+	return out
+}
+
+// ungrantableScope returns the first permission the caller does not hold, so
+// nobody can hand out more than they have.
+func ungrantableScope(ctx context.Context, scopes []string) string {
+	for _, scope := range scopes {
+		if !middleware.HasPermission(ctx, strings.TrimSpace(scope)) {
+			return scope
+		}
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/rbac"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// roleRequest builds a request on /api/admin/roles/{name} made by a
+// principal holding perms.
+func roleRequest(method, name string, body AdminRoleRequest, perms ...string) *http.Request {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, "/api/admin/roles/"+name, bytes.NewReader(payload))
+	ctx := middleware.WithPrincipal(req.Context(), middleware.Principal{UserID: "caller", Permissions: perms})
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("name", name)
+	return req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, routeCtx))
+}
+
+func TestRolesCannotCarryMoreThanTheCaller(t *testing.T) {
+	s := newMongoTestServer(t)
+	// The caller may manage roles and holds every sales permission, but
+	// not the user management ones.
+	perms := []string{rbac.RolesWrite, "rfp:*", "contacts:*"}
+
+	rec := httptest.NewRecorder()
+	s.AdminCreateRole(rec, roleRequest(http.MethodPost, "", AdminRoleRequest{Name: "takeover", Permissions: []string{rbac.UsersWrite}}, perms...))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("create with users:write: status %d, want 403", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.AdminCreateRole(rec, roleRequest(http.MethodPost, "", AdminRoleRequest{Name: "wildcard", Permissions: []string{rbac.Wildcard}}, perms...))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("create with *: status %d, want 403", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.AdminCreateRole(rec, roleRequest(http.MethodPost, "", AdminRoleRequest{Name: "leads", Permissions: []string{rbac.RFPRead}}, perms...))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create with rfp:read: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.AdminUpdateRole(rec, roleRequest(http.MethodPut, "leads", AdminRoleRequest{Permissions: []string{rbac.RFPRead, rbac.RolesWrite, rbac.UsersWrite}}, perms...))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("update with users:write: status %d, want 403", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.AdminUpdateRole(rec, roleRequest(http.MethodPut, "leads", AdminRoleRequest{Permissions: []string{rbac.RFPRead, rbac.RFPWrite}}, perms...))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update with rfp:write: status %d: %s", rec.Code, rec.Body.String())
+	}
+	permissions, found, err := s.RolePermissions(context.Background(), "leads")
+	if err != nil || !found || len(permissions) != 2 {
+		t.Fatalf("leads permissions = %v (found %v, error %v)", permissions, found, err)
+	}
+}
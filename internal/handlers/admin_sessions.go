@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	errSessionInvalid = errors.New("session invalid")
+	errSessionReused  = errors.New("refresh token reused")
+)
+
+const maxSessionUserAgent = 256
+
+// startAdminSession records a new login session and sets its cookies.
+func (s *Server) startAdminSession(ctx context.Context, w http.ResponseWriter, r *http.Request, user models.User) error {
+	sessionID, err := auth.NewTokenID()
+	if err != nil {
+		return err
+	}
+	manager := s.newAdminJWTManager()
+	identity := auth.Identity{UserID: user.ID, Username: user.Username, Role: user.Role, SessionID: sessionID}
+
+	accessToken, err := manager.NewAccessToken(identity)
+	if err != nil {
+		return err
+	}
+	refreshToken, tokenID, err := manager.NewRefreshToken(identity)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().In(s.Cfg.Timezone)
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxSessionUserAgent {
+		userAgent = userAgent[:maxSessionUserAgent]
+	}
+	session := models.AdminSession{
+		ID:             sessionID,
+		UserID:         user.ID,
+		Username:       user.Username,
+		CurrentTokenID: tokenID,
+		UserAgent:      userAgent,
+		IP:             middleware.ClientIP(r),
+		CreatedAt:      now,
+		LastUsedAt:     now,
+		ExpiresAt:      now.Add(manager.RefreshTTL),
+	}
+	if _, err := s.Cols.AdminSessions.InsertOne(ctx, session); err != nil {
+		return err
+	}
+
+	setAuthCookies(w, accessToken, refreshToken, manager.AccessTTL, manager.RefreshTTL, s.Cfg.CookieSecure)
+	return nil
+}
+
+// rotateAdminSession swaps the session's refresh token for a new one. A token
+// that was already rotated away means it leaked (or was replayed): the whole
+// session is revoked and errSessionReused is returned.
+func (s *Server) rotateAdminSession(ctx context.Context, w http.ResponseWriter, r *http.Request, claims *auth.Claims, user models.User) error {
+	var session models.AdminSession
+	if err := s.Cols.AdminSessions.FindOne(ctx, bson.M{"_id": claims.SessionID}).Decode(&session); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errSessionInvalid
+		}
+		return err
+	}
+	now := time.Now().In(s.Cfg.Timezone)
+	if session.RevokedAt != nil || session.UserID != claims.Subject || !now.Before(session.ExpiresAt) {
+		return errSessionInvalid
+	}
+	if session.CurrentTokenID != claims.ID {
+		if err := s.revokeAdminSessions(ctx, bson.M{"_id": session.ID}, models.SessionRevokedReuse); err != nil {
+			return err
+		}
+		return errSessionReused
+	}
+
+	manager := s.newAdminJWTManager()
+	identity := auth.Identity{UserID: user.ID, Username: user.Username, Role: user.Role, SessionID: session.ID}
+	accessToken, err := manager.NewAccessToken(identity)
+	if err != nil {
+		return err
+	}
+	refreshToken, tokenID, err := manager.NewRefreshToken(identity)
+	if err != nil {
+		return err
+	}
+
+	// The filter on the presented token ID makes the swap atomic: of two
+	// concurrent refreshes with the same token, only one wins.
+	filter := bson.M{
+		"_id":            session.ID,
+		"currentTokenId": claims.ID,
+		"revokedAt":      bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"currentTokenId": tokenID,
+			"lastUsedAt":     now,
+			"expiresAt":      now.Add(manager.RefreshTTL),
+			"ip":             middleware.ClientIP(r),
+		},
+		"$inc": bson.M{"rotations": 1},
+	}
+	res, err := s.Cols.AdminSessions.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		if err := s.revokeAdminSessions(ctx, bson.M{"_id": session.ID}, models.SessionRevokedReuse); err != nil {
+			return err
+		}
+		return errSessionReused
+	}
+
+	setAuthCookies(w, accessToken, refreshToken, manager.AccessTTL, manager.RefreshTTL, s.Cfg.CookieSecure)
+	return nil
+}
+
+// revokeAdminSessions revokes every still-active session matching filter.
+func (s *Server) revokeAdminSessions(ctx context.Context, filter bson.M, reason string) error {
+	if s.Cols == nil || s.Cols.AdminSessions == nil {
+		return nil
+	}
+	filter["revokedAt"] = bson.M{"$exists": false}
+	update := bson.M{
+		"$set": bson.M{
+			"revokedAt":     time.Now().In(s.Cfg.Timezone),
+			"revokedReason": reason,
+		},
+	}
+	_, err := s.Cols.AdminSessions.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// SessionActive reports whether an access token's session is still live, for
+// the admin auth middleware.
+func (s *Server) SessionActive(ctx context.Context, id string) (bool, error) {
+	filter := bson.M{
+		"_id":       id,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+	count, err := s.Cols.AdminSessions.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+type AdminSessionResponse struct {
+	models.AdminSession
+	Current bool `json:"current"`
+}
+
+// AdminListSessions lists active sessions, optionally for one user.
+func (s *Server) AdminListSessions(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+	if userID := r.URL.Query().Get("userId"); userID != "" {
+		filter["userId"] = userID
+	}
+
+	var sessions []models.AdminSession
+	opts := options.Find().SetSort(bson.D{{Key: "lastUsedAt", Value: -1}})
+	if err := s.findAll(ctx, s.Cols.AdminSessions, filter, opts, &sessions); err != nil {
+		log.Error("admin sessions list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	items := make([]AdminSessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		items = append(items, AdminSessionResponse{
+			AdminSession: session,
+			Current:      principal.SessionID != "" && session.ID == principal.SessionID,
+		})
+	}
+	transport.WriteJSON(w, http.StatusOK, items)
+}
+
+// AdminRevokeSession kills one session. Its access tokens stop working
+// immediately and its refresh token can no longer be used.
+func (s *Server) AdminRevokeSession(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		log.Warn("admin sessions revoke: missing id")
+		transport.WriteError(w, http.StatusBadRequest, "missing id", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	active := bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}}
+	var session models.AdminSession
+	if err := s.Cols.AdminSessions.FindOne(ctx, active).Decode(&session); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin sessions revoke: not found", slog.String("session_id", id))
+			transport.WriteError(w, http.StatusNotFound, "session not found", nil)
+			return
+		}
+		log.Error("admin sessions revoke: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	// Signing someone out is as sensitive as signing them out everywhere.
+	if !s.guardOutrankedUser(ctx, w, r, session.UserID, "admin sessions revoke") {
+		return
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"revokedAt":     time.Now().In(s.Cfg.Timezone),
+			"revokedReason": models.SessionRevokedByAdmin,
+		},
+	}
+	res, err := s.Cols.AdminSessions.UpdateOne(ctx, active, update)
+	if err != nil {
+		log.Error("admin sessions revoke: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.MatchedCount == 0 {
+		log.Warn("admin sessions revoke: not found", slog.String("session_id", id))
+		transport.WriteError(w, http.StatusNotFound, "session not found", nil)
+		return
+	}
+
+	log.Info("admin sessions revoke: ok", slog.String("session_id", id))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
+
+// AdminRevokeUserSessions signs a user out everywhere.
+func (s *Server) AdminRevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		log.Warn("admin sessions revoke user: missing id")
+		transport.WriteError(w, http.StatusBadRequest, "missing id", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin sessions revoke user") {
+		return
+	}
+
+	if err := s.revokeAdminSessions(ctx, bson.M{"userId": id}, models.SessionRevokedByAdmin); err != nil {
+		log.Error("admin sessions revoke user: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin sessions revoke user: ok", slog.String("user_id", id))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
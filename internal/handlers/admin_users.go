@@ -5,22 +5,26 @@ import (
 	"crypto/subtle"
 	"log/slog"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
 	"gbh-backend/internal/auth"
+	"gbh-backend/internal/httpx"
 	"gbh-backend/internal/models"
 	"gbh-backend/internal/transport"
 	"github.com/go-chi/chi/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type AdminUserCreateRequest struct {
 	Username string `json:"username" validate:"required"`
 	Email    string `json:"email" validate:"omitempty,email"`
 	Password string `json:"password" validate:"required"`
+	Role     string `json:"role" validate:"required"`
 }
 
 type AdminRegisterRequest struct {
@@ -34,6 +38,10 @@ type AdminUserPasswordRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type AdminUserNotificationsRequest struct {
+	Mode string `json:"mode" validate:"required,oneof=realtime digest"`
+}
+
 func (s *Server) AdminRegister(w http.ResponseWriter, r *http.Request) {
 	log := s.logWithRequest(r)
 	var req AdminRegisterRequest
@@ -49,6 +57,11 @@ func (s *Server) AdminRegister(w http.ResponseWriter, r *http.Request) {
 		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
 		return
 	}
+	if details := passwordStrengthDetails(req.Password, req.Username, req.Email); details != nil {
+		log.Warn("admin register: weak password")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
 	if s.Cols == nil || s.Cols.Users == nil {
 		log.Warn("admin register: not configured")
 		transport.WriteError(w, http.StatusServiceUnavailable, "admin users not configured", nil)
@@ -103,8 +116,7 @@ func (s *Server) AdminRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Info("admin register: ok", slog.String("user_id", user.ID), slog.String("username", user.Username))
-	_, _, err = s.issueAdminSession(w)
-	if err != nil {
+	if err := s.startAdminSession(ctx, w, r, user); err != nil {
 		log.Error("admin register: token error", slog.String("error", err.Error()))
 		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
 		return
@@ -121,18 +133,44 @@ func (s *Server) AdminCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	req.Username, req.Email = normalizeAdminUserIdentity(req.Username, req.Email)
+	req.Role = strings.TrimSpace(req.Role)
 	if err := s.Val.Struct(req); err != nil {
 		log.Warn("admin users create: validation error")
 		details := validationDetails(s.Val.ValidationErrors(err))
 		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
 		return
 	}
+	if details := passwordStrengthDetails(req.Password, req.Username, req.Email); details != nil {
+		log.Warn("admin users create: weak password")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
 	if s.Cols == nil || s.Cols.Users == nil {
 		log.Warn("admin users create: not configured")
 		transport.WriteError(w, http.StatusServiceUnavailable, "admin users not configured", nil)
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	perms, found, err := s.RolePermissions(ctx, req.Role)
+	if err != nil {
+		log.Error("admin users create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !found {
+		log.Warn("admin users create: unknown role", slog.String("role", req.Role))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"role": "unknown"})
+		return
+	}
+	if missing := ungrantableScope(r.Context(), perms); missing != "" {
+		log.Warn("admin users create: role above caller", slog.String("role", req.Role))
+		transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": missing})
+		return
+	}
+
 	hash, err := auth.HashPassword(req.Password)
 	if err != nil {
 		log.Error("admin users create: hash error", slog.String("error", err.Error()))
@@ -146,14 +184,11 @@ func (s *Server) AdminCreateUser(w http.ResponseWriter, r *http.Request) {
 		Username:     req.Username,
 		Email:        req.Email,
 		PasswordHash: hash,
-		Role:         models.UserRoleAdmin,
+		Role:         req.Role,
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
 	if _, err := s.Cols.Users.InsertOne(ctx, user); err != nil {
 		if mongo.IsDuplicateKeyError(err) {
 			log.Warn("admin users create: duplicate", slog.String("username", req.Username))
@@ -196,6 +231,30 @@ func (s *Server) AdminUpdateUserPassword(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin users password") {
+		return
+	}
+
+	var user models.User
+	if err := s.Cols.Users.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin users password: not found", slog.String("user_id", id))
+			transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+			return
+		}
+		log.Error("admin users password: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details := passwordStrengthDetails(req.Password, user.Username, user.Email); details != nil {
+		log.Warn("admin users password: weak password", slog.String("user_id", id))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
 	hash, err := auth.HashPassword(req.Password)
 	if err != nil {
 		log.Error("admin users password: hash error", slog.String("error", err.Error()))
@@ -203,16 +262,13 @@ func (s *Server) AdminUpdateUserPassword(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
 	update := bson.M{
 		"$set": bson.M{
 			"passwordHash": hash,
 			"updatedAt":    time.Now().In(s.Cfg.Timezone),
 		},
 	}
-	res, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": id, "role": models.UserRoleAdmin}, update)
+	res, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": id}, update)
 	if err != nil {
 		log.Error("admin users password: database error", slog.String("error", err.Error()))
 		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
@@ -224,10 +280,99 @@ func (s *Server) AdminUpdateUserPassword(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := s.revokeAdminSessions(ctx, bson.M{"userId": id}, models.SessionRevokedPasswordChanged); err != nil {
+		log.Error("admin users password: revoke sessions failed", slog.String("error", err.Error()))
+	}
+
 	log.Info("admin users password: ok", slog.String("user_id", id))
 	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
+func (s *Server) AdminUpdateUserNotifications(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		log.Warn("admin users notifications: missing id")
+		transport.WriteError(w, http.StatusBadRequest, "missing id", nil)
+		return
+	}
+
+	var req AdminUserNotificationsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin users notifications: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin users notifications: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if s.Cols == nil || s.Cols.Users == nil {
+		log.Warn("admin users notifications: not configured")
+		transport.WriteError(w, http.StatusServiceUnavailable, "admin users not configured", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin users notifications") {
+		return
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"notificationMode": req.Mode,
+			"updatedAt":        time.Now().In(s.Cfg.Timezone),
+		},
+	}
+	res, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		log.Error("admin users notifications: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.MatchedCount == 0 {
+		log.Warn("admin users notifications: not found", slog.String("user_id", id))
+		transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+		return
+	}
+
+	log.Info("admin users notifications: ok", slog.String("user_id", id), slog.String("mode", req.Mode))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated", "mode": req.Mode})
+}
+
+// guardOutrankedUser answers 403 and returns false when the user's role
+// holds a permission the caller lacks: users:write must not be a way to
+// take over, or lock out, a broader account.
+func (s *Server) guardOutrankedUser(ctx context.Context, w http.ResponseWriter, r *http.Request, id, op string) bool {
+	log := s.logWithRequest(r)
+	user, found, err := s.LookupUser(ctx, id)
+	if err != nil {
+		log.Error(op+": database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return false
+	}
+	if !found {
+		transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+		return false
+	}
+	perms, _, err := s.RolePermissions(ctx, user.Role)
+	if err != nil {
+		log.Error(op+": database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return false
+	}
+	if missing := ungrantableScope(r.Context(), perms); missing != "" {
+		log.Warn(op+": user above caller", slog.String("user_id", id), slog.String("role", user.Role))
+		transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": missing})
+		return false
+	}
+	return true
+}
+
 func normalizeAdminUserIdentity(username, email string) (string, string) {
 	username = strings.TrimSpace(username)
 	email = strings.TrimSpace(email)
@@ -237,3 +382,319 @@ func normalizeAdminUserIdentity(username, email string) (string, string) {
 	email = strings.ToLower(email)
 	return username, email
 }
+
+// AdminUserProfileRequest updates the fields that are sent; omitted fields
+// are left as they are. The email cannot be cleared since password resets
+// are sent to it.
+type AdminUserProfileRequest struct {
+	Username *string `json:"username" validate:"omitempty,min=1,max=80"`
+	Email    *string `json:"email" validate:"omitempty,email"`
+}
+
+// AdminListUsers lists back-office users sorted by username. q matches the
+// username or email; status is "active", "disabled" or "locked".
+func (s *Server) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	limit, offset, err := httpx.ParseLimitOffset(r.URL.Query(), 50, 200)
+	if err != nil {
+		log.Warn("admin users list: invalid query", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := bson.M{}
+	if search := strings.TrimSpace(q.Get("q")); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		filter["$or"] = []bson.M{{"username": pattern}, {"email": pattern}}
+	}
+	if role := strings.TrimSpace(q.Get("role")); role != "" {
+		filter["role"] = role
+	}
+	switch q.Get("status") {
+	case "":
+	case "active":
+		filter["disabledAt"] = bson.M{"$exists": false}
+	case "disabled":
+		filter["disabledAt"] = bson.M{"$exists": true}
+	case "locked":
+		filter["lockedUntil"] = bson.M{"$gt": time.Now()}
+	default:
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"status": "oneof"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	total, err := s.Cols.Users.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Error("admin users list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var users []models.User
+	opts := options.Find().
+		SetSort(bson.D{{Key: "username", Value: 1}}).
+		SetCollation(&options.Collation{Locale: "en", Strength: 2}).
+		SetSkip(offset).
+		SetLimit(limit)
+	if err := s.findAll(ctx, s.Cols.Users, filter, opts, &users); err != nil {
+		log.Error("admin users list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if users == nil {
+		users = []models.User{}
+	}
+
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  users,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+	})
+}
+
+func (s *Server) AdminGetUser(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, found, err := s.LookupUser(ctx, id)
+	if err != nil {
+		log.Error("admin users get: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !found {
+		transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+		return
+	}
+	transport.WriteJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) AdminUpdateUser(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin users update") {
+		return
+	}
+	s.updateUserProfile(w, r, id, "admin users update")
+}
+
+// AdminUpdateMe lets the signed-in admin edit their own profile.
+func (s *Server) AdminUpdateMe(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.currentUser(w, r, "admin me update")
+	if !ok {
+		return
+	}
+	s.updateUserProfile(w, r, user.ID, "admin me update")
+}
+
+func (s *Server) updateUserProfile(w http.ResponseWriter, r *http.Request, id, op string) {
+	log := s.logWithRequest(r)
+	var req AdminUserProfileRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn(op + ": invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	set := bson.M{}
+	if req.Username != nil {
+		username, _ := normalizeAdminUserIdentity(*req.Username, "")
+		req.Username = &username
+		set["username"] = username
+	}
+	if req.Email != nil {
+		_, email := normalizeAdminUserIdentity("", *req.Email)
+		req.Email = &email
+		set["email"] = email
+	}
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn(op + ": validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if len(set) == 0 {
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"body": "empty"})
+		return
+	}
+	set["updatedAt"] = time.Now().In(s.Cfg.Timezone)
+	update := bson.M{"$set": set}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.Users.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn(op+": not found", slog.String("user_id", id))
+			transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+			return
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			log.Warn(op+": duplicate", slog.String("user_id", id))
+			transport.WriteError(w, http.StatusConflict, "username or email already exists", nil)
+			return
+		}
+		log.Error(op+": database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info(op+": ok", slog.String("user_id", id))
+	transport.WriteJSON(w, http.StatusOK, user)
+}
+
+// AdminDisableUser blocks an account without deleting it and ends its
+// sessions. The last active admin cannot be disabled.
+func (s *Server) AdminDisableUser(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin users disable") {
+		return
+	}
+
+	now := time.Now().In(s.Cfg.Timezone)
+	update := bson.M{"$set": bson.M{"disabledAt": now, "updatedAt": now}}
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.Users.FindOneAndUpdate(ctx, bson.M{"_id": id, "disabledAt": bson.M{"$exists": false}}, update, opts).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "user not found or already disabled", nil)
+			return
+		}
+		log.Error("admin users disable: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if user.Role == models.UserRoleAdmin && !s.keepLastAdmin(ctx, w, r, id, reenableUser, "admin users disable") {
+		return
+	}
+	if err := s.revokeAdminSessions(ctx, bson.M{"userId": id}, models.SessionRevokedUserDisabled); err != nil {
+		log.Error("admin users disable: revoke sessions failed", slog.String("error", err.Error()))
+	}
+
+	log.Info("admin users disable: ok", slog.String("user_id", id))
+	transport.WriteJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) AdminEnableUser(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin users enable") {
+		return
+	}
+
+	update := bson.M{
+		"$unset": bson.M{"disabledAt": ""},
+		"$set":   bson.M{"updatedAt": time.Now().In(s.Cfg.Timezone)},
+	}
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.Users.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+			return
+		}
+		log.Error("admin users enable: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin users enable: ok", slog.String("user_id", id))
+	transport.WriteJSON(w, http.StatusOK, user)
+}
+
+// AdminDeleteUser removes an account for good, with its sessions and pending
+// password resets. The last active admin cannot be deleted.
+func (s *Server) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin users delete") {
+		return
+	}
+
+	// An enabled admin is disabled first, so the last-admin check covers
+	// deletions racing with other removals.
+	standDown := bson.M{"$set": bson.M{"disabledAt": time.Now().In(s.Cfg.Timezone)}}
+	res, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": id, "role": models.UserRoleAdmin, "disabledAt": bson.M{"$exists": false}}, standDown)
+	if err != nil {
+		log.Error("admin users delete: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.MatchedCount > 0 && !s.keepLastAdmin(ctx, w, r, id, reenableUser, "admin users delete") {
+		return
+	}
+
+	deleted, err := s.Cols.Users.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		log.Error("admin users delete: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if deleted.DeletedCount == 0 {
+		transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+		return
+	}
+	if err := s.revokeAdminSessions(ctx, bson.M{"userId": id}, models.SessionRevokedUserDeleted); err != nil {
+		log.Error("admin users delete: revoke sessions failed", slog.String("error", err.Error()))
+	}
+	if s.Cols.PasswordResets != nil {
+		if _, err := s.Cols.PasswordResets.DeleteMany(ctx, bson.M{"userId": id}); err != nil {
+			log.Warn("admin users delete: password resets cleanup failed", slog.String("error", err.Error()))
+		}
+	}
+
+	log.Info("admin users delete: ok", slog.String("user_id", id))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// reenableUser undoes the disabling of an admin.
+var reenableUser = bson.M{"$unset": bson.M{"disabledAt": ""}}
+
+// keepLastAdmin runs once an admin has been disabled, deleted or demoted.
+// When no enabled admin is left, it applies undo to the user, answers 409 and
+// returns false, so the back office can never lock itself out. Counting after
+// the write keeps the guard sound under concurrent requests: two admins
+// removed at once both see the other gone, and at worst both are undone.
+func (s *Server) keepLastAdmin(ctx context.Context, w http.ResponseWriter, r *http.Request, id string, undo bson.M, op string) bool {
+	log := s.logWithRequest(r)
+	left, err := s.Cols.Users.CountDocuments(ctx, bson.M{
+		"role":       models.UserRoleAdmin,
+		"disabledAt": bson.M{"$exists": false},
+	}, options.Count().SetLimit(1))
+	if err == nil && left > 0 {
+		return true
+	}
+	if _, undoErr := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": id}, undo); undoErr != nil {
+		log.Error(op+": undo failed", slog.String("user_id", id), slog.String("error", undoErr.Error()))
+	}
+	if err != nil {
+		log.Error(op+": database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return false
+	}
+	log.Warn(op+": last admin", slog.String("user_id", id))
+	transport.WriteError(w, http.StatusConflict, "cannot remove the last admin", nil)
+	return false
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/rbac"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// adminRequest builds a request as the admin middleware would pass it on:
+// the {id} route parameter set and a principal holding perms.
+func adminRequest(method, target, id string, body interface{}, perms ...string) *http.Request {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+	ctx := middleware.WithPrincipal(req.Context(), middleware.Principal{UserID: "caller", Permissions: perms})
+	if id != "" {
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, routeCtx)
+	}
+	return req.WithContext(ctx)
+}
+
+func TestUsersWriteCannotReachBroaderRoles(t *testing.T) {
+	s := newMongoTestServer(t)
+	insertTestUsers(t, s,
+		models.User{ID: "u-admin", Username: "boss", Email: "boss@example.com", Role: rbac.RoleAdmin},
+		models.User{ID: "u-sales", Username: "seller", Email: "seller@example.com", Role: rbac.RoleSales},
+	)
+	// The caller may manage users and holds every sales permission, but
+	// not the admin ones.
+	perms := []string{rbac.UsersWrite, "rfp:*", "contacts:*"}
+
+	rec := httptest.NewRecorder()
+	s.AdminCreateUser(rec, adminRequest(http.MethodPost, "/api/admin/users", "", AdminUserCreateRequest{
+		Username: "newboss", Email: "newboss@example.com", Password: "Correct-Horse-42!", Role: rbac.RoleAdmin,
+	}, perms...))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("create admin: status %d, want 403", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.AdminCreateUser(rec, adminRequest(http.MethodPost, "/api/admin/users", "", AdminUserCreateRequest{
+		Username: "newboss", Email: "newboss@example.com", Password: "Correct-Horse-42!",
+	}, perms...))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("create without role: status %d, want 400", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.AdminCreateUser(rec, adminRequest(http.MethodPost, "/api/admin/users", "", AdminUserCreateRequest{
+		Username: "seller2", Email: "seller2@example.com", Password: "Correct-Horse-42!", Role: rbac.RoleSales,
+	}, perms...))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create sales: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	password := AdminUserPasswordRequest{Password: "Another-Pass-77!"}
+	rec = httptest.NewRecorder()
+	s.AdminUpdateUserPassword(rec, adminRequest(http.MethodPatch, "/api/admin/users/u-admin/password", "u-admin", password, perms...))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("admin password: status %d, want 403", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.AdminUpdateUserPassword(rec, adminRequest(http.MethodPatch, "/api/admin/users/u-sales/password", "u-sales", password, perms...))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("sales password: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	guarded := map[string]http.HandlerFunc{
+		"disable":  s.AdminDisableUser,
+		"delete":   s.AdminDeleteUser,
+		"2fa":      s.AdminResetUserTwoFactor,
+		"unlock":   s.AdminUnlockUser,
+		"profile":  s.AdminUpdateUser,
+		"sessions": s.AdminRevokeUserSessions,
+	}
+	for name, handler := range guarded {
+		rec := httptest.NewRecorder()
+		handler(rec, adminRequest(http.MethodPost, "/api/admin/users/u-admin", "u-admin", map[string]string{"email": "me@example.com"}, perms...))
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("%s on an admin: status %d, want 403", name, rec.Code)
+		}
+	}
+
+	for _, session := range []models.AdminSession{
+		{ID: "sess-admin", UserID: "u-admin", ExpiresAt: time.Now().Add(time.Hour)},
+		{ID: "sess-sales", UserID: "u-sales", ExpiresAt: time.Now().Add(time.Hour)},
+	} {
+		if _, err := s.Cols.AdminSessions.InsertOne(context.Background(), session); err != nil {
+			t.Fatalf("insert session %s: %v", session.ID, err)
+		}
+	}
+	rec = httptest.NewRecorder()
+	s.AdminRevokeSession(rec, adminRequest(http.MethodDelete, "/api/admin/sessions/sess-admin", "sess-admin", nil, perms...))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("revoke an admin session: status %d, want 403", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.AdminRevokeSession(rec, adminRequest(http.MethodDelete, "/api/admin/sessions/sess-sales", "sess-sales", nil, perms...))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revoke a sales session: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.AdminUpdateUserRole(rec, adminRequest(http.MethodPatch, "/api/admin/users/u-sales/role", "u-sales", AdminUserRoleRequest{Role: rbac.RoleAdmin}, append(perms, rbac.RolesWrite)...))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("promote to admin: status %d, want 403", rec.Code)
+	}
+}
+
+func TestLastAdminCannotBeDisabledOrDeleted(t *testing.T) {
+	s := newMongoTestServer(t)
+	disabledAt := time.Now()
+	insertTestUsers(t, s,
+		models.User{ID: "u-admin", Username: "boss", Email: "boss@example.com", Role: rbac.RoleAdmin},
+		models.User{ID: "u-former", Username: "former", Email: "former@example.com", Role: rbac.RoleAdmin, DisabledAt: &disabledAt},
+	)
+	call := func(handler http.HandlerFunc, id string) int {
+		rec := httptest.NewRecorder()
+		handler(rec, adminRequest(http.MethodPost, "/api/admin/users/"+id, id, nil, rbac.Wildcard))
+		return rec.Code
+	}
+
+	// A disabled admin does not count as a second one.
+	if code := call(s.AdminDisableUser, "u-admin"); code != http.StatusConflict {
+		t.Fatalf("disable last admin: status %d, want 409", code)
+	}
+	if code := call(s.AdminDeleteUser, "u-admin"); code != http.StatusConflict {
+		t.Fatalf("delete last admin: status %d, want 409", code)
+	}
+	if code := call(s.AdminDisableUser, "u-former"); code != http.StatusNotFound {
+		t.Fatalf("disable an already disabled admin: status %d, want 404", code)
+	}
+
+	insertTestUsers(t, s, models.User{ID: "u-admin2", Username: "boss2", Email: "boss2@example.com", Role: rbac.RoleAdmin})
+	if code := call(s.AdminDisableUser, "u-admin"); code != http.StatusOK {
+		t.Fatalf("disable with another admin left: status %d, want 200", code)
+	}
+	if code := call(s.AdminDeleteUser, "u-admin2"); code != http.StatusConflict {
+		t.Fatalf("delete the admin left: status %d, want 409", code)
+	}
+	if code := call(s.AdminDeleteUser, "u-admin"); code != http.StatusOK {
+		t.Fatalf("delete a disabled admin: status %d, want 200", code)
+	}
+}
+
+func TestLastAdminSurvivesConcurrentRemovals(t *testing.T) {
+	s := newMongoTestServer(t)
+	ctx := context.Background()
+	removals := map[string]func(id string) int{
+		"disable": func(id string) int {
+			rec := httptest.NewRecorder()
+			s.AdminDisableUser(rec, adminRequest(http.MethodPost, "/api/admin/users/"+id+"/disable", id, nil, rbac.Wildcard))
+			return rec.Code
+		},
+		"delete": func(id string) int {
+			rec := httptest.NewRecorder()
+			s.AdminDeleteUser(rec, adminRequest(http.MethodDelete, "/api/admin/users/"+id, id, nil, rbac.Wildcard))
+			return rec.Code
+		},
+		"demote": func(id string) int {
+			rec := httptest.NewRecorder()
+			s.AdminUpdateUserRole(rec, adminRequest(http.MethodPatch, "/api/admin/users/"+id+"/role", id, AdminUserRoleRequest{Role: rbac.RoleSales}, rbac.Wildcard))
+			return rec.Code
+		},
+	}
+
+	for first, removeFirst := range removals {
+		for second, removeSecond := range removals {
+			for round := 0; round < 5; round++ {
+				if _, err := s.Cols.Users.DeleteMany(ctx, bson.M{}); err != nil {
+					t.Fatalf("reset users: %v", err)
+				}
+				insertTestUsers(t, s,
+					models.User{ID: "u-a", Username: "a", Email: "a@example.com", Role: rbac.RoleAdmin},
+					models.User{ID: "u-b", Username: "b", Email: "b@example.com", Role: rbac.RoleAdmin},
+				)
+
+				// Each admin is removed by the other at the same time.
+				var wg sync.WaitGroup
+				codes := make([]int, 2)
+				start := make(chan struct{})
+				for i, remove := range []func() int{
+					func() int { return removeFirst("u-a") },
+					func() int { return removeSecond("u-b") },
+				} {
+					wg.Add(1)
+					go func(i int, remove func() int) {
+						defer wg.Done()
+						<-start
+						codes[i] = remove()
+					}(i, remove)
+				}
+				close(start)
+				wg.Wait()
+
+				left, err := s.Cols.Users.CountDocuments(ctx, bson.M{"role": rbac.RoleAdmin, "disabledAt": bson.M{"$exists": false}})
+				if err != nil {
+					t.Fatalf("count admins: %v", err)
+				}
+				removed := 0
+				for _, code := range codes {
+					switch code {
+					case http.StatusOK:
+						removed++
+					case http.StatusConflict:
+					default:
+						t.Fatalf("%s/%s: unexpected status %d", first, second, code)
+					}
+				}
+				if left == 0 || int(left) != 2-removed {
+					t.Fatalf("%s/%s: %d admins left after statuses %v", first, second, left, codes)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/booking"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/schedule"
+	"gbh-backend/internal/transport"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errStatusChanged is returned when the appointment changed status between
+// the read and the update.
+var errStatusChanged = errors.New("appointment status changed")
+
+type CancelAppointmentRequest struct {
+	ID     string `json:"id" validate:"required"`
+	Email  string `json:"email" validate:"required,email"`
+	Reason string `json:"reason,omitempty" validate:"omitempty,max=500"`
+}
+
+// transitionAppointment moves current to status to on behalf of actor and
+// records change in the history. The update only applies if the status is
+// still the one that was checked.
+func (s *Server) transitionAppointment(ctx context.Context, current models.Appointment, to, actor string, change models.AppointmentStatusChange) (models.Appointment, error) {
+	if err := booking.CheckTransition(current.Status, to, actor); err != nil {
+		return models.Appointment{}, err
+	}
+	now := time.Now().In(s.Cfg.Timezone)
+	change.From = current.Status
+	change.To = to
+	change.At = now
+
+	set := bson.M{"status": to}
+	update := bson.M{
+		"$set":  set,
+		"$push": bson.M{"statusHistory": change},
+	}
+	if models.AppointmentCanceled(to) {
+		// A cancellation is a new revision of the calendar invitation.
+		update["$inc"] = bson.M{"calendarSequence": 1}
+		if refund := refundFor(current, now); refund != nil {
+			set["refund"] = refund
+		}
+	}
+
+	var updated models.Appointment
+	filter := bson.M{"_id": current.ID, "status": current.Status}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.Appointments.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.Appointment{}, errStatusChanged
+		}
+		return models.Appointment{}, err
+	}
+	return updated, nil
+}
+
+// refundFor returns the refund owed when a prepaid appointment is canceled.
+func refundFor(appointment models.Appointment, now time.Time) *models.Refund {
+	if appointment.PaymentMethod != models.PaymentOnline || appointment.Total <= 0 || appointment.Refund != nil {
+		return nil
+	}
+	return &models.Refund{
+		Status:      models.RefundStatusPending,
+		Amount:      appointment.Total,
+		Currency:    "CDF",
+		RequestedAt: now,
+	}
+}
+
+// afterAppointmentTransition runs the side effects of a status change:
+// availability, waitlist offers, customer email and admin notifications.
+func (s *Server) afterAppointmentTransition(ctx context.Context, log *slog.Logger, before, after models.Appointment) {
+	s.settleAppointmentTransition(ctx, log, before, after)
+	if !models.AppointmentCanceled(after.Status) {
+		return
+	}
+
+	refundRequested := after.Refund != nil && before.Refund == nil
+	if after.Status != models.AppointmentStatusCanceledByCustomer && !refundRequested {
+		return
+	}
+	go func(appointment models.Appointment, refundRequested bool) {
+		subject := "Rendez-vous annulé"
+		var body strings.Builder
+		if appointment.Status == models.AppointmentStatusCanceledByCustomer {
+			fmt.Fprintf(&body, "<p><strong>%s</strong> a annulé son rendez-vous du <strong>%s</strong> à <strong>%s</strong>.</p>",
+				html.EscapeString(appointment.Name), appointment.Date, appointment.Time)
+		} else {
+			fmt.Fprintf(&body, "<p>Le rendez-vous de <strong>%s</strong> du <strong>%s</strong> à <strong>%s</strong> a été annulé.</p>",
+				html.EscapeString(appointment.Name), appointment.Date, appointment.Time)
+		}
+		if reason := lastStatusReason(appointment); reason != "" {
+			fmt.Fprintf(&body, "<p>Motif : %s</p>", html.EscapeString(reason))
+		}
+		if refundRequested {
+			subject = "Remboursement à traiter"
+			fmt.Fprintf(&body, "<p>Le rendez-vous avait été payé en ligne : un remboursement de <strong>%d %s</strong> est à effectuer.</p>",
+				appointment.Refund.Amount, appointment.Refund.Currency)
+		}
+		fmt.Fprintf(&body, "<p>Référence : %s</p>", appointment.ID)
+		s.NotifyAdmins(context.Background(), subject, body.String())
+	}(after, refundRequested)
+}
+
+// settleAppointmentTransition is afterAppointmentTransition without the
+// admin notification, for callers that report several changes at once.
+func (s *Server) settleAppointmentTransition(ctx context.Context, log *slog.Logger, before, after models.Appointment) {
+	if models.AppointmentHoldsSlot(before.Status) != models.AppointmentHoldsSlot(after.Status) && s.Cache != nil {
+		_ = s.Cache.DeletePrefix(ctx, "availability:"+after.Date+":")
+	}
+	if models.AppointmentHoldsSlot(before.Status) && !models.AppointmentHoldsSlot(after.Status) {
+		s.offerFreedSlotsAsync(after.Date, appointmentCalendar(after))
+	}
+	if models.AppointmentCanceled(after.Status) && s.Mailer != nil {
+		go s.sendAppointmentCancellationEmail(log, after)
+	}
+}
+
+func lastStatusReason(appointment models.Appointment) string {
+	if len(appointment.StatusHistory) == 0 {
+		return ""
+	}
+	return appointment.StatusHistory[len(appointment.StatusHistory)-1].Reason
+}
+
+// CancelAppointment lets a customer cancel their own appointment. The email
+// used for the booking must be given alongside the reference.
+func (s *Server) CancelAppointment(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req CancelAppointmentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("appointments cancel: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.ID = strings.TrimSpace(req.ID)
+	req.Email = strings.TrimSpace(req.Email)
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("appointments cancel: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var appointment models.Appointment
+	if err := s.Cols.Appointments.FindOne(ctx, bson.M{"_id": req.ID}).Decode(&appointment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("appointments cancel: not found", slog.String("appointment_id", req.ID))
+			transport.WriteError(w, http.StatusNotFound, "appointment not found", nil)
+			return
+		}
+		log.Error("appointments cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	// Same answer as an unknown reference so ids cannot be probed.
+	if !strings.EqualFold(appointment.Email, req.Email) {
+		log.Warn("appointments cancel: email mismatch", slog.String("appointment_id", req.ID))
+		transport.WriteError(w, http.StatusNotFound, "appointment not found", nil)
+		return
+	}
+
+	if past, err := schedule.IsSlotPast(appointment.Date, appointment.Time, s.Cfg.Timezone, time.Now()); err == nil && past {
+		log.Warn("appointments cancel: already started", slog.String("appointment_id", req.ID))
+		transport.WriteError(w, http.StatusConflict, "appointment already started", nil)
+		return
+	}
+
+	change := models.AppointmentStatusChange{Actor: booking.ActorCustomer, Reason: req.Reason}
+	updated, err := s.transitionAppointment(ctx, appointment, models.AppointmentStatusCanceledByCustomer, booking.ActorCustomer, change)
+	if err != nil {
+		switch {
+		case errors.Is(err, booking.ErrInvalidTransition), errors.Is(err, booking.ErrNotAllowed), errors.Is(err, errStatusChanged):
+			log.Warn("appointments cancel: not cancelable", slog.String("appointment_id", req.ID), slog.String("status", appointment.Status))
+			transport.WriteError(w, http.StatusConflict, "appointment cannot be canceled", nil)
+		default:
+			log.Error("appointments cancel: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		}
+		return
+	}
+
+	s.afterAppointmentTransition(r.Context(), log, appointment, updated)
+
+	log.Info("appointments cancel: ok", slog.String("appointment_id", req.ID))
+	transport.WriteJSON(w, http.StatusOK, updated)
+}
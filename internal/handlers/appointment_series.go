@@ -0,0 +1,1007 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/booking"
+	"gbh-backend/internal/middleware"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/recurrence"
+	"gbh-backend/internal/schedule"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// seriesMaxOccurrences bounds a series to about six months of weekly
+// appointments.
+const seriesMaxOccurrences = 26
+
+// Outcomes of one occurrence in a series report.
+const (
+	occurrenceAvailable = "available"
+	occurrenceConflict  = "conflict"
+	occurrenceBooked    = "booked"
+	occurrenceMoved     = "moved"
+	occurrenceCanceled  = "canceled"
+	occurrenceSkipped   = "skipped"
+)
+
+// errSlotTaken is returned when another appointment took the target slot
+// between the check and the write.
+var errSlotTaken = errors.New("slot already booked")
+
+// CreateAppointmentSeriesRequest books every occurrence of RRule starting on
+// Date. With SkipConflicts the free occurrences are booked and the others
+// reported; otherwise a single conflict refuses the whole series. DryRun
+// only reports.
+type CreateAppointmentSeriesRequest struct {
+	ServiceID     string `json:"serviceId" validate:"required"`
+	Name          string `json:"name" validate:"required"`
+	Email         string `json:"email" validate:"required,email"`
+	Phone         string `json:"phone" validate:"required,phone"`
+	Type          string `json:"type" validate:"required,oneof=online presentiel"`
+	Date          string `json:"date" validate:"required,date"`
+	Time          string `json:"time" validate:"required,clock"`
+	Duration      int    `json:"duration" validate:"omitempty,gte=15,lte=240,minutes15"`
+	PaymentMethod string `json:"paymentMethod" validate:"required,oneof=online place"`
+	Price         int    `json:"price" validate:"gte=0"`
+	RRule         string `json:"rrule" validate:"required,max=200"`
+	SkipConflicts bool   `json:"skipConflicts"`
+	DryRun        bool   `json:"dryRun"`
+	LocationID    string `json:"locationId,omitempty"`
+	RoomID        string `json:"roomId,omitempty"`
+	// Intake answers the service questionnaire once for every occurrence.
+	Intake map[string]interface{} `json:"intake,omitempty"`
+}
+
+// RescheduleAppointmentRequest moves one appointment, for instance a single
+// occurrence of a series.
+type RescheduleAppointmentRequest struct {
+	ID    string `json:"id" validate:"required"`
+	Email string `json:"email" validate:"required,email"`
+	Date  string `json:"date" validate:"required,date"`
+	Time  string `json:"time" validate:"required,clock"`
+}
+
+// CancelAppointmentSeriesRequest cancels the upcoming occurrences of a
+// series, or only those from From onwards.
+type CancelAppointmentSeriesRequest struct {
+	Email  string `json:"email" validate:"required,email"`
+	From   string `json:"from,omitempty" validate:"omitempty,date"`
+	Reason string `json:"reason,omitempty" validate:"omitempty,max=500"`
+}
+
+// RescheduleAppointmentSeriesRequest moves the upcoming occurrences of a
+// series (from From onwards) to another time of their day.
+type RescheduleAppointmentSeriesRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Time  string `json:"time" validate:"required,clock"`
+	From  string `json:"from,omitempty" validate:"omitempty,date"`
+}
+
+type AdminCancelAppointmentSeriesRequest struct {
+	From   string `json:"from,omitempty" validate:"omitempty,date"`
+	Reason string `json:"reason,omitempty" validate:"omitempty,max=500"`
+}
+
+// AdminUpdateAppointmentSeriesRequest moves the upcoming occurrences of a
+// series. Notify defaults to sending the updated invitations.
+type AdminUpdateAppointmentSeriesRequest struct {
+	Time                 *string `json:"time,omitempty" validate:"omitempty,clock"`
+	Duration             *int    `json:"duration,omitempty" validate:"omitempty,gte=15,lte=240,minutes15"`
+	From                 string  `json:"from,omitempty" validate:"omitempty,date"`
+	OverrideAvailability bool    `json:"overrideAvailability"`
+	Notify               *bool   `json:"notify,omitempty"`
+}
+
+// seriesOccurrence reports what happened to one date of a series.
+type seriesOccurrence struct {
+	Date          string `json:"date"`
+	Time          string `json:"time"`
+	Status        string `json:"status"`
+	Reason        string `json:"reason,omitempty"`
+	AppointmentID string `json:"appointmentId,omitempty"`
+	RoomID        string `json:"roomId,omitempty"`
+	// placement is the room found free when the occurrence was checked.
+	placement *models.AppointmentLocation
+}
+
+func countConflicts(occurrences []seriesOccurrence) int {
+	n := 0
+	for _, occurrence := range occurrences {
+		if occurrence.Status == occurrenceConflict {
+			n++
+		}
+	}
+	return n
+}
+
+// writeSeriesConflict refuses a series change and tells which occurrences
+// are in the way.
+func writeSeriesConflict(w http.ResponseWriter, occurrences []seriesOccurrence) {
+	transport.WriteJSON(w, http.StatusConflict, map[string]interface{}{
+		"error":       "slot not available",
+		"conflicts":   countConflicts(occurrences),
+		"occurrences": occurrences,
+	})
+}
+
+// occurrenceConflictReason checks one occurrence like a single booking and
+// returns why it cannot be booked, or "" and the room it would take.
+func (s *Server) occurrenceConflictReason(ctx context.Context, place slotPlace, date, clock string, duration int, override bool, excludeID string, now time.Time) (string, *models.AppointmentLocation, error) {
+	past, err := schedule.IsSlotPast(date, clock, s.Cfg.Timezone, now)
+	if err != nil {
+		return "invalid date", nil, nil
+	}
+	if past {
+		return "slot already passed", nil, nil
+	}
+	placement, code, msg, err := s.placeSlot(ctx, place, date, clock, duration, override, excludeID)
+	if err != nil || code == 0 {
+		return "", placement, err
+	}
+	return msg, nil, nil
+}
+
+// CreateAppointmentSeries books a recurring appointment in one request.
+func (s *Server) CreateAppointmentSeries(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req CreateAppointmentSeriesRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("appointments series create: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.TrimSpace(req.Email)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("appointments series create: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	duration := req.Duration
+	if duration == 0 {
+		duration = schedule.SlotMinutes
+	}
+
+	rule, err := recurrence.Parse(req.RRule)
+	if err != nil {
+		log.Warn("appointments series create: invalid rrule", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, "invalid rrule", map[string]string{"rrule": err.Error()})
+		return
+	}
+	dates, err := rule.Dates(req.Date, seriesMaxOccurrences)
+	if err != nil {
+		if errors.Is(err, recurrence.ErrTooMany) {
+			transport.WriteError(w, http.StatusBadRequest, "too many occurrences", map[string]string{"max": strconv.Itoa(seriesMaxOccurrences)})
+			return
+		}
+		transport.WriteError(w, http.StatusBadRequest, "invalid rrule", map[string]string{"rrule": err.Error()})
+		return
+	}
+	past, err := schedule.IsDatePast(req.Date, s.Cfg.Timezone, time.Now())
+	if err != nil {
+		transport.WriteError(w, http.StatusBadRequest, "invalid date", nil)
+		return
+	}
+	if past {
+		transport.WriteError(w, http.StatusBadRequest, "date in the past", nil)
+		return
+	}
+
+	// Meeting links are created per occurrence, so allow for the provider.
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": req.ServiceID}).Decode(&service); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("appointments series create: service not found", slog.String("service_id", req.ServiceID))
+			transport.WriteError(w, http.StatusBadRequest, "service not found", nil)
+			return
+		}
+		log.Error("appointments series create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	answers, details, err := s.resolveIntake(ctx, service, req.Intake, true)
+	if err != nil {
+		log.Error("appointments series create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details != nil {
+		log.Warn("appointments series create: intake validation error")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	place, details, err := s.resolvePlace(ctx, service, req.Type, req.LocationID, req.RoomID)
+	if err != nil {
+		log.Error("appointments series create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details != nil {
+		log.Warn("appointments series create: invalid location")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	now := time.Now().In(s.Cfg.Timezone)
+	occurrences := make([]seriesOccurrence, 0, len(dates))
+	for _, date := range dates {
+		reason, placement, err := s.occurrenceConflictReason(ctx, place, date, req.Time, duration, false, "", now)
+		if err != nil {
+			log.Error("appointments series create: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+			return
+		}
+		occurrence := seriesOccurrence{Date: date, Time: req.Time, Status: occurrenceAvailable, placement: placement}
+		if placement != nil {
+			occurrence.RoomID = placement.RoomID
+		}
+		if reason != "" {
+			occurrence.Status = occurrenceConflict
+			occurrence.Reason = reason
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	conflicts := countConflicts(occurrences)
+
+	if req.DryRun {
+		transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"conflicts":   conflicts,
+			"occurrences": occurrences,
+		})
+		return
+	}
+	if conflicts == len(occurrences) || (conflicts > 0 && !req.SkipConflicts) {
+		log.Warn("appointments series create: conflicts", slog.Int("conflicts", conflicts), slog.Int("occurrences", len(occurrences)))
+		writeSeriesConflict(w, occurrences)
+		return
+	}
+
+	series := models.AppointmentSeries{
+		ID:            primitive.NewObjectID().Hex(),
+		ServiceID:     req.ServiceID,
+		Name:          req.Name,
+		Email:         req.Email,
+		Phone:         req.Phone,
+		Type:          req.Type,
+		RRule:         strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(req.RRule)), "RRULE:"),
+		StartDate:     req.Date,
+		Time:          req.Time,
+		Duration:      duration,
+		PaymentMethod: req.PaymentMethod,
+		Price:         req.Price,
+		Status:        models.SeriesStatusActive,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if place.Location != nil {
+		series.LocationID = place.Location.ID
+	}
+	if _, err := s.Cols.AppointmentSeries.InsertOne(ctx, series); err != nil {
+		log.Error("appointments series create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	var appointments []models.Appointment
+	for i := range occurrences {
+		occurrence := &occurrences[i]
+		if occurrence.Status != occurrenceAvailable {
+			continue
+		}
+		appointment := models.Appointment{
+			ID:            primitive.NewObjectID().Hex(),
+			ServiceID:     req.ServiceID,
+			Name:          req.Name,
+			Email:         req.Email,
+			Phone:         req.Phone,
+			Type:          req.Type,
+			Date:          occurrence.Date,
+			Time:          req.Time,
+			Duration:      duration,
+			Price:         req.Price,
+			Total:         req.Price,
+			Status:        models.AppointmentStatusBooked,
+			PaymentMethod: req.PaymentMethod,
+			CreatedAt:     now,
+			SeriesID:      series.ID,
+			Intake:        answers,
+			Location:      occurrence.placement,
+		}
+		appointment.StatusHistory = []models.AppointmentStatusChange{{
+			To:    appointment.Status,
+			Actor: booking.ActorCustomer,
+			At:    now,
+		}}
+		s.assignMeetingLink(ctx, log, &appointment, service)
+
+		if _, err := s.Cols.Appointments.InsertOne(ctx, appointment); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				occurrence.Status = occurrenceConflict
+				occurrence.Reason = "slot already booked"
+				continue
+			}
+			// Leave nothing half-booked behind.
+			log.Error("appointments series create: database error", slog.String("error", err.Error()))
+			cleanup, cleanupCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cleanupCancel()
+			_, _ = s.Cols.Appointments.DeleteMany(cleanup, bson.M{"seriesId": series.ID})
+			_, _ = s.Cols.AppointmentSeries.DeleteOne(cleanup, bson.M{"_id": series.ID})
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+			return
+		}
+		occurrence.Status = occurrenceBooked
+		occurrence.AppointmentID = appointment.ID
+		appointments = append(appointments, appointment)
+	}
+
+	// Slots taken by someone else in the meantime count as conflicts too.
+	if len(appointments) == 0 || (!req.SkipConflicts && countConflicts(occurrences) > 0) {
+		_, _ = s.Cols.Appointments.DeleteMany(ctx, bson.M{"seriesId": series.ID})
+		_, _ = s.Cols.AppointmentSeries.DeleteOne(ctx, bson.M{"_id": series.ID})
+		log.Warn("appointments series create: slots taken", slog.String("series_id", series.ID))
+		for i := range occurrences {
+			if occurrences[i].Status == occurrenceBooked {
+				occurrences[i].Status = occurrenceAvailable
+				occurrences[i].AppointmentID = ""
+			}
+		}
+		writeSeriesConflict(w, occurrences)
+		return
+	}
+
+	if err := s.attachIntakeFiles(ctx, answers); err != nil {
+		log.Warn("appointments series create: intake files not attached", slog.String("series_id", series.ID), slog.String("error", err.Error()))
+	}
+
+	if s.Cache != nil {
+		for _, appointment := range appointments {
+			_ = s.Cache.DeletePrefix(r.Context(), "availability:"+appointment.Date+":")
+		}
+	}
+	if s.Mailer != nil {
+		go func(appointments []models.Appointment) {
+			for _, appointment := range appointments {
+				s.sendAppointmentConfirmationEmail(log, appointment, service)
+			}
+		}(appointments)
+	}
+	go func(series models.AppointmentSeries, booked int) {
+		subject := "Nouvelle série de rendez-vous"
+		htmlBody := fmt.Sprintf("<p><strong>%s</strong> a réservé une série de <strong>%d</strong> rendez-vous pour le service <strong>%s</strong> à <strong>%s</strong>, à partir du <strong>%s</strong> (%s).</p><p>Référence de la série : %s</p>",
+			html.EscapeString(series.Name), booked, html.EscapeString(service.Name), series.Time, series.StartDate, html.EscapeString(series.RRule), series.ID)
+		s.NotifyAdmins(context.Background(), subject, htmlBody)
+	}(series, len(appointments))
+
+	log.Info("appointments series create: booked",
+		slog.String("series_id", series.ID),
+		slog.Int("booked", len(appointments)),
+		slog.Int("conflicts", countConflicts(occurrences)),
+	)
+	transport.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+		"series":       series,
+		"appointments": appointments,
+		"occurrences":  occurrences,
+	})
+}
+
+// moveAppointment moves current to date and time, giving online
+// appointments a new meeting link. The update only applies if the status is
+// unchanged; a taken target slot gives errSlotTaken.
+func (s *Server) moveAppointment(ctx context.Context, log *slog.Logger, current models.Appointment, date, clock string, duration int, placement *models.AppointmentLocation, notify bool) (models.Appointment, error) {
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": current.ServiceID}).Decode(&service); err != nil && err != mongo.ErrNoDocuments {
+		return models.Appointment{}, err
+	}
+
+	moved := current
+	moved.Date, moved.Time, moved.Duration = date, clock, duration
+	set := bson.M{"date": date, "time": clock, "duration": duration}
+	if placement != nil {
+		moved.Location = placement
+		set["location"] = placement
+	}
+	if moved.Type == models.ConsultationOnline {
+		s.assignMeetingLink(ctx, log, &moved, service)
+		if moved.Meeting != current.Meeting {
+			set["meeting"] = moved.Meeting
+		}
+	}
+	update := bson.M{
+		"$set": set,
+		// Calendars only replace the event when the sequence grows.
+		"$inc": bson.M{"calendarSequence": 1},
+	}
+
+	var saved models.Appointment
+	filter := bson.M{"_id": current.ID, "status": current.Status}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.Appointments.FindOneAndUpdate(ctx, filter, update, opts).Decode(&saved); err != nil {
+		switch {
+		case mongo.IsDuplicateKeyError(err):
+			return models.Appointment{}, errSlotTaken
+		case err == mongo.ErrNoDocuments:
+			return models.Appointment{}, errStatusChanged
+		}
+		return models.Appointment{}, err
+	}
+
+	if s.Cache != nil {
+		_ = s.Cache.DeletePrefix(ctx, "availability:"+current.Date+":")
+		_ = s.Cache.DeletePrefix(ctx, "availability:"+saved.Date+":")
+	}
+	s.offerFreedSlotsAsync(current.Date, appointmentCalendar(current))
+	if notify && s.Mailer != nil {
+		go s.sendAppointmentRescheduledEmail(log, saved, service)
+	}
+	return saved, nil
+}
+
+// RescheduleAppointment lets a customer move one upcoming appointment, with
+// the same checks as a new booking.
+func (s *Server) RescheduleAppointment(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req RescheduleAppointmentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("appointments reschedule: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.ID = strings.TrimSpace(req.ID)
+	req.Email = strings.TrimSpace(req.Email)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("appointments reschedule: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var current models.Appointment
+	if err := s.Cols.Appointments.FindOne(ctx, bson.M{"_id": req.ID}).Decode(&current); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "appointment not found", nil)
+			return
+		}
+		log.Error("appointments reschedule: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	// Same answer as an unknown reference so ids cannot be probed.
+	if !strings.EqualFold(current.Email, req.Email) {
+		log.Warn("appointments reschedule: email mismatch", slog.String("appointment_id", req.ID))
+		transport.WriteError(w, http.StatusNotFound, "appointment not found", nil)
+		return
+	}
+	now := time.Now()
+	started, err := schedule.IsSlotPast(current.Date, current.Time, s.Cfg.Timezone, now)
+	if !booking.Upcoming(current.Status) || (err == nil && started) {
+		log.Warn("appointments reschedule: closed appointment", slog.String("appointment_id", req.ID), slog.String("status", current.Status))
+		transport.WriteError(w, http.StatusConflict, "appointment can no longer be rescheduled", nil)
+		return
+	}
+	if req.Date == current.Date && req.Time == current.Time {
+		transport.WriteJSON(w, http.StatusOK, current)
+		return
+	}
+
+	place, err := s.appointmentPlace(ctx, current)
+	if err != nil {
+		log.Error("appointments reschedule: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	reason, placement, err := s.occurrenceConflictReason(ctx, place, req.Date, req.Time, current.Duration, false, current.ID, now)
+	if err != nil {
+		log.Error("appointments reschedule: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if reason != "" {
+		log.Warn("appointments reschedule: slot refused", slog.String("appointment_id", req.ID), slog.String("reason", reason))
+		transport.WriteError(w, http.StatusConflict, reason, nil)
+		return
+	}
+
+	saved, err := s.moveAppointment(ctx, log, current, req.Date, req.Time, current.Duration, placement, true)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSlotTaken):
+			transport.WriteError(w, http.StatusConflict, "slot already booked", nil)
+		case errors.Is(err, errStatusChanged):
+			transport.WriteError(w, http.StatusConflict, "appointment status changed", nil)
+		default:
+			log.Error("appointments reschedule: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		}
+		return
+	}
+
+	go func(before, after models.Appointment) {
+		subject := "Rendez-vous déplacé"
+		htmlBody := fmt.Sprintf("<p><strong>%s</strong> a déplacé son rendez-vous du <strong>%s</strong> à <strong>%s</strong> au <strong>%s</strong> à <strong>%s</strong>.</p><p>Référence : %s</p>",
+			html.EscapeString(after.Name), before.Date, before.Time, after.Date, after.Time, after.ID)
+		s.NotifyAdmins(context.Background(), subject, htmlBody)
+	}(current, saved)
+
+	log.Info("appointments reschedule: ok", slog.String("appointment_id", saved.ID), slog.String("date", saved.Date), slog.String("time", saved.Time))
+	transport.WriteJSON(w, http.StatusOK, saved)
+}
+
+// upcomingOccurrences returns the occurrences of a series that have not
+// started yet, from the date from onwards when it is set.
+func (s *Server) upcomingOccurrences(ctx context.Context, seriesID, from string, now time.Time) ([]models.Appointment, error) {
+	today := now.In(s.Cfg.Timezone).Format("2006-01-02")
+	if from < today {
+		from = today
+	}
+	filter := bson.M{
+		"seriesId": seriesID,
+		"date":     bson.M{"$gte": from},
+		"status": bson.M{"$in": []string{
+			models.AppointmentStatusPending,
+			models.AppointmentStatusBooked,
+			models.AppointmentStatusConfirmed,
+		}},
+	}
+	var appointments []models.Appointment
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+	if err := s.findAll(ctx, s.Cols.Appointments, filter, opts, &appointments); err != nil {
+		return nil, err
+	}
+	upcoming := appointments[:0]
+	for _, appointment := range appointments {
+		if started, err := schedule.IsSlotPast(appointment.Date, appointment.Time, s.Cfg.Timezone, now); err == nil && started {
+			continue
+		}
+		upcoming = append(upcoming, appointment)
+	}
+	return upcoming, nil
+}
+
+// findCustomerSeries loads a series for its customer. A wrong email looks
+// like an unknown series.
+func (s *Server) findCustomerSeries(ctx context.Context, id, email string) (models.AppointmentSeries, error) {
+	var series models.AppointmentSeries
+	if err := s.Cols.AppointmentSeries.FindOne(ctx, bson.M{"_id": id}).Decode(&series); err != nil {
+		return models.AppointmentSeries{}, err
+	}
+	if !strings.EqualFold(series.Email, email) {
+		return models.AppointmentSeries{}, mongo.ErrNoDocuments
+	}
+	return series, nil
+}
+
+// cancelOccurrences cancels each occurrence to status to and returns the
+// total of the refunds it opened. Occurrences that changed in the meantime
+// are reported as skipped.
+func (s *Server) cancelOccurrences(ctx context.Context, log *slog.Logger, occurrences []models.Appointment, to, actor string, change models.AppointmentStatusChange) ([]seriesOccurrence, []models.Appointment, int, error) {
+	report := make([]seriesOccurrence, 0, len(occurrences))
+	var canceled []models.Appointment
+	refunds := 0
+	for _, occurrence := range occurrences {
+		item := seriesOccurrence{Date: occurrence.Date, Time: occurrence.Time, AppointmentID: occurrence.ID, Status: occurrenceCanceled}
+		updated, err := s.transitionAppointment(ctx, occurrence, to, actor, change)
+		if err != nil {
+			if !errors.Is(err, errStatusChanged) && !errors.Is(err, booking.ErrInvalidTransition) {
+				return report, canceled, refunds, err
+			}
+			item.Status = occurrenceSkipped
+			item.Reason = "appointment status changed"
+			report = append(report, item)
+			continue
+		}
+		s.settleAppointmentTransition(ctx, log, occurrence, updated)
+		if updated.Refund != nil && occurrence.Refund == nil {
+			refunds += updated.Refund.Amount
+		}
+		canceled = append(canceled, updated)
+		report = append(report, item)
+	}
+	return report, canceled, refunds, nil
+}
+
+// notifySeriesCancellation sends admins one summary for a series
+// cancellation, with the refunds it opened.
+func (s *Server) notifySeriesCancellation(series models.AppointmentSeries, canceled []models.Appointment, refunds int, byCustomer bool, reason string) {
+	if len(canceled) == 0 || (!byCustomer && refunds == 0) {
+		return
+	}
+	go func() {
+		subject := "Série de rendez-vous annulée"
+		var body strings.Builder
+		fmt.Fprintf(&body, "<p>%d rendez-vous de la série de <strong>%s</strong> (à partir du <strong>%s</strong>) ont été annulés",
+			len(canceled), html.EscapeString(series.Name), canceled[0].Date)
+		if byCustomer {
+			body.WriteString(" par le client")
+		}
+		body.WriteString(".</p>")
+		if reason != "" {
+			fmt.Fprintf(&body, "<p>Motif : %s</p>", html.EscapeString(reason))
+		}
+		if refunds > 0 {
+			subject = "Remboursement à traiter"
+			fmt.Fprintf(&body, "<p>Des rendez-vous avaient été payés en ligne : un remboursement de <strong>%d CDF</strong> est à effectuer.</p>", refunds)
+		}
+		fmt.Fprintf(&body, "<p>Référence de la série : %s</p>", series.ID)
+		s.NotifyAdmins(context.Background(), subject, body.String())
+	}()
+}
+
+// closeSeriesIfDone marks a series canceled once it has no upcoming
+// occurrence left.
+func (s *Server) closeSeriesIfDone(ctx context.Context, series models.AppointmentSeries, now time.Time) {
+	left, err := s.upcomingOccurrences(ctx, series.ID, "", now)
+	if err != nil || len(left) > 0 {
+		return
+	}
+	update := bson.M{"$set": bson.M{"status": models.SeriesStatusCanceled, "updatedAt": now.In(s.Cfg.Timezone)}}
+	_, _ = s.Cols.AppointmentSeries.UpdateOne(ctx, bson.M{"_id": series.ID}, update)
+}
+
+// CancelAppointmentSeries lets a customer cancel the rest of their series.
+// Single occurrences are canceled with POST /appointments/cancel.
+func (s *Server) CancelAppointmentSeries(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	var req CancelAppointmentSeriesRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("appointments series cancel: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("appointments series cancel: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	series, err := s.findCustomerSeries(ctx, id, req.Email)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("appointments series cancel: not found", slog.String("series_id", id))
+			transport.WriteError(w, http.StatusNotFound, "series not found", nil)
+			return
+		}
+		log.Error("appointments series cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	now := time.Now()
+	occurrences, err := s.upcomingOccurrences(ctx, series.ID, req.From, now)
+	if err != nil {
+		log.Error("appointments series cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	change := models.AppointmentStatusChange{Actor: booking.ActorCustomer, Reason: req.Reason}
+	report, canceled, refunds, err := s.cancelOccurrences(ctx, log, occurrences, models.AppointmentStatusCanceledByCustomer, booking.ActorCustomer, change)
+	if err != nil {
+		log.Error("appointments series cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	s.notifySeriesCancellation(series, canceled, refunds, true, req.Reason)
+	s.closeSeriesIfDone(ctx, series, now)
+
+	log.Info("appointments series cancel: ok", slog.String("series_id", series.ID), slog.Int("canceled", len(canceled)))
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"seriesId":    series.ID,
+		"canceled":    len(canceled),
+		"occurrences": report,
+	})
+}
+
+// rescheduleOccurrences moves every occurrence to clock and duration on its
+// own day. Nothing is moved unless all of them fit; a slot taken between the
+// check and the write is reported on that occurrence.
+func (s *Server) rescheduleOccurrences(ctx context.Context, log *slog.Logger, occurrences []models.Appointment, clock string, duration int, override, notify bool, now time.Time) ([]seriesOccurrence, bool, error) {
+	report := make([]seriesOccurrence, 0, len(occurrences))
+	places := map[string]slotPlace{}
+	for _, occurrence := range occurrences {
+		item := seriesOccurrence{Date: occurrence.Date, Time: clock, AppointmentID: occurrence.ID, Status: occurrenceAvailable}
+		var place slotPlace
+		if occurrence.Location != nil {
+			// Occurrences usually share their location; look it up once.
+			cached, ok := places[occurrence.Location.ID]
+			if !ok {
+				var err error
+				if cached, err = s.appointmentPlace(ctx, occurrence); err != nil {
+					return nil, false, err
+				}
+				places[occurrence.Location.ID] = cached
+			}
+			place = cached
+			place.RoomID = occurrence.Location.RoomID
+		}
+		reason, placement, err := s.occurrenceConflictReason(ctx, place, occurrence.Date, clock, duration, override, occurrence.ID, now)
+		if err != nil {
+			return nil, false, err
+		}
+		item.placement = placement
+		if placement != nil {
+			item.RoomID = placement.RoomID
+		}
+		if reason != "" {
+			item.Status = occurrenceConflict
+			item.Reason = reason
+		}
+		report = append(report, item)
+	}
+	if countConflicts(report) > 0 {
+		return report, false, nil
+	}
+
+	for i, occurrence := range occurrences {
+		if occurrence.Time == clock && occurrence.Duration == duration {
+			report[i].Status = occurrenceSkipped
+			report[i].Reason = "unchanged"
+			continue
+		}
+		_, err := s.moveAppointment(ctx, log, occurrence, occurrence.Date, clock, duration, report[i].placement, notify)
+		switch {
+		case err == nil:
+			report[i].Status = occurrenceMoved
+		case errors.Is(err, errSlotTaken):
+			report[i].Status = occurrenceConflict
+			report[i].Reason = "slot already booked"
+		case errors.Is(err, errStatusChanged):
+			report[i].Status = occurrenceSkipped
+			report[i].Reason = "appointment status changed"
+		default:
+			return report, true, err
+		}
+	}
+	return report, true, nil
+}
+
+// RescheduleAppointmentSeries lets a customer move the rest of their series
+// to another time. Single occurrences are moved with
+// POST /appointments/reschedule.
+func (s *Server) RescheduleAppointmentSeries(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	var req RescheduleAppointmentSeriesRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("appointments series reschedule: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("appointments series reschedule: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	series, err := s.findCustomerSeries(ctx, id, req.Email)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("appointments series reschedule: not found", slog.String("series_id", id))
+			transport.WriteError(w, http.StatusNotFound, "series not found", nil)
+			return
+		}
+		log.Error("appointments series reschedule: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	s.rescheduleSeries(r.Context(), w, log, series, req.From, req.Time, series.Duration, false, true, "appointments series reschedule")
+}
+
+// rescheduleSeries is shared by the customer and admin endpoints and writes
+// the response.
+func (s *Server) rescheduleSeries(ctx context.Context, w http.ResponseWriter, log *slog.Logger, series models.AppointmentSeries, from, clock string, duration int, override, notify bool, op string) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	occurrences, err := s.upcomingOccurrences(ctx, series.ID, from, now)
+	if err != nil {
+		log.Error(op+": database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if len(occurrences) == 0 {
+		transport.WriteError(w, http.StatusConflict, "no upcoming occurrence", nil)
+		return
+	}
+
+	report, applied, err := s.rescheduleOccurrences(ctx, log, occurrences, clock, duration, override, notify, now)
+	if err != nil {
+		log.Error(op+": database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !applied {
+		log.Warn(op+": conflicts", slog.String("series_id", series.ID), slog.Int("conflicts", countConflicts(report)))
+		writeSeriesConflict(w, report)
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"time": clock, "duration": duration, "updatedAt": now.In(s.Cfg.Timezone)}}
+	if _, err := s.Cols.AppointmentSeries.UpdateOne(ctx, bson.M{"_id": series.ID}, update); err != nil {
+		log.Warn(op+": series not updated", slog.String("series_id", series.ID), slog.String("error", err.Error()))
+	}
+
+	log.Info(op+": ok", slog.String("series_id", series.ID), slog.String("time", clock))
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"seriesId":    series.ID,
+		"conflicts":   countConflicts(report),
+		"occurrences": report,
+	})
+}
+
+// AdminGetAppointmentSeries returns a series with all its occurrences.
+func (s *Server) AdminGetAppointmentSeries(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var series models.AppointmentSeries
+	if err := s.Cols.AppointmentSeries.FindOne(ctx, bson.M{"_id": id}).Decode(&series); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "series not found", nil)
+			return
+		}
+		log.Error("admin appointments series get: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var appointments []models.Appointment
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+	if err := s.findAll(ctx, s.Cols.Appointments, bson.M{"seriesId": id}, opts, &appointments); err != nil {
+		log.Error("admin appointments series get: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if appointments == nil {
+		appointments = []models.Appointment{}
+	}
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"series":       series,
+		"appointments": appointments,
+	})
+}
+
+// AdminUpdateAppointmentSeries moves the upcoming occurrences of a series.
+// Single occurrences are edited with PATCH /appointments/{id}.
+func (s *Server) AdminUpdateAppointmentSeries(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	var req AdminUpdateAppointmentSeriesRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin appointments series update: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin appointments series update: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var series models.AppointmentSeries
+	if err := s.Cols.AppointmentSeries.FindOne(ctx, bson.M{"_id": id}).Decode(&series); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "series not found", nil)
+			return
+		}
+		log.Error("admin appointments series update: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	clock, duration := series.Time, series.Duration
+	if req.Time != nil {
+		clock = *req.Time
+	}
+	if req.Duration != nil {
+		duration = *req.Duration
+	}
+	notify := true
+	if req.Notify != nil {
+		notify = *req.Notify
+	}
+	s.rescheduleSeries(r.Context(), w, log, series, req.From, clock, duration, req.OverrideAvailability, notify, "admin appointments series update")
+}
+
+// AdminCancelAppointmentSeries cancels the upcoming occurrences of a series,
+// or those from From onwards.
+func (s *Server) AdminCancelAppointmentSeries(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	var req AdminCancelAppointmentSeriesRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			log.Warn("admin appointments series cancel: invalid json")
+			transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+			return
+		}
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin appointments series cancel: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var series models.AppointmentSeries
+	if err := s.Cols.AppointmentSeries.FindOne(ctx, bson.M{"_id": id}).Decode(&series); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "series not found", nil)
+			return
+		}
+		log.Error("admin appointments series cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	now := time.Now()
+	occurrences, err := s.upcomingOccurrences(ctx, series.ID, req.From, now)
+	if err != nil {
+		log.Error("admin appointments series cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	change := models.AppointmentStatusChange{
+		Actor:   principal.Username,
+		ActorID: principal.UserID,
+		Reason:  req.Reason,
+	}
+	report, canceled, refunds, err := s.cancelOccurrences(ctx, log, occurrences, models.AppointmentStatusCanceledByAdmin, booking.ActorAdmin, change)
+	if err != nil {
+		log.Error("admin appointments series cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	s.notifySeriesCancellation(series, canceled, refunds, false, req.Reason)
+	s.closeSeriesIfDone(ctx, series, now)
+
+	log.Info("admin appointments series cancel: ok", slog.String("series_id", series.ID), slog.Int("canceled", len(canceled)))
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"seriesId":    series.ID,
+		"canceled":    len(canceled),
+		"occurrences": report,
+	})
+}
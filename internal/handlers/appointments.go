@@ -8,7 +8,9 @@ import (
 	"strings"
 	"time"
 
+	"gbh-backend/internal/booking"
 	"gbh-backend/internal/models"
+	"gbh-backend/internal/notifications"
 	"gbh-backend/internal/schedule"
 	"gbh-backend/internal/transport"
 
@@ -30,12 +32,64 @@ type CreateAppointmentRequest struct {
 	Duration      int    `json:"duration" validate:"omitempty,gte=15,lte=240,minutes15"`
 	PaymentMethod string `json:"paymentMethod" validate:"required,oneof=online place"`
 	Price         int    `json:"price" validate:"gte=0"`
+	// LocationID and RoomID place a presentiel appointment; the room is
+	// picked when left out.
+	LocationID string `json:"locationId,omitempty"`
+	RoomID     string `json:"roomId,omitempty"`
+	// Intake answers the service questionnaire, keyed by question ID: a
+	// string, a list of strings for multiple choice, or an upload token.
+	Intake map[string]interface{} `json:"intake,omitempty"`
 }
 
 type AppointmentLookupRequest struct {
 	ID string `json:"id" validate:"required"`
 }
 
+// appointmentView is what customers see of an appointment. The status
+// history, intake answers, calendar sync, refund and series bookkeeping stay
+// on the admin side.
+type appointmentView struct {
+	ID            string                      `json:"id"`
+	ServiceID     string                      `json:"serviceId"`
+	Name          string                      `json:"name"`
+	Email         string                      `json:"email"`
+	Phone         string                      `json:"phone"`
+	Type          string                      `json:"type"`
+	Date          string                      `json:"date"`
+	Time          string                      `json:"time"`
+	Duration      int                         `json:"duration"`
+	Price         int                         `json:"price"`
+	Tax           int                         `json:"tax"`
+	Total         int                         `json:"total"`
+	Status        string                      `json:"status"`
+	PaymentMethod string                      `json:"paymentMethod"`
+	CreatedAt     time.Time                   `json:"createdAt"`
+	Meeting       *models.MeetingLink         `json:"meeting,omitempty"`
+	Location      *models.AppointmentLocation `json:"location,omitempty"`
+}
+
+func viewAppointment(a models.Appointment) appointmentView {
+	return appointmentView{
+		ID:            a.ID,
+		ServiceID:     a.ServiceID,
+		Name:          a.Name,
+		Email:         a.Email,
+		Phone:         a.Phone,
+		Type:          a.Type,
+		Date:          a.Date,
+		Time:          a.Time,
+		Duration:      a.Duration,
+		Price:         a.Price,
+		Tax:           a.Tax,
+		Total:         a.Total,
+		Status:        a.Status,
+		PaymentMethod: a.PaymentMethod,
+		CreatedAt:     a.CreatedAt,
+		Meeting:       a.Meeting,
+		Location:      a.Location,
+	}
+}
+
 func (s *Server) CreateAppointment(w http.ResponseWriter, r *http.Request) {
 	log := s.logWithRequest(r)
 	var req CreateAppointmentRequest
@@ -69,16 +123,19 @@ func (s *Server) CreateAppointment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	allowed, err := schedule.IsSlotAllowedWithDuration(req.Date, req.Time, duration, s.Cfg.Timezone)
-	if err != nil {
-		log.Warn("appointments create: invalid time", slog.String("time", req.Time))
-		transport.WriteError(w, http.StatusBadRequest, "invalid time", nil)
-		return
-	}
-	if !allowed {
-		log.Warn("appointments create: slot not allowed", slog.String("date", req.Date), slog.String("time", req.Time))
-		transport.WriteError(w, http.StatusBadRequest, "slot not available", nil)
-		return
+	// Locations have their own opening hours, checked once the place is known.
+	if req.LocationID == "" {
+		allowed, err := schedule.IsSlotAllowedWithDuration(req.Date, req.Time, duration, s.Cfg.Timezone)
+		if err != nil {
+			log.Warn("appointments create: invalid time", slog.String("time", req.Time))
+			transport.WriteError(w, http.StatusBadRequest, "invalid time", nil)
+			return
+		}
+		if !allowed {
+			log.Warn("appointments create: slot not allowed", slog.String("date", req.Date), slog.String("time", req.Time))
+			transport.WriteError(w, http.StatusBadRequest, "slot not available", nil)
+			return
+		}
 	}
 
 	if dateIsToday(req.Date, s.Cfg.Timezone) {
@@ -110,26 +167,40 @@ func (s *Server) CreateAppointment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	reserved, err := s.reservedIntervals(ctx, req.Date)
+	answers, details, err := s.resolveIntake(ctx, service, req.Intake, true)
 	if err != nil {
 		log.Error("appointments create: database error", slog.String("error", err.Error()))
 		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
 		return
 	}
+	if details != nil {
+		log.Warn("appointments create: intake validation error")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
 
-	startMin, err := schedule.ParseClockToMinutes(req.Time)
+	place, details, err := s.resolvePlace(ctx, service, req.Type, req.LocationID, req.RoomID)
 	if err != nil {
-		log.Warn("appointments create: invalid time", slog.String("time", req.Time))
-		transport.WriteError(w, http.StatusBadRequest, "invalid time", nil)
+		log.Error("appointments create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
 		return
 	}
-	current := schedule.Interval{Start: startMin, End: startMin + duration}
-	for _, interval := range reserved {
-		if schedule.Overlaps(current, interval) {
-			log.Warn("appointments create: slot overlap", slog.String("date", req.Date), slog.String("time", req.Time))
-			transport.WriteError(w, http.StatusConflict, "slot not available", nil)
-			return
-		}
+	if details != nil {
+		log.Warn("appointments create: invalid location")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	placement, code, msg, err := s.placeSlot(ctx, place, req.Date, req.Time, duration, false, "")
+	if err != nil {
+		log.Error("appointments create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if code != 0 {
+		log.Warn("appointments create: slot refused", slog.String("date", req.Date), slog.String("time", req.Time), slog.String("reason", msg))
+		transport.WriteError(w, code, msg, nil)
+		return
 	}
 
 	appointment := models.Appointment{
@@ -148,7 +219,15 @@ func (s *Server) CreateAppointment(w http.ResponseWriter, r *http.Request) {
 		Status:        models.AppointmentStatusBooked,
 		PaymentMethod: req.PaymentMethod,
 		CreatedAt:     time.Now().In(s.Cfg.Timezone),
+		Intake:        answers,
+		Location:      placement,
 	}
+	appointment.StatusHistory = []models.AppointmentStatusChange{{
+		To:    appointment.Status,
+		Actor: booking.ActorCustomer,
+		At:    appointment.CreatedAt,
+	}}
+	s.assignMeetingLink(ctx, log, &appointment, service)
 
 	_, err = s.Cols.Appointments.InsertOne(ctx, appointment)
 	if err != nil {
@@ -162,6 +241,10 @@ func (s *Server) CreateAppointment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := s.attachIntakeFiles(ctx, appointment.Intake); err != nil {
+		log.Warn("appointments create: intake files not attached", slog.String("appointment_id", appointment.ID), slog.String("error", err.Error()))
+	}
+
 	if s.Cache != nil {
 		_ = s.Cache.DeletePrefix(r.Context(), "availability:"+req.Date+":")
 	}
@@ -183,6 +266,9 @@ func (s *Server) CreateAppointment(w http.ResponseWriter, r *http.Request) {
 	go func(appointment models.Appointment, service models.Service) {
 		subject := "Nouveau rendez-vous réservé"
 		htmlBody := fmt.Sprintf("<p>Un nouveau rendez-vous a été réservé par <strong>%s</strong> pour le service <strong>%s</strong> le <strong>%s</strong> à <strong>%s</strong>.</p><p>Référence : %s</p>", appointment.Name, service.Name, appointment.Date, appointment.Time, appointment.ID)
+		if answers, err := notifications.BuildIntakeAnswersHTML(appointment.Intake); err == nil {
+			htmlBody += answers
+		}
 		s.NotifyAdmins(context.Background(), subject, htmlBody)
 	}(appointment, service)
 
@@ -192,12 +278,12 @@ func (s *Server) CreateAppointment(w http.ResponseWriter, r *http.Request) {
 		slog.String("date", appointment.Date),
 		slog.String("time", appointment.Time),
 	)
-	availableSlots, err := s.computeAvailableSlots(ctx, req.Date, duration, time.Now())
+	availableSlots, err := s.availableSlotsAt(ctx, place, req.Date, duration, time.Now())
 	if err != nil {
 		log.Warn("appointments create: availability compute error", slog.String("error", err.Error()))
 	}
 	transport.WriteJSON(w, http.StatusCreated, map[string]interface{}{
-		"appointment":    appointment,
+		"appointment":    viewAppointment(appointment),
 		"availableSlots": availableSlots,
 	})
 }
@@ -224,6 +310,74 @@ func (s *Server) sendAppointmentConfirmationEmail(log *slog.Logger, appointment
 		slog.String("email", appointment.Email),
 		slog.String("message_id", messageID),
 	)
+
+	// Keep the message ID so Brevo delivery events can be traced back to the appointment.
+	update := bson.M{"$set": bson.M{"confirmationMessageId": messageID}}
+	if _, err := s.Cols.Appointments.UpdateOne(ctx, bson.M{"_id": appointment.ID}, update); err != nil {
+		log.Warn("appointments email: message id not saved",
+			slog.String("appointment_id", appointment.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func (s *Server) sendAppointmentCancellationEmail(log *slog.Logger, appointment models.Appointment) {
+	if s.Mailer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": appointment.ServiceID}).Decode(&service); err != nil && err != mongo.ErrNoDocuments {
+		log.Warn("appointments cancellation email: service lookup failed",
+			slog.String("appointment_id", appointment.ID),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	messageID, err := s.Mailer.SendAppointmentCancellation(ctx, appointment, service)
+	if err != nil {
+		log.Warn("appointments cancellation email: send failed",
+			slog.String("appointment_id", appointment.ID),
+			slog.String("email", appointment.Email),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	log.Info("appointments cancellation email: sent",
+		slog.String("appointment_id", appointment.ID),
+		slog.String("email", appointment.Email),
+		slog.String("message_id", messageID),
+	)
+}
+
+// sendAppointmentRescheduledEmail sends the updated invitation; the caller
+// must have bumped calendarSequence so calendars replace the old event.
+func (s *Server) sendAppointmentRescheduledEmail(log *slog.Logger, appointment models.Appointment, service models.Service) {
+	if s.Mailer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	messageID, err := s.Mailer.SendAppointmentRescheduled(ctx, appointment, service)
+	if err != nil {
+		log.Warn("appointments rescheduled email: send failed",
+			slog.String("appointment_id", appointment.ID),
+			slog.String("email", appointment.Email),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	log.Info("appointments rescheduled email: sent",
+		slog.String("appointment_id", appointment.ID),
+		slog.String("email", appointment.Email),
+		slog.String("message_id", messageID),
+	)
 }
 
 func (s *Server) sendAppointmentConfirmationPush(log *slog.Logger, appointment models.Appointment, service models.Service, deviceToken string) {
@@ -262,7 +416,7 @@ func (s *Server) GetAppointment(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	doc, err := s.findAppointmentByID(ctx, id)
+	appointment, err := s.findAppointmentByID(ctx, id)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			log.Warn("appointments get: not found", slog.String("appointment_id", id))
@@ -275,7 +429,7 @@ func (s *Server) GetAppointment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Info("appointments get: ok", slog.String("appointment_id", id))
-	transport.WriteJSON(w, http.StatusOK, normalizeID(doc))
+	transport.WriteJSON(w, http.StatusOK, viewAppointment(appointment))
 }
 
 func (s *Server) LookupAppointment(w http.ResponseWriter, r *http.Request) {
@@ -297,7 +451,7 @@ func (s *Server) LookupAppointment(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	doc, err := s.findAppointmentByID(ctx, req.ID)
+	appointment, err := s.findAppointmentByID(ctx, req.ID)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			log.Warn("appointments lookup: not found", slog.String("appointment_id", req.ID))
@@ -310,13 +464,11 @@ func (s *Server) LookupAppointment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Info("appointments lookup: ok", slog.String("appointment_id", req.ID))
-	transport.WriteJSON(w, http.StatusOK, normalizeID(doc))
+	transport.WriteJSON(w, http.StatusOK, viewAppointment(appointment))
 }
 
-func (s *Server) findAppointmentByID(ctx context.Context, id string) (bson.M, error) {
-	var doc bson.M
-	if err := s.Cols.Appointments.FindOne(ctx, bson.M{"_id": id}).Decode(&doc); err != nil {
-		return nil, err
-	}
-	return doc, nil
+func (s *Server) findAppointmentByID(ctx context.Context, id string) (models.Appointment, error) {
+	var appointment models.Appointment
+	err := s.Cols.Appointments.FindOne(ctx, bson.M{"_id": id}).Decode(&appointment)
+	return appointment, err
 }
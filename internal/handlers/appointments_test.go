@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func bookAppointment(t *testing.T, s *Server, date, slot string) (int, models.Appointment) {
+	t.Helper()
+	body, _ := json.Marshal(CreateAppointmentRequest{
+		ServiceID:     "svc-test",
+		Name:          "Jean Test",
+		Email:         "jean@example.com",
+		Phone:         "+243810000000",
+		Type:          models.ConsultationPresentiel,
+		Date:          date,
+		Time:          slot,
+		PaymentMethod: models.PaymentPlace,
+	})
+	rec := httptest.NewRecorder()
+	s.CreateAppointment(rec, httptest.NewRequest(http.MethodPost, "/api/appointments", bytes.NewReader(body)))
+	var resp struct {
+		Appointment models.Appointment `json:"appointment"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp.Appointment
+}
+
+func TestCanceledAppointmentFreesSlot(t *testing.T) {
+	s := newMongoTestServer(t)
+	ctx := context.Background()
+	if _, err := s.Cols.Services.InsertOne(ctx, models.Service{ID: "svc-test", Name: "Consultation"}); err != nil {
+		t.Fatalf("insert service: %v", err)
+	}
+	date := nextWeekday(s.Cfg.Timezone)
+
+	code, first := bookAppointment(t, s, date, "09:00")
+	if code != http.StatusCreated {
+		t.Fatalf("first booking: status %d", code)
+	}
+	if code, _ := bookAppointment(t, s, date, "09:00"); code != http.StatusConflict {
+		t.Fatalf("second booking on a taken slot: status %d, want 409", code)
+	}
+
+	body, _ := json.Marshal(AdminStatusRequest{Status: models.AppointmentStatusCanceledByAdmin})
+	req := httptest.NewRequest(http.MethodPatch, "/api/admin/appointments/"+first.ID+"/status", bytes.NewReader(body))
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", first.ID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	s.AdminUpdateAppointmentStatus(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	slots, err := s.computeAvailableSlots(ctx, date, 45, time.Now())
+	if err != nil {
+		t.Fatalf("computeAvailableSlots() error = %v", err)
+	}
+	if !slices.Contains(slots, "09:00") {
+		t.Fatalf("expected 09:00 to be available again, got %v", slots)
+	}
+
+	code, second := bookAppointment(t, s, date, "09:00")
+	if code != http.StatusCreated {
+		t.Fatalf("rebooking a canceled slot: status %d, want 201", code)
+	}
+	if second.ID == first.ID {
+		t.Fatalf("expected a new appointment")
+	}
+	if code, _ := bookAppointment(t, s, date, "09:00"); code != http.StatusConflict {
+		t.Fatalf("booking over the rebooked slot: status %d, want 409", code)
+	}
+}
+
+func TestPublicAppointmentHidesInternals(t *testing.T) {
+	s := newMongoTestServer(t)
+	sequence := 2
+	appointment := models.Appointment{
+		ID:                   "apt-public",
+		ServiceID:            "svc-test",
+		Name:                 "Jean Test",
+		Email:                "jean@example.com",
+		Status:               models.AppointmentStatusCanceledByAdmin,
+		CreatedAt:            time.Now(),
+		CalendarSequence:     sequence,
+		CalDAVHref:           "/calendars/gbh/apt-public.ics",
+		CalDAVETag:           `"etag"`,
+		CalDAVSyncedSequence: &sequence,
+		SeriesID:             "series-1",
+		StatusHistory: []models.AppointmentStatusChange{
+			{From: models.AppointmentStatusBooked, To: models.AppointmentStatusCanceledByAdmin, Actor: "admin", ActorID: "u-admin", Reason: "note interne", At: time.Now()},
+		},
+		Refund: &models.Refund{Status: models.RefundStatusPending, Amount: 5000, Currency: "CDF", RequestedAt: time.Now()},
+		Intake: []models.IntakeAnswer{{QuestionID: "q1", Label: "Motif", Type: "text", Value: "confidentiel"}},
+	}
+	if _, err := s.Cols.Appointments.InsertOne(context.Background(), appointment); err != nil {
+		t.Fatalf("insert appointment: %v", err)
+	}
+
+	get := httptest.NewRequest(http.MethodGet, "/api/appointments/"+appointment.ID, nil)
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", appointment.ID)
+	get = get.WithContext(context.WithValue(get.Context(), chi.RouteCtxKey, routeCtx))
+	lookup := httptest.NewRequest(http.MethodPost, "/api/appointments/lookup", bytes.NewReader([]byte(`{"id":"`+appointment.ID+`"}`)))
+
+	for name, call := range map[string]func() *httptest.ResponseRecorder{
+		"get": func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			s.GetAppointment(rec, get)
+			return rec
+		},
+		"lookup": func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			s.LookupAppointment(rec, lookup)
+			return rec
+		},
+	} {
+		rec := call()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", name, rec.Code, rec.Body.String())
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		if body["id"] != appointment.ID || body["status"] != appointment.Status {
+			t.Fatalf("%s: body = %v", name, body)
+		}
+		for _, key := range []string{"_id", "statusHistory", "intake", "refund", "seriesId", "caldavHref", "caldavEtag", "caldavSyncedSequence", "calendarSequence", "confirmationMessageId"} {
+			if _, ok := body[key]; ok {
+				t.Fatalf("%s: response exposes %q", name, key)
+			}
+		}
+	}
+}
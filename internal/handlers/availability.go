@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -11,10 +12,22 @@ import (
 	"gbh-backend/internal/transport"
 )
 
+// maxAvailabilityRangeDays bounds GET /availability/range, and
+// nextAvailabilityDays is how far GET /availability/next looks ahead.
+const (
+	maxAvailabilityRangeDays = 60
+	nextAvailabilityDays     = 30
+)
+
 type availabilityQuery struct {
 	Date string `validate:"required,date"`
 }
 
+type availabilityRangeQuery struct {
+	From string `validate:"required,date"`
+	To   string `validate:"required,date"`
+}
+
 func (s *Server) GetAvailability(w http.ResponseWriter, r *http.Request) {
 	log := s.logWithRequest(r)
 	q := availabilityQuery{Date: r.URL.Query().Get("date")}
@@ -32,7 +45,22 @@ func (s *Server) GetAvailability(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cacheKey := "availability:" + q.Date + ":" + strconv.Itoa(duration)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	place, details, err := s.queryPlace(ctx, r.URL.Query())
+	if err != nil {
+		log.Error("availability: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details != nil {
+		log.Warn("availability: invalid place")
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", details)
+		return
+	}
+
+	cacheKey := availabilityCacheKey(q.Date, duration, place)
 	if s.Cache != nil {
 		if cached, ok, err := s.Cache.Get(r.Context(), cacheKey); err == nil && ok {
 			log.Info("availability: cache hit", slog.String("date", q.Date))
@@ -53,28 +81,208 @@ func (s *Server) GetAvailability(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-	slots, err := s.computeAvailableSlots(ctx, q.Date, duration, time.Now())
+	slots, err := s.availableSlotsAt(ctx, place, q.Date, duration, time.Now())
 	if err != nil {
 		log.Error("availability: compute error", slog.String("error", err.Error()))
 		transport.WriteError(w, http.StatusInternalServerError, "availability error", nil)
 		return
 	}
 
+	response := s.availabilityResponse(q.Date, duration, place, slots)
+	if payload, err := encodeJSON(response); err == nil && s.Cache != nil {
+		_ = s.Cache.Set(r.Context(), cacheKey, payload, time.Duration(s.Cfg.CacheTTLSeconds)*time.Second)
+	}
+
+	log.Info("availability: ok", slog.String("date", q.Date), slog.Int("duration", duration), slog.Int("slots", len(slots)))
+	transport.WriteJSON(w, http.StatusOK, response)
+}
+
+// GetAvailabilityRange returns, for each day from from to to (inclusive,
+// at most maxAvailabilityRangeDays), the number of free slots, and the
+// slots themselves with slots=true. Days already cached by GetAvailability
+// are reused; the others are computed together and cached in turn.
+func (s *Server) GetAvailabilityRange(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	values := r.URL.Query()
+	today := time.Now().In(s.Cfg.Timezone).Format("2006-01-02")
+	q := availabilityRangeQuery{From: values.Get("from"), To: values.Get("to")}
+	if q.From == "" {
+		q.From = today
+	}
+	if q.To == "" {
+		if start, err := schedule.ParseDate(q.From, s.Cfg.Timezone); err == nil {
+			q.To = start.AddDate(0, 0, nextAvailabilityDays-1).Format("2006-01-02")
+		}
+	}
+	if err := s.Val.Struct(q); err != nil {
+		log.Warn("availability range: invalid query")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", details)
+		return
+	}
+
+	duration, err := parseDurationParam(values.Get("duration"), schedule.SlotMinutes)
+	if err != nil {
+		log.Warn("availability range: invalid duration")
+		transport.WriteError(w, http.StatusBadRequest, "invalid duration", nil)
+		return
+	}
+	includeSlots := values.Get("slots") == "true"
+
+	startDate, err := schedule.ParseDate(q.From, s.Cfg.Timezone)
+	if err != nil {
+		transport.WriteError(w, http.StatusBadRequest, "invalid date", nil)
+		return
+	}
+	endDate, err := schedule.ParseDate(q.To, s.Cfg.Timezone)
+	if err != nil {
+		transport.WriteError(w, http.StatusBadRequest, "invalid date", nil)
+		return
+	}
+	if endDate.Before(startDate) {
+		log.Warn("availability range: invalid range", slog.String("from", q.From), slog.String("to", q.To))
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"to": "gtefield"})
+		return
+	}
+	if endDate.After(startDate.AddDate(0, 0, maxAvailabilityRangeDays-1)) {
+		log.Warn("availability range: range too long", slog.String("from", q.From), slog.String("to", q.To))
+		transport.WriteError(w, http.StatusBadRequest, "range too long", map[string]string{"days": strconv.Itoa(maxAvailabilityRangeDays)})
+		return
+	}
+	if q.To < today {
+		log.Warn("availability range: date in the past", slog.String("to", q.To))
+		transport.WriteError(w, http.StatusBadRequest, "date in the past", nil)
+		return
+	}
+	// A month view may start before today: past days are left out.
+	if q.From < today {
+		q.From = today
+		startDate, _ = schedule.ParseDate(today, s.Cfg.Timezone)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	place, details, err := s.queryPlace(ctx, values)
+	if err != nil {
+		log.Error("availability range: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details != nil {
+		log.Warn("availability range: invalid place")
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", details)
+		return
+	}
+
+	dates := dateRange(startDate, endDate)
+	slotsByDate := make(map[string][]string, len(dates))
+	missing := make([]string, 0, len(dates))
+	for _, date := range dates {
+		if slots, ok := s.cachedAvailability(r.Context(), availabilityCacheKey(date, duration, place)); ok {
+			slotsByDate[date] = slots
+			continue
+		}
+		missing = append(missing, date)
+	}
+	if len(missing) > 0 {
+		computed, err := s.availableSlotsByDate(ctx, place, missing, duration, time.Now())
+		if err != nil {
+			log.Error("availability range: compute error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "availability error", nil)
+			return
+		}
+		for _, date := range missing {
+			slotsByDate[date] = computed[date]
+			if s.Cache == nil {
+				continue
+			}
+			if payload, err := encodeJSON(s.availabilityResponse(date, duration, place, computed[date])); err == nil {
+				_ = s.Cache.Set(r.Context(), availabilityCacheKey(date, duration, place), payload, time.Duration(s.Cfg.CacheTTLSeconds)*time.Second)
+			}
+		}
+	}
+
+	days := make([]map[string]interface{}, 0, len(dates))
+	total := 0
+	for _, date := range dates {
+		day := map[string]interface{}{
+			"date":  date,
+			"count": len(slotsByDate[date]),
+		}
+		if includeSlots {
+			day["slots"] = slotsByDate[date]
+		}
+		total += len(slotsByDate[date])
+		days = append(days, day)
+	}
+
+	response := map[string]interface{}{
+		"from":     q.From,
+		"to":       q.To,
+		"timezone": s.Cfg.Timezone.String(),
+		"duration": duration,
+		"days":     days,
+	}
+	if place.Location != nil {
+		response["locationId"] = place.Location.ID
+		if place.RoomID != "" {
+			response["roomId"] = place.RoomID
+		}
+	}
+
+	log.Info("availability range: ok", slog.String("from", q.From), slog.String("to", q.To), slog.Int("days", len(dates)), slog.Int("cached", len(dates)-len(missing)), slog.Int("slots", total))
+	transport.WriteJSON(w, http.StatusOK, response)
+}
+
+// availabilityCacheKey is the cache entry of the free slots of date. It is
+// under the "availability:<date>:" prefix cleared when the day changes.
+func availabilityCacheKey(date string, duration int, place slotPlace) string {
+	return "availability:" + date + ":" + strconv.Itoa(duration) + place.cacheSuffix()
+}
+
+// availabilityResponse is the body of GetAvailability, cached as is.
+func (s *Server) availabilityResponse(date string, duration int, place slotPlace, slots []string) map[string]interface{} {
 	response := map[string]interface{}{
-		"date":     q.Date,
+		"date":     date,
 		"timezone": s.Cfg.Timezone.String(),
 		"duration": duration,
 		"slots":    slots,
 	}
+	if place.Location != nil {
+		response["locationId"] = place.Location.ID
+		if place.RoomID != "" {
+			response["roomId"] = place.RoomID
+		}
+	}
+	return response
+}
 
-	if payload, err := encodeJSON(response); err == nil && s.Cache != nil {
-		_ = s.Cache.Set(r.Context(), cacheKey, payload, time.Duration(s.Cfg.CacheTTLSeconds)*time.Second)
+// cachedAvailability reads the slots of a cached availabilityResponse.
+func (s *Server) cachedAvailability(ctx context.Context, key string) ([]string, bool) {
+	if s.Cache == nil {
+		return nil, false
+	}
+	cached, ok, err := s.Cache.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false
 	}
+	var day struct {
+		Slots []string `json:"slots"`
+	}
+	if err := json.Unmarshal(cached, &day); err != nil || day.Slots == nil {
+		return nil, false
+	}
+	return day.Slots, true
+}
 
-	log.Info("availability: ok", slog.String("date", q.Date), slog.Int("duration", duration), slog.Int("slots", len(slots)))
-	transport.WriteJSON(w, http.StatusOK, response)
+// dateRange lists the dates from start to end, both included.
+func dateRange(start, end time.Time) []string {
+	dates := make([]string, 0)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day.Format("2006-01-02"))
+	}
+	return dates
 }
 
 func dateIsToday(dateStr string, loc *time.Location) bool {
@@ -4,9 +4,11 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
+	"gbh-backend/internal/models"
 	"gbh-backend/internal/schedule"
 	"gbh-backend/internal/transport"
 	"github.com/go-chi/chi/v5"
@@ -51,7 +53,8 @@ func (s *Server) GetServiceAvailability(w http.ResponseWriter, r *http.Request)
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": serviceID}).Err(); err != nil {
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": serviceID}).Decode(&service); err != nil {
 		if err == mongo.ErrNoDocuments {
 			log.Warn("service availability: service not found", slog.String("service_id", serviceID))
 			transport.WriteError(w, http.StatusNotFound, "service not found", nil)
@@ -62,7 +65,22 @@ func (s *Server) GetServiceAvailability(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	slots, err := s.computeAvailableSlots(ctx, q.Date, duration, time.Now())
+	place, details, err := s.queryPlace(ctx, r.URL.Query())
+	if err != nil {
+		log.Error("service availability: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details == nil && place.Location != nil && !slices.Contains(service.LocationIDs, place.Location.ID) {
+		details = map[string]string{"location": "oneof"}
+	}
+	if details != nil {
+		log.Warn("service availability: invalid place", slog.String("service_id", serviceID))
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", details)
+		return
+	}
+
+	slots, err := s.availableSlotsAt(ctx, place, q.Date, duration, time.Now())
 	if err != nil {
 		log.Error("service availability: compute error", slog.String("error", err.Error()))
 		transport.WriteError(w, http.StatusInternalServerError, "availability error", nil)
@@ -76,6 +94,12 @@ func (s *Server) GetServiceAvailability(w http.ResponseWriter, r *http.Request)
 		"duration":  duration,
 		"slots":     slots,
 	}
+	if place.Location != nil {
+		response["locationId"] = place.Location.ID
+		if place.RoomID != "" {
+			response["roomId"] = place.RoomID
+		}
+	}
 
 	log.Info("service availability: ok", slog.String("service_id", serviceID), slog.String("date", q.Date), slog.Int("slots", len(slots)))
 	transport.WriteJSON(w, http.StatusOK, response)
@@ -115,18 +139,18 @@ func (s *Server) GetNextAvailability(w http.ResponseWriter, r *http.Request) {
 		transport.WriteError(w, http.StatusBadRequest, "invalid date", nil)
 		return
 	}
-	for i := 0; i < 30; i++ {
-		current := startDate.AddDate(0, 0, i)
-		dateStr := current.Format("2006-01-02")
-
-		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-		slots, err := s.computeAvailableSlots(ctx, dateStr, duration, time.Now())
-		cancel()
-		if err != nil {
-			log.Error("availability next: compute error", slog.String("error", err.Error()))
-			transport.WriteError(w, http.StatusInternalServerError, "availability error", nil)
-			return
-		}
+	// Look at the whole window in one go rather than day by day.
+	dates := dateRange(startDate, startDate.AddDate(0, 0, nextAvailabilityDays-1))
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+	free, err := s.computeAvailableSlotsByDate(ctx, dates, duration, time.Now())
+	if err != nil {
+		log.Error("availability next: compute error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "availability error", nil)
+		return
+	}
+	for _, dateStr := range dates {
+		slots := free[dateStr]
 		if len(slots) > 0 {
 			response := map[string]interface{}{
 				"date":     dateStr,
@@ -140,5 +164,5 @@ func (s *Server) GetNextAvailability(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	transport.WriteError(w, http.StatusNotFound, "no availability found", map[string]string{"days": strconv.Itoa(30)})
+	transport.WriteError(w, http.StatusNotFound, "no availability found", map[string]string{"days": strconv.Itoa(nextAvailabilityDays)})
 }
@@ -4,14 +4,38 @@ import (
 	"context"
 	"time"
 
+	"gbh-backend/internal/models"
 	"gbh-backend/internal/schedule"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-func (s *Server) reservedIntervals(ctx context.Context, date string) ([]schedule.Interval, error) {
-	intervals := make([]schedule.Interval, 0)
+// reservedIntervals returns the busy time of date. Appointments listed in
+// excludeIDs are ignored, so an appointment being moved does not collide
+// with itself.
+func (s *Server) reservedIntervals(ctx context.Context, date string, excludeIDs ...string) ([]schedule.Interval, error) {
+	busy, err := s.reservedIntervalsByDate(ctx, []string{date}, excludeIDs...)
+	if err != nil {
+		return nil, err
+	}
+	return busy[date], nil
+}
 
-	appCursor, err := s.Cols.Appointments.Find(ctx, bson.M{"date": date})
+// reservedIntervalsByDate is reservedIntervals for several dates, with one
+// query per collection whatever the number of dates.
+func (s *Server) reservedIntervalsByDate(ctx context.Context, dates []string, excludeIDs ...string) (map[string][]schedule.Interval, error) {
+	busy := make(map[string][]schedule.Interval, len(dates))
+
+	// Canceled appointments give their slot back. Appointments held at a
+	// location only take their room.
+	appFilter := bson.M{
+		"date":        bson.M{"$in": dates},
+		"status":      bson.M{"$in": models.ActiveAppointmentStatuses},
+		"location.id": bson.M{"$exists": false},
+	}
+	if len(excludeIDs) > 0 {
+		appFilter["_id"] = bson.M{"$nin": excludeIDs}
+	}
+	appCursor, err := s.Cols.Appointments.Find(ctx, appFilter)
 	if err != nil {
 		return nil, err
 	}
@@ -20,74 +44,159 @@ func (s *Server) reservedIntervals(ctx context.Context, date string) ([]schedule
 		if err := appCursor.Decode(&doc); err != nil {
 			continue
 		}
-		timeStr, ok := doc["time"].(string)
-		if !ok || timeStr == "" {
-			continue
-		}
-		start, err := schedule.ParseClockToMinutes(timeStr)
-		if err != nil {
+		date, start, ok := docStart(doc)
+		if !ok {
 			continue
 		}
 		duration := extractInt(doc["duration"])
 		if duration <= 0 {
 			duration = schedule.SlotMinutes
 		}
-		intervals = append(intervals, schedule.Interval{Start: start, End: start + duration})
+		busy[date] = append(busy[date], schedule.Interval{Start: start, End: start + duration})
 	}
 	if err := appCursor.Err(); err != nil {
 		return nil, err
 	}
 	appCursor.Close(ctx)
 
-	blockCursor, err := s.Cols.ReservationBlocks.Find(ctx, bson.M{"date": date})
+	blocks, err := s.blockIntervalsByDate(ctx, dates)
 	if err != nil {
 		return nil, err
 	}
-	for blockCursor.Next(ctx) {
+	for date, intervals := range blocks {
+		busy[date] = append(busy[date], intervals...)
+	}
+
+	// Busy time pulled from external calendars by the CalDAV sync.
+	busyCursor, err := s.Cols.ExternalBusy.Find(ctx, bson.M{"date": bson.M{"$in": dates}})
+	if err != nil {
+		return nil, err
+	}
+	for busyCursor.Next(ctx) {
 		var doc bson.M
-		if err := blockCursor.Decode(&doc); err != nil {
+		if err := busyCursor.Decode(&doc); err != nil {
 			continue
 		}
-		timeStr, ok := doc["time"].(string)
-		if !ok || timeStr == "" {
+		date, start, ok := docStart(doc)
+		if !ok {
 			continue
 		}
-		start, err := schedule.ParseClockToMinutes(timeStr)
-		if err != nil {
+		duration := extractInt(doc["duration"])
+		if duration <= 0 {
 			continue
 		}
-		intervals = append(intervals, schedule.Interval{Start: start, End: start + schedule.SlotMinutes})
+		busy[date] = append(busy[date], schedule.Interval{Start: start, End: start + duration})
 	}
-	if err := blockCursor.Err(); err != nil {
+	if err := busyCursor.Err(); err != nil {
 		return nil, err
 	}
-	blockCursor.Close(ctx)
+	busyCursor.Close(ctx)
 
-	return intervals, nil
+	// Scheduled group sessions keep the practitioner busy.
+	sessionFilter := bson.M{"date": bson.M{"$in": dates}, "status": models.GroupSessionStatusScheduled}
+	if len(excludeIDs) > 0 {
+		sessionFilter["_id"] = bson.M{"$nin": excludeIDs}
+	}
+	var sessions []models.GroupSession
+	if err := s.findAll(ctx, s.Cols.GroupSessions, sessionFilter, nil, &sessions); err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		start, err := schedule.ParseClockToMinutes(session.Time)
+		if err != nil {
+			continue
+		}
+		busy[session.Date] = append(busy[session.Date], schedule.Interval{Start: start, End: start + session.Duration})
+	}
+
+	return busy, nil
 }
 
-func (s *Server) computeAvailableSlots(ctx context.Context, date string, duration int, now time.Time) ([]string, error) {
-	slots, err := schedule.GenerateSlotsWithDuration(date, duration, s.Cfg.Timezone)
+// blockIntervals returns the slots of date closed by admins. They apply to
+// every calendar, rooms included.
+func (s *Server) blockIntervals(ctx context.Context, date string) ([]schedule.Interval, error) {
+	blocks, err := s.blockIntervalsByDate(ctx, []string{date})
 	if err != nil {
 		return nil, err
 	}
+	return blocks[date], nil
+}
+
+// blockIntervalsByDate is blockIntervals for several dates.
+func (s *Server) blockIntervalsByDate(ctx context.Context, dates []string) (map[string][]schedule.Interval, error) {
+	blocks := make(map[string][]schedule.Interval, len(dates))
+	blockCursor, err := s.Cols.ReservationBlocks.Find(ctx, bson.M{"date": bson.M{"$in": dates}})
+	if err != nil {
+		return nil, err
+	}
+	defer blockCursor.Close(ctx)
+	for blockCursor.Next(ctx) {
+		var doc bson.M
+		if err := blockCursor.Decode(&doc); err != nil {
+			continue
+		}
+		date, start, ok := docStart(doc)
+		if !ok {
+			continue
+		}
+		blocks[date] = append(blocks[date], schedule.Interval{Start: start, End: start + schedule.SlotMinutes})
+	}
+	if err := blockCursor.Err(); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// docStart reads the date and start time of a busy document.
+func docStart(doc bson.M) (string, int, bool) {
+	date, _ := doc["date"].(string)
+	timeStr, _ := doc["time"].(string)
+	if date == "" || timeStr == "" {
+		return "", 0, false
+	}
+	start, err := schedule.ParseClockToMinutes(timeStr)
+	if err != nil {
+		return "", 0, false
+	}
+	return date, start, true
+}
 
-	intervals, err := s.reservedIntervals(ctx, date)
+func (s *Server) computeAvailableSlots(ctx context.Context, date string, duration int, now time.Time) ([]string, error) {
+	slots, err := s.computeAvailableSlotsByDate(ctx, []string{date}, duration, now)
 	if err != nil {
 		return nil, err
 	}
+	return slots[date], nil
+}
 
-	slots, err = schedule.FilterOverlapping(slots, duration, intervals)
+// computeAvailableSlotsByDate lists the free slots of each of dates on the
+// general calendar.
+func (s *Server) computeAvailableSlotsByDate(ctx context.Context, dates []string, duration int, now time.Time) (map[string][]string, error) {
+	busy, err := s.reservedIntervalsByDate(ctx, dates)
 	if err != nil {
 		return nil, err
 	}
 
-	if dateIsToday(date, s.Cfg.Timezone) {
-		slots, err = schedule.FilterPastSlots(date, slots, s.Cfg.Timezone, now)
+	free := make(map[string][]string, len(dates))
+	for _, date := range dates {
+		slots, err := schedule.GenerateSlotsWithDuration(date, duration, s.Cfg.Timezone)
 		if err != nil {
 			return nil, err
 		}
+
+		slots, err = schedule.FilterOverlapping(slots, duration, busy[date])
+		if err != nil {
+			return nil, err
+		}
+
+		if dateIsToday(date, s.Cfg.Timezone) {
+			slots, err = schedule.FilterPastSlots(date, slots, s.Cfg.Timezone, now)
+			if err != nil {
+				return nil, err
+			}
+		}
+		free[date] = slots
 	}
 
-	return slots, nil
+	return free, nil
 }
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/config"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/schedule"
+	"gbh-backend/internal/validation"
+)
+
+type memoryCache map[string][]byte
+
+func (c memoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, ok := c[key]
+	return value, ok, nil
+}
+
+func (c memoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c[key] = value
+	return nil
+}
+
+func (c memoryCache) Delete(ctx context.Context, key string) error {
+	delete(c, key)
+	return nil
+}
+
+func (c memoryCache) DeletePrefix(ctx context.Context, prefix string) error {
+	for key := range c {
+		if strings.HasPrefix(key, prefix) {
+			delete(c, key)
+		}
+	}
+	return nil
+}
+
+func TestGetAvailabilityRangeFromCache(t *testing.T) {
+	loc, err := time.LoadLocation("Africa/Kinshasa")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	mem := memoryCache{}
+	// Without a database, the handler can only answer from the cache.
+	s := &Server{
+		Cfg:   &config.Config{Timezone: loc},
+		Val:   validation.New(),
+		Log:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Cache: mem,
+	}
+	start := time.Now().In(loc).AddDate(0, 0, 1)
+	dates := dateRange(start, start.AddDate(0, 0, 2))
+	for i, date := range dates {
+		payload, _ := encodeJSON(s.availabilityResponse(date, schedule.SlotMinutes, slotPlace{}, []string{"09:00", "10:00", "11:00"}[:i+1]))
+		mem[availabilityCacheKey(date, schedule.SlotMinutes, slotPlace{})] = payload
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.GetAvailabilityRange(rec, httptest.NewRequest(http.MethodGet, "/api/availability/range?"+query, nil))
+		return rec
+	}
+
+	rec := get("from=" + dates[0] + "&to=" + dates[2] + "&slots=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Days []struct {
+			Date  string   `json:"date"`
+			Count int      `json:"count"`
+			Slots []string `json:"slots"`
+		} `json:"days"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Days) != 3 {
+		t.Fatalf("days = %+v", resp.Days)
+	}
+	for i, day := range resp.Days {
+		if day.Date != dates[i] || day.Count != i+1 || len(day.Slots) != i+1 {
+			t.Fatalf("days[%d] = %+v", i, day)
+		}
+	}
+
+	end := start.AddDate(0, 0, maxAvailabilityRangeDays).Format("2006-01-02")
+	if rec := get("from=" + dates[0] + "&to=" + end); rec.Code != http.StatusBadRequest {
+		t.Fatalf("range of %d days: status %d, want 400", maxAvailabilityRangeDays+1, rec.Code)
+	}
+	if rec := get("from=" + dates[2] + "&to=" + dates[0]); rec.Code != http.StatusBadRequest {
+		t.Fatalf("to before from: status %d, want 400", rec.Code)
+	}
+}
+
+func TestAvailableSlotsByDateMatchesDays(t *testing.T) {
+	s := newMongoTestServer(t)
+	ctx := context.Background()
+	date := nextWeekday(s.Cfg.Timezone)
+	appointment := models.Appointment{
+		ID:       "apt-range",
+		Date:     date,
+		Time:     "10:00",
+		Duration: 90,
+		Status:   models.AppointmentStatusBooked,
+	}
+	if _, err := s.Cols.Appointments.InsertOne(ctx, appointment); err != nil {
+		t.Fatalf("insert appointment: %v", err)
+	}
+	if _, err := s.Cols.ReservationBlocks.InsertOne(ctx, models.ReservationBlock{ID: "block-range", Date: date, Time: "15:00"}); err != nil {
+		t.Fatalf("insert block: %v", err)
+	}
+
+	start, _ := schedule.ParseDate(date, s.Cfg.Timezone)
+	dates := dateRange(start.AddDate(0, 0, -2), start.AddDate(0, 0, 4))
+	free, err := s.availableSlotsByDate(ctx, slotPlace{}, dates, schedule.SlotMinutes, time.Now())
+	if err != nil {
+		t.Fatalf("availableSlotsByDate() error = %v", err)
+	}
+	for _, day := range dates {
+		want, err := s.computeAvailableSlots(ctx, day, schedule.SlotMinutes, time.Now())
+		if err != nil {
+			t.Fatalf("computeAvailableSlots(%s) error = %v", day, err)
+		}
+		if !slices.Equal(free[day], want) {
+			t.Fatalf("slots of %s = %v, want %v", day, free[day], want)
+		}
+	}
+	if slices.Contains(free[date], "09:45") || slices.Contains(free[date], "14:45") {
+		t.Fatalf("expected the appointment and block to be busy, got %v", free[date])
+	}
+}
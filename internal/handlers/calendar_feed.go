@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/calendar"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/notifications"
+	"gbh-backend/internal/rbac"
+	"gbh-backend/internal/transport"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	calendarFeedPastDays   = 30
+	calendarFeedFutureDays = 365
+	calendarFeedLimit      = 2000
+)
+
+type CalendarFeedResponse struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// AdminCreateCalendarFeed issues (or rotates) the admin's calendar feed token.
+// Any previous feed URL stops working.
+func (s *Server) AdminCreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		log.Warn("admin calendar feed create: missing id")
+		transport.WriteError(w, http.StatusBadRequest, "missing id", nil)
+		return
+	}
+
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		log.Error("admin calendar feed create: token error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin calendar feed create") {
+		return
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"calendarFeedTokenHash": hash,
+			"updatedAt":             time.Now().In(s.Cfg.Timezone),
+		},
+	}
+	res, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		log.Error("admin calendar feed create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.MatchedCount == 0 {
+		log.Warn("admin calendar feed create: not found", slog.String("user_id", id))
+		transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+		return
+	}
+
+	log.Info("admin calendar feed create: ok", slog.String("user_id", id))
+	transport.WriteJSON(w, http.StatusCreated, CalendarFeedResponse{
+		Token: token,
+		URL:   s.publicBaseURL(r) + "/api/calendar/" + token + ".ics",
+	})
+}
+
+func (s *Server) AdminRevokeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		log.Warn("admin calendar feed revoke: missing id")
+		transport.WriteError(w, http.StatusBadRequest, "missing id", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !s.guardOutrankedUser(ctx, w, r, id, "admin calendar feed revoke") {
+		return
+	}
+
+	update := bson.M{
+		"$unset": bson.M{"calendarFeedTokenHash": ""},
+		"$set":   bson.M{"updatedAt": time.Now().In(s.Cfg.Timezone)},
+	}
+	res, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		log.Error("admin calendar feed revoke: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.MatchedCount == 0 {
+		log.Warn("admin calendar feed revoke: not found", slog.String("user_id", id))
+		transport.WriteError(w, http.StatusNotFound, "user not found", nil)
+		return
+	}
+
+	log.Info("admin calendar feed revoke: ok", slog.String("user_id", id))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
+
+// GetCalendarFeed serves the appointments of an admin's feed token as an
+// iCalendar document. Optional filters: service and status, both comma-separated.
+// Canceled appointments are left out unless asked for through status.
+func (s *Server) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	token := strings.TrimSpace(chi.URLParam(r, "token"))
+	if token == "" {
+		transport.WriteError(w, http.StatusNotFound, "feed not found", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var user models.User
+	filter := bson.M{"calendarFeedTokenHash": auth.HashToken(token), "disabledAt": bson.M{"$exists": false}}
+	if err := s.Cols.Users.FindOne(ctx, filter).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("calendar feed: unknown token")
+			transport.WriteError(w, http.StatusNotFound, "feed not found", nil)
+			return
+		}
+		log.Error("calendar feed: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	permissions, _, err := s.RolePermissions(ctx, user.Role)
+	if err != nil {
+		log.Error("calendar feed: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if !rbac.Allows(permissions, rbac.AppointmentsRead) {
+		log.Warn("calendar feed: role cannot read appointments", slog.String("user_id", user.ID))
+		transport.WriteError(w, http.StatusNotFound, "feed not found", nil)
+		return
+	}
+
+	now := time.Now().In(s.Cfg.Timezone)
+	apptFilter := bson.M{
+		"date": bson.M{
+			"$gte": now.AddDate(0, 0, -calendarFeedPastDays).Format("2006-01-02"),
+			"$lte": now.AddDate(0, 0, calendarFeedFutureDays).Format("2006-01-02"),
+		},
+	}
+	if services := splitQueryList(r.URL.Query().Get("service")); len(services) > 0 {
+		apptFilter["serviceId"] = bson.M{"$in": services}
+	}
+	if statuses := splitQueryList(r.URL.Query().Get("status")); len(statuses) > 0 {
+		apptFilter["status"] = bson.M{"$in": statuses}
+	} else {
+		apptFilter["status"] = bson.M{"$in": models.ActiveAppointmentStatuses}
+	}
+
+	var appointments []models.Appointment
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "time", Value: 1}}).SetLimit(calendarFeedLimit)
+	if err := s.findAll(ctx, s.Cols.Appointments, apptFilter, opts, &appointments); err != nil {
+		log.Error("calendar feed: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	serviceNames, err := s.serviceNames(ctx)
+	if err != nil {
+		log.Error("calendar feed: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	cal := calendar.Calendar{
+		Method:   calendar.MethodPublish,
+		Name:     "GBH - Rendez-vous",
+		Location: s.Cfg.Timezone,
+		Events:   make([]calendar.Event, 0, len(appointments)),
+	}
+	for _, appt := range appointments {
+		service := models.Service{ID: appt.ServiceID, Name: serviceNames[appt.ServiceID]}
+		event, err := notifications.AppointmentCalendarEvent(appt, service, s.Cfg.Timezone)
+		if err != nil {
+			log.Warn("calendar feed: skipped appointment", slog.String("appointment_id", appt.ID), slog.String("error", err.Error()))
+			continue
+		}
+		event.Description += "\n" + appt.Email + " / " + appt.Phone
+		cal.Events = append(cal.Events, event)
+	}
+
+	log.Info("calendar feed: ok", slog.String("user_id", user.ID), slog.Int("events", len(cal.Events)))
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="gbh-rendez-vous.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(cal.Render(now)))
+}
+
+// publicBaseURL prefers PUBLIC_BASE_URL and falls back to the request host.
+func (s *Server) publicBaseURL(r *http.Request) string {
+	if s.Cfg.PublicBaseURL != "" {
+		return s.Cfg.PublicBaseURL
+	}
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func splitQueryList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return normalizeStringList(strings.Split(value, ","))
+}
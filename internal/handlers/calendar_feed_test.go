@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/calendar"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/rbac"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getCalendarFeed(s *Server, token, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/calendar/"+token+".ics?"+query, nil)
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("token", token)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	s.GetCalendarFeed(rec, req)
+	return rec
+}
+
+func createCalendarFeed(t *testing.T, s *Server, userID string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.AdminCreateCalendarFeed(rec, adminRequest(http.MethodPost, "/api/admin/users/"+userID+"/calendar-feed", userID, nil, rbac.Wildcard))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create feed for %s: status %d: %s", userID, rec.Code, rec.Body.String())
+	}
+	var resp CalendarFeedResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode feed: %v", err)
+	}
+	if !strings.HasSuffix(resp.URL, "/api/calendar/"+resp.Token+".ics") {
+		t.Fatalf("feed url = %q", resp.URL)
+	}
+	return resp.Token
+}
+
+// feedEvents reports which of ids appear as events of the feed.
+func feedEvents(body string, ids ...string) []string {
+	var found []string
+	for _, id := range ids {
+		if strings.Contains(body, "UID:"+calendar.AppointmentUID(id)) {
+			found = append(found, id)
+		}
+	}
+	return found
+}
+
+func TestCalendarFeed(t *testing.T) {
+	s := newMongoTestServer(t)
+	ctx := context.Background()
+	insertTestUsers(t, s,
+		models.User{ID: "u-desk", Username: "desk", Email: "desk@example.com", Role: rbac.RoleReception},
+		models.User{ID: "u-sales", Username: "seller", Email: "seller@example.com", Role: rbac.RoleSales},
+	)
+	date := nextWeekday(s.Cfg.Timezone)
+	for _, appointment := range []models.Appointment{
+		{ID: "apt-a", ServiceID: "svc-a", Date: date, Time: "09:00", Duration: 45, Status: models.AppointmentStatusBooked},
+		{ID: "apt-b", ServiceID: "svc-b", Date: date, Time: "10:00", Duration: 45, Status: models.AppointmentStatusBooked},
+		{ID: "apt-c", ServiceID: "svc-a", Date: date, Time: "11:00", Duration: 45, Status: models.AppointmentStatusCanceledByAdmin},
+		{ID: "apt-old", ServiceID: "svc-a", Date: time.Now().AddDate(0, 0, -calendarFeedPastDays-5).Format("2006-01-02"), Time: "09:00", Duration: 45, Status: models.AppointmentStatusBooked},
+	} {
+		if _, err := s.Cols.Appointments.InsertOne(ctx, appointment); err != nil {
+			t.Fatalf("insert appointment %s: %v", appointment.ID, err)
+		}
+	}
+	ids := []string{"apt-a", "apt-b", "apt-c", "apt-old"}
+
+	token := createCalendarFeed(t, s, "u-desk")
+	rec := getCalendarFeed(s, token, "")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/calendar") {
+		t.Fatalf("feed: status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if got := feedEvents(rec.Body.String(), ids...); strings.Join(got, ",") != "apt-a,apt-b" {
+		t.Fatalf("default feed events = %v, want apt-a,apt-b", got)
+	}
+	if got := feedEvents(getCalendarFeed(s, token, "service=svc-a").Body.String(), ids...); strings.Join(got, ",") != "apt-a" {
+		t.Fatalf("service filter events = %v, want apt-a", got)
+	}
+	if got := feedEvents(getCalendarFeed(s, token, "status=booked,canceled_by_admin").Body.String(), ids...); strings.Join(got, ",") != "apt-a,apt-b,apt-c" {
+		t.Fatalf("status filter events = %v, want apt-a,apt-b,apt-c", got)
+	}
+	if rec := getCalendarFeed(s, "not-a-token", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown token: status %d, want 404", rec.Code)
+	}
+
+	// Rotating the feed kills the old URL; revoking kills the new one.
+	rotated := createCalendarFeed(t, s, "u-desk")
+	if rec := getCalendarFeed(s, token, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("rotated token: status %d, want 404", rec.Code)
+	}
+	if rec := getCalendarFeed(s, rotated, ""); rec.Code != http.StatusOK {
+		t.Fatalf("new token: status %d, want 200", rec.Code)
+	}
+	disable := bson.M{"$set": bson.M{"disabledAt": time.Now()}}
+	if _, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": "u-desk"}, disable); err != nil {
+		t.Fatalf("disable user: %v", err)
+	}
+	if rec := getCalendarFeed(s, rotated, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("disabled user: status %d, want 404", rec.Code)
+	}
+	if _, err := s.Cols.Users.UpdateOne(ctx, bson.M{"_id": "u-desk"}, bson.M{"$unset": bson.M{"disabledAt": ""}}); err != nil {
+		t.Fatalf("enable user: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	s.AdminRevokeCalendarFeed(rec, adminRequest(http.MethodDelete, "/api/admin/users/u-desk/calendar-feed", "u-desk", nil, rbac.Wildcard))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d, want 200", rec.Code)
+	}
+	if rec := getCalendarFeed(s, rotated, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("revoked token: status %d, want 404", rec.Code)
+	}
+
+	// A role without appointments:read gets no feed.
+	if rec := getCalendarFeed(s, createCalendarFeed(t, s, "u-sales"), ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("sales feed: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/notifications"
+	"gbh-backend/internal/rfp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const digestContactsLimit = 50
+
+// SendDailyAdminDigest emails the daily summary to admins in digest mode once
+// the configured hour has passed. Each day is claimed in the admin_digests
+// collection first, so only one instance sends it.
+func (s *Server) SendDailyAdminDigest(ctx context.Context, now time.Time) {
+	if s == nil || s.Cols == nil || s.Cols.AdminDigests == nil {
+		return
+	}
+	if s.Mailer == nil {
+		return
+	}
+
+	now = now.In(s.Cfg.Timezone)
+	if now.Hour() < s.Cfg.AdminDigestHour {
+		return
+	}
+
+	emails, err := s.digestAdminEmails(ctx)
+	if err != nil {
+		s.Log.Warn("admin digest: failed to list admins", slog.String("error", err.Error()))
+		return
+	}
+	if len(emails) == 0 {
+		return
+	}
+
+	today := now.Format("2006-01-02")
+	if _, err := s.Cols.AdminDigests.InsertOne(ctx, bson.M{"_id": today, "createdAt": now}); err != nil {
+		if !mongo.IsDuplicateKeyError(err) {
+			s.Log.Warn("admin digest: claim failed", slog.String("date", today), slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	digest, err := s.buildAdminDigest(ctx, now)
+	if err != nil {
+		s.Log.Warn("admin digest: build failed", slog.String("error", err.Error()))
+		// Release the claim so the next tick retries.
+		_, _ = s.Cols.AdminDigests.DeleteOne(ctx, bson.M{"_id": today})
+		return
+	}
+
+	if digest.IsEmpty() {
+		s.Log.Info("admin digest: nothing to send", slog.String("date", today))
+		return
+	}
+
+	htmlBody, err := notifications.BuildAdminDigestHTML(digest)
+	if err != nil {
+		s.Log.Warn("admin digest: render failed", slog.String("error", err.Error()))
+		return
+	}
+
+	subject := fmt.Sprintf("Résumé quotidien du %s", today)
+	for _, email := range emails {
+		if _, err := s.Mailer.SendEmail(ctx, email, "Admin", subject, htmlBody); err != nil {
+			s.Log.Warn("admin digest: send failed", slog.String("email", email), slog.String("error", err.Error()))
+		}
+	}
+	s.Log.Info("admin digest: sent", slog.String("date", today), slog.Int("recipients", len(emails)))
+}
+
+func (s *Server) buildAdminDigest(ctx context.Context, now time.Time) (notifications.AdminDigest, error) {
+	since := now.Add(-24 * time.Hour)
+	digest := notifications.AdminDigest{
+		Date:             now.Format("2006-01-02"),
+		AppointmentsDate: now.AddDate(0, 0, 1).Format("2006-01-02"),
+	}
+
+	serviceNames, err := s.serviceNames(ctx)
+	if err != nil {
+		return digest, err
+	}
+
+	// Tomorrow's appointments, grouped by service.
+	apptFilter := bson.M{
+		"date":   digest.AppointmentsDate,
+		"status": bson.M{"$in": models.ActiveAppointmentStatuses},
+	}
+	var appointments []models.Appointment
+	if err := s.findAll(ctx, s.Cols.Appointments, apptFilter, options.Find().SetSort(bson.D{{Key: "time", Value: 1}}), &appointments); err != nil {
+		return digest, err
+	}
+	groups := make(map[string]*notifications.AdminDigestServiceGroup)
+	for _, appt := range appointments {
+		name := serviceNames[appt.ServiceID]
+		if name == "" {
+			name = appt.ServiceID
+		}
+		group, ok := groups[name]
+		if !ok {
+			group = &notifications.AdminDigestServiceGroup{ServiceName: name}
+			groups[name] = group
+		}
+		group.Appointments = append(group.Appointments, notifications.AdminDigestAppointment{
+			ID:        appt.ID,
+			Time:      appt.Time,
+			Name:      appt.Name,
+			TypeLabel: notifications.AppointmentTypeLabel(appt.Type),
+		})
+	}
+	for _, group := range groups {
+		digest.AppointmentGroups = append(digest.AppointmentGroups, *group)
+	}
+	sort.Slice(digest.AppointmentGroups, func(i, j int) bool {
+		return digest.AppointmentGroups[i].ServiceName < digest.AppointmentGroups[j].ServiceName
+	})
+
+	// RFP leads received in the last 24 hours, grouped by status.
+	var leads []rfp.Lead
+	leadFilter := bson.M{"created_at": bson.M{"$gte": since}}
+	if err := s.findAll(ctx, s.Cols.RFPLeads, leadFilter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}), &leads); err != nil {
+		return digest, err
+	}
+	leadGroups := make(map[string]*notifications.AdminDigestLeadGroup)
+	for _, lead := range leads {
+		group, ok := leadGroups[lead.Status]
+		if !ok {
+			group = &notifications.AdminDigestLeadGroup{Status: lead.Status}
+			leadGroups[lead.Status] = group
+		}
+		group.Leads = append(group.Leads, notifications.AdminDigestLead{
+			ID:           lead.ID,
+			Organization: lead.Organization,
+			ContactName:  lead.ContactName,
+		})
+	}
+	for _, group := range leadGroups {
+		digest.LeadGroups = append(digest.LeadGroups, *group)
+	}
+	sort.Slice(digest.LeadGroups, func(i, j int) bool {
+		return digest.LeadGroups[i].Status < digest.LeadGroups[j].Status
+	})
+
+	// Contact messages nobody has answered yet, oldest first.
+	var contacts []models.ContactMessage
+	contactOpts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(digestContactsLimit)
+	if err := s.findAll(ctx, s.Cols.ContactMessages, bson.M{"answeredAt": bson.M{"$exists": false}}, contactOpts, &contacts); err != nil {
+		return digest, err
+	}
+	for _, contact := range contacts {
+		digest.UnansweredContacts = append(digest.UnansweredContacts, notifications.AdminDigestContact{
+			Name:       contact.Name,
+			Email:      contact.Email,
+			Subject:    contact.Subject,
+			ReceivedAt: contact.CreatedAt.In(s.Cfg.Timezone).Format("2006-01-02 15:04"),
+		})
+	}
+
+	// Testimonials left in the last 24 hours.
+	var testimonials []models.ServiceTestimonial
+	testimonialFilter := bson.M{"createdAt": bson.M{"$gte": since}}
+	if err := s.findAll(ctx, s.Cols.ServiceTestimonials, testimonialFilter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}), &testimonials); err != nil {
+		return digest, err
+	}
+	for _, testimonial := range testimonials {
+		name := serviceNames[testimonial.ServiceID]
+		if name == "" {
+			name = testimonial.ServiceID
+		}
+		digest.Testimonials = append(digest.Testimonials, notifications.AdminDigestTestimonial{
+			ServiceName: name,
+			Name:        testimonial.Name,
+			Rating:      testimonial.Rating,
+			Message:     testimonial.Message,
+		})
+	}
+
+	return digest, nil
+}
+
+func (s *Server) serviceNames(ctx context.Context) (map[string]string, error) {
+	var services []models.Service
+	if err := s.findAll(ctx, s.Cols.Services, bson.M{}, options.Find().SetProjection(bson.M{"name": 1}), &services); err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(services))
+	for _, service := range services {
+		names[service.ID] = service.Name
+	}
+	return names, nil
+}
+
+func (s *Server) findAll(ctx context.Context, col *mongo.Collection, filter bson.M, opts *options.FindOptions, out interface{}) error {
+	cursor, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, out)
+}
@@ -0,0 +1,869 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/export"
+	"gbh-backend/internal/httpx"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/notifications"
+	"gbh-backend/internal/schedule"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RegisterGroupSessionRequest books seats of a session. When the session is
+// full, JoinWaitlist queues the registration instead of refusing it.
+type RegisterGroupSessionRequest struct {
+	Name          string `json:"name" validate:"required"`
+	Email         string `json:"email" validate:"required,email"`
+	Phone         string `json:"phone" validate:"required,phone"`
+	Seats         int    `json:"seats" validate:"omitempty,gte=1,lte=10"`
+	PaymentMethod string `json:"paymentMethod" validate:"required,oneof=online place"`
+	JoinWaitlist  bool   `json:"joinWaitlist"`
+}
+
+type CancelGroupSessionRegistrationRequest struct {
+	ID    string `json:"id" validate:"required"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+// AdminCreateGroupSessionRequest schedules a session. As for appointments,
+// OverrideAvailability skips opening hours and overlap checks.
+type AdminCreateGroupSessionRequest struct {
+	ServiceID            string `json:"serviceId" validate:"required"`
+	Title                string `json:"title" validate:"required,max=200"`
+	Description          string `json:"description" validate:"max=5000"`
+	Date                 string `json:"date" validate:"required,date"`
+	Time                 string `json:"time" validate:"required,clock"`
+	Duration             int    `json:"duration" validate:"required,gte=15,lte=720,minutes15"`
+	Location             string `json:"location" validate:"required,max=300"`
+	Capacity             int    `json:"capacity" validate:"required,gte=1,lte=500"`
+	PricePerSeat         int    `json:"pricePerSeat" validate:"gte=0"`
+	OverrideAvailability bool   `json:"overrideAvailability"`
+}
+
+// AdminUpdateGroupSessionRequest edits a scheduled session; omitted fields
+// are kept. The capacity cannot go below the seats already taken.
+type AdminUpdateGroupSessionRequest struct {
+	Title                *string `json:"title,omitempty" validate:"omitempty,min=1,max=200"`
+	Description          *string `json:"description,omitempty" validate:"omitempty,max=5000"`
+	Date                 *string `json:"date,omitempty" validate:"omitempty,date"`
+	Time                 *string `json:"time,omitempty" validate:"omitempty,clock"`
+	Duration             *int    `json:"duration,omitempty" validate:"omitempty,gte=15,lte=720,minutes15"`
+	Location             *string `json:"location,omitempty" validate:"omitempty,min=1,max=300"`
+	Capacity             *int    `json:"capacity,omitempty" validate:"omitempty,gte=1,lte=500"`
+	PricePerSeat         *int    `json:"pricePerSeat,omitempty" validate:"omitempty,gte=0"`
+	OverrideAvailability bool    `json:"overrideAvailability"`
+}
+
+type AdminCancelGroupSessionRequest struct {
+	Reason string `json:"reason,omitempty" validate:"omitempty,max=500"`
+}
+
+// groupSessionView adds the seats still open to a session.
+type groupSessionView struct {
+	models.GroupSession `bson:",inline"`
+	SeatsLeft           int `json:"seatsLeft"`
+}
+
+func viewGroupSession(session models.GroupSession) groupSessionView {
+	return groupSessionView{GroupSession: session, SeatsLeft: session.SeatsLeft()}
+}
+
+var activeRegistrationStatuses = []string{models.RegistrationStatusConfirmed, models.RegistrationStatusWaitlisted}
+
+// ListGroupSessions lists the upcoming scheduled sessions.
+func (s *Server) ListGroupSessions(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	q := r.URL.Query()
+	limit, offset, err := httpx.ParseLimitOffset(q, 50, 200)
+	if err != nil {
+		log.Warn("group sessions list: invalid query", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	today := time.Now().In(s.Cfg.Timezone).Format("2006-01-02")
+	dateFilter := bson.M{"$gte": today}
+	if from := strings.TrimSpace(q.Get("from")); from > today {
+		dateFilter["$gte"] = from
+	}
+	if to := strings.TrimSpace(q.Get("to")); to != "" {
+		dateFilter["$lte"] = to
+	}
+	filter := bson.M{"status": models.GroupSessionStatusScheduled, "date": dateFilter}
+	if services := splitQueryList(q.Get("service")); len(services) > 0 {
+		filter["serviceId"] = bson.M{"$in": services}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	total, err := s.Cols.GroupSessions.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Error("group sessions list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var sessions []models.GroupSession
+	opts := options.Find().
+		SetSort(bson.D{{Key: "date", Value: 1}, {Key: "time", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	if err := s.findAll(ctx, s.Cols.GroupSessions, filter, opts, &sessions); err != nil {
+		log.Error("group sessions list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	items := make([]groupSessionView, 0, len(sessions))
+	for _, session := range sessions {
+		items = append(items, viewGroupSession(session))
+	}
+
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+	})
+}
+
+func (s *Server) GetGroupSession(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var session models.GroupSession
+	if err := s.Cols.GroupSessions.FindOne(ctx, bson.M{"_id": id}).Decode(&session); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "session not found", nil)
+			return
+		}
+		log.Error("group sessions get: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	transport.WriteJSON(w, http.StatusOK, viewGroupSession(session))
+}
+
+// reserveSeats takes seats of a scheduled session without ever going over
+// its capacity. It returns mongo.ErrNoDocuments when they do not fit.
+func (s *Server) reserveSeats(ctx context.Context, sessionID string, seats int) error {
+	filter := bson.M{
+		"_id":    sessionID,
+		"status": models.GroupSessionStatusScheduled,
+		"$expr":  bson.M{"$lte": bson.A{bson.M{"$add": bson.A{"$seatsTaken", seats}}, "$capacity"}},
+	}
+	update := bson.M{
+		"$inc": bson.M{"seatsTaken": seats},
+		"$set": bson.M{"updatedAt": time.Now().In(s.Cfg.Timezone)},
+	}
+	res, err := s.Cols.GroupSessions.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *Server) releaseSeats(ctx context.Context, sessionID string, seats int) error {
+	update := bson.M{
+		"$inc": bson.M{"seatsTaken": -seats},
+		"$set": bson.M{"updatedAt": time.Now().In(s.Cfg.Timezone)},
+	}
+	_, err := s.Cols.GroupSessions.UpdateOne(ctx, bson.M{"_id": sessionID}, update)
+	return err
+}
+
+// RegisterGroupSession books seats of a session, or puts the customer on its
+// waitlist when it is full and they asked for it.
+func (s *Server) RegisterGroupSession(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	var req RegisterGroupSessionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("group sessions register: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("group sessions register: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if req.Seats == 0 {
+		req.Seats = 1
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var session models.GroupSession
+	if err := s.Cols.GroupSessions.FindOne(ctx, bson.M{"_id": id}).Decode(&session); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("group sessions register: not found", slog.String("session_id", id))
+			transport.WriteError(w, http.StatusNotFound, "session not found", nil)
+			return
+		}
+		log.Error("group sessions register: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if session.Status != models.GroupSessionStatusScheduled {
+		transport.WriteError(w, http.StatusConflict, "session canceled", nil)
+		return
+	}
+	now := time.Now().In(s.Cfg.Timezone)
+	if past, err := schedule.IsSlotPast(session.Date, session.Time, s.Cfg.Timezone, now); err == nil && past {
+		transport.WriteError(w, http.StatusConflict, "session already started", nil)
+		return
+	}
+
+	registration := models.SessionRegistration{
+		ID:            primitive.NewObjectID().Hex(),
+		SessionID:     session.ID,
+		Name:          req.Name,
+		Email:         req.Email,
+		Phone:         req.Phone,
+		Seats:         req.Seats,
+		Total:         req.Seats * session.PricePerSeat,
+		PaymentMethod: req.PaymentMethod,
+		Status:        models.RegistrationStatusConfirmed,
+		CreatedAt:     now,
+		ConfirmedAt:   &now,
+	}
+
+	if err := s.reserveSeats(ctx, session.ID, req.Seats); err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Error("group sessions register: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+			return
+		}
+		if !req.JoinWaitlist {
+			log.Warn("group sessions register: not enough seats", slog.String("session_id", session.ID), slog.Int("seats", req.Seats))
+			transport.WriteError(w, http.StatusConflict, "not enough seats", map[string]string{"seatsLeft": strconv.Itoa(session.SeatsLeft())})
+			return
+		}
+		registration.Status = models.RegistrationStatusWaitlisted
+		registration.ConfirmedAt = nil
+	}
+
+	if _, err := s.Cols.SessionRegistrations.InsertOne(ctx, registration); err != nil {
+		if registration.Status == models.RegistrationStatusConfirmed {
+			if releaseErr := s.releaseSeats(ctx, session.ID, registration.Seats); releaseErr != nil {
+				log.Error("group sessions register: seats not released", slog.String("session_id", session.ID), slog.String("error", releaseErr.Error()))
+			}
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			log.Warn("group sessions register: already registered", slog.String("session_id", session.ID))
+			transport.WriteError(w, http.StatusConflict, "already registered", nil)
+			return
+		}
+		log.Error("group sessions register: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if registration.Status == models.RegistrationStatusConfirmed && s.Cache != nil {
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:"+session.Date+":")
+	}
+
+	go s.sendGroupSessionEmail(log, session, registration, "")
+
+	log.Info("group sessions register: ok",
+		slog.String("session_id", session.ID),
+		slog.String("registration_id", registration.ID),
+		slog.String("status", registration.Status),
+	)
+	transport.WriteJSON(w, http.StatusCreated, registration)
+}
+
+// CancelGroupSessionRegistration lets a customer give back their seats or
+// leave the waitlist. Freed seats go to the waitlist.
+func (s *Server) CancelGroupSessionRegistration(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req CancelGroupSessionRegistrationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("group sessions cancel: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.ID = strings.TrimSpace(req.ID)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("group sessions cancel: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var registration models.SessionRegistration
+	filter := bson.M{"_id": req.ID, "email": req.Email, "status": bson.M{"$in": activeRegistrationStatuses}}
+	if err := s.Cols.SessionRegistrations.FindOne(ctx, filter).Decode(&registration); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("group sessions cancel: not found", slog.String("registration_id", req.ID))
+			transport.WriteError(w, http.StatusNotFound, "registration not found", nil)
+			return
+		}
+		log.Error("group sessions cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var session models.GroupSession
+	if err := s.Cols.GroupSessions.FindOne(ctx, bson.M{"_id": registration.SessionID}).Decode(&session); err != nil {
+		log.Error("group sessions cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if past, err := schedule.IsSlotPast(session.Date, session.Time, s.Cfg.Timezone, time.Now()); err == nil && past {
+		transport.WriteError(w, http.StatusConflict, "session already started", nil)
+		return
+	}
+
+	canceled, err := s.cancelRegistration(ctx, registration)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "registration not found", nil)
+			return
+		}
+		log.Error("group sessions cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if registration.Status == models.RegistrationStatusConfirmed {
+		s.promoteGroupSessionWaitlist(ctx, log, session.ID)
+		if s.Cache != nil {
+			_ = s.Cache.DeletePrefix(r.Context(), "availability:"+session.Date+":")
+		}
+	}
+
+	log.Info("group sessions cancel: ok", slog.String("registration_id", registration.ID))
+	transport.WriteJSON(w, http.StatusOK, canceled)
+}
+
+// cancelRegistration cancels an active registration and gives back its seats
+// when it held some.
+func (s *Server) cancelRegistration(ctx context.Context, registration models.SessionRegistration) (models.SessionRegistration, error) {
+	now := time.Now().In(s.Cfg.Timezone)
+	var canceled models.SessionRegistration
+	filter := bson.M{"_id": registration.ID, "status": registration.Status}
+	update := bson.M{"$set": bson.M{"status": models.RegistrationStatusCanceled, "canceledAt": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.SessionRegistrations.FindOneAndUpdate(ctx, filter, update, opts).Decode(&canceled); err != nil {
+		return models.SessionRegistration{}, err
+	}
+	if registration.Status == models.RegistrationStatusConfirmed {
+		if err := s.releaseSeats(ctx, registration.SessionID, registration.Seats); err != nil {
+			return models.SessionRegistration{}, err
+		}
+	}
+	return canceled, nil
+}
+
+// promoteGroupSessionWaitlist confirms waitlisted registrations in arrival
+// order while seats are left. A registration asking for more seats than are
+// open is skipped so smaller ones behind it can still get in.
+func (s *Server) promoteGroupSessionWaitlist(ctx context.Context, log *slog.Logger, sessionID string) {
+	var session models.GroupSession
+	if err := s.Cols.GroupSessions.FindOne(ctx, bson.M{"_id": sessionID}).Decode(&session); err != nil {
+		log.Warn("group sessions waitlist: database error", slog.String("error", err.Error()))
+		return
+	}
+	if session.Status != models.GroupSessionStatusScheduled || session.SeatsLeft() == 0 {
+		return
+	}
+
+	var waiting []models.SessionRegistration
+	filter := bson.M{"sessionId": sessionID, "status": models.RegistrationStatusWaitlisted}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	if err := s.findAll(ctx, s.Cols.SessionRegistrations, filter, opts, &waiting); err != nil {
+		log.Warn("group sessions waitlist: database error", slog.String("error", err.Error()))
+		return
+	}
+	for _, registration := range waiting {
+		if err := s.reserveSeats(ctx, sessionID, registration.Seats); err != nil {
+			if err != mongo.ErrNoDocuments {
+				log.Warn("group sessions waitlist: database error", slog.String("error", err.Error()))
+				return
+			}
+			continue
+		}
+		now := time.Now().In(s.Cfg.Timezone)
+		promote := bson.M{"$set": bson.M{"status": models.RegistrationStatusConfirmed, "confirmedAt": now}}
+		res, err := s.Cols.SessionRegistrations.UpdateOne(ctx, bson.M{"_id": registration.ID, "status": models.RegistrationStatusWaitlisted}, promote)
+		if err != nil || res.MatchedCount == 0 {
+			// Canceled in the meantime: hand the seats back.
+			if releaseErr := s.releaseSeats(ctx, sessionID, registration.Seats); releaseErr != nil {
+				log.Error("group sessions waitlist: seats not released", slog.String("session_id", sessionID), slog.String("error", releaseErr.Error()))
+			}
+			continue
+		}
+		registration.Status = models.RegistrationStatusConfirmed
+		registration.ConfirmedAt = &now
+		go s.sendGroupSessionEmail(log, session, registration, "")
+		log.Info("group sessions waitlist: promoted", slog.String("session_id", sessionID), slog.String("registration_id", registration.ID))
+	}
+}
+
+// sendGroupSessionEmail tells the customer about the current status of their
+// registration.
+func (s *Server) sendGroupSessionEmail(log *slog.Logger, session models.GroupSession, registration models.SessionRegistration, reason string) {
+	if s.Mailer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	body, err := notifications.BuildSessionRegistrationHTML(notifications.SessionRegistrationEmail{
+		Name:           registration.Name,
+		Title:          session.Title,
+		Date:           session.Date,
+		Time:           session.Time,
+		Location:       session.Location,
+		Seats:          registration.Seats,
+		Total:          registration.Total,
+		Status:         registration.Status,
+		Reason:         reason,
+		RegistrationID: registration.ID,
+	})
+	if err != nil {
+		log.Warn("group sessions email: template error", slog.String("error", err.Error()))
+		return
+	}
+	var subject string
+	switch registration.Status {
+	case models.RegistrationStatusConfirmed:
+		subject = "Inscription confirmée : " + session.Title
+	case models.RegistrationStatusWaitlisted:
+		subject = "Liste d'attente : " + session.Title
+	default:
+		subject = "Inscription annulée : " + session.Title
+	}
+	if _, err := s.Mailer.SendEmail(ctx, registration.Email, registration.Name, subject, body); err != nil {
+		log.Warn("group sessions email: send failed", slog.String("registration_id", registration.ID), slog.String("error", err.Error()))
+	}
+}
+
+func (s *Server) AdminListGroupSessions(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	q := r.URL.Query()
+	limit, offset, err := httpx.ParseLimitOffset(q, 50, 200)
+	if err != nil {
+		log.Warn("admin group sessions list: invalid query", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	filter := bson.M{}
+	switch status := q.Get("status"); status {
+	case "":
+	case models.GroupSessionStatusScheduled, models.GroupSessionStatusCanceled:
+		filter["status"] = status
+	default:
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"status": "oneof"})
+		return
+	}
+	if services := splitQueryList(q.Get("service")); len(services) > 0 {
+		filter["serviceId"] = bson.M{"$in": services}
+	}
+	dateFilter := bson.M{}
+	if from := strings.TrimSpace(q.Get("from")); from != "" {
+		dateFilter["$gte"] = from
+	}
+	if to := strings.TrimSpace(q.Get("to")); to != "" {
+		dateFilter["$lte"] = to
+	}
+	if len(dateFilter) > 0 {
+		filter["date"] = dateFilter
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	total, err := s.Cols.GroupSessions.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Error("admin group sessions list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var sessions []models.GroupSession
+	opts := options.Find().
+		SetSort(bson.D{{Key: "date", Value: 1}, {Key: "time", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	if err := s.findAll(ctx, s.Cols.GroupSessions, filter, opts, &sessions); err != nil {
+		log.Error("admin group sessions list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	items := make([]groupSessionView, 0, len(sessions))
+	for _, session := range sessions {
+		items = append(items, viewGroupSession(session))
+	}
+
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+	})
+}
+
+func (s *Server) AdminCreateGroupSession(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminCreateGroupSessionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin group sessions create: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	req.Description = strings.TrimSpace(req.Description)
+	req.Location = strings.TrimSpace(req.Location)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin group sessions create: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": req.ServiceID}).Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusBadRequest, "service not found", nil)
+			return
+		}
+		log.Error("admin group sessions create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	code, msg, err := s.checkSlot(ctx, req.Date, req.Time, req.Duration, req.OverrideAvailability, "")
+	if err != nil {
+		log.Error("admin group sessions create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if code != 0 {
+		log.Warn("admin group sessions create: slot refused", slog.String("date", req.Date), slog.String("time", req.Time), slog.String("reason", msg))
+		transport.WriteError(w, code, msg, nil)
+		return
+	}
+
+	now := time.Now().In(s.Cfg.Timezone)
+	session := models.GroupSession{
+		ID:           primitive.NewObjectID().Hex(),
+		ServiceID:    req.ServiceID,
+		Title:        req.Title,
+		Description:  req.Description,
+		Date:         req.Date,
+		Time:         req.Time,
+		Duration:     req.Duration,
+		Location:     req.Location,
+		Capacity:     req.Capacity,
+		PricePerSeat: req.PricePerSeat,
+		Status:       models.GroupSessionStatusScheduled,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if _, err := s.Cols.GroupSessions.InsertOne(ctx, session); err != nil {
+		log.Error("admin group sessions create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if s.Cache != nil {
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:"+session.Date+":")
+	}
+
+	log.Info("admin group sessions create: ok", slog.String("session_id", session.ID), slog.String("date", session.Date))
+	transport.WriteJSON(w, http.StatusCreated, viewGroupSession(session))
+}
+
+func (s *Server) AdminUpdateGroupSession(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	var req AdminUpdateGroupSessionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin group sessions update: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin group sessions update: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var current models.GroupSession
+	if err := s.Cols.GroupSessions.FindOne(ctx, bson.M{"_id": id}).Decode(&current); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "session not found", nil)
+			return
+		}
+		log.Error("admin group sessions update: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if current.Status != models.GroupSessionStatusScheduled {
+		transport.WriteError(w, http.StatusConflict, "session canceled", nil)
+		return
+	}
+
+	updated := current
+	set := bson.M{}
+	if req.Title != nil {
+		updated.Title = strings.TrimSpace(*req.Title)
+		set["title"] = updated.Title
+	}
+	if req.Description != nil {
+		updated.Description = strings.TrimSpace(*req.Description)
+		set["description"] = updated.Description
+	}
+	if req.Location != nil {
+		updated.Location = strings.TrimSpace(*req.Location)
+		set["location"] = updated.Location
+	}
+	if req.PricePerSeat != nil {
+		updated.PricePerSeat = *req.PricePerSeat
+		set["pricePerSeat"] = updated.PricePerSeat
+	}
+	if req.Date != nil {
+		updated.Date = *req.Date
+	}
+	if req.Time != nil {
+		updated.Time = *req.Time
+	}
+	if req.Duration != nil {
+		updated.Duration = *req.Duration
+	}
+	moved := updated.Date != current.Date || updated.Time != current.Time || updated.Duration != current.Duration
+	if moved {
+		code, msg, err := s.checkSlot(ctx, updated.Date, updated.Time, updated.Duration, req.OverrideAvailability, current.ID)
+		if err != nil {
+			log.Error("admin group sessions update: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+			return
+		}
+		if code != 0 {
+			log.Warn("admin group sessions update: slot refused", slog.String("session_id", id), slog.String("reason", msg))
+			transport.WriteError(w, code, msg, nil)
+			return
+		}
+		set["date"] = updated.Date
+		set["time"] = updated.Time
+		set["duration"] = updated.Duration
+	}
+
+	filter := bson.M{"_id": id, "status": models.GroupSessionStatusScheduled}
+	if req.Capacity != nil {
+		// Checked in the update so a registration racing with it cannot
+		// leave more seats taken than the new capacity.
+		filter["seatsTaken"] = bson.M{"$lte": *req.Capacity}
+		set["capacity"] = *req.Capacity
+	}
+	if len(set) == 0 {
+		transport.WriteJSON(w, http.StatusOK, viewGroupSession(current))
+		return
+	}
+	set["updatedAt"] = time.Now().In(s.Cfg.Timezone)
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.GroupSessions.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&updated); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin group sessions update: capacity below seats taken", slog.String("session_id", id))
+			transport.WriteError(w, http.StatusConflict, "capacity below seats taken", map[string]string{"seatsTaken": strconv.Itoa(current.SeatsTaken)})
+			return
+		}
+		log.Error("admin group sessions update: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	if moved && s.Cache != nil {
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:"+current.Date+":")
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:"+updated.Date+":")
+	}
+	if moved {
+		s.offerFreedSlotsAsync(current.Date, "")
+	}
+	if updated.Capacity > current.Capacity {
+		s.promoteGroupSessionWaitlist(ctx, log, updated.ID)
+	}
+
+	log.Info("admin group sessions update: ok", slog.String("session_id", id))
+	transport.WriteJSON(w, http.StatusOK, viewGroupSession(updated))
+}
+
+// AdminCancelGroupSession cancels a session and every registration to it,
+// and emails the attendees.
+func (s *Server) AdminCancelGroupSession(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	var req AdminCancelGroupSessionRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			log.Warn("admin group sessions cancel: invalid json")
+			transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+			return
+		}
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("admin group sessions cancel: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	now := time.Now().In(s.Cfg.Timezone)
+	var session models.GroupSession
+	filter := bson.M{"_id": id, "status": models.GroupSessionStatusScheduled}
+	update := bson.M{"$set": bson.M{"status": models.GroupSessionStatusCanceled, "updatedAt": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.GroupSessions.FindOneAndUpdate(ctx, filter, update, opts).Decode(&session); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin group sessions cancel: not found", slog.String("session_id", id))
+			transport.WriteError(w, http.StatusNotFound, "session not found", nil)
+			return
+		}
+		log.Error("admin group sessions cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	var registrations []models.SessionRegistration
+	active := bson.M{"sessionId": id, "status": bson.M{"$in": activeRegistrationStatuses}}
+	if err := s.findAll(ctx, s.Cols.SessionRegistrations, active, nil, &registrations); err != nil {
+		log.Error("admin group sessions cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	cancelAll := bson.M{"$set": bson.M{"status": models.RegistrationStatusCanceled, "canceledAt": now}}
+	if _, err := s.Cols.SessionRegistrations.UpdateMany(ctx, active, cancelAll); err != nil {
+		log.Error("admin group sessions cancel: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	if s.Cache != nil {
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:"+session.Date+":")
+	}
+	s.offerFreedSlotsAsync(session.Date, "")
+	go func(registrations []models.SessionRegistration) {
+		for _, registration := range registrations {
+			registration.Status = models.RegistrationStatusCanceled
+			s.sendGroupSessionEmail(log, session, registration, req.Reason)
+		}
+	}(registrations)
+
+	log.Info("admin group sessions cancel: ok", slog.String("session_id", id), slog.Int("registrations", len(registrations)))
+	transport.WriteJSON(w, http.StatusOK, viewGroupSession(session))
+}
+
+var attendeeExportHeader = []string{
+	"id", "nom", "email", "telephone", "places", "total", "paiement", "statut", "inscrit_le",
+}
+
+// AdminListGroupSessionAttendees lists the registrations of a session,
+// confirmed first then the waitlist in order. format=csv|xlsx downloads the
+// list instead.
+func (s *Server) AdminListGroupSessionAttendees(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	q := r.URL.Query()
+
+	filter := bson.M{"sessionId": id}
+	switch status := q.Get("status"); status {
+	case "":
+		filter["status"] = bson.M{"$in": activeRegistrationStatuses}
+	case models.RegistrationStatusConfirmed, models.RegistrationStatusWaitlisted, models.RegistrationStatusCanceled:
+		filter["status"] = status
+	case "all":
+	default:
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"status": "oneof"})
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(q.Get("format")))
+	if format != "" && format != export.FormatCSV && format != export.FormatXLSX {
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"format": "oneof"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var session models.GroupSession
+	if err := s.Cols.GroupSessions.FindOne(ctx, bson.M{"_id": id}).Decode(&session); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "session not found", nil)
+			return
+		}
+		log.Error("admin group sessions attendees: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var registrations []models.SessionRegistration
+	// "confirmed" sorts before "waitlisted".
+	opts := options.Find().SetSort(bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}})
+	if err := s.findAll(ctx, s.Cols.SessionRegistrations, filter, opts, &registrations); err != nil {
+		log.Error("admin group sessions attendees: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if registrations == nil {
+		registrations = []models.SessionRegistration{}
+	}
+
+	if format == "" {
+		transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"session": viewGroupSession(session),
+			"items":   registrations,
+		})
+		return
+	}
+
+	table := export.Table{Header: attendeeExportHeader, Rows: make([][]interface{}, 0, len(registrations))}
+	for _, a := range registrations {
+		table.Rows = append(table.Rows, []interface{}{
+			a.ID, a.Name, a.Email, a.Phone, a.Seats, a.Total, a.PaymentMethod, a.Status, a.CreatedAt.In(s.Cfg.Timezone),
+		})
+	}
+	filename := fmt.Sprintf("participants-%s-%s.%s", session.Date, session.ID, format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	if err := export.Write(w, format, table); err != nil {
+		log.Error("admin group sessions attendees: write failed", slog.String("error", err.Error()))
+		return
+	}
+	log.Info("admin group sessions attendees: exported", slog.String("session_id", id), slog.Int("count", len(registrations)))
+}
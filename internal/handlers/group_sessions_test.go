@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/models"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func insertGroupSession(t *testing.T, s *Server, id string, capacity int) models.GroupSession {
+	t.Helper()
+	now := time.Now()
+	session := models.GroupSession{
+		ID:        id,
+		ServiceID: "svc-test",
+		Title:     "Atelier CV",
+		Date:      nextWeekday(s.Cfg.Timezone),
+		Time:      "14:00",
+		Duration:  120,
+		Location:  "Salle 1",
+		Capacity:  capacity,
+		Status:    models.GroupSessionStatusScheduled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := s.Cols.GroupSessions.InsertOne(context.Background(), session); err != nil {
+		t.Fatalf("insert session: %v", err)
+	}
+	return session
+}
+
+func findGroupSession(t *testing.T, s *Server, id string) models.GroupSession {
+	t.Helper()
+	var session models.GroupSession
+	if err := s.Cols.GroupSessions.FindOne(context.Background(), bson.M{"_id": id}).Decode(&session); err != nil {
+		t.Fatalf("find session %s: %v", id, err)
+	}
+	return session
+}
+
+func registerGroupSession(s *Server, sessionID, email string, seats int, waitlist bool) (int, models.SessionRegistration) {
+	body, _ := json.Marshal(RegisterGroupSessionRequest{
+		Name:          "Jean Test",
+		Email:         email,
+		Phone:         "+243810000000",
+		Seats:         seats,
+		PaymentMethod: models.PaymentPlace,
+		JoinWaitlist:  waitlist,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/group-sessions/"+sessionID+"/register", bytes.NewReader(body))
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", sessionID)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	s.RegisterGroupSession(rec, req)
+	var registration models.SessionRegistration
+	_ = json.Unmarshal(rec.Body.Bytes(), &registration)
+	return rec.Code, registration
+}
+
+func TestReserveSeatsNeverOverbooks(t *testing.T) {
+	s := newMongoTestServer(t)
+	session := insertGroupSession(t, s, "gs-race", 5)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.reserveSeats(context.Background(), session.ID, 1)
+			if err != nil && err != mongo.ErrNoDocuments {
+				t.Errorf("reserveSeats() error = %v", err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if reserved != 5 {
+		t.Fatalf("reserved %d seats, want 5", reserved)
+	}
+	if got := findGroupSession(t, s, session.ID); got.SeatsTaken != 5 {
+		t.Fatalf("seatsTaken = %d, want 5", got.SeatsTaken)
+	}
+	if err := s.reserveSeats(context.Background(), session.ID, 1); err != mongo.ErrNoDocuments {
+		t.Fatalf("reserveSeats() on a full session: error = %v, want ErrNoDocuments", err)
+	}
+}
+
+func TestGroupSessionWaitlistPromotion(t *testing.T) {
+	s := newMongoTestServer(t)
+	session := insertGroupSession(t, s, "gs-waitlist", 3)
+
+	code, first := registerGroupSession(s, session.ID, "first@example.com", 2, false)
+	if code != http.StatusCreated || first.Status != models.RegistrationStatusConfirmed {
+		t.Fatalf("first: status %d, %+v", code, first)
+	}
+	if code, _ := registerGroupSession(s, session.ID, "large@example.com", 3, false); code != http.StatusConflict {
+		t.Fatalf("too many seats without waitlist: status %d, want 409", code)
+	}
+	if code, _ := registerGroupSession(s, session.ID, "second@example.com", 1, false); code != http.StatusCreated {
+		t.Fatalf("last seat: status %d, want 201", code)
+	}
+	// Registrations queue by creation time.
+	time.Sleep(5 * time.Millisecond)
+	code, large := registerGroupSession(s, session.ID, "large@example.com", 3, true)
+	if code != http.StatusCreated || large.Status != models.RegistrationStatusWaitlisted {
+		t.Fatalf("large on waitlist: status %d, %+v", code, large)
+	}
+	time.Sleep(5 * time.Millisecond)
+	code, small := registerGroupSession(s, session.ID, "small@example.com", 1, true)
+	if code != http.StatusCreated || small.Status != models.RegistrationStatusWaitlisted {
+		t.Fatalf("small on waitlist: status %d, %+v", code, small)
+	}
+
+	// Two seats free up: the three-seat registration is skipped, the
+	// one-seat one behind it gets in.
+	body, _ := json.Marshal(CancelGroupSessionRegistrationRequest{ID: first.ID, Email: "first@example.com"})
+	rec := httptest.NewRecorder()
+	s.CancelGroupSessionRegistration(rec, httptest.NewRequest(http.MethodPost, "/api/group-sessions/cancel", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	status := func(id string) string {
+		var registration models.SessionRegistration
+		if err := s.Cols.SessionRegistrations.FindOne(context.Background(), bson.M{"_id": id}).Decode(&registration); err != nil {
+			t.Fatalf("find registration %s: %v", id, err)
+		}
+		return registration.Status
+	}
+	if got := status(large.ID); got != models.RegistrationStatusWaitlisted {
+		t.Fatalf("large registration status = %q, want waitlisted", got)
+	}
+	if got := status(small.ID); got != models.RegistrationStatusConfirmed {
+		t.Fatalf("small registration status = %q, want confirmed", got)
+	}
+	if got := findGroupSession(t, s, session.ID); got.SeatsTaken != 2 {
+		t.Fatalf("seatsTaken = %d, want 2", got.SeatsTaken)
+	}
+}
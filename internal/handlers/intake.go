@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/intake"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// intakeFileTTL is how long an upload waits for its booking.
+const intakeFileTTL = 24 * time.Hour
+
+type AdminServiceIntakeRequest struct {
+	Questions []models.IntakeQuestion `json:"questions"`
+}
+
+// AdminUpdateServiceIntake replaces the questionnaire of a service. Answers
+// already stored on appointments keep their own copy of the labels.
+func (s *Server) AdminUpdateServiceIntake(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	var req AdminServiceIntakeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin services intake: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	questions := intake.Normalize(req.Questions)
+	if details := intake.ValidateSchema(questions); details != nil {
+		log.Warn("admin services intake: validation error")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	res, err := s.Cols.Services.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"intake": questions}})
+	if err != nil {
+		log.Error("admin services intake: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.MatchedCount == 0 {
+		log.Warn("admin services intake: not found", slog.String("service_id", id))
+		transport.WriteError(w, http.StatusNotFound, "service not found", nil)
+		return
+	}
+	if s.Cache != nil {
+		_ = s.Cache.Delete(r.Context(), "services:all")
+	}
+
+	log.Info("admin services intake: ok", slog.String("service_id", id), slog.Int("questions", len(questions)))
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{"questions": questions})
+}
+
+// UploadIntakeFile stores a document for a file question before booking.
+// The multipart form carries serviceId, questionId and file; the returned
+// token is the answer to that question.
+func (s *Server) UploadIntakeFile(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	r.Body = http.MaxBytesReader(w, r.Body, intake.MaxFileBytes+64<<10)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			transport.WriteError(w, http.StatusRequestEntityTooLarge, "file too large", map[string]string{"max": strconv.Itoa(intake.MaxFileBytes)})
+			return
+		}
+		log.Warn("intake upload: invalid form", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, "invalid form", nil)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	serviceID := strings.TrimSpace(r.FormValue("serviceId"))
+	questionID := strings.TrimSpace(r.FormValue("questionId"))
+	file, header, err := r.FormFile("file")
+	if serviceID == "" || questionID == "" || err != nil {
+		details := map[string]string{}
+		if serviceID == "" {
+			details["serviceId"] = "required"
+		}
+		if questionID == "" {
+			details["questionId"] = "required"
+		}
+		if err != nil {
+			details["file"] = "required"
+		}
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, intake.MaxFileBytes+1))
+	if err != nil {
+		log.Warn("intake upload: read failed", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, "invalid form", nil)
+		return
+	}
+	if len(data) > intake.MaxFileBytes {
+		transport.WriteError(w, http.StatusRequestEntityTooLarge, "file too large", map[string]string{"max": strconv.Itoa(intake.MaxFileBytes)})
+		return
+	}
+	if len(data) == 0 {
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"file": "required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": serviceID}).Decode(&service); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusBadRequest, "service not found", nil)
+			return
+		}
+		log.Error("intake upload: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	question, ok := intake.Find(service.Intake, questionID)
+	if !ok || question.Type != models.IntakeTypeFile {
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"questionId": "oneof"})
+		return
+	}
+	// Trust the content rather than the name or header sent by the client.
+	contentType := sniffContentType(data, header.Filename)
+	if !intake.Accepts(question, contentType) {
+		log.Warn("intake upload: type refused", slog.String("question_id", questionID), slog.String("content_type", contentType))
+		transport.WriteError(w, http.StatusUnsupportedMediaType, "file type not accepted", map[string]string{"accept": strings.Join(question.Accept, ",")})
+		return
+	}
+
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		log.Error("intake upload: token error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "token error", nil)
+		return
+	}
+	now := time.Now().In(s.Cfg.Timezone)
+	expiresAt := now.Add(intakeFileTTL)
+	doc := models.IntakeFile{
+		ID:          primitive.NewObjectID().Hex(),
+		TokenHash:   hash,
+		ServiceID:   serviceID,
+		QuestionID:  questionID,
+		Name:        filepath.Base(strings.ReplaceAll(header.Filename, `\`, "/")),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Data:        data,
+		CreatedAt:   now,
+		ExpiresAt:   &expiresAt,
+	}
+	if _, err := s.Cols.IntakeFiles.InsertOne(ctx, doc); err != nil {
+		log.Error("intake upload: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("intake upload: ok", slog.String("service_id", serviceID), slog.String("question_id", questionID), slog.Int64("size", doc.Size))
+	transport.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":       token,
+		"name":        doc.Name,
+		"contentType": doc.ContentType,
+		"size":        doc.Size,
+		"expiresAt":   expiresAt,
+	})
+}
+
+// sniffContentType detects the type of data. Office documents are zip files
+// to the sniffer, so their extension settles it.
+func sniffContentType(data []byte, filename string) string {
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if detected == "application/zip" || detected == "application/octet-stream" {
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".docx":
+			return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		case ".xlsx":
+			return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		case ".pptx":
+			return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+		}
+	}
+	return detected
+}
+
+// resolveIntake validates the answers given when booking service. It
+// returns validation details when they do not fit the questionnaire.
+func (s *Server) resolveIntake(ctx context.Context, service models.Service, raw map[string]interface{}, enforceRequired bool) ([]models.IntakeAnswer, map[string]string, error) {
+	answers, files, details := intake.Answers(service.Intake, raw, enforceRequired)
+	if details != nil {
+		return nil, details, nil
+	}
+	for i := range answers {
+		token, ok := files[answers[i].QuestionID]
+		if !ok {
+			continue
+		}
+		var file models.IntakeFile
+		filter := bson.M{
+			"tokenHash":  auth.HashToken(token),
+			"serviceId":  service.ID,
+			"questionId": answers[i].QuestionID,
+			"attachedAt": bson.M{"$exists": false},
+			"expiresAt":  bson.M{"$gt": time.Now()},
+		}
+		if err := s.Cols.IntakeFiles.FindOne(ctx, filter).Decode(&file); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, map[string]string{"intake." + answers[i].QuestionID: "file"}, nil
+			}
+			return nil, nil, err
+		}
+		answers[i].File = &models.IntakeFileRef{
+			ID:          file.ID,
+			Name:        file.Name,
+			ContentType: file.ContentType,
+			Size:        file.Size,
+		}
+	}
+	return answers, nil, nil
+}
+
+// attachIntakeFiles keeps the uploads used by answers from expiring.
+func (s *Server) attachIntakeFiles(ctx context.Context, answers []models.IntakeAnswer) error {
+	var ids []string
+	for _, answer := range answers {
+		if answer.File != nil {
+			ids = append(ids, answer.File.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	update := bson.M{
+		"$set":   bson.M{"attachedAt": time.Now().In(s.Cfg.Timezone)},
+		"$unset": bson.M{"expiresAt": ""},
+	}
+	_, err := s.Cols.IntakeFiles.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, update)
+	return err
+}
+
+// AdminGetIntakeFile downloads a document attached to an appointment.
+func (s *Server) AdminGetIntakeFile(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var file models.IntakeFile
+	if err := s.Cols.IntakeFiles.FindOne(ctx, bson.M{"_id": id}).Decode(&file); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "file not found", nil)
+			return
+		}
+		log.Error("admin intake file: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(int64(len(file.Data)), 10))
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": file.Name})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(file.Data)
+}
@@ -0,0 +1,743 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/schedule"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OpeningHoursRequest struct {
+	Day   int    `json:"day" validate:"gte=0,lte=6"`
+	Start string `json:"start" validate:"required,clock"`
+	End   string `json:"end" validate:"required,clock"`
+}
+
+// LocationRoomRequest describes a room. ID is omitted for a new room and
+// kept for an existing one, so its appointments stay attached to it.
+type LocationRoomRequest struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Capacity int    `json:"capacity" validate:"gte=0,lte=500"`
+}
+
+type AdminLocationRequest struct {
+	Name       string                `json:"name" validate:"required,max=120"`
+	Address    string                `json:"address" validate:"required,max=300"`
+	City       string                `json:"city" validate:"omitempty,max=100"`
+	Directions string                `json:"directions" validate:"omitempty,max=2000"`
+	MapURL     string                `json:"mapUrl" validate:"omitempty,url"`
+	Phone      string                `json:"phone" validate:"omitempty,phone"`
+	Hours      []OpeningHoursRequest `json:"hours" validate:"omitempty,max=21,dive"`
+	Rooms      []LocationRoomRequest `json:"rooms" validate:"required,min=1,max=50,dive"`
+	Active     *bool                 `json:"active,omitempty"`
+}
+
+// slotPlace is where an appointment is booked. A nil Location is the general
+// calendar. RoomID pins a room; with PreferRoom it is only tried first, as
+// when an appointment is moved.
+type slotPlace struct {
+	Location   *models.Location
+	RoomID     string
+	PreferRoom bool
+}
+
+// ListLocations returns the active locations, or those offering a service
+// with ?service=.
+func (s *Server) ListLocations(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"active": true}
+	if serviceID := strings.TrimSpace(r.URL.Query().Get("service")); serviceID != "" {
+		var service models.Service
+		if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": serviceID}).Decode(&service); err != nil {
+			if err == mongo.ErrNoDocuments {
+				transport.WriteError(w, http.StatusNotFound, "service not found", nil)
+				return
+			}
+			log.Error("locations: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+			return
+		}
+		filter["_id"] = bson.M{"$in": service.LocationIDs}
+	}
+
+	locations := []models.Location{}
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	if err := s.findAll(ctx, s.Cols.Locations, filter, opts, &locations); err != nil {
+		log.Error("locations: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("locations: ok", slog.Int("count", len(locations)))
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{"locations": locations})
+}
+
+func (s *Server) GetLocation(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var location models.Location
+	if err := s.Cols.Locations.FindOne(ctx, bson.M{"_id": id, "active": true}).Decode(&location); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "location not found", nil)
+			return
+		}
+		log.Error("locations get: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	transport.WriteJSON(w, http.StatusOK, location)
+}
+
+func (s *Server) AdminListLocations(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	locations := []models.Location{}
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	if err := s.findAll(ctx, s.Cols.Locations, bson.M{}, opts, &locations); err != nil {
+		log.Error("admin locations list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{"locations": locations})
+}
+
+// locationFromRequest validates req and builds the location it describes.
+// Rooms of current keep their ID; other IDs are refused.
+func (s *Server) locationFromRequest(req AdminLocationRequest, current *models.Location) (models.Location, map[string]string) {
+	if err := s.Val.Struct(req); err != nil {
+		return models.Location{}, validationDetails(s.Val.ValidationErrors(err))
+	}
+	details := map[string]string{}
+
+	hours := make([]models.OpeningHours, 0, len(req.Hours))
+	for i, h := range req.Hours {
+		start, _ := schedule.ParseClockToMinutes(h.Start)
+		end, _ := schedule.ParseClockToMinutes(h.End)
+		if end <= start {
+			details[fmt.Sprintf("hours[%d].end", i)] = "gtfield"
+			continue
+		}
+		for j, other := range req.Hours[:i] {
+			otherStart, _ := schedule.ParseClockToMinutes(other.Start)
+			otherEnd, _ := schedule.ParseClockToMinutes(other.End)
+			if other.Day == h.Day && schedule.Overlaps(schedule.Interval{Start: start, End: end}, schedule.Interval{Start: otherStart, End: otherEnd}) {
+				details[fmt.Sprintf("hours[%d]", i)] = fmt.Sprintf("overlaps hours[%d]", j)
+				break
+			}
+		}
+		hours = append(hours, models.OpeningHours{Day: h.Day, Start: h.Start, End: h.End})
+	}
+
+	existing := map[string]bool{}
+	if current != nil {
+		for _, room := range current.Rooms {
+			existing[room.ID] = true
+		}
+	}
+	names := map[string]bool{}
+	seen := map[string]bool{}
+	rooms := make([]models.Room, 0, len(req.Rooms))
+	for i, room := range req.Rooms {
+		name := strings.TrimSpace(room.Name)
+		if names[strings.ToLower(name)] {
+			details[fmt.Sprintf("rooms[%d].name", i)] = "unique"
+		}
+		names[strings.ToLower(name)] = true
+		id := strings.TrimSpace(room.ID)
+		switch {
+		case id == "":
+			id = primitive.NewObjectID().Hex()
+		case !existing[id] || seen[id]:
+			details[fmt.Sprintf("rooms[%d].id", i)] = "oneof"
+		}
+		seen[id] = true
+		rooms = append(rooms, models.Room{ID: id, Name: name, Capacity: room.Capacity})
+	}
+	if len(details) > 0 {
+		return models.Location{}, details
+	}
+
+	location := models.Location{
+		Name:       strings.TrimSpace(req.Name),
+		Address:    strings.TrimSpace(req.Address),
+		City:       strings.TrimSpace(req.City),
+		Directions: strings.TrimSpace(req.Directions),
+		MapURL:     strings.TrimSpace(req.MapURL),
+		Phone:      strings.TrimSpace(req.Phone),
+		Hours:      hours,
+		Rooms:      rooms,
+		Active:     req.Active == nil || *req.Active,
+	}
+	return location, nil
+}
+
+func (s *Server) AdminCreateLocation(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req AdminLocationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin locations create: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	location, details := s.locationFromRequest(req, nil)
+	if details != nil {
+		log.Warn("admin locations create: validation error")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	now := time.Now().In(s.Cfg.Timezone)
+	location.ID = primitive.NewObjectID().Hex()
+	location.CreatedAt = now
+	location.UpdatedAt = now
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.Cols.Locations.InsertOne(ctx, location); err != nil {
+		log.Error("admin locations create: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin locations create: ok", slog.String("location_id", location.ID), slog.Int("rooms", len(location.Rooms)))
+	transport.WriteJSON(w, http.StatusCreated, location)
+}
+
+// AdminUpdateLocation replaces a location. A room with upcoming appointments
+// cannot be removed; upcoming appointments get the new address, directions
+// and room names.
+func (s *Server) AdminUpdateLocation(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	var req AdminLocationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("admin locations update: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var current models.Location
+	if err := s.Cols.Locations.FindOne(ctx, bson.M{"_id": id}).Decode(&current); err != nil {
+		if err == mongo.ErrNoDocuments {
+			transport.WriteError(w, http.StatusNotFound, "location not found", nil)
+			return
+		}
+		log.Error("admin locations update: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	location, details := s.locationFromRequest(req, &current)
+	if details != nil {
+		log.Warn("admin locations update: validation error")
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	kept := map[string]bool{}
+	for _, room := range location.Rooms {
+		kept[room.ID] = true
+	}
+	var removed []string
+	for _, room := range current.Rooms {
+		if !kept[room.ID] {
+			removed = append(removed, room.ID)
+		}
+	}
+	today := time.Now().In(s.Cfg.Timezone).Format("2006-01-02")
+	if len(removed) > 0 {
+		var booked models.Appointment
+		filter := bson.M{
+			"location.roomId": bson.M{"$in": removed},
+			"date":            bson.M{"$gte": today},
+			"status":          bson.M{"$in": models.ActiveAppointmentStatuses},
+		}
+		err := s.Cols.Appointments.FindOne(ctx, filter).Decode(&booked)
+		if err == nil {
+			log.Warn("admin locations update: room in use", slog.String("location_id", id), slog.String("room_id", booked.Location.RoomID))
+			transport.WriteError(w, http.StatusConflict, "room has upcoming appointments", map[string]string{"roomId": booked.Location.RoomID})
+			return
+		}
+		if err != mongo.ErrNoDocuments {
+			log.Error("admin locations update: database error", slog.String("error", err.Error()))
+			transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+			return
+		}
+	}
+
+	location.ID = current.ID
+	location.CreatedAt = current.CreatedAt
+	location.UpdatedAt = time.Now().In(s.Cfg.Timezone)
+	if _, err := s.Cols.Locations.ReplaceOne(ctx, bson.M{"_id": id}, location); err != nil {
+		log.Error("admin locations update: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	for _, room := range location.Rooms {
+		filter := bson.M{"location.roomId": room.ID, "date": bson.M{"$gte": today}}
+		update := bson.M{"$set": bson.M{"location": appointmentLocation(location, room)}}
+		if _, err := s.Cols.Appointments.UpdateMany(ctx, filter, update); err != nil {
+			log.Warn("admin locations update: appointments not refreshed", slog.String("room_id", room.ID), slog.String("error", err.Error()))
+		}
+	}
+	if s.Cache != nil {
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:")
+	}
+
+	log.Info("admin locations update: ok", slog.String("location_id", id))
+	transport.WriteJSON(w, http.StatusOK, location)
+}
+
+// AdminDeleteLocation removes a location without upcoming appointments and
+// unlinks it from its services. Deactivating it keeps its history visible.
+func (s *Server) AdminDeleteLocation(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	today := time.Now().In(s.Cfg.Timezone).Format("2006-01-02")
+	upcoming, err := s.Cols.Appointments.CountDocuments(ctx, bson.M{
+		"location.id": id,
+		"date":        bson.M{"$gte": today},
+		"status":      bson.M{"$in": models.ActiveAppointmentStatuses},
+	})
+	if err != nil {
+		log.Error("admin locations delete: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if upcoming > 0 {
+		log.Warn("admin locations delete: location in use", slog.String("location_id", id), slog.Int64("appointments", upcoming))
+		transport.WriteError(w, http.StatusConflict, "location has upcoming appointments", nil)
+		return
+	}
+
+	res, err := s.Cols.Locations.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		log.Error("admin locations delete: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.DeletedCount == 0 {
+		transport.WriteError(w, http.StatusNotFound, "location not found", nil)
+		return
+	}
+	if _, err := s.Cols.Services.UpdateMany(ctx, bson.M{"locationIds": id}, bson.M{"$pull": bson.M{"locationIds": id}}); err != nil {
+		log.Warn("admin locations delete: services not unlinked", slog.String("location_id", id), slog.String("error", err.Error()))
+	}
+	if s.Cache != nil {
+		_ = s.Cache.Delete(r.Context(), "services:all")
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:")
+	}
+
+	log.Info("admin locations delete: ok", slog.String("location_id", id))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// checkLocationIDs reports the IDs of ids that are not locations.
+func (s *Server) checkLocationIDs(ctx context.Context, ids []string) (map[string]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var found []models.Location
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	if err := s.findAll(ctx, s.Cols.Locations, bson.M{"_id": bson.M{"$in": ids}}, opts, &found); err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(found))
+	for _, location := range found {
+		known[location.ID] = true
+	}
+	for i, id := range ids {
+		if !known[id] {
+			return map[string]string{fmt.Sprintf("locationIds[%d]", i): "oneof"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// resolvePlace checks where a new appointment of service should be held.
+// Presentiel appointments of a service offered at locations need one of
+// them; it may be left out when there is only one.
+func (s *Server) resolvePlace(ctx context.Context, service models.Service, consultationType, locationID, roomID string) (slotPlace, map[string]string, error) {
+	locationID = strings.TrimSpace(locationID)
+	roomID = strings.TrimSpace(roomID)
+	if consultationType != models.ConsultationPresentiel || len(service.LocationIDs) == 0 {
+		if locationID != "" {
+			return slotPlace{}, map[string]string{"locationId": "excluded"}, nil
+		}
+		if roomID != "" {
+			return slotPlace{}, map[string]string{"roomId": "excluded"}, nil
+		}
+		return slotPlace{}, nil, nil
+	}
+	if locationID == "" {
+		if len(service.LocationIDs) > 1 {
+			return slotPlace{}, map[string]string{"locationId": "required"}, nil
+		}
+		locationID = service.LocationIDs[0]
+	}
+	offered := false
+	for _, id := range service.LocationIDs {
+		offered = offered || id == locationID
+	}
+	if !offered {
+		return slotPlace{}, map[string]string{"locationId": "oneof"}, nil
+	}
+
+	var location models.Location
+	if err := s.Cols.Locations.FindOne(ctx, bson.M{"_id": locationID, "active": true}).Decode(&location); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return slotPlace{}, map[string]string{"locationId": "oneof"}, nil
+		}
+		return slotPlace{}, nil, err
+	}
+	if roomID != "" {
+		if _, ok := findRoom(location, roomID); !ok {
+			return slotPlace{}, map[string]string{"roomId": "oneof"}, nil
+		}
+	}
+	return slotPlace{Location: &location, RoomID: roomID}, nil, nil
+}
+
+// appointmentPlace is where an existing appointment is held, its current
+// room first. A location removed since cannot take it anymore.
+func (s *Server) appointmentPlace(ctx context.Context, appointment models.Appointment) (slotPlace, error) {
+	if appointment.Location == nil {
+		return slotPlace{}, nil
+	}
+	var location models.Location
+	if err := s.Cols.Locations.FindOne(ctx, bson.M{"_id": appointment.Location.ID}).Decode(&location); err != nil {
+		if err != mongo.ErrNoDocuments {
+			return slotPlace{}, err
+		}
+		location = models.Location{ID: appointment.Location.ID}
+	}
+	return slotPlace{Location: &location, RoomID: appointment.Location.RoomID, PreferRoom: true}, nil
+}
+
+// waitlistPlace is the calendar a waitlist entry waits on. A location
+// removed since has no room left to offer.
+func (s *Server) waitlistPlace(ctx context.Context, entry models.WaitlistEntry) (slotPlace, error) {
+	if entry.LocationID == "" {
+		return slotPlace{}, nil
+	}
+	var location models.Location
+	if err := s.Cols.Locations.FindOne(ctx, bson.M{"_id": entry.LocationID}).Decode(&location); err != nil {
+		if err != mongo.ErrNoDocuments {
+			return slotPlace{}, err
+		}
+		location = models.Location{ID: entry.LocationID}
+	}
+	return slotPlace{Location: &location, RoomID: entry.RoomID}, nil
+}
+
+// queryPlace reads the location and room parameters of the availability
+// endpoints.
+func (s *Server) queryPlace(ctx context.Context, values url.Values) (slotPlace, map[string]string, error) {
+	locationID := strings.TrimSpace(values.Get("location"))
+	roomID := strings.TrimSpace(values.Get("room"))
+	if locationID == "" {
+		if roomID != "" {
+			return slotPlace{}, map[string]string{"room": "excluded"}, nil
+		}
+		return slotPlace{}, nil, nil
+	}
+	var location models.Location
+	if err := s.Cols.Locations.FindOne(ctx, bson.M{"_id": locationID, "active": true}).Decode(&location); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return slotPlace{}, map[string]string{"location": "oneof"}, nil
+		}
+		return slotPlace{}, nil, err
+	}
+	if roomID != "" {
+		if _, ok := findRoom(location, roomID); !ok {
+			return slotPlace{}, map[string]string{"room": "oneof"}, nil
+		}
+	}
+	return slotPlace{Location: &location, RoomID: roomID}, nil, nil
+}
+
+func findRoom(location models.Location, roomID string) (models.Room, bool) {
+	for _, room := range location.Rooms {
+		if room.ID == roomID {
+			return room, true
+		}
+	}
+	return models.Room{}, false
+}
+
+func appointmentLocation(location models.Location, room models.Room) *models.AppointmentLocation {
+	return &models.AppointmentLocation{
+		ID:         location.ID,
+		Name:       location.Name,
+		Address:    location.Address,
+		Directions: location.Directions,
+		MapURL:     location.MapURL,
+		RoomID:     room.ID,
+		RoomName:   room.Name,
+	}
+}
+
+// candidateRooms lists the rooms place may use, in the order to try them.
+func (p slotPlace) candidateRooms() []models.Room {
+	if p.Location == nil {
+		return nil
+	}
+	if p.RoomID == "" {
+		return p.Location.Rooms
+	}
+	rooms := make([]models.Room, 0, len(p.Location.Rooms))
+	if room, ok := findRoom(*p.Location, p.RoomID); ok {
+		rooms = append(rooms, room)
+	}
+	if p.PreferRoom {
+		for _, room := range p.Location.Rooms {
+			if room.ID != p.RoomID {
+				rooms = append(rooms, room)
+			}
+		}
+	}
+	return rooms
+}
+
+// locationSlots lists the slots of date within the opening hours of
+// location, or the default hours when it has none.
+func (s *Server) locationSlots(location models.Location, date string, duration int) ([]string, error) {
+	if len(location.Hours) == 0 {
+		return schedule.GenerateSlotsWithDuration(date, duration, s.Cfg.Timezone)
+	}
+	day, err := schedule.ParseDate(date, s.Cfg.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []schedule.TimeRange
+	for _, h := range location.Hours {
+		if time.Weekday(h.Day) == day.Weekday() {
+			ranges = append(ranges, schedule.TimeRange{Start: h.Start, End: h.End})
+		}
+	}
+	return schedule.GenerateSlotsInRanges(ranges, duration)
+}
+
+// roomIntervals returns the busy time of each room of a location on date.
+func (s *Server) roomIntervals(ctx context.Context, locationID, date string, excludeIDs ...string) (map[string][]schedule.Interval, error) {
+	busy, err := s.roomIntervalsByDate(ctx, locationID, []string{date}, excludeIDs...)
+	if err != nil {
+		return nil, err
+	}
+	return busy[date], nil
+}
+
+// roomIntervalsByDate is roomIntervals for several dates, keyed by date
+// then room.
+func (s *Server) roomIntervalsByDate(ctx context.Context, locationID string, dates []string, excludeIDs ...string) (map[string]map[string][]schedule.Interval, error) {
+	filter := bson.M{
+		"date":        bson.M{"$in": dates},
+		"status":      bson.M{"$in": models.ActiveAppointmentStatuses},
+		"location.id": locationID,
+	}
+	if len(excludeIDs) > 0 {
+		filter["_id"] = bson.M{"$nin": excludeIDs}
+	}
+	var appointments []models.Appointment
+	opts := options.Find().SetProjection(bson.M{"date": 1, "time": 1, "duration": 1, "location": 1})
+	if err := s.findAll(ctx, s.Cols.Appointments, filter, opts, &appointments); err != nil {
+		return nil, err
+	}
+	busy := make(map[string]map[string][]schedule.Interval, len(dates))
+	for _, appointment := range appointments {
+		start, err := schedule.ParseClockToMinutes(appointment.Time)
+		if err != nil || appointment.Location == nil {
+			continue
+		}
+		duration := appointment.Duration
+		if duration <= 0 {
+			duration = schedule.SlotMinutes
+		}
+		rooms := busy[appointment.Date]
+		if rooms == nil {
+			rooms = map[string][]schedule.Interval{}
+			busy[appointment.Date] = rooms
+		}
+		rooms[appointment.Location.RoomID] = append(rooms[appointment.Location.RoomID], schedule.Interval{Start: start, End: start + duration})
+	}
+	return busy, nil
+}
+
+func overlapsAny(current schedule.Interval, intervals []schedule.Interval) bool {
+	for _, interval := range intervals {
+		if schedule.Overlaps(current, interval) {
+			return true
+		}
+	}
+	return false
+}
+
+// placeSlot is checkSlot for an appointment that may be held at a location:
+// the slot must fit the opening hours of the location, miss the blocked
+// slots and find a free room, which is returned. Without a location it
+// falls back to checkSlot. override skips the hours and blocks, but a room
+// is still needed.
+func (s *Server) placeSlot(ctx context.Context, place slotPlace, date, clock string, duration int, override bool, excludeID string) (*models.AppointmentLocation, int, string, error) {
+	if place.Location == nil {
+		code, msg, err := s.checkSlot(ctx, date, clock, duration, override, excludeID)
+		return nil, code, msg, err
+	}
+	if _, err := schedule.ParseDateTime(date, clock, s.Cfg.Timezone); err != nil {
+		return nil, http.StatusBadRequest, "invalid date", nil
+	}
+	if !place.Location.Active || len(place.Location.Rooms) == 0 {
+		return nil, http.StatusConflict, "location not available", nil
+	}
+	start, err := schedule.ParseClockToMinutes(clock)
+	if err != nil {
+		return nil, http.StatusBadRequest, "invalid time", nil
+	}
+	current := schedule.Interval{Start: start, End: start + duration}
+
+	if !override {
+		slots, err := s.locationSlots(*place.Location, date, duration)
+		if err != nil {
+			return nil, http.StatusBadRequest, "invalid time", nil
+		}
+		allowed := false
+		for _, slot := range slots {
+			allowed = allowed || slot == clock
+		}
+		if !allowed {
+			return nil, http.StatusBadRequest, "slot not available", nil
+		}
+		blocks, err := s.blockIntervals(ctx, date)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		if overlapsAny(current, blocks) {
+			return nil, http.StatusConflict, "slot not available", nil
+		}
+	}
+
+	var exclude []string
+	if excludeID != "" {
+		exclude = append(exclude, excludeID)
+	}
+	busy, err := s.roomIntervals(ctx, place.Location.ID, date, exclude...)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	for _, room := range place.candidateRooms() {
+		if !overlapsAny(current, busy[room.ID]) {
+			return appointmentLocation(*place.Location, room), 0, "", nil
+		}
+	}
+	return nil, http.StatusConflict, "slot not available", nil
+}
+
+// computeLocationSlotsByDate lists, for each of dates, the slots where
+// place has a free room.
+func (s *Server) computeLocationSlotsByDate(ctx context.Context, place slotPlace, dates []string, duration int, now time.Time) (map[string][]string, error) {
+	blocks, err := s.blockIntervalsByDate(ctx, dates)
+	if err != nil {
+		return nil, err
+	}
+	busy, err := s.roomIntervalsByDate(ctx, place.Location.ID, dates)
+	if err != nil {
+		return nil, err
+	}
+	rooms := place.candidateRooms()
+
+	free := make(map[string][]string, len(dates))
+	for _, date := range dates {
+		slots, err := s.locationSlots(*place.Location, date, duration)
+		if err != nil {
+			return nil, err
+		}
+		slots, err = schedule.FilterOverlapping(slots, duration, blocks[date])
+		if err != nil {
+			return nil, err
+		}
+
+		open := make([]string, 0, len(slots))
+		for _, slot := range slots {
+			start, err := schedule.ParseClockToMinutes(slot)
+			if err != nil {
+				return nil, err
+			}
+			current := schedule.Interval{Start: start, End: start + duration}
+			for _, room := range rooms {
+				if !overlapsAny(current, busy[date][room.ID]) {
+					open = append(open, slot)
+					break
+				}
+			}
+		}
+
+		if dateIsToday(date, s.Cfg.Timezone) {
+			open, err = schedule.FilterPastSlots(date, open, s.Cfg.Timezone, now)
+			if err != nil {
+				return nil, err
+			}
+		}
+		free[date] = open
+	}
+	return free, nil
+}
+
+// availableSlotsAt lists the free slots of date on the calendar of place.
+func (s *Server) availableSlotsAt(ctx context.Context, place slotPlace, date string, duration int, now time.Time) ([]string, error) {
+	slots, err := s.availableSlotsByDate(ctx, place, []string{date}, duration, now)
+	if err != nil {
+		return nil, err
+	}
+	return slots[date], nil
+}
+
+// availableSlotsByDate lists the free slots of each of dates on the
+// calendar of place.
+func (s *Server) availableSlotsByDate(ctx context.Context, place slotPlace, dates []string, duration int, now time.Time) (map[string][]string, error) {
+	if place.Location == nil {
+		return s.computeAvailableSlotsByDate(ctx, dates, duration, now)
+	}
+	return s.computeLocationSlotsByDate(ctx, place, dates, duration, now)
+}
+
+func (p slotPlace) locationID() string {
+	if p.Location == nil {
+		return ""
+	}
+	return p.Location.ID
+}
+
+// cacheSuffix keeps the cached availability of each place apart.
+func (p slotPlace) cacheSuffix() string {
+	if p.Location == nil {
+		return ""
+	}
+	return ":" + p.Location.ID + ":" + p.RoomID
+}
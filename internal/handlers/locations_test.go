@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/validation"
+)
+
+func TestLocationFromRequest(t *testing.T) {
+	s := &Server{Val: validation.New()}
+	current := &models.Location{Rooms: []models.Room{{ID: "room-a", Name: "A"}}}
+
+	location, details := s.locationFromRequest(AdminLocationRequest{
+		Name:    " Agence Gombe ",
+		Address: "Avenue du Commerce 12",
+		Hours:   []OpeningHoursRequest{{Day: 1, Start: "08:00", End: "12:00"}, {Day: 1, Start: "13:00", End: "17:00"}},
+		Rooms:   []LocationRoomRequest{{ID: "room-a", Name: "A"}, {Name: "B"}},
+	}, current)
+	if details != nil {
+		t.Fatalf("locationFromRequest() details = %v", details)
+	}
+	if location.Name != "Agence Gombe" || !location.Active || len(location.Rooms) != 2 {
+		t.Fatalf("locationFromRequest() = %+v", location)
+	}
+	if location.Rooms[0].ID != "room-a" || location.Rooms[1].ID == "" {
+		t.Fatalf("room ids = %+v", location.Rooms)
+	}
+
+	_, details = s.locationFromRequest(AdminLocationRequest{
+		Name:    "Agence",
+		Address: "Rue 1",
+		Hours:   []OpeningHoursRequest{{Day: 2, Start: "10:00", End: "09:00"}, {Day: 3, Start: "08:00", End: "12:00"}, {Day: 3, Start: "11:00", End: "13:00"}},
+		Rooms:   []LocationRoomRequest{{ID: "room-x", Name: "A"}, {Name: "a"}},
+	}, current)
+	want := map[string]string{
+		"hours[0].end":  "gtfield",
+		"hours[2]":      "overlaps hours[1]",
+		"rooms[0].id":   "oneof",
+		"rooms[1].name": "unique",
+	}
+	if len(details) != len(want) {
+		t.Fatalf("locationFromRequest() details = %v, want %v", details, want)
+	}
+	for key, value := range want {
+		if details[key] != value {
+			t.Fatalf("details[%q] = %q, want %q (all: %v)", key, details[key], value, details)
+		}
+	}
+}
+
+func TestLocationRoomsBookedConcurrently(t *testing.T) {
+	s := newMongoTestServer(t)
+	ctx := context.Background()
+	location := models.Location{
+		ID:      "loc-test",
+		Name:    "Agence Gombe",
+		Address: "Avenue du Commerce 12",
+		Rooms:   []models.Room{{ID: "room-a", Name: "A"}, {ID: "room-b", Name: "B"}},
+		Active:  true,
+	}
+	if _, err := s.Cols.Locations.InsertOne(ctx, location); err != nil {
+		t.Fatalf("insert location: %v", err)
+	}
+	if _, err := s.Cols.Services.InsertOne(ctx, models.Service{ID: "svc-loc", Name: "Consultation", LocationIDs: []string{location.ID}}); err != nil {
+		t.Fatalf("insert service: %v", err)
+	}
+	date := nextWeekday(s.Cfg.Timezone)
+
+	book := func(consultationType string) (int, models.Appointment) {
+		body, _ := json.Marshal(CreateAppointmentRequest{
+			ServiceID:     "svc-loc",
+			Name:          "Jean Test",
+			Email:         "jean@example.com",
+			Phone:         "+243810000000",
+			Type:          consultationType,
+			Date:          date,
+			Time:          "09:00",
+			PaymentMethod: models.PaymentPlace,
+		})
+		rec := httptest.NewRecorder()
+		s.CreateAppointment(rec, httptest.NewRequest(http.MethodPost, "/api/appointments", bytes.NewReader(body)))
+		var resp struct {
+			Appointment models.Appointment `json:"appointment"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.Appointment
+	}
+
+	rooms := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		code, appointment := book(models.ConsultationPresentiel)
+		if code != http.StatusCreated || appointment.Location == nil {
+			t.Fatalf("booking %d: status %d, location %+v", i, code, appointment.Location)
+		}
+		rooms[appointment.Location.RoomID] = true
+	}
+	if len(rooms) != 2 {
+		t.Fatalf("expected both rooms to be used, got %v", rooms)
+	}
+	if code, _ := book(models.ConsultationPresentiel); code != http.StatusConflict {
+		t.Fatalf("third booking with every room taken: status %d, want 409", code)
+	}
+
+	slots, err := s.availableSlotsAt(ctx, slotPlace{Location: &location}, date, 45, time.Now())
+	if err != nil {
+		t.Fatalf("availableSlotsAt() error = %v", err)
+	}
+	if slices.Contains(slots, "09:00") {
+		t.Fatalf("expected 09:00 to be full at the location, got %v", slots)
+	}
+	// Rooms do not take the general calendar used by online appointments.
+	if code, _ := book(models.ConsultationOnline); code != http.StatusCreated {
+		t.Fatalf("online booking at the same time: status %d, want 201", code)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gbh-backend/internal/meeting"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/schedule"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// assignMeetingLink gives an online appointment a fresh meeting link. It is
+// called at booking and whenever the appointment moves. A provider failure
+// is logged and the appointment goes on without a link.
+func (s *Server) assignMeetingLink(ctx context.Context, log *slog.Logger, appointment *models.Appointment, service models.Service) {
+	if s.Meetings == nil || appointment.Type != models.ConsultationOnline {
+		return
+	}
+	start, err := schedule.ParseDateTime(appointment.Date, appointment.Time, s.Cfg.Timezone)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	link, err := s.Meetings.CreateMeeting(ctx, meeting.Request{
+		AppointmentID: appointment.ID,
+		Title:         "GBH - " + service.Name,
+		Start:         start,
+		Duration:      time.Duration(appointment.Duration) * time.Minute,
+	})
+	if err != nil {
+		log.Warn("appointments meeting: create failed",
+			slog.String("appointment_id", appointment.ID),
+			slog.String("provider", s.Meetings.Name()),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	link.CreatedAt = time.Now().In(s.Cfg.Timezone)
+	appointment.Meeting = &link
+}
+
+// AdminRegenerateMeetingLink replaces the meeting link of an online
+// appointment (e.g. a leaked link) and sends the client an updated invitation.
+func (s *Server) AdminRegenerateMeetingLink(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		log.Warn("admin appointments meeting: missing id")
+		transport.WriteError(w, http.StatusBadRequest, "missing id", nil)
+		return
+	}
+	if s.Meetings == nil {
+		log.Warn("admin appointments meeting: provider not configured")
+		transport.WriteError(w, http.StatusServiceUnavailable, "meeting provider not configured", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	var appointment models.Appointment
+	if err := s.Cols.Appointments.FindOne(ctx, bson.M{"_id": id}).Decode(&appointment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin appointments meeting: not found", slog.String("appointment_id", id))
+			transport.WriteError(w, http.StatusNotFound, "appointment not found", nil)
+			return
+		}
+		log.Error("admin appointments meeting: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if appointment.Type != models.ConsultationOnline || models.AppointmentCanceled(appointment.Status) {
+		log.Warn("admin appointments meeting: not an active online appointment", slog.String("appointment_id", id))
+		transport.WriteError(w, http.StatusConflict, "appointment is not an active online consultation", nil)
+		return
+	}
+
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": appointment.ServiceID}).Decode(&service); err != nil && err != mongo.ErrNoDocuments {
+		log.Error("admin appointments meeting: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	previous := appointment.Meeting
+	s.assignMeetingLink(ctx, log, &appointment, service)
+	if appointment.Meeting == previous {
+		transport.WriteError(w, http.StatusBadGateway, "meeting provider error", nil)
+		return
+	}
+
+	update := bson.M{
+		"$set": bson.M{"meeting": appointment.Meeting},
+		"$inc": bson.M{"calendarSequence": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := s.Cols.Appointments.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&appointment); err != nil {
+		log.Error("admin appointments meeting: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	if s.Mailer != nil {
+		go s.sendAppointmentRescheduledEmail(log, appointment, service)
+	}
+
+	log.Info("admin appointments meeting: regenerated", slog.String("appointment_id", id), slog.String("provider", appointment.Meeting.Provider))
+	transport.WriteJSON(w, http.StatusOK, appointment)
+}
@@ -8,6 +8,7 @@ import (
 	"gbh-backend/internal/cache"
 	"gbh-backend/internal/config"
 	"gbh-backend/internal/db"
+	"gbh-backend/internal/meeting"
 	"gbh-backend/internal/middleware"
 	"gbh-backend/internal/models"
 	"gbh-backend/internal/validation"
@@ -15,9 +16,16 @@ import (
 
 type AppointmentMailer interface {
 	SendAppointmentConfirmation(ctx context.Context, appointment models.Appointment, service models.Service) (string, error)
+	SendAppointmentRescheduled(ctx context.Context, appointment models.Appointment, service models.Service) (string, error)
+	SendAppointmentCancellation(ctx context.Context, appointment models.Appointment, service models.Service) (string, error)
 	SendEmail(ctx context.Context, toEmail, toName, subject, htmlBody string) (string, error)
 }
 
+// SMSSender delivers text messages, e.g. waitlist offers.
+type SMSSender interface {
+	SendSMS(ctx context.Context, phone, content string) (string, error)
+}
+
 type AppointmentPusher interface {
 	SendAppointmentConfirmation(ctx context.Context, deviceToken string, appointment models.Appointment, service models.Service) (string, error)
 }
@@ -30,14 +38,22 @@ type Server struct {
 	Cache  cache.Cache
 	Mailer AppointmentMailer
 	Push   AppointmentPusher
+	// Creates video links for online appointments; nil disables them.
+	Meetings meeting.Provider
+	// Sends SMS; nil makes SMS waitlist offers fall back to email.
+	SMS SMSSender
 }
 
 func (s *Server) logWithRequest(r *http.Request) *slog.Logger {
 	if r == nil {
 		return s.Log
 	}
+	log := s.Log
 	if id := middleware.RequestIDFromContext(r.Context()); id != "" {
-		return s.Log.With(slog.String("request_id", id))
+		log = log.With(slog.String("request_id", id))
+	}
+	if principal, ok := middleware.PrincipalFromContext(r.Context()); ok {
+		log = log.With(slog.String("admin", principal.Username))
 	}
-	return s.Log
+	return log
 }
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/config"
+	"gbh-backend/internal/db"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/validation"
+)
+
+// newMongoTestServer returns a Server backed by a throwaway database on the
+// MongoDB at TEST_MONGO_URI. Tests using it are skipped when it is not set.
+func newMongoTestServer(t *testing.T) *Server {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI not set")
+	}
+	ctx := context.Background()
+	dbName := fmt.Sprintf("gbh_test_%d", time.Now().UnixNano())
+	client, cols, err := db.Connect(ctx, uri, dbName)
+	if err != nil {
+		t.Fatalf("db.Connect() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Database(dbName).Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+	if err := db.EnsureIndexes(ctx, cols); err != nil {
+		t.Fatalf("db.EnsureIndexes() error = %v", err)
+	}
+	loc, err := time.LoadLocation("Africa/Kinshasa")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	return &Server{
+		Cfg:  &config.Config{Timezone: loc},
+		Cols: cols,
+		Val:  validation.New(),
+		Log:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// nextWeekday returns a weekday at least a week ahead, so its slots are open.
+func nextWeekday(loc *time.Location) string {
+	day := time.Now().In(loc).AddDate(0, 0, 7)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day.Format("2006-01-02")
+}
+
+func insertTestUsers(t *testing.T, s *Server, users ...models.User) {
+	t.Helper()
+	now := time.Now()
+	for _, user := range users {
+		user.CreatedAt, user.UpdatedAt = now, now
+		if _, err := s.Cols.Users.InsertOne(context.Background(), user); err != nil {
+			t.Fatalf("insert user %s: %v", user.ID, err)
+		}
+	}
+}
+
+// recordingMailer keeps the recipients of the emails sent by the handlers.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (m *recordingMailer) record(email string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, email)
+	return "msg-test", nil
+}
+
+func (m *recordingMailer) SendAppointmentConfirmation(ctx context.Context, appointment models.Appointment, service models.Service) (string, error) {
+	return m.record(appointment.Email)
+}
+
+func (m *recordingMailer) SendAppointmentRescheduled(ctx context.Context, appointment models.Appointment, service models.Service) (string, error) {
+	return m.record(appointment.Email)
+}
+
+func (m *recordingMailer) SendAppointmentCancellation(ctx context.Context, appointment models.Appointment, service models.Service) (string, error) {
+	return m.record(appointment.Email)
+}
+
+func (m *recordingMailer) SendEmail(ctx context.Context, toEmail, toName, subject, htmlBody string) (string, error) {
+	return m.record(toEmail)
+}
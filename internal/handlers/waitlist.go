@@ -0,0 +1,760 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/booking"
+	"gbh-backend/internal/httpx"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/notifications"
+	"gbh-backend/internal/schedule"
+	"gbh-backend/internal/transport"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// waitlistMaxRangeDays bounds the period a customer can wait for.
+const waitlistMaxRangeDays = 90
+
+type JoinWaitlistRequest struct {
+	ServiceID     string `json:"serviceId" validate:"required"`
+	Name          string `json:"name" validate:"required"`
+	Email         string `json:"email" validate:"required,email"`
+	Phone         string `json:"phone" validate:"required,phone"`
+	Channel       string `json:"channel" validate:"omitempty,oneof=email sms"`
+	Type          string `json:"type" validate:"required,oneof=online presentiel"`
+	Duration      int    `json:"duration" validate:"omitempty,gte=15,lte=240,minutes15"`
+	PaymentMethod string `json:"paymentMethod" validate:"required,oneof=online place"`
+	Price         int    `json:"price" validate:"gte=0"`
+	LocationID    string `json:"locationId"`
+	RoomID        string `json:"roomId"`
+	From          string `json:"from" validate:"required,date"`
+	To            string `json:"to" validate:"required,date"`
+}
+
+type LeaveWaitlistRequest struct {
+	ID    string `json:"id" validate:"required"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ClaimWaitlistRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (s *Server) waitlistConfigured() bool {
+	return s.Cfg.WaitlistClaimURL != "" && (s.Mailer != nil || s.SMS != nil)
+}
+
+func (s *Server) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req JoinWaitlistRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("waitlist join: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("waitlist join: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+	if !s.waitlistConfigured() {
+		log.Warn("waitlist join: not configured")
+		transport.WriteError(w, http.StatusServiceUnavailable, "waitlist not available", nil)
+		return
+	}
+
+	now := time.Now().In(s.Cfg.Timezone)
+	from, errFrom := schedule.ParseDate(req.From, s.Cfg.Timezone)
+	to, errTo := schedule.ParseDate(req.To, s.Cfg.Timezone)
+	if errFrom != nil || errTo != nil {
+		transport.WriteError(w, http.StatusBadRequest, "invalid date", nil)
+		return
+	}
+	today := now.Format("2006-01-02")
+	switch {
+	case req.To < today:
+		transport.WriteError(w, http.StatusBadRequest, "date in the past", nil)
+		return
+	case req.From > req.To:
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"To": "gtefield"})
+		return
+	case to.Sub(from) > waitlistMaxRangeDays*24*time.Hour:
+		transport.WriteError(w, http.StatusBadRequest, "validation error", map[string]string{"To": "max"})
+		return
+	}
+	if req.From < today {
+		req.From = today
+	}
+	if req.Channel == "" {
+		req.Channel = models.WaitlistChannelEmail
+	}
+	if req.Duration == 0 {
+		req.Duration = schedule.SlotMinutes
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": req.ServiceID}).Decode(&service); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("waitlist join: service not found", slog.String("service_id", req.ServiceID))
+			transport.WriteError(w, http.StatusBadRequest, "service not found", nil)
+			return
+		}
+		log.Error("waitlist join: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	place, details, err := s.resolvePlace(ctx, service, req.Type, req.LocationID, req.RoomID)
+	if err != nil {
+		log.Error("waitlist join: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if details != nil {
+		log.Warn("waitlist join: invalid place", slog.String("service_id", req.ServiceID))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	existing := bson.M{
+		"email":     req.Email,
+		"serviceId": req.ServiceID,
+		"status":    bson.M{"$in": []string{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}},
+	}
+	count, err := s.Cols.Waitlist.CountDocuments(ctx, existing)
+	if err != nil {
+		log.Error("waitlist join: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if count > 0 {
+		log.Warn("waitlist join: already waiting", slog.String("service_id", req.ServiceID))
+		transport.WriteError(w, http.StatusConflict, "already on the waitlist", nil)
+		return
+	}
+
+	entry := models.WaitlistEntry{
+		ID:            primitive.NewObjectID().Hex(),
+		ServiceID:     req.ServiceID,
+		Name:          req.Name,
+		Email:         req.Email,
+		Phone:         req.Phone,
+		Channel:       req.Channel,
+		Type:          req.Type,
+		Duration:      req.Duration,
+		PaymentMethod: req.PaymentMethod,
+		Price:         req.Price,
+		LocationID:    place.locationID(),
+		RoomID:        place.RoomID,
+		From:          req.From,
+		To:            req.To,
+		Status:        models.WaitlistStatusWaiting,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if _, err := s.Cols.Waitlist.InsertOne(ctx, entry); err != nil {
+		log.Error("waitlist join: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("waitlist join: ok", slog.String("entry_id", entry.ID), slog.String("from", entry.From), slog.String("to", entry.To))
+	transport.WriteJSON(w, http.StatusCreated, entry)
+}
+
+// LeaveWaitlist removes a customer from the waitlist. As with appointment
+// cancellation, the email must match.
+func (s *Server) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req LeaveWaitlistRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("waitlist leave: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.ID = strings.TrimSpace(req.ID)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("waitlist leave: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":    req.ID,
+		"email":  req.Email,
+		"status": bson.M{"$in": []string{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}},
+	}
+	if err := s.cancelWaitlistEntry(ctx, filter); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("waitlist leave: not found", slog.String("entry_id", req.ID))
+			transport.WriteError(w, http.StatusNotFound, "waitlist entry not found", nil)
+			return
+		}
+		log.Error("waitlist leave: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("waitlist leave: ok", slog.String("entry_id", req.ID))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": models.WaitlistStatusCanceled})
+}
+
+func (s *Server) cancelWaitlistEntry(ctx context.Context, filter bson.M) error {
+	now := time.Now().In(s.Cfg.Timezone)
+	update := bson.M{
+		"$set":   bson.M{"status": models.WaitlistStatusCanceled, "canceledAt": now, "updatedAt": now},
+		"$unset": bson.M{"offer": ""},
+	}
+	res, err := s.Cols.Waitlist.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// liveWaitlistOffer returns the entry holding an unexpired offer for token.
+func (s *Server) liveWaitlistOffer(ctx context.Context, token string) (models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	filter := bson.M{
+		"offer.tokenHash": auth.HashToken(token),
+		"status":          models.WaitlistStatusOffered,
+		"offer.expiresAt": bson.M{"$gt": time.Now()},
+	}
+	err := s.Cols.Waitlist.FindOne(ctx, filter).Decode(&entry)
+	return entry, err
+}
+
+// GetWaitlistOffer describes the slot behind a claim link so the page can
+// show it before the customer confirms.
+func (s *Server) GetWaitlistOffer(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	token := strings.TrimSpace(chi.URLParam(r, "token"))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	entry, err := s.liveWaitlistOffer(ctx, token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("waitlist offer: invalid token")
+			transport.WriteError(w, http.StatusNotFound, "invalid or expired offer", nil)
+			return
+		}
+		log.Error("waitlist offer: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": entry.ServiceID}).Decode(&service); err != nil && err != mongo.ErrNoDocuments {
+		log.Error("waitlist offer: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	place, err := s.waitlistPlace(ctx, entry)
+	if err != nil {
+		log.Error("waitlist offer: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	placement, code, _, err := s.placeSlot(ctx, place, entry.Offer.Date, entry.Offer.Time, entry.Duration, false, "")
+	if err != nil {
+		log.Error("waitlist offer: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	response := map[string]interface{}{
+		"serviceId":   entry.ServiceID,
+		"serviceName": service.Name,
+		"name":        entry.Name,
+		"type":        entry.Type,
+		"date":        entry.Offer.Date,
+		"time":        entry.Offer.Time,
+		"duration":    entry.Duration,
+		"expiresAt":   entry.Offer.ExpiresAt,
+		"available":   code == 0,
+	}
+	if placement != nil {
+		response["location"] = placement
+	}
+	transport.WriteJSON(w, http.StatusOK, response)
+}
+
+// ClaimWaitlistOffer books the offered slot. Several customers may hold an
+// offer for the same slot; the first claim wins and the others get 409.
+func (s *Server) ClaimWaitlistOffer(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	var req ClaimWaitlistRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Warn("waitlist claim: invalid json")
+		transport.WriteError(w, http.StatusBadRequest, "invalid json", nil)
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if err := s.Val.Struct(req); err != nil {
+		log.Warn("waitlist claim: validation error")
+		details := validationDetails(s.Val.ValidationErrors(err))
+		transport.WriteError(w, http.StatusBadRequest, "validation error", details)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	entry, err := s.liveWaitlistOffer(ctx, req.Token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("waitlist claim: invalid token")
+			transport.WriteError(w, http.StatusBadRequest, "invalid or expired offer", nil)
+			return
+		}
+		log.Error("waitlist claim: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	offer := *entry.Offer
+
+	var service models.Service
+	if err := s.Cols.Services.FindOne(ctx, bson.M{"_id": entry.ServiceID}).Decode(&service); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("waitlist claim: service not found", slog.String("service_id", entry.ServiceID))
+			transport.WriteError(w, http.StatusConflict, "slot no longer available", nil)
+			return
+		}
+		log.Error("waitlist claim: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	place, err := s.waitlistPlace(ctx, entry)
+	if err != nil {
+		log.Error("waitlist claim: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	past, _ := schedule.IsSlotPast(offer.Date, offer.Time, s.Cfg.Timezone, time.Now())
+	placement, code, _, err := s.placeSlot(ctx, place, offer.Date, offer.Time, entry.Duration, false, "")
+	if err != nil {
+		log.Error("waitlist claim: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if past || code != 0 {
+		s.releaseWaitlistOffer(ctx, entry.ID, offer.TokenHash)
+		log.Warn("waitlist claim: slot taken", slog.String("entry_id", entry.ID), slog.String("date", offer.Date), slog.String("time", offer.Time))
+		transport.WriteError(w, http.StatusConflict, "slot no longer available", nil)
+		return
+	}
+
+	// Claim the offer first so the link cannot be used twice.
+	now := time.Now().In(s.Cfg.Timezone)
+	claim := bson.M{"_id": entry.ID, "status": models.WaitlistStatusOffered, "offer.tokenHash": offer.TokenHash}
+	res, err := s.Cols.Waitlist.UpdateOne(ctx, claim, bson.M{"$set": bson.M{"status": models.WaitlistStatusBooked, "updatedAt": now}})
+	if err != nil {
+		log.Error("waitlist claim: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if res.ModifiedCount == 0 {
+		log.Warn("waitlist claim: already claimed", slog.String("entry_id", entry.ID))
+		transport.WriteError(w, http.StatusBadRequest, "invalid or expired offer", nil)
+		return
+	}
+
+	appointment := models.Appointment{
+		ID:            primitive.NewObjectID().Hex(),
+		ServiceID:     entry.ServiceID,
+		Name:          entry.Name,
+		Email:         entry.Email,
+		Phone:         entry.Phone,
+		Type:          entry.Type,
+		Date:          offer.Date,
+		Time:          offer.Time,
+		Duration:      entry.Duration,
+		Price:         entry.Price,
+		Total:         entry.Price,
+		Status:        models.AppointmentStatusBooked,
+		PaymentMethod: entry.PaymentMethod,
+		Location:      placement,
+		CreatedAt:     now,
+		StatusHistory: []models.AppointmentStatusChange{{
+			To:     models.AppointmentStatusBooked,
+			Actor:  booking.ActorCustomer,
+			Reason: "waitlist",
+			At:     now,
+		}},
+	}
+	s.assignMeetingLink(ctx, log, &appointment, service)
+	if _, err := s.Cols.Appointments.InsertOne(ctx, appointment); err != nil {
+		s.releaseWaitlistOffer(ctx, entry.ID, offer.TokenHash)
+		if mongo.IsDuplicateKeyError(err) {
+			log.Warn("waitlist claim: duplicate key", slog.String("entry_id", entry.ID))
+			transport.WriteError(w, http.StatusConflict, "slot no longer available", nil)
+			return
+		}
+		log.Error("waitlist claim: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"appointmentId": appointment.ID}, "$unset": bson.M{"offer": ""}}
+	if _, err := s.Cols.Waitlist.UpdateOne(ctx, bson.M{"_id": entry.ID}, update); err != nil {
+		log.Warn("waitlist claim: entry not updated", slog.String("entry_id", entry.ID), slog.String("error", err.Error()))
+	}
+
+	if s.Cache != nil {
+		_ = s.Cache.DeletePrefix(r.Context(), "availability:"+appointment.Date+":")
+	}
+	if s.Mailer != nil {
+		go s.sendAppointmentConfirmationEmail(log, appointment, service)
+	}
+	go func(appointment models.Appointment, service models.Service) {
+		subject := "Créneau repris depuis la liste d'attente"
+		htmlBody := fmt.Sprintf("<p><strong>%s</strong> a réservé le créneau libéré du <strong>%s</strong> à <strong>%s</strong> pour le service <strong>%s</strong>.</p><p>Référence : %s</p>",
+			html.EscapeString(appointment.Name), appointment.Date, appointment.Time, html.EscapeString(service.Name), appointment.ID)
+		s.NotifyAdmins(context.Background(), subject, htmlBody)
+	}(appointment, service)
+
+	log.Info("waitlist claim: booked", slog.String("entry_id", entry.ID), slog.String("appointment_id", appointment.ID))
+	transport.WriteJSON(w, http.StatusCreated, map[string]interface{}{"appointment": viewAppointment(appointment)})
+}
+
+// releaseWaitlistOffer puts an entry whose offer could not be honoured back
+// in the queue. The slot stays in offeredSlots.
+func (s *Server) releaseWaitlistOffer(ctx context.Context, entryID, tokenHash string) {
+	filter := bson.M{"_id": entryID, "offer.tokenHash": tokenHash}
+	update := bson.M{
+		"$set":   bson.M{"status": models.WaitlistStatusWaiting, "updatedAt": time.Now().In(s.Cfg.Timezone)},
+		"$unset": bson.M{"offer": ""},
+	}
+	if _, err := s.Cols.Waitlist.UpdateOne(ctx, filter, update); err != nil {
+		s.Log.Warn("waitlist: release offer failed", slog.String("entry_id", entryID), slog.String("error", err.Error()))
+	}
+}
+
+// allCalendars tells OfferFreedSlots that time was freed on every calendar
+// at once, as when an admin block is removed.
+const allCalendars = "*"
+
+// OfferFreedSlots proposes the free slots of date to the customers waiting
+// for it, oldest first. calendar is where time was freed: "" for the
+// general calendar, a location ID or allCalendars. Each slot of a calendar
+// goes to at most WaitlistOfferBatch customers at a time, and never twice
+// to the same customer.
+func (s *Server) OfferFreedSlots(ctx context.Context, date, calendar string) {
+	if !s.waitlistConfigured() || s.Cols.Waitlist == nil {
+		return
+	}
+	log := s.Log.With(slog.String("date", date))
+	now := time.Now().In(s.Cfg.Timezone)
+	if past, err := schedule.IsDatePast(date, s.Cfg.Timezone, now); err != nil || past {
+		return
+	}
+	batch := s.Cfg.WaitlistOfferBatch
+	if batch <= 0 {
+		batch = 1
+	}
+
+	var entries []models.WaitlistEntry
+	filter := bson.M{
+		"status": models.WaitlistStatusWaiting,
+		"from":   bson.M{"$lte": date},
+		"to":     bson.M{"$gte": date},
+	}
+	switch calendar {
+	case allCalendars:
+	case "":
+		filter["locationId"] = bson.M{"$exists": false}
+	default:
+		filter["locationId"] = calendar
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(100)
+	if err := s.findAll(ctx, s.Cols.Waitlist, filter, opts, &entries); err != nil {
+		log.Warn("waitlist offers: database error", slog.String("error", err.Error()))
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	// Offers already out for this date count against the batch of their
+	// calendar.
+	var live []models.WaitlistEntry
+	liveFilter := bson.M{"status": models.WaitlistStatusOffered, "offer.date": date, "offer.expiresAt": bson.M{"$gt": now}}
+	liveOpts := options.Find().SetProjection(bson.M{"offer": 1, "locationId": 1})
+	if err := s.findAll(ctx, s.Cols.Waitlist, liveFilter, liveOpts, &live); err != nil {
+		log.Warn("waitlist offers: database error", slog.String("error", err.Error()))
+		return
+	}
+	offered := map[string]int{}
+	for _, entry := range live {
+		offered[entry.LocationID+" "+entry.Offer.Time]++
+	}
+
+	slotsByCalendar := map[string][]string{}
+	for _, entry := range entries {
+		key := entry.LocationID + "|" + entry.RoomID + "|" + strconv.Itoa(entry.Duration)
+		slots, ok := slotsByCalendar[key]
+		if !ok {
+			place, err := s.waitlistPlace(ctx, entry)
+			if err != nil {
+				log.Warn("waitlist offers: database error", slog.String("error", err.Error()))
+				return
+			}
+			if place.Location == nil || place.Location.Active {
+				slots, err = s.availableSlotsAt(ctx, place, date, entry.Duration, now)
+				if err != nil {
+					log.Warn("waitlist offers: availability error", slog.String("error", err.Error()))
+					return
+				}
+			}
+			slotsByCalendar[key] = slots
+		}
+		for _, slot := range slots {
+			if offered[entry.LocationID+" "+slot] >= batch || slices.Contains(entry.OfferedSlots, date+" "+slot) {
+				continue
+			}
+			if s.offerWaitlistSlot(ctx, log, entry, date, slot, now) {
+				offered[entry.LocationID+" "+slot]++
+			}
+			break
+		}
+	}
+}
+
+func (s *Server) offerWaitlistSlot(ctx context.Context, log *slog.Logger, entry models.WaitlistEntry, date, slot string, now time.Time) bool {
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		log.Warn("waitlist offers: token error", slog.String("error", err.Error()))
+		return false
+	}
+	validFor := time.Duration(s.Cfg.WaitlistOfferMinutes) * time.Minute
+	if validFor <= 0 {
+		validFor = 2 * time.Hour
+	}
+	offer := models.WaitlistOffer{Date: date, Time: slot, TokenHash: hash, OfferedAt: now, ExpiresAt: now.Add(validFor)}
+	update := bson.M{
+		"$set":      bson.M{"status": models.WaitlistStatusOffered, "offer": offer, "updatedAt": now},
+		"$addToSet": bson.M{"offeredSlots": date + " " + slot},
+	}
+	res, err := s.Cols.Waitlist.UpdateOne(ctx, bson.M{"_id": entry.ID, "status": models.WaitlistStatusWaiting}, update)
+	if err != nil || res.ModifiedCount == 0 {
+		return false
+	}
+
+	var service models.Service
+	_ = s.Cols.Services.FindOne(ctx, bson.M{"_id": entry.ServiceID}).Decode(&service)
+	link, err := tokenLink(s.Cfg.WaitlistClaimURL, token)
+	if err != nil {
+		log.Warn("waitlist offers: invalid claim url", slog.String("error", err.Error()))
+		s.releaseWaitlistOffer(ctx, entry.ID, hash)
+		return false
+	}
+	message := notifications.WaitlistOffer{
+		Name:         entry.Name,
+		ServiceName:  service.Name,
+		Date:         date,
+		Time:         slot,
+		ClaimURL:     link,
+		ValidMinutes: int(validFor.Minutes()),
+	}
+	go s.sendWaitlistOffer(log, entry, message)
+
+	log.Info("waitlist offers: offered", slog.String("entry_id", entry.ID), slog.String("time", slot))
+	return true
+}
+
+func (s *Server) sendWaitlistOffer(log *slog.Logger, entry models.WaitlistEntry, offer notifications.WaitlistOffer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+	defer cancel()
+
+	if entry.Channel == models.WaitlistChannelSMS && s.SMS != nil {
+		_, err := s.SMS.SendSMS(ctx, entry.Phone, notifications.BuildWaitlistOfferSMS(offer))
+		if err == nil {
+			return
+		}
+		log.Warn("waitlist offers: sms failed, falling back to email", slog.String("entry_id", entry.ID), slog.String("error", err.Error()))
+	}
+	if s.Mailer == nil {
+		return
+	}
+	body, err := notifications.BuildWaitlistOfferHTML(offer)
+	if err != nil {
+		log.Warn("waitlist offers: template error", slog.String("error", err.Error()))
+		return
+	}
+	if _, err := s.Mailer.SendEmail(ctx, entry.Email, entry.Name, "Un créneau s'est libéré", body); err != nil {
+		log.Warn("waitlist offers: email failed", slog.String("entry_id", entry.ID), slog.String("error", err.Error()))
+	}
+}
+
+// ExpireWaitlistOffers returns unclaimed offers to the queue, offers their
+// slots to the next customers and closes entries whose period is over.
+func (s *Server) ExpireWaitlistOffers(ctx context.Context, now time.Time) {
+	if s.Cols.Waitlist == nil {
+		return
+	}
+	now = now.In(s.Cfg.Timezone)
+
+	var expired []models.WaitlistEntry
+	filter := bson.M{"status": models.WaitlistStatusOffered, "offer.expiresAt": bson.M{"$lte": now}}
+	opts := options.Find().SetProjection(bson.M{"offer": 1, "locationId": 1})
+	if err := s.findAll(ctx, s.Cols.Waitlist, filter, opts, &expired); err != nil {
+		s.Log.Warn("waitlist expiry: database error", slog.String("error", err.Error()))
+		return
+	}
+	type freed struct{ date, calendar string }
+	released := map[freed]bool{}
+	for _, entry := range expired {
+		s.releaseWaitlistOffer(ctx, entry.ID, entry.Offer.TokenHash)
+		released[freed{entry.Offer.Date, entry.LocationID}] = true
+	}
+
+	over := bson.M{
+		"status": bson.M{"$in": []string{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}},
+		"to":     bson.M{"$lt": now.Format("2006-01-02")},
+	}
+	closeUpdate := bson.M{"$set": bson.M{"status": models.WaitlistStatusExpired, "updatedAt": now}, "$unset": bson.M{"offer": ""}}
+	if _, err := s.Cols.Waitlist.UpdateMany(ctx, over, closeUpdate); err != nil {
+		s.Log.Warn("waitlist expiry: database error", slog.String("error", err.Error()))
+	}
+
+	for slot := range released {
+		s.OfferFreedSlots(ctx, slot.date, slot.calendar)
+	}
+}
+
+// offerFreedSlotsAsync runs OfferFreedSlots after a request has freed time
+// on date in calendar.
+func (s *Server) offerFreedSlotsAsync(date, calendar string) {
+	if !s.waitlistConfigured() || date == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		s.OfferFreedSlots(ctx, date, calendar)
+	}()
+}
+
+// appointmentCalendar is the calendar an appointment frees when it is
+// canceled or moved: its location, or "" for the general calendar.
+func appointmentCalendar(appointment models.Appointment) string {
+	if appointment.Location == nil {
+		return ""
+	}
+	return appointment.Location.ID
+}
+
+func (s *Server) AdminListWaitlist(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	limit, offset, err := httpx.ParseLimitOffset(r.URL.Query(), 50, 200)
+	if err != nil {
+		log.Warn("admin waitlist list: invalid query", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	filter := bson.M{}
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+		filter["status"] = bson.M{"$in": []string{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}}
+	case models.WaitlistStatusWaiting, models.WaitlistStatusOffered, models.WaitlistStatusBooked,
+		models.WaitlistStatusCanceled, models.WaitlistStatusExpired:
+		filter["status"] = status
+	case "all":
+	default:
+		transport.WriteError(w, http.StatusBadRequest, "invalid query", map[string]string{"status": "oneof"})
+		return
+	}
+	if services := splitQueryList(r.URL.Query().Get("service")); len(services) > 0 {
+		filter["serviceId"] = bson.M{"$in": services}
+	}
+	if date := strings.TrimSpace(r.URL.Query().Get("date")); date != "" {
+		filter["from"] = bson.M{"$lte": date}
+		filter["to"] = bson.M{"$gte": date}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	total, err := s.Cols.Waitlist.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Error("admin waitlist list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	var items []models.WaitlistEntry
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+	if err := s.findAll(ctx, s.Cols.Waitlist, filter, opts, &items); err != nil {
+		log.Error("admin waitlist list: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+	if items == nil {
+		items = []models.WaitlistEntry{}
+	}
+
+	transport.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"items":  items,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+	})
+}
+
+func (s *Server) AdminDeleteWaitlistEntry(w http.ResponseWriter, r *http.Request) {
+	log := s.logWithRequest(r)
+	id := chi.URLParam(r, "id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":    id,
+		"status": bson.M{"$in": []string{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}},
+	}
+	if err := s.cancelWaitlistEntry(ctx, filter); err != nil {
+		if err == mongo.ErrNoDocuments {
+			log.Warn("admin waitlist delete: not found", slog.String("entry_id", id))
+			transport.WriteError(w, http.StatusNotFound, "waitlist entry not found", nil)
+			return
+		}
+		log.Error("admin waitlist delete: database error", slog.String("error", err.Error()))
+		transport.WriteError(w, http.StatusInternalServerError, "database error", nil)
+		return
+	}
+
+	log.Info("admin waitlist delete: ok", slog.String("entry_id", id))
+	transport.WriteJSON(w, http.StatusOK, map[string]string{"status": models.WaitlistStatusCanceled})
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func newWaitlistTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := newMongoTestServer(t)
+	s.Cfg.WaitlistClaimURL = "https://example.com/waitlist/claim"
+	s.Mailer = &recordingMailer{}
+	return s
+}
+
+func insertWaitlistEntries(t *testing.T, s *Server, entries ...models.WaitlistEntry) {
+	t.Helper()
+	now := time.Now()
+	for i, entry := range entries {
+		// Entries queue in the order given.
+		entry.Status = models.WaitlistStatusWaiting
+		entry.CreatedAt = now.Add(time.Duration(i) * time.Millisecond)
+		entry.UpdatedAt = entry.CreatedAt
+		if _, err := s.Cols.Waitlist.InsertOne(context.Background(), entry); err != nil {
+			t.Fatalf("insert waitlist entry %s: %v", entry.ID, err)
+		}
+	}
+}
+
+func findWaitlistEntry(t *testing.T, s *Server, id string) models.WaitlistEntry {
+	t.Helper()
+	var entry models.WaitlistEntry
+	if err := s.Cols.Waitlist.FindOne(context.Background(), bson.M{"_id": id}).Decode(&entry); err != nil {
+		t.Fatalf("find waitlist entry %s: %v", id, err)
+	}
+	return entry
+}
+
+// setWaitlistOffer replaces the offer of an entry by one whose token is
+// known to the test.
+func setWaitlistOffer(t *testing.T, s *Server, id, date, slot string, expiresAt time.Time) string {
+	t.Helper()
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		t.Fatalf("NewOpaqueToken() error = %v", err)
+	}
+	offer := models.WaitlistOffer{Date: date, Time: slot, TokenHash: hash, OfferedAt: time.Now(), ExpiresAt: expiresAt}
+	update := bson.M{"$set": bson.M{"status": models.WaitlistStatusOffered, "offer": offer}}
+	if _, err := s.Cols.Waitlist.UpdateOne(context.Background(), bson.M{"_id": id}, update); err != nil {
+		t.Fatalf("set offer of %s: %v", id, err)
+	}
+	return token
+}
+
+func claimWaitlistOffer(s *Server, token string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"token":"` + token + `"}`)
+	s.ClaimWaitlistOffer(rec, httptest.NewRequest(http.MethodPost, "/api/waitlist/claim", body))
+	return rec
+}
+
+func TestWaitlistOffersFollowTheEntryLocation(t *testing.T) {
+	s := newWaitlistTestServer(t)
+	ctx := context.Background()
+	location := models.Location{
+		ID:      "loc-wl",
+		Name:    "Agence Gombe",
+		Address: "Avenue du Commerce 12",
+		Rooms:   []models.Room{{ID: "room-a", Name: "A"}},
+		Active:  true,
+	}
+	if _, err := s.Cols.Locations.InsertOne(ctx, location); err != nil {
+		t.Fatalf("insert location: %v", err)
+	}
+	if _, err := s.Cols.Services.InsertOne(ctx, models.Service{ID: "svc-wl", Name: "Consultation", LocationIDs: []string{location.ID}}); err != nil {
+		t.Fatalf("insert service: %v", err)
+	}
+	date := nextWeekday(s.Cfg.Timezone)
+	entry := models.WaitlistEntry{
+		ServiceID: "svc-wl",
+		Name:      "Jean Test",
+		Email:     "jean@example.com",
+		Phone:     "+243810000000",
+		Channel:   models.WaitlistChannelEmail,
+		Type:      models.ConsultationPresentiel,
+		Duration:  45,
+		From:      date,
+		To:        date,
+	}
+	general, atLocation := entry, entry
+	general.ID = "wl-general"
+	atLocation.ID, atLocation.LocationID = "wl-location", location.ID
+	insertWaitlistEntries(t, s, general, atLocation)
+
+	// Time freed at the location is only offered to its own queue.
+	s.OfferFreedSlots(ctx, date, location.ID)
+	if got := findWaitlistEntry(t, s, general.ID); got.Status != models.WaitlistStatusWaiting {
+		t.Fatalf("general entry status = %q, want waiting", got.Status)
+	}
+	offered := findWaitlistEntry(t, s, atLocation.ID)
+	if offered.Status != models.WaitlistStatusOffered || offered.Offer == nil {
+		t.Fatalf("location entry = %+v, want an offer", offered)
+	}
+
+	// The only room is taken in the meantime: the claim is refused.
+	busy := models.Appointment{
+		ID:       "apt-room-a",
+		Date:     date,
+		Time:     offered.Offer.Time,
+		Duration: 45,
+		Status:   models.AppointmentStatusBooked,
+		Location: &models.AppointmentLocation{ID: location.ID, RoomID: "room-a"},
+	}
+	if _, err := s.Cols.Appointments.InsertOne(ctx, busy); err != nil {
+		t.Fatalf("insert appointment: %v", err)
+	}
+	token := setWaitlistOffer(t, s, atLocation.ID, date, offered.Offer.Time, time.Now().Add(time.Hour))
+	if rec := claimWaitlistOffer(s, token); rec.Code != http.StatusConflict {
+		t.Fatalf("claim of a taken room: status %d, want 409", rec.Code)
+	}
+
+	if _, err := s.Cols.Appointments.DeleteOne(ctx, bson.M{"_id": busy.ID}); err != nil {
+		t.Fatalf("delete appointment: %v", err)
+	}
+	token = setWaitlistOffer(t, s, atLocation.ID, date, offered.Offer.Time, time.Now().Add(time.Hour))
+	if rec := claimWaitlistOffer(s, token); rec.Code != http.StatusCreated {
+		t.Fatalf("claim: status %d: %s", rec.Code, rec.Body.String())
+	}
+	booked := findWaitlistEntry(t, s, atLocation.ID)
+	var appointment models.Appointment
+	if err := s.Cols.Appointments.FindOne(ctx, bson.M{"_id": booked.AppointmentID}).Decode(&appointment); err != nil {
+		t.Fatalf("find claimed appointment: %v", err)
+	}
+	if appointment.Location == nil || appointment.Location.ID != location.ID || appointment.Location.RoomID != "room-a" {
+		t.Fatalf("claimed appointment location = %+v", appointment.Location)
+	}
+}
+
+func TestWaitlistClaimAndExpiry(t *testing.T) {
+	s := newWaitlistTestServer(t)
+	ctx := context.Background()
+	if _, err := s.Cols.Services.InsertOne(ctx, models.Service{ID: "svc-test", Name: "Consultation"}); err != nil {
+		t.Fatalf("insert service: %v", err)
+	}
+	date := nextWeekday(s.Cfg.Timezone)
+	day, _ := time.Parse("2006-01-02", date)
+	yesterday := time.Now().In(s.Cfg.Timezone).AddDate(0, 0, -1).Format("2006-01-02")
+	entry := models.WaitlistEntry{
+		ServiceID:     "svc-test",
+		Name:          "Jean Test",
+		Phone:         "+243810000000",
+		Channel:       models.WaitlistChannelEmail,
+		Type:          models.ConsultationPresentiel,
+		Duration:      45,
+		PaymentMethod: models.PaymentPlace,
+		From:          date,
+		To:            day.AddDate(0, 0, 7).Format("2006-01-02"),
+	}
+	var entries []models.WaitlistEntry
+	for _, id := range []string{"wl-1", "wl-2", "wl-3", "wl-over"} {
+		e := entry
+		e.ID, e.Email = id, id+"@example.com"
+		entries = append(entries, e)
+	}
+	entries[3].From, entries[3].To = yesterday, yesterday
+	insertWaitlistEntries(t, s, entries...)
+
+	// Two customers hold links to the same slot: the first claim wins.
+	first := setWaitlistOffer(t, s, "wl-1", date, "09:00", time.Now().Add(time.Hour))
+	second := setWaitlistOffer(t, s, "wl-2", date, "09:00", time.Now().Add(time.Hour))
+	if rec := claimWaitlistOffer(s, first); rec.Code != http.StatusCreated {
+		t.Fatalf("first claim: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := claimWaitlistOffer(s, first); rec.Code != http.StatusBadRequest {
+		t.Fatalf("claim twice: status %d, want 400", rec.Code)
+	}
+	if rec := claimWaitlistOffer(s, second); rec.Code != http.StatusConflict {
+		t.Fatalf("second claim: status %d, want 409", rec.Code)
+	}
+	if got := findWaitlistEntry(t, s, "wl-1"); got.Status != models.WaitlistStatusBooked || got.AppointmentID == "" {
+		t.Fatalf("winner = %+v", got)
+	}
+	if got := findWaitlistEntry(t, s, "wl-2"); got.Status != models.WaitlistStatusWaiting || got.Offer != nil {
+		t.Fatalf("loser = %+v, want back in the queue", got)
+	}
+
+	// An expired link stops working and its slot goes to the next in line.
+	expired := setWaitlistOffer(t, s, "wl-3", date, "09:45", time.Now().Add(-time.Minute))
+	if rec := claimWaitlistOffer(s, expired); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expired claim: status %d, want 400", rec.Code)
+	}
+	s.ExpireWaitlistOffers(ctx, time.Now())
+	if got := findWaitlistEntry(t, s, "wl-2"); got.Offer == nil || got.Offer.Date != date || got.Offer.Time != "09:45" {
+		t.Fatalf("next in line = %+v, want an offer for 09:45", got)
+	}
+	if got := findWaitlistEntry(t, s, "wl-3"); got.Offer == nil || got.Offer.Time == "09:45" {
+		t.Fatalf("expired entry = %+v, want a later slot", got)
+	}
+	if got := findWaitlistEntry(t, s, "wl-over"); got.Status != models.WaitlistStatusExpired {
+		t.Fatalf("entry past its period: status %q, want expired", got.Status)
+	}
+}
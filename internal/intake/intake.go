@@ -0,0 +1,295 @@
+// Package intake validates service questionnaires and the answers given to
+// them when booking.
+package intake
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gbh-backend/internal/models"
+)
+
+const (
+	MaxQuestions     = 20
+	MaxOptions       = 30
+	MaxLabelLength   = 200
+	DefaultMaxLength = 2000
+	MaxTextLength    = 5000
+	// MaxFileBytes bounds one uploaded document.
+	MaxFileBytes = 5 << 20
+)
+
+var questionID = regexp.MustCompile(`^[a-z0-9_-]{1,40}$`)
+
+// ValidateSchema checks a questionnaire defined by an admin. Problems are
+// keyed "intake[i].field", like validation details elsewhere in the API.
+func ValidateSchema(questions []models.IntakeQuestion) map[string]string {
+	details := map[string]string{}
+	if len(questions) > MaxQuestions {
+		details["intake"] = "max"
+		return details
+	}
+	seen := map[string]bool{}
+	for i, q := range questions {
+		key := fmt.Sprintf("intake[%d]", i)
+		switch {
+		case !questionID.MatchString(q.ID):
+			details[key+".id"] = "format"
+		case seen[q.ID]:
+			details[key+".id"] = "unique"
+		}
+		seen[q.ID] = true
+
+		label := strings.TrimSpace(q.Label)
+		if label == "" {
+			details[key+".label"] = "required"
+		} else if utf8.RuneCountInString(label) > MaxLabelLength {
+			details[key+".label"] = "max"
+		}
+
+		switch q.Type {
+		case models.IntakeTypeText:
+			if q.MaxLength < 0 || q.MaxLength > MaxTextLength {
+				details[key+".maxLength"] = "max"
+			}
+		case models.IntakeTypeChoice:
+			if len(q.Options) < 2 || len(q.Options) > MaxOptions {
+				details[key+".options"] = "len"
+				continue
+			}
+			options := map[string]bool{}
+			for _, option := range q.Options {
+				option = strings.TrimSpace(option)
+				if option == "" || options[option] {
+					details[key+".options"] = "unique"
+					break
+				}
+				options[option] = true
+			}
+		case models.IntakeTypeFile:
+			for _, accept := range q.Accept {
+				if _, _, err := mime.ParseMediaType(accept); err != nil || !strings.Contains(accept, "/") {
+					details[key+".accept"] = "format"
+					break
+				}
+			}
+		default:
+			details[key+".type"] = "oneof"
+		}
+		if q.Type != models.IntakeTypeChoice && (len(q.Options) > 0 || q.Multiple) {
+			details[key+".options"] = "excluded"
+		}
+		if q.Type != models.IntakeTypeFile && len(q.Accept) > 0 {
+			details[key+".accept"] = "excluded"
+		}
+	}
+	if len(details) == 0 {
+		return nil
+	}
+	return details
+}
+
+// Normalize trims labels and options so answers compare exactly.
+func Normalize(questions []models.IntakeQuestion) []models.IntakeQuestion {
+	out := make([]models.IntakeQuestion, 0, len(questions))
+	for _, q := range questions {
+		q.Label = strings.TrimSpace(q.Label)
+		q.Help = strings.TrimSpace(q.Help)
+		for i := range q.Options {
+			q.Options[i] = strings.TrimSpace(q.Options[i])
+		}
+		for i := range q.Accept {
+			q.Accept[i] = strings.ToLower(strings.TrimSpace(q.Accept[i]))
+		}
+		out = append(out, q)
+	}
+	return out
+}
+
+// Answers checks raw answers, keyed by question ID, against questions. It
+// returns the answers to store in questionnaire order and, for file
+// questions, the upload token given for each question ID; resolving those
+// tokens is left to the caller. When enforceRequired is false (an admin
+// booking for a customer) required questions may be left out. Problems are
+// keyed "intake.<question id>".
+func Answers(questions []models.IntakeQuestion, raw map[string]interface{}, enforceRequired bool) ([]models.IntakeAnswer, map[string]string, map[string]string) {
+	details := map[string]string{}
+	known := make(map[string]bool, len(questions))
+	var answers []models.IntakeAnswer
+	files := map[string]string{}
+
+	for _, q := range questions {
+		known[q.ID] = true
+		key := "intake." + q.ID
+		value, present := raw[q.ID]
+		if !present || isEmpty(value) {
+			if q.Required && enforceRequired {
+				details[key] = "required"
+			}
+			continue
+		}
+		answer := models.IntakeAnswer{QuestionID: q.ID, Label: q.Label, Type: q.Type}
+
+		switch q.Type {
+		case models.IntakeTypeText:
+			text, ok := value.(string)
+			if !ok {
+				details[key] = "type"
+				continue
+			}
+			text = strings.TrimSpace(text)
+			maxLength := q.MaxLength
+			if maxLength == 0 {
+				maxLength = DefaultMaxLength
+			}
+			if utf8.RuneCountInString(text) > maxLength {
+				details[key] = "max"
+				continue
+			}
+			answer.Value = text
+		case models.IntakeTypeChoice:
+			picked, ok := choices(value, q.Multiple)
+			if !ok {
+				details[key] = "type"
+				continue
+			}
+			if !allIn(picked, q.Options) {
+				details[key] = "oneof"
+				continue
+			}
+			if q.Multiple {
+				answer.Values = picked
+			} else {
+				answer.Value = picked[0]
+			}
+		case models.IntakeTypeFile:
+			token, ok := value.(string)
+			if !ok {
+				details[key] = "type"
+				continue
+			}
+			files[q.ID] = strings.TrimSpace(token)
+		}
+		answers = append(answers, answer)
+	}
+	for id := range raw {
+		if !known[id] {
+			details["intake."+id] = "unknown"
+		}
+	}
+	if len(details) == 0 {
+		details = nil
+	}
+	return answers, files, details
+}
+
+// Accepts reports whether a file of contentType may answer q. A question
+// without Accept takes any file.
+func Accepts(q models.IntakeQuestion, contentType string) bool {
+	if len(q.Accept) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, accept := range q.Accept {
+		if accept == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(accept, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the question with id.
+func Find(questions []models.IntakeQuestion, id string) (models.IntakeQuestion, bool) {
+	for _, q := range questions {
+		if q.ID == id {
+			return q, true
+		}
+	}
+	return models.IntakeQuestion{}, false
+}
+
+// Summary renders answers as "Label: value" lines, for exports and plain
+// text notifications.
+func Summary(answers []models.IntakeAnswer) []string {
+	lines := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		lines = append(lines, answer.Label+": "+DisplayValue(answer))
+	}
+	return lines
+}
+
+// DisplayValue is the human readable value of an answer.
+func DisplayValue(answer models.IntakeAnswer) string {
+	switch {
+	case answer.File != nil:
+		return answer.File.Name
+	case len(answer.Values) > 0:
+		return strings.Join(answer.Values, ", ")
+	}
+	return answer.Value
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// choices reads a choice answer: a string, or a list of strings when
+// several options may be picked.
+func choices(value interface{}, multiple bool) ([]string, bool) {
+	switch v := value.(type) {
+	case string:
+		return []string{strings.TrimSpace(v)}, true
+	case []interface{}:
+		if !multiple {
+			return nil, false
+		}
+		picked := make([]string, 0, len(v))
+		seen := map[string]bool{}
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			s = strings.TrimSpace(s)
+			if !seen[s] {
+				seen[s] = true
+				picked = append(picked, s)
+			}
+		}
+		return picked, true
+	}
+	return nil, false
+}
+
+func allIn(values, options []string) bool {
+	for _, value := range values {
+		found := false
+		for _, option := range options {
+			if value == option {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package intake
+
+import (
+	"reflect"
+	"testing"
+
+	"gbh-backend/internal/models"
+)
+
+var questionnaire = []models.IntakeQuestion{
+	{ID: "goal", Label: "Objectif", Type: models.IntakeTypeText, Required: true, MaxLength: 20},
+	{ID: "sector", Label: "Secteur", Type: models.IntakeTypeChoice, Options: []string{"Mines", "Banque", "ONG"}},
+	{ID: "topics", Label: "Sujets", Type: models.IntakeTypeChoice, Multiple: true, Options: []string{"Fiscalite", "RH", "Statuts"}},
+	{ID: "statutes", Label: "Statuts", Type: models.IntakeTypeFile, Accept: []string{"application/pdf", "image/*"}},
+}
+
+func TestValidateSchema(t *testing.T) {
+	if details := ValidateSchema(questionnaire); details != nil {
+		t.Fatalf("ValidateSchema(valid) = %v", details)
+	}
+
+	invalid := []models.IntakeQuestion{
+		{ID: "Goal!", Label: "Objectif", Type: models.IntakeTypeText},
+		{ID: "a", Label: "", Type: "date"},
+		{ID: "a", Label: "Choix", Type: models.IntakeTypeChoice, Options: []string{"Oui"}},
+		{ID: "b", Label: "Texte", Type: models.IntakeTypeText, Options: []string{"x", "y"}},
+		{ID: "c", Label: "Fichier", Type: models.IntakeTypeFile, Accept: []string{"pdf"}},
+	}
+	want := map[string]string{
+		"intake[0].id":      "format",
+		"intake[1].label":   "required",
+		"intake[1].type":    "oneof",
+		"intake[2].id":      "unique",
+		"intake[2].options": "len",
+		"intake[3].options": "excluded",
+		"intake[4].accept":  "format",
+	}
+	if got := ValidateSchema(invalid); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ValidateSchema(invalid) = %v, want %v", got, want)
+	}
+}
+
+func TestAnswers(t *testing.T) {
+	raw := map[string]interface{}{
+		"goal":     " Creer une SARL ",
+		"sector":   "Banque",
+		"topics":   []interface{}{"RH", "Statuts", "RH"},
+		"statutes": "tok123",
+	}
+	answers, files, details := Answers(questionnaire, raw, true)
+	if details != nil {
+		t.Fatalf("Answers() details = %v", details)
+	}
+	if len(answers) != 4 || answers[0].Value != "Creer une SARL" || answers[1].Value != "Banque" {
+		t.Fatalf("Answers() = %+v", answers)
+	}
+	if !reflect.DeepEqual(answers[2].Values, []string{"RH", "Statuts"}) {
+		t.Fatalf("multiple choice = %v", answers[2].Values)
+	}
+	if files["statutes"] != "tok123" {
+		t.Fatalf("files = %v", files)
+	}
+
+	_, _, details = Answers(questionnaire, map[string]interface{}{
+		"sector": []interface{}{"Mines"},
+		"topics": []interface{}{"Droit"},
+		"extra":  "x",
+	}, true)
+	want := map[string]string{
+		"intake.goal":   "required",
+		"intake.sector": "type",
+		"intake.topics": "oneof",
+		"intake.extra":  "unknown",
+	}
+	if !reflect.DeepEqual(details, want) {
+		t.Fatalf("Answers() details = %v, want %v", details, want)
+	}
+
+	if _, _, details := Answers(questionnaire, map[string]interface{}{"goal": "beaucoup trop long pour la limite"}, true); details["intake.goal"] != "max" {
+		t.Fatalf("Answers() long text details = %v", details)
+	}
+	if _, _, details := Answers(questionnaire, nil, false); details != nil {
+		t.Fatalf("Answers() without required = %v", details)
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	q := questionnaire[3]
+	for contentType, want := range map[string]bool{
+		"application/pdf":           true,
+		"image/png":                 true,
+		"text/plain; charset=utf-8": false,
+		"application/zip":           false,
+	} {
+		if got := Accepts(q, contentType); got != want {
+			t.Errorf("Accepts(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package meeting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/models"
+)
+
+// HostedProvider delegates meeting creation to an HTTP endpoint, typically a
+// small bridge in front of Zoom, Teams or Google Meet. The endpoint receives
+// a JSON description of the appointment and answers with the meeting details.
+//
+// Request:  {"appointment_id","title","start","duration_minutes"}
+// Response: {"id","url","passcode"}
+type HostedProvider struct {
+	endpoint string
+	apiKey   string
+	http     *http.Client
+}
+
+func NewHostedProvider(endpoint, apiKey string, httpClient *http.Client) (*HostedProvider, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return nil, ErrNotConfigured
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HostedProvider{
+		endpoint: endpoint,
+		apiKey:   apiKey,
+		http:     httpClient,
+	}, nil
+}
+
+func (p *HostedProvider) Name() string {
+	return ProviderHosted
+}
+
+type hostedRequest struct {
+	AppointmentID   string `json:"appointment_id"`
+	Title           string `json:"title"`
+	Start           string `json:"start"`
+	DurationMinutes int    `json:"duration_minutes"`
+}
+
+type hostedResponse struct {
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	Passcode string `json:"passcode"`
+}
+
+func (p *HostedProvider) CreateMeeting(ctx context.Context, req Request) (models.MeetingLink, error) {
+	payload, err := json.Marshal(hostedRequest{
+		AppointmentID:   req.AppointmentID,
+		Title:           req.Title,
+		Start:           req.Start.Format(time.RFC3339),
+		DurationMinutes: int(req.Duration.Minutes()),
+	})
+	if err != nil {
+		return models.MeetingLink{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return models.MeetingLink{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.http.Do(httpReq)
+	if err != nil {
+		return models.MeetingLink{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return models.MeetingLink{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return models.MeetingLink{}, fmt.Errorf("meeting: provider returned %d", resp.StatusCode)
+	}
+
+	var out hostedResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return models.MeetingLink{}, fmt.Errorf("meeting: invalid provider response: %w", err)
+	}
+	if strings.TrimSpace(out.URL) == "" {
+		return models.MeetingLink{}, errors.New("meeting: provider response without url")
+	}
+	return models.MeetingLink{
+		Provider: ProviderHosted,
+		ID:       out.ID,
+		URL:      out.URL,
+		Passcode: out.Passcode,
+	}, nil
+}
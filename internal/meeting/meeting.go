@@ -0,0 +1,91 @@
+package meeting
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"gbh-backend/internal/models"
+)
+
+const (
+	ProviderJitsi  = "jitsi"
+	ProviderHosted = "hosted"
+)
+
+var ErrNotConfigured = errors.New("meeting provider not configured")
+
+// Request describes the meeting to create for an appointment.
+type Request struct {
+	AppointmentID string
+	Title         string
+	Start         time.Time
+	Duration      time.Duration
+}
+
+// Provider creates online meeting rooms. A new call for the same appointment
+// returns a new link; the previous one is simply no longer shared.
+type Provider interface {
+	Name() string
+	CreateMeeting(ctx context.Context, req Request) (models.MeetingLink, error)
+}
+
+// JitsiProvider builds room URLs on a Jitsi Meet server. Rooms exist as soon
+// as someone joins, so no API call is needed; the random suffix keeps room
+// names unguessable.
+type JitsiProvider struct {
+	baseURL    string
+	roomPrefix string
+}
+
+func NewJitsiProvider(baseURL, roomPrefix string) (*JitsiProvider, error) {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("meeting: invalid jitsi base url")
+	}
+	return &JitsiProvider{
+		baseURL:    baseURL,
+		roomPrefix: strings.Trim(strings.TrimSpace(roomPrefix), "-"),
+	}, nil
+}
+
+func (p *JitsiProvider) Name() string {
+	return ProviderJitsi
+}
+
+func (p *JitsiProvider) CreateMeeting(ctx context.Context, req Request) (models.MeetingLink, error) {
+	suffix, err := randomSuffix()
+	if err != nil {
+		return models.MeetingLink{}, err
+	}
+	parts := make([]string, 0, 3)
+	if p.roomPrefix != "" {
+		parts = append(parts, p.roomPrefix)
+	}
+	if req.AppointmentID != "" {
+		parts = append(parts, req.AppointmentID)
+	}
+	parts = append(parts, suffix)
+	room := strings.Join(parts, "-")
+
+	return models.MeetingLink{
+		Provider: ProviderJitsi,
+		ID:       room,
+		URL:      p.baseURL + "/" + url.PathEscape(room),
+	}, nil
+}
+
+var roomEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func randomSuffix() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return strings.ToLower(roomEncoding.EncodeToString(buf)), nil
+}
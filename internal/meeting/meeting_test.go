@@ -0,0 +1,63 @@
+package meeting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJitsiProviderCreatesDistinctRooms(t *testing.T) {
+	provider, err := NewJitsiProvider("https://meet.example.org/", "gbh")
+	if err != nil {
+		t.Fatalf("NewJitsiProvider() error = %v", err)
+	}
+	req := Request{AppointmentID: "RDV-1", Start: time.Now(), Duration: 45 * time.Minute}
+
+	first, err := provider.CreateMeeting(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateMeeting() error = %v", err)
+	}
+	second, err := provider.CreateMeeting(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateMeeting() error = %v", err)
+	}
+
+	if !strings.HasPrefix(first.URL, "https://meet.example.org/gbh-RDV-1-") {
+		t.Fatalf("unexpected url %q", first.URL)
+	}
+	if first.URL == second.URL {
+		t.Fatalf("expected a new room on each call, got %q twice", first.URL)
+	}
+}
+
+func TestHostedProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body hostedRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.DurationMinutes != 30 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(hostedResponse{ID: "m-1", URL: "https://video.example.org/m-1", Passcode: "4821"})
+	}))
+	defer server.Close()
+
+	provider, err := NewHostedProvider(server.URL, "secret", server.Client())
+	if err != nil {
+		t.Fatalf("NewHostedProvider() error = %v", err)
+	}
+	link, err := provider.CreateMeeting(context.Background(), Request{AppointmentID: "RDV-2", Start: time.Now(), Duration: 30 * time.Minute})
+	if err != nil {
+		t.Fatalf("CreateMeeting() error = %v", err)
+	}
+	if link.Provider != ProviderHosted || link.URL != "https://video.example.org/m-1" || link.Passcode != "4821" {
+		t.Fatalf("unexpected link %+v", link)
+	}
+}
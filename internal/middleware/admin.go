@@ -1,41 +1,96 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
 	"strings"
 
 	"gbh-backend/internal/auth"
 	"gbh-backend/internal/models"
+	"gbh-backend/internal/rbac"
 	"gbh-backend/internal/transport"
 )
 
-func AdminAuth(adminKey string, manager *auth.Manager) func(http.Handler) http.Handler {
+// Principal is the authenticated caller of an admin route.
+type Principal struct {
+	UserID    string
+	Username  string
+	Role      string
+	TokenID   string
+	SessionID string
+	// Permissions are those of Role at the time of the request.
+	Permissions []string
+	// TwoFactor is set when the user has TOTP enabled.
+	TwoFactor bool
+	// APIKey is set when X-Admin-Key was used instead of a user token.
+	// APIKeyID is empty for the legacy shared ADMIN_API_KEY.
+	APIKey   bool
+	APIKeyID string
+}
+
+// Can reports whether the principal holds permission p.
+func (p Principal) Can(permission string) bool {
+	return rbac.Allows(p.Permissions, permission)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// Directory resolves what an admin token points at.
+type Directory interface {
+	// LookupUser loads the user a token was issued to; found is false when
+	// the user no longer exists.
+	LookupUser(ctx context.Context, id string) (user models.User, found bool, err error)
+	// SessionActive reports whether a login session is still active (not
+	// logged out, revoked or expired).
+	SessionActive(ctx context.Context, id string) (active bool, err error)
+	// RolePermissions returns the permissions of a role; found is false for
+	// an unknown role.
+	RolePermissions(ctx context.Context, role string) (permissions []string, found bool, err error)
+	// AuthenticateAPIKey returns the active (not revoked, not expired) key
+	// matching the raw key and records its use.
+	AuthenticateAPIKey(ctx context.Context, key, ip string) (apiKey models.APIKey, found bool, err error)
+}
+
+func AdminAuth(adminKey string, manager *auth.Manager, dir Directory) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if adminKey == "" && manager == nil {
+			if adminKey == "" && manager == nil && dir == nil {
 				transport.WriteError(w, http.StatusServiceUnavailable, "admin auth not configured", nil)
 				return
 			}
 
-			if adminKey != "" && r.Header.Get("X-Admin-Key") == adminKey {
-				next.ServeHTTP(w, r)
-				return
+			if key := r.Header.Get("X-Admin-Key"); key != "" {
+				principal, status := apiKeyPrincipal(r, key, adminKey, dir)
+				if status == http.StatusOK {
+					next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+					return
+				}
+				if status == http.StatusInternalServerError {
+					transport.WriteError(w, status, "database error", nil)
+					return
+				}
 			}
 
 			if manager != nil {
-				cookie, err := r.Cookie("gbh_access")
-				if err == nil && cookie.Value != "" {
-					claims, err := manager.Parse(cookie.Value)
-					if err == nil && claims.Role == models.UserRoleAdmin {
-						next.ServeHTTP(w, r)
+				claims := adminClaims(r, manager)
+				if claims != nil {
+					principal, status := resolvePrincipal(r.Context(), claims, dir)
+					if status == http.StatusOK {
+						next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
 						return
 					}
-				}
-
-				if token := bearerToken(r.Header.Get("Authorization")); token != "" {
-					claims, err := manager.Parse(token)
-					if err == nil && claims.Role == models.UserRoleAdmin {
-						next.ServeHTTP(w, r)
+					if status == http.StatusInternalServerError {
+						transport.WriteError(w, status, "database error", nil)
 						return
 					}
 				}
@@ -46,6 +101,128 @@ func AdminAuth(adminKey string, manager *auth.Manager) func(http.Handler) http.H
 	}
 }
 
+// apiKeyPrincipal resolves X-Admin-Key: the legacy shared key grants every
+// permission, managed keys grant their scopes.
+func apiKeyPrincipal(r *http.Request, key, adminKey string, dir Directory) (Principal, int) {
+	if adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1 {
+		return Principal{
+			Username:    "api-key",
+			Role:        rbac.RoleAdmin,
+			Permissions: []string{rbac.Wildcard},
+			APIKey:      true,
+		}, http.StatusOK
+	}
+	if dir == nil {
+		return Principal{}, http.StatusUnauthorized
+	}
+	apiKey, found, err := dir.AuthenticateAPIKey(r.Context(), key, ClientIP(r))
+	if err != nil {
+		return Principal{}, http.StatusInternalServerError
+	}
+	if !found {
+		return Principal{}, http.StatusUnauthorized
+	}
+	return Principal{
+		Username:    "api-key:" + apiKey.Name,
+		Permissions: apiKey.Scopes,
+		APIKey:      true,
+		APIKeyID:    apiKey.ID,
+	}, http.StatusOK
+}
+
+// adminClaims returns the first valid access token from the access cookie or
+// the Authorization header. Refresh tokens are not accepted here.
+func adminClaims(r *http.Request, manager *auth.Manager) *auth.Claims {
+	candidates := make([]string, 0, 2)
+	if cookie, err := r.Cookie("gbh_access"); err == nil && cookie.Value != "" {
+		candidates = append(candidates, cookie.Value)
+	}
+	if token := bearerToken(r.Header.Get("Authorization")); token != "" {
+		candidates = append(candidates, token)
+	}
+	for _, token := range candidates {
+		claims, err := manager.Parse(token)
+		if err == nil && claims.Type == auth.TokenTypeAccess {
+			return claims
+		}
+	}
+	return nil
+}
+
+// resolvePrincipal checks that the token's session is still active and its
+// user still exists and is enabled, then loads the permissions of the user's
+// current role. Tokens issued without a subject or session are refused.
+func resolvePrincipal(ctx context.Context, claims *auth.Claims, dir Directory) (Principal, int) {
+	if claims.Subject == "" || claims.SessionID == "" {
+		return Principal{}, http.StatusUnauthorized
+	}
+	principal := Principal{
+		UserID:    claims.Subject,
+		Username:  claims.Username,
+		Role:      claims.Role,
+		TokenID:   claims.ID,
+		SessionID: claims.SessionID,
+	}
+	if dir == nil {
+		principal.Permissions, _ = rbac.Builtin(claims.Role)
+		return principal, http.StatusOK
+	}
+
+	active, err := dir.SessionActive(ctx, claims.SessionID)
+	if err != nil {
+		return Principal{}, http.StatusInternalServerError
+	}
+	if !active {
+		return Principal{}, http.StatusUnauthorized
+	}
+
+	user, found, err := dir.LookupUser(ctx, claims.Subject)
+	if err != nil {
+		return Principal{}, http.StatusInternalServerError
+	}
+	if !found || user.DisabledAt != nil {
+		return Principal{}, http.StatusUnauthorized
+	}
+	permissions, found, err := dir.RolePermissions(ctx, user.Role)
+	if err != nil {
+		return Principal{}, http.StatusInternalServerError
+	}
+	if !found {
+		return Principal{}, http.StatusUnauthorized
+	}
+	principal.Username = user.Username
+	principal.Role = user.Role
+	principal.Permissions = permissions
+	principal.TwoFactor = user.TOTPEnabledAt != nil
+	return principal, http.StatusOK
+}
+
+// RequirePermission lets the request through only if the principal set by
+// AdminAuth holds permission p.
+func RequirePermission(p string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				transport.WriteError(w, http.StatusUnauthorized, "unauthorized", nil)
+				return
+			}
+			if !principal.Can(p) {
+				transport.WriteError(w, http.StatusForbidden, "forbidden", map[string]string{"permission": p})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HasPermission reports whether the caller on ctx holds permission p, for
+// handlers that guard individual fields rather than whole routes.
+func HasPermission(ctx context.Context, p string) bool {
+	principal, ok := PrincipalFromContext(ctx)
+	return ok && principal.Can(p)
+}
+
 func bearerToken(authHeader string) string {
 	authHeader = strings.TrimSpace(authHeader)
 	if authHeader == "" {
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/auth"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/rbac"
+)
+
+type fakeDirectory struct {
+	users map[string]models.User
+	roles map[string][]string
+	keys  map[string]models.APIKey
+}
+
+func (d fakeDirectory) LookupUser(ctx context.Context, id string) (models.User, bool, error) {
+	user, ok := d.users[id]
+	return user, ok, nil
+}
+
+func (d fakeDirectory) SessionActive(ctx context.Context, id string) (bool, error) {
+	return id == "s1", nil
+}
+
+func (d fakeDirectory) AuthenticateAPIKey(ctx context.Context, key, ip string) (models.APIKey, bool, error) {
+	apiKey, ok := d.keys[key]
+	return apiKey, ok, nil
+}
+
+func (d fakeDirectory) RolePermissions(ctx context.Context, role string) ([]string, bool, error) {
+	perms, ok := d.roles[role]
+	return perms, ok, nil
+}
+
+func TestAdminAuthPutsUserOnContext(t *testing.T) {
+	manager := &auth.Manager{Secret: []byte("test-secret"), AccessTTL: time.Minute, Issuer: "test"}
+	disabledAt := time.Now()
+	dir := fakeDirectory{
+		users: map[string]models.User{
+			"u1": {ID: "u1", Username: "alice", Role: models.UserRoleAdmin},
+			"u2": {ID: "u2", Username: "bob", Role: models.UserRoleAdmin, DisabledAt: &disabledAt},
+			"u4": {ID: "u4", Username: "dave", Role: "retired"},
+		},
+		roles: map[string][]string{models.UserRoleAdmin: {rbac.Wildcard}},
+	}
+
+	var got Principal
+	handler := AdminAuth("", manager, dir)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		identity auth.Identity
+		want     int
+	}{
+		{name: "active admin", identity: auth.Identity{UserID: "u1", Username: "alice", Role: models.UserRoleAdmin, SessionID: "s1"}, want: http.StatusOK},
+		{name: "disabled admin", identity: auth.Identity{UserID: "u2", Username: "bob", Role: models.UserRoleAdmin, SessionID: "s1"}, want: http.StatusUnauthorized},
+		{name: "deleted admin", identity: auth.Identity{UserID: "u3", Username: "carol", Role: models.UserRoleAdmin, SessionID: "s1"}, want: http.StatusUnauthorized},
+		{name: "no subject", identity: auth.Identity{Role: models.UserRoleAdmin, SessionID: "s1"}, want: http.StatusUnauthorized},
+		{name: "revoked session", identity: auth.Identity{UserID: "u1", Username: "alice", Role: models.UserRoleAdmin, SessionID: "s2"}, want: http.StatusUnauthorized},
+		{name: "unknown role", identity: auth.Identity{UserID: "u4", Username: "dave", Role: "retired", SessionID: "s1"}, want: http.StatusUnauthorized},
+		{name: "no session", identity: auth.Identity{UserID: "u1", Username: "alice", Role: models.UserRoleAdmin}, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := manager.NewAccessToken(tt.identity)
+			if err != nil {
+				t.Fatalf("NewAccessToken() error = %v", err)
+			}
+			got = Principal{}
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/me", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, rec.Code)
+			}
+			if tt.want == http.StatusOK && (got.UserID != tt.identity.UserID || got.Username != "alice" || got.TokenID == "" || got.SessionID != "s1") {
+				t.Fatalf("unexpected principal %+v", got)
+			}
+		})
+	}
+}
+
+func TestAdminAuthRejectsRefreshToken(t *testing.T) {
+	manager := &auth.Manager{Secret: []byte("test-secret"), AccessTTL: time.Minute, RefreshTTL: time.Hour, Issuer: "test"}
+	handler := AdminAuth("", manager, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	token, _, err := manager.NewRefreshToken(auth.Identity{UserID: "u1", Username: "alice", Role: models.UserRoleAdmin, SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("NewRefreshToken() error = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	handler := RequirePermission(rbac.RFPWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name        string
+		permissions []string
+		want        int
+	}{
+		{name: "granted", permissions: []string{rbac.RFPRead, rbac.RFPWrite}, want: http.StatusOK},
+		{name: "area wildcard", permissions: []string{"rfp:*"}, want: http.StatusOK},
+		{name: "missing", permissions: []string{rbac.RFPRead}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/api/admin/rfp/1", nil)
+			req = req.WithContext(WithPrincipal(req.Context(), Principal{UserID: "u1", Permissions: tt.permissions}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAdminAuthAPIKeys(t *testing.T) {
+	dir := fakeDirectory{
+		keys: map[string]models.APIKey{
+			"gbh_cms": {ID: "k1", Name: "cms", Scopes: []string{rbac.ContentRead, rbac.ContentWrite}},
+		},
+	}
+	var got Principal
+	handler := AdminAuth("legacy-key", nil, dir)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name string
+		key  string
+		want int
+		can  string
+	}{
+		{name: "legacy key", key: "legacy-key", want: http.StatusOK, can: rbac.UsersWrite},
+		{name: "managed key", key: "gbh_cms", want: http.StatusOK, can: rbac.ContentWrite},
+		{name: "unknown key", key: "gbh_nope", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = Principal{}
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/references", nil)
+			req.Header.Set("X-Admin-Key", tt.key)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, rec.Code)
+			}
+			if tt.want == http.StatusOK && (!got.APIKey || !got.Can(tt.can)) {
+				t.Fatalf("unexpected principal %+v", got)
+			}
+		})
+	}
+	if got.Can(rbac.UsersWrite) {
+		t.Fatalf("managed key must not exceed its scopes")
+	}
+}
@@ -47,7 +47,8 @@ func (rl *RateLimiter) Allow(key string) bool {
 	return true
 }
 
-func clientIP(r *http.Request) string {
+// ClientIP returns the caller address, preferring the first X-Forwarded-For hop.
+func ClientIP(r *http.Request) string {
 	if xf := r.Header.Get("X-Forwarded-For"); xf != "" {
 		parts := strings.Split(xf, ",")
 		return strings.TrimSpace(parts[0])
@@ -62,7 +63,7 @@ func clientIP(r *http.Request) string {
 
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		key := clientIP(r) + ":" + r.URL.Path
+		key := ClientIP(r) + ":" + r.URL.Path
 		if !rl.Allow(key) {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusTooManyRequests)
@@ -9,13 +9,96 @@ const (
 	PaymentOnline = "online"
 	PaymentPlace  = "place"
 
-	AppointmentStatusPending  = "pending"
-	AppointmentStatusBooked   = "booked"
-	AppointmentStatusCanceled = "canceled"
+	AppointmentStatusPending            = "pending"
+	AppointmentStatusBooked             = "booked"
+	AppointmentStatusConfirmed          = "confirmed"
+	AppointmentStatusCheckedIn          = "checked_in"
+	AppointmentStatusCompleted          = "completed"
+	AppointmentStatusNoShow             = "no_show"
+	AppointmentStatusCanceledByCustomer = "canceled_by_customer"
+	AppointmentStatusCanceledByAdmin    = "canceled_by_admin"
+	// AppointmentStatusLegacyCanceled is the single canceled status used
+	// before the lifecycle was introduced; the startup migration rewrites it.
+	AppointmentStatusLegacyCanceled = "canceled"
+
+	RefundStatusPending = "pending"
 
 	UserRoleAdmin = "admin"
+
+	NotificationModeRealtime = "realtime"
+	NotificationModeDigest   = "digest"
+
+	SessionRevokedLogout          = "logout"
+	SessionRevokedReuse           = "reuse_detected"
+	SessionRevokedPasswordChanged = "password_changed"
+	SessionRevokedByAdmin         = "revoked_by_admin"
+	SessionRevokedUserDisabled    = "user_disabled"
+	SessionRevokedUserDeleted     = "user_deleted"
+
+	LoginFailedCredentials = "invalid_credentials"
+	LoginFailedTwoFactor   = "invalid_second_factor"
+	LoginFailedDisabled    = "account_disabled"
+	LoginBlockedLocked     = "account_locked"
+	LoginBlockedThrottled  = "throttled"
+	LoginBlockedIP         = "ip_blocked"
+
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusRevoked  = "revoked"
+
+	WaitlistStatusWaiting  = "waiting"
+	WaitlistStatusOffered  = "offered"
+	WaitlistStatusBooked   = "booked"
+	WaitlistStatusCanceled = "canceled"
+	WaitlistStatusExpired  = "expired"
+
+	WaitlistChannelEmail = "email"
+	WaitlistChannelSMS   = "sms"
+
+	GroupSessionStatusScheduled = "scheduled"
+	GroupSessionStatusCanceled  = "canceled"
+
+	RegistrationStatusConfirmed  = "confirmed"
+	RegistrationStatusWaitlisted = "waitlisted"
+	RegistrationStatusCanceled   = "canceled"
+
+	SeriesStatusActive   = "active"
+	SeriesStatusCanceled = "canceled"
+
+	IntakeTypeText   = "text"
+	IntakeTypeChoice = "choice"
+	IntakeTypeFile   = "file"
 )
 
+// ActiveAppointmentStatuses are the statuses that hold a time slot. Only
+// these count against availability and the unique (date, time) index.
+var ActiveAppointmentStatuses = []string{
+	AppointmentStatusPending,
+	AppointmentStatusBooked,
+	AppointmentStatusConfirmed,
+	AppointmentStatusCheckedIn,
+	AppointmentStatusCompleted,
+	AppointmentStatusNoShow,
+}
+
+// AppointmentCanceled reports whether status is one of the canceled states.
+func AppointmentCanceled(status string) bool {
+	return status == AppointmentStatusCanceledByCustomer ||
+		status == AppointmentStatusCanceledByAdmin ||
+		status == AppointmentStatusLegacyCanceled
+}
+
+// AppointmentHoldsSlot reports whether an appointment with this status keeps
+// its slot taken.
+func AppointmentHoldsSlot(status string) bool {
+	for _, active := range ActiveAppointmentStatuses {
+		if status == active {
+			return true
+		}
+	}
+	return false
+}
+
 type Service struct {
 	ID               string    `bson:"_id,omitempty" json:"id"`
 	Name             string    `bson:"name" json:"name"`
@@ -26,47 +109,401 @@ type Service struct {
 	ForAudience      string    `bson:"forAudience" json:"forAudience"`
 	Slug             string    `bson:"slug" json:"slug"`
 	CreatedAt        time.Time `bson:"createdAt" json:"createdAt"`
+	// Intake is the questionnaire customers answer when booking.
+	Intake []IntakeQuestion `bson:"intake,omitempty" json:"intake,omitempty"`
+	// LocationIDs lists the locations offering the service in person. A
+	// service without locations books presentiel appointments on the
+	// general calendar.
+	LocationIDs []string `bson:"locationIds,omitempty" json:"locationIds,omitempty"`
+}
+
+// IntakeQuestion is one question of a service questionnaire. Options lists
+// the choices of a choice question (several may be picked when Multiple is
+// set); Accept lists the content types of a file question, "image/*" style
+// wildcards included.
+type IntakeQuestion struct {
+	ID        string   `bson:"id" json:"id"`
+	Label     string   `bson:"label" json:"label"`
+	Help      string   `bson:"help,omitempty" json:"help,omitempty"`
+	Type      string   `bson:"type" json:"type"`
+	Required  bool     `bson:"required" json:"required"`
+	Options   []string `bson:"options,omitempty" json:"options,omitempty"`
+	Multiple  bool     `bson:"multiple,omitempty" json:"multiple,omitempty"`
+	MaxLength int      `bson:"maxLength,omitempty" json:"maxLength,omitempty"`
+	Accept    []string `bson:"accept,omitempty" json:"accept,omitempty"`
+}
+
+// IntakeAnswer is stored on the appointment with a copy of the question
+// label, so later edits of the questionnaire do not change past answers.
+type IntakeAnswer struct {
+	QuestionID string         `bson:"questionId" json:"questionId"`
+	Label      string         `bson:"label" json:"label"`
+	Type       string         `bson:"type" json:"type"`
+	Value      string         `bson:"value,omitempty" json:"value,omitempty"`
+	Values     []string       `bson:"values,omitempty" json:"values,omitempty"`
+	File       *IntakeFileRef `bson:"file,omitempty" json:"file,omitempty"`
+}
+
+type IntakeFileRef struct {
+	ID          string `bson:"id" json:"id"`
+	Name        string `bson:"name" json:"name"`
+	ContentType string `bson:"contentType" json:"contentType"`
+	Size        int64  `bson:"size" json:"size"`
+}
+
+// IntakeFile is a document uploaded for a file question before booking. The
+// customer only gets an opaque token; files never attached to an
+// appointment expire.
+type IntakeFile struct {
+	ID          string     `bson:"_id" json:"id"`
+	TokenHash   string     `bson:"tokenHash" json:"-"`
+	ServiceID   string     `bson:"serviceId" json:"serviceId"`
+	QuestionID  string     `bson:"questionId" json:"questionId"`
+	Name        string     `bson:"name" json:"name"`
+	ContentType string     `bson:"contentType" json:"contentType"`
+	Size        int64      `bson:"size" json:"size"`
+	Data        []byte     `bson:"data" json:"-"`
+	CreatedAt   time.Time  `bson:"createdAt" json:"createdAt"`
+	ExpiresAt   *time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	AttachedAt  *time.Time `bson:"attachedAt,omitempty" json:"attachedAt,omitempty"`
 }
 
 type User struct {
-	ID           string    `bson:"_id,omitempty" json:"id"`
-	Username     string    `bson:"username" json:"username"`
-	Email        string    `bson:"email,omitempty" json:"email,omitempty"`
-	PasswordHash string    `bson:"passwordHash" json:"-"`
-	Role         string    `bson:"role" json:"role"`
-	CreatedAt    time.Time `bson:"createdAt" json:"createdAt"`
-	UpdatedAt    time.Time `bson:"updatedAt" json:"updatedAt"`
+	ID                    string     `bson:"_id,omitempty" json:"id"`
+	Username              string     `bson:"username" json:"username"`
+	Email                 string     `bson:"email,omitempty" json:"email,omitempty"`
+	PasswordHash          string     `bson:"passwordHash" json:"-"`
+	Role                  string     `bson:"role" json:"role"`
+	NotificationMode      string     `bson:"notificationMode,omitempty" json:"notificationMode,omitempty"`
+	EmailBouncedAt        *time.Time `bson:"emailBouncedAt,omitempty" json:"emailBouncedAt,omitempty"`
+	CalendarFeedTokenHash string     `bson:"calendarFeedTokenHash,omitempty" json:"-"`
+	DisabledAt            *time.Time `bson:"disabledAt,omitempty" json:"disabledAt,omitempty"`
+	TOTPSecret            string     `bson:"totpSecret,omitempty" json:"-"`
+	TOTPPendingSecret     string     `bson:"totpPendingSecret,omitempty" json:"-"`
+	TOTPEnabledAt         *time.Time `bson:"totpEnabledAt,omitempty" json:"totpEnabledAt,omitempty"`
+	TOTPLastStep          int64      `bson:"totpLastStep,omitempty" json:"-"`
+	RecoveryCodeHashes    []string   `bson:"recoveryCodeHashes,omitempty" json:"-"`
+	FailedLogins          int        `bson:"failedLogins,omitempty" json:"failedLogins,omitempty"`
+	LastFailedLoginAt     *time.Time `bson:"lastFailedLoginAt,omitempty" json:"lastFailedLoginAt,omitempty"`
+	LockedUntil           *time.Time `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	CreatedAt             time.Time  `bson:"createdAt" json:"createdAt"`
+	UpdatedAt             time.Time  `bson:"updatedAt" json:"updatedAt"`
 }
 
-type Appointment struct {
-	ID             string     `bson:"_id,omitempty" json:"id"`
-	ServiceID      string     `bson:"serviceId" json:"serviceId"`
-	Name           string     `bson:"name" json:"name"`
+// Role is a custom back-office role. Built-in roles (see package rbac) are
+// not stored.
+type Role struct {
+	Name        string    `bson:"_id" json:"name"`
+	Description string    `bson:"description,omitempty" json:"description,omitempty"`
+	Permissions []string  `bson:"permissions" json:"permissions"`
+	CreatedAt   time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// APIKey is a named, scoped credential for an integration, sent in
+// X-Admin-Key. Only the SHA-256 hash of the key is stored.
+type APIKey struct {
+	ID          string     `bson:"_id" json:"id"`
+	Name        string     `bson:"name" json:"name"`
+	Prefix      string     `bson:"prefix" json:"prefix"`
+	KeyHash     string     `bson:"keyHash" json:"-"`
+	Scopes      []string   `bson:"scopes" json:"scopes"`
+	ExpiresAt   *time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	LastUsedIP  string     `bson:"lastUsedIp,omitempty" json:"lastUsedIp,omitempty"`
+	RotatedFrom string     `bson:"rotatedFrom,omitempty" json:"rotatedFrom,omitempty"`
+	CreatedBy   string     `bson:"createdBy,omitempty" json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `bson:"createdAt" json:"createdAt"`
+	RevokedAt   *time.Time `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// AdminSession is one admin login. Its refresh token is rotated on every
+// use; CurrentTokenID is the only token ID the session still accepts.
+type AdminSession struct {
+	ID             string     `bson:"_id" json:"id"`
+	UserID         string     `bson:"userId" json:"userId"`
+	Username       string     `bson:"username" json:"username"`
+	CurrentTokenID string     `bson:"currentTokenId" json:"-"`
+	Rotations      int        `bson:"rotations" json:"rotations"`
+	UserAgent      string     `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	IP             string     `bson:"ip,omitempty" json:"ip,omitempty"`
+	CreatedAt      time.Time  `bson:"createdAt" json:"createdAt"`
+	LastUsedAt     time.Time  `bson:"lastUsedAt" json:"lastUsedAt"`
+	ExpiresAt      time.Time  `bson:"expiresAt" json:"expiresAt"`
+	RevokedAt      *time.Time `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	RevokedReason  string     `bson:"revokedReason,omitempty" json:"revokedReason,omitempty"`
+}
+
+// PasswordReset is a single-use token mailed to an admin who forgot their
+// password. Only the hash of the token is stored.
+type PasswordReset struct {
+	ID        string     `bson:"_id" json:"id"`
+	UserID    string     `bson:"userId" json:"userId"`
+	TokenHash string     `bson:"tokenHash" json:"-"`
+	IP        string     `bson:"ip,omitempty" json:"ip,omitempty"`
+	CreatedAt time.Time  `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time  `bson:"expiresAt" json:"expiresAt"`
+	UsedAt    *time.Time `bson:"usedAt,omitempty" json:"usedAt,omitempty"`
+}
+
+// Invitation lets someone create their own admin account with a given role.
+// CurrentTokenID is the only link ID still accepted; resending replaces it.
+type Invitation struct {
+	ID             string     `bson:"_id" json:"id"`
 	Email          string     `bson:"email" json:"email"`
-	Phone          string     `bson:"phone" json:"phone"`
-	Type           string     `bson:"type" json:"type"`
-	Date           string     `bson:"date" json:"date"`
-	Time           string     `bson:"time" json:"time"`
-	Duration       int        `bson:"duration" json:"duration"`
-	Price          int        `bson:"price" json:"price"`
-	Tax            int        `bson:"tax" json:"tax"`
-	Total          int        `bson:"total" json:"total"`
+	Role           string     `bson:"role" json:"role"`
 	Status         string     `bson:"status" json:"status"`
-	PaymentMethod  string     `bson:"paymentMethod" json:"paymentMethod"`
+	CurrentTokenID string     `bson:"currentTokenId" json:"-"`
+	InvitedBy      string     `bson:"invitedBy,omitempty" json:"invitedBy,omitempty"`
+	SendCount      int        `bson:"sendCount" json:"sendCount"`
+	LastSentAt     *time.Time `bson:"lastSentAt,omitempty" json:"lastSentAt,omitempty"`
 	CreatedAt      time.Time  `bson:"createdAt" json:"createdAt"`
-	ReminderSentAt *time.Time `bson:"reminderSentAt,omitempty" json:"reminderSentAt,omitempty"`
+	ExpiresAt      time.Time  `bson:"expiresAt" json:"expiresAt"`
+	AcceptedAt     *time.Time `bson:"acceptedAt,omitempty" json:"acceptedAt,omitempty"`
+	UserID         string     `bson:"userId,omitempty" json:"userId,omitempty"`
+	RevokedAt      *time.Time `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
 }
 
-type ContactMessage struct {
-	ID        string    `bson:"_id,omitempty" json:"id"`
-	Name      string    `bson:"name" json:"name"`
-	Email     string    `bson:"email" json:"email"`
-	Phone     string    `bson:"phone" json:"phone"`
-	Subject   string    `bson:"subject" json:"subject"`
-	Message   string    `bson:"message" json:"message"`
+// LoginEvent is one admin sign-in attempt. Username is the identifier as
+// typed, so attempts against unknown accounts are kept too.
+type LoginEvent struct {
+	ID        string    `bson:"_id" json:"id"`
+	UserID    string    `bson:"userId,omitempty" json:"userId,omitempty"`
+	Username  string    `bson:"username" json:"username"`
+	IP        string    `bson:"ip" json:"ip"`
+	UserAgent string    `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	Success   bool      `bson:"success" json:"success"`
+	Reason    string    `bson:"reason,omitempty" json:"reason,omitempty"`
 	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
 }
 
+// WaitlistEntry is a customer waiting for a slot of a service between From
+// and To. The booking details (type, duration, payment, price) are taken at
+// sign-up so a claimed offer can be booked in one click. LocationID and
+// RoomID are set for presentiel entries of a service offered at locations:
+// offers then come from that calendar.
+type WaitlistEntry struct {
+	ID            string         `bson:"_id" json:"id"`
+	ServiceID     string         `bson:"serviceId" json:"serviceId"`
+	Name          string         `bson:"name" json:"name"`
+	Email         string         `bson:"email" json:"email"`
+	Phone         string         `bson:"phone" json:"phone"`
+	Channel       string         `bson:"channel" json:"channel"`
+	Type          string         `bson:"type" json:"type"`
+	Duration      int            `bson:"duration" json:"duration"`
+	PaymentMethod string         `bson:"paymentMethod" json:"paymentMethod"`
+	Price         int            `bson:"price" json:"price"`
+	LocationID    string         `bson:"locationId,omitempty" json:"locationId,omitempty"`
+	RoomID        string         `bson:"roomId,omitempty" json:"roomId,omitempty"`
+	From          string         `bson:"from" json:"from"`
+	To            string         `bson:"to" json:"to"`
+	Status        string         `bson:"status" json:"status"`
+	Offer         *WaitlistOffer `bson:"offer,omitempty" json:"offer,omitempty"`
+	// OfferedSlots ("date time") are never offered to this entry again.
+	OfferedSlots  []string   `bson:"offeredSlots,omitempty" json:"offeredSlots,omitempty"`
+	AppointmentID string     `bson:"appointmentId,omitempty" json:"appointmentId,omitempty"`
+	CreatedAt     time.Time  `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time  `bson:"updatedAt" json:"updatedAt"`
+	CanceledAt    *time.Time `bson:"canceledAt,omitempty" json:"canceledAt,omitempty"`
+}
+
+// WaitlistOffer is a freed slot proposed to a waitlist entry. The claim link
+// carries a token whose hash is stored here.
+type WaitlistOffer struct {
+	Date      string    `bson:"date" json:"date"`
+	Time      string    `bson:"time" json:"time"`
+	TokenHash string    `bson:"tokenHash" json:"-"`
+	OfferedAt time.Time `bson:"offeredAt" json:"offeredAt"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+// GroupSession is a group event of a service (e.g. a training) with a fixed
+// number of seats. SeatsTaken only counts confirmed registrations and is
+// updated atomically with them.
+type GroupSession struct {
+	ID           string    `bson:"_id" json:"id"`
+	ServiceID    string    `bson:"serviceId" json:"serviceId"`
+	Title        string    `bson:"title" json:"title"`
+	Description  string    `bson:"description,omitempty" json:"description,omitempty"`
+	Date         string    `bson:"date" json:"date"`
+	Time         string    `bson:"time" json:"time"`
+	Duration     int       `bson:"duration" json:"duration"`
+	Location     string    `bson:"location" json:"location"`
+	Capacity     int       `bson:"capacity" json:"capacity"`
+	SeatsTaken   int       `bson:"seatsTaken" json:"seatsTaken"`
+	PricePerSeat int       `bson:"pricePerSeat" json:"pricePerSeat"`
+	Status       string    `bson:"status" json:"status"`
+	CreatedAt    time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// SeatsLeft returns the number of seats still open.
+func (s GroupSession) SeatsLeft() int {
+	if s.SeatsTaken >= s.Capacity {
+		return 0
+	}
+	return s.Capacity - s.SeatsTaken
+}
+
+// SessionRegistration books one or more seats of a session. Waitlisted
+// registrations hold no seat until they are promoted.
+type SessionRegistration struct {
+	ID            string     `bson:"_id" json:"id"`
+	SessionID     string     `bson:"sessionId" json:"sessionId"`
+	Name          string     `bson:"name" json:"name"`
+	Email         string     `bson:"email" json:"email"`
+	Phone         string     `bson:"phone" json:"phone"`
+	Seats         int        `bson:"seats" json:"seats"`
+	Total         int        `bson:"total" json:"total"`
+	PaymentMethod string     `bson:"paymentMethod" json:"paymentMethod"`
+	Status        string     `bson:"status" json:"status"`
+	CreatedAt     time.Time  `bson:"createdAt" json:"createdAt"`
+	ConfirmedAt   *time.Time `bson:"confirmedAt,omitempty" json:"confirmedAt,omitempty"`
+	CanceledAt    *time.Time `bson:"canceledAt,omitempty" json:"canceledAt,omitempty"`
+}
+
+type Appointment struct {
+	ID                    string       `bson:"_id,omitempty" json:"id"`
+	ServiceID             string       `bson:"serviceId" json:"serviceId"`
+	Name                  string       `bson:"name" json:"name"`
+	Email                 string       `bson:"email" json:"email"`
+	Phone                 string       `bson:"phone" json:"phone"`
+	Type                  string       `bson:"type" json:"type"`
+	Date                  string       `bson:"date" json:"date"`
+	Time                  string       `bson:"time" json:"time"`
+	Duration              int          `bson:"duration" json:"duration"`
+	Price                 int          `bson:"price" json:"price"`
+	Tax                   int          `bson:"tax" json:"tax"`
+	Total                 int          `bson:"total" json:"total"`
+	Status                string       `bson:"status" json:"status"`
+	PaymentMethod         string       `bson:"paymentMethod" json:"paymentMethod"`
+	CreatedAt             time.Time    `bson:"createdAt" json:"createdAt"`
+	ReminderSentAt        *time.Time   `bson:"reminderSentAt,omitempty" json:"reminderSentAt,omitempty"`
+	ConfirmationMessageID string       `bson:"confirmationMessageId,omitempty" json:"confirmationMessageId,omitempty"`
+	EmailBouncedAt        *time.Time   `bson:"emailBouncedAt,omitempty" json:"emailBouncedAt,omitempty"`
+	CalendarSequence      int          `bson:"calendarSequence" json:"calendarSequence"`
+	CalDAVHref            string       `bson:"caldavHref,omitempty" json:"caldavHref,omitempty"`
+	CalDAVETag            string       `bson:"caldavEtag,omitempty" json:"-"`
+	CalDAVSyncedSequence  *int         `bson:"caldavSyncedSequence,omitempty" json:"-"`
+	Meeting               *MeetingLink `bson:"meeting,omitempty" json:"meeting,omitempty"`
+	SeriesID              string       `bson:"seriesId,omitempty" json:"seriesId,omitempty"`
+	// StatusHistory records every status change, oldest first.
+	StatusHistory []AppointmentStatusChange `bson:"statusHistory,omitempty" json:"statusHistory,omitempty"`
+	Refund        *Refund                   `bson:"refund,omitempty" json:"refund,omitempty"`
+	// Intake holds the answers to the service questionnaire.
+	Intake []IntakeAnswer `bson:"intake,omitempty" json:"intake,omitempty"`
+	// Location is where a presentiel appointment takes place, when its
+	// service is offered at locations.
+	Location *AppointmentLocation `bson:"location,omitempty" json:"location,omitempty"`
+}
+
+// AppointmentSeries is a recurring booking. Each occurrence is a regular
+// appointment carrying the series ID, so it can be moved or canceled on its
+// own; Time and Duration are those of the occurrences booked last.
+type AppointmentSeries struct {
+	ID            string    `bson:"_id" json:"id"`
+	ServiceID     string    `bson:"serviceId" json:"serviceId"`
+	Name          string    `bson:"name" json:"name"`
+	Email         string    `bson:"email" json:"email"`
+	Phone         string    `bson:"phone" json:"phone"`
+	Type          string    `bson:"type" json:"type"`
+	RRule         string    `bson:"rrule" json:"rrule"`
+	StartDate     string    `bson:"startDate" json:"startDate"`
+	Time          string    `bson:"time" json:"time"`
+	Duration      int       `bson:"duration" json:"duration"`
+	PaymentMethod string    `bson:"paymentMethod" json:"paymentMethod"`
+	Price         int       `bson:"price" json:"price"`
+	Status        string    `bson:"status" json:"status"`
+	CreatedAt     time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time `bson:"updatedAt" json:"updatedAt"`
+	// LocationID is where the occurrences were booked, if anywhere.
+	LocationID string `bson:"locationId,omitempty" json:"locationId,omitempty"`
+}
+
+// AppointmentStatusChange is one entry of an appointment's history. Actor is
+// "customer", "system" or the admin's username.
+type AppointmentStatusChange struct {
+	From    string    `bson:"from,omitempty" json:"from,omitempty"`
+	To      string    `bson:"to" json:"to"`
+	Actor   string    `bson:"actor" json:"actor"`
+	ActorID string    `bson:"actorId,omitempty" json:"actorId,omitempty"`
+	Reason  string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	At      time.Time `bson:"at" json:"at"`
+}
+
+// Refund is owed when a prepaid appointment is canceled. Payments are
+// settled outside the API, so it is recorded for the team to process.
+type Refund struct {
+	Status      string    `bson:"status" json:"status"`
+	Amount      int       `bson:"amount" json:"amount"`
+	Currency    string    `bson:"currency" json:"currency"`
+	RequestedAt time.Time `bson:"requestedAt" json:"requestedAt"`
+}
+
+// Location is an office where presentiel appointments take place. Each room
+// has its own calendar, so a location with two rooms hosts two appointments
+// at once. Hours replaces the default opening hours when set.
+type Location struct {
+	ID         string         `bson:"_id" json:"id"`
+	Name       string         `bson:"name" json:"name"`
+	Address    string         `bson:"address" json:"address"`
+	City       string         `bson:"city,omitempty" json:"city,omitempty"`
+	Directions string         `bson:"directions,omitempty" json:"directions,omitempty"`
+	MapURL     string         `bson:"mapUrl,omitempty" json:"mapUrl,omitempty"`
+	Phone      string         `bson:"phone,omitempty" json:"phone,omitempty"`
+	Hours      []OpeningHours `bson:"hours,omitempty" json:"hours,omitempty"`
+	Rooms      []Room         `bson:"rooms" json:"rooms"`
+	Active     bool           `bson:"active" json:"active"`
+	CreatedAt  time.Time      `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time      `bson:"updatedAt" json:"updatedAt"`
+}
+
+// OpeningHours is one opening range of a weekday (0 is Sunday). A day may
+// have several ranges, for instance around lunch.
+type OpeningHours struct {
+	Day   int    `bson:"day" json:"day"`
+	Start string `bson:"start" json:"start"`
+	End   string `bson:"end" json:"end"`
+}
+
+type Room struct {
+	ID       string `bson:"id" json:"id"`
+	Name     string `bson:"name" json:"name"`
+	Capacity int    `bson:"capacity,omitempty" json:"capacity,omitempty"`
+}
+
+// AppointmentLocation is the place of an appointment, copied from its
+// location so confirmations and calendars do not need another lookup.
+type AppointmentLocation struct {
+	ID         string `bson:"id" json:"id"`
+	Name       string `bson:"name" json:"name"`
+	Address    string `bson:"address" json:"address"`
+	Directions string `bson:"directions,omitempty" json:"directions,omitempty"`
+	MapURL     string `bson:"mapUrl,omitempty" json:"mapUrl,omitempty"`
+	RoomID     string `bson:"roomId" json:"roomId"`
+	RoomName   string `bson:"roomName" json:"roomName"`
+}
+
+// MeetingLink is the video call attached to an online appointment.
+type MeetingLink struct {
+	Provider  string    `bson:"provider" json:"provider"`
+	ID        string    `bson:"id,omitempty" json:"id,omitempty"`
+	URL       string    `bson:"url" json:"url"`
+	Passcode  string    `bson:"passcode,omitempty" json:"passcode,omitempty"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+type ContactMessage struct {
+	ID         string     `bson:"_id,omitempty" json:"id"`
+	Name       string     `bson:"name" json:"name"`
+	Email      string     `bson:"email" json:"email"`
+	Phone      string     `bson:"phone" json:"phone"`
+	Subject    string     `bson:"subject" json:"subject"`
+	Message    string     `bson:"message" json:"message"`
+	AnsweredAt *time.Time `bson:"answeredAt,omitempty" json:"answeredAt,omitempty"`
+	CreatedAt  time.Time  `bson:"createdAt" json:"createdAt"`
+}
+
 type ReservationBlock struct {
 	ID        string    `bson:"_id,omitempty" json:"id"`
 	Date      string    `bson:"date" json:"date"`
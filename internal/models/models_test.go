@@ -0,0 +1,16 @@
+package models
+
+import "testing"
+
+func TestAppointmentHoldsSlot(t *testing.T) {
+	for _, status := range []string{AppointmentStatusPending, AppointmentStatusBooked, AppointmentStatusCheckedIn, AppointmentStatusNoShow} {
+		if !AppointmentHoldsSlot(status) {
+			t.Errorf("expected %q to hold its slot", status)
+		}
+	}
+	for _, status := range []string{AppointmentStatusCanceledByCustomer, AppointmentStatusCanceledByAdmin, AppointmentStatusLegacyCanceled, ""} {
+		if AppointmentHoldsSlot(status) {
+			t.Errorf("expected %q to free its slot", status)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package notifications
+
+import (
+	"bytes"
+	"html/template"
+)
+
+const adminDigestTemplate = `<!DOCTYPE html>
+<html>
+<body>
+  <h3>Resume quotidien - {{.Date}}</h3>
+
+  <h4>Rendez-vous du {{.AppointmentsDate}}</h4>
+  {{if .AppointmentGroups}}
+  {{range .AppointmentGroups}}
+  <p><strong>{{.ServiceName}}</strong> ({{len .Appointments}})</p>
+  <ul>
+    {{range .Appointments}}<li>{{.Time}} - {{.Name}} ({{.TypeLabel}}) - ID : {{.ID}}</li>
+    {{end}}
+  </ul>
+  {{end}}
+  {{else}}
+  <p>Aucun rendez-vous prevu.</p>
+  {{end}}
+
+  <h4>Nouvelles demandes RFP</h4>
+  {{if .LeadGroups}}
+  {{range .LeadGroups}}
+  <p><strong>{{.Status}}</strong> ({{len .Leads}})</p>
+  <ul>
+    {{range .Leads}}<li>{{.Organization}}{{if .ContactName}} - {{.ContactName}}{{end}} - ID : {{.ID}}</li>
+    {{end}}
+  </ul>
+  {{end}}
+  {{else}}
+  <p>Aucune nouvelle demande.</p>
+  {{end}}
+
+  <h4>Messages de contact sans reponse ({{len .UnansweredContacts}})</h4>
+  {{if .UnansweredContacts}}
+  <ul>
+    {{range .UnansweredContacts}}<li>{{.ReceivedAt}} - {{.Name}} ({{.Email}}) : {{.Subject}}</li>
+    {{end}}
+  </ul>
+  {{else}}
+  <p>Aucun message en attente.</p>
+  {{end}}
+
+  <h4>Nouveaux temoignages</h4>
+  {{if .Testimonials}}
+  <ul>
+    {{range .Testimonials}}<li>{{.ServiceName}} - {{.Name}} ({{.Rating}}/5) : {{.Message}}</li>
+    {{end}}
+  </ul>
+  {{else}}
+  <p>Aucun nouveau temoignage.</p>
+  {{end}}
+</body>
+</html>`
+
+var adminDigestTmpl = template.Must(template.New("admin_digest").Parse(adminDigestTemplate))
+
+type AdminDigest struct {
+	Date               string
+	AppointmentsDate   string
+	AppointmentGroups  []AdminDigestServiceGroup
+	LeadGroups         []AdminDigestLeadGroup
+	UnansweredContacts []AdminDigestContact
+	Testimonials       []AdminDigestTestimonial
+}
+
+type AdminDigestServiceGroup struct {
+	ServiceName  string
+	Appointments []AdminDigestAppointment
+}
+
+type AdminDigestAppointment struct {
+	ID        string
+	Time      string
+	Name      string
+	TypeLabel string
+}
+
+type AdminDigestLeadGroup struct {
+	Status string
+	Leads  []AdminDigestLead
+}
+
+type AdminDigestLead struct {
+	ID           string
+	Organization string
+	ContactName  string
+}
+
+type AdminDigestContact struct {
+	Name       string
+	Email      string
+	Subject    string
+	ReceivedAt string
+}
+
+type AdminDigestTestimonial struct {
+	ServiceName string
+	Name        string
+	Rating      int
+	Message     string
+}
+
+// IsEmpty reports whether the digest has nothing worth sending.
+func (d AdminDigest) IsEmpty() bool {
+	return len(d.AppointmentGroups) == 0 &&
+		len(d.LeadGroups) == 0 &&
+		len(d.UnansweredContacts) == 0 &&
+		len(d.Testimonials) == 0
+}
+
+func BuildAdminDigestHTML(digest AdminDigest) (string, error) {
+	var buf bytes.Buffer
+	if err := adminDigestTmpl.Execute(&buf, digest); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// AppointmentTypeLabel returns the French label used in emails for a consultation type.
+func AppointmentTypeLabel(value string) string {
+	return appointmentTypeLabel(value)
+}
@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildAdminDigestHTMLGroupsSections(t *testing.T) {
+	digest := AdminDigest{
+		Date:             "2026-04-22",
+		AppointmentsDate: "2026-04-23",
+		AppointmentGroups: []AdminDigestServiceGroup{
+			{
+				ServiceName: "Formation",
+				Appointments: []AdminDigestAppointment{
+					{ID: "RDV-001", Time: "09:00", Name: "Jean", TypeLabel: "Presentiel"},
+					{ID: "RDV-002", Time: "10:30", Name: "Amina", TypeLabel: "En ligne"},
+				},
+			},
+		},
+		LeadGroups: []AdminDigestLeadGroup{
+			{Status: "new", Leads: []AdminDigestLead{{ID: "RFP-1", Organization: "ACME"}}},
+		},
+	}
+
+	html, err := BuildAdminDigestHTML(digest)
+	if err != nil {
+		t.Fatalf("BuildAdminDigestHTML() error = %v", err)
+	}
+
+	for _, want := range []string{"Formation</strong> (2)", "RDV-002", "ACME - ID : RFP-1", "Aucun message en attente.", "Aucun nouveau temoignage."} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("expected %q in digest, got %q", want, html)
+		}
+	}
+}
+
+func TestAdminDigestIsEmpty(t *testing.T) {
+	if !(AdminDigest{Date: "2026-04-22"}).IsEmpty() {
+		t.Fatalf("expected digest without sections to be empty")
+	}
+	digest := AdminDigest{Testimonials: []AdminDigestTestimonial{{Name: "Jean", Rating: 5}}}
+	if digest.IsEmpty() {
+		t.Fatalf("expected digest with testimonials not to be empty")
+	}
+}
@@ -7,11 +7,17 @@ import (
 	"gbh-backend/internal/models"
 )
 
+const officeAddress = "Boulevard Sendwe, immeuble Adi Construct, quatrieme niveau, commune de Kalamu, quartier Matonge."
+
 const appointmentConfirmationTemplate = `<!DOCTYPE html>
 <html>
 <body>
   <p>Bonjour {{.Name}},</p>
+  {{if .Rescheduled}}
+  <p>Votre rendez-vous a ete modifie. Voici les nouveaux details :</p>
+  {{else}}
   <p>Votre reservation est confirmee. Voici les details :</p>
+  {{end}}
   <p><strong>ID de reservation : {{.AppointmentID}}</strong></p>
   <p>Conservez cet ID. Il est necessaire pour retrouver votre rendez-vous.</p>
   <ul>
@@ -25,7 +31,20 @@ const appointmentConfirmationTemplate = `<!DOCTYPE html>
     <li>Total : {{.Total}}</li>
   </ul>
   {{if .ShowOfficeAddress}}
-  <p><strong>Adresse de nos bureaux :</strong> Boulevard Sendwe, immeuble Adi Construct, quatrieme niveau, commune de Kalamu, quartier Matonge.</p>
+  {{if .LocationName}}<p><strong>Lieu :</strong> {{.LocationName}}{{if .RoomName}}, salle {{.RoomName}}{{end}}</p>{{end}}
+  <p><strong>Adresse de nos bureaux :</strong> {{.OfficeAddress}}</p>
+  {{if .Directions}}<p><strong>Comment venir :</strong> {{.Directions}}</p>{{end}}
+  {{if .MapURL}}<p><a href="{{.MapURL}}">Voir le plan d'acces</a></p>{{end}}
+  {{end}}
+  {{if .MeetingURL}}
+  <p><strong>Lien de la visioconference :</strong> <a href="{{.MeetingURL}}">{{.MeetingURL}}</a></p>
+  {{if .MeetingPasscode}}<p>Code d'acces : {{.MeetingPasscode}}</p>{{end}}
+  {{end}}
+  {{if .Intake}}
+  <p><strong>Vos reponses au questionnaire :</strong></p>
+  <ul>
+    {{range .Intake}}<li>{{.Label}} : {{.Value}}</li>{{end}}
+  </ul>
   {{end}}
   <p>Recherche de rendez-vous : utilisez cet ID dans l'option de recherche par ID.</p>
   <p>A apporter le jour du rendez-vous :</p>
@@ -33,10 +52,29 @@ const appointmentConfirmationTemplate = `<!DOCTYPE html>
     <li>Carte d'identite</li>
     <li>Cet email imprime</li>
   </ul>
+  <p>L'invitation jointe (.ics) ajoute ce rendez-vous a votre calendrier.</p>
+  <p>Merci.</p>
+</body>
+</html>`
+
+const appointmentCancellationTemplate = `<!DOCTYPE html>
+<html>
+<body>
+  <p>Bonjour {{.Name}},</p>
+  <p>Votre rendez-vous a ete annule :</p>
+  <p><strong>ID de reservation : {{.AppointmentID}}</strong></p>
+  <ul>
+    <li>Service : {{.ServiceName}}</li>
+    <li>Date : {{.Date}}</li>
+    <li>Heure : {{.Time}}</li>
+  </ul>
+  <p>L'invitation jointe (.ics) retire ce rendez-vous de votre calendrier.</p>
   <p>Merci.</p>
 </body>
 </html>`
 
+var appointmentCancellationTmpl = template.Must(template.New("appointment_cancellation").Parse(appointmentCancellationTemplate))
+
 var appointmentConfirmationTmpl = template.Must(template.New("appointment_confirmation").Parse(appointmentConfirmationTemplate))
 
 type appointmentConfirmationData struct {
@@ -51,9 +89,30 @@ type appointmentConfirmationData struct {
 	Total             int
 	AppointmentID     string
 	ShowOfficeAddress bool
+	OfficeAddress     string
+	LocationName      string
+	RoomName          string
+	Directions        string
+	MapURL            string
+	MeetingURL        string
+	MeetingPasscode   string
+	Rescheduled       bool
+	Intake            []intakeAnswerLine
 }
 
 func buildAppointmentConfirmationHTML(appointment models.Appointment, service models.Service) (string, error) {
+	return buildAppointmentEmailHTML(appointmentConfirmationTmpl, appointment, service, false)
+}
+
+func buildAppointmentRescheduledHTML(appointment models.Appointment, service models.Service) (string, error) {
+	return buildAppointmentEmailHTML(appointmentConfirmationTmpl, appointment, service, true)
+}
+
+func buildAppointmentCancellationHTML(appointment models.Appointment, service models.Service) (string, error) {
+	return buildAppointmentEmailHTML(appointmentCancellationTmpl, appointment, service, false)
+}
+
+func buildAppointmentEmailHTML(tmpl *template.Template, appointment models.Appointment, service models.Service, rescheduled bool) (string, error) {
 	data := appointmentConfirmationData{
 		Name:              appointment.Name,
 		ServiceName:       service.Name,
@@ -66,9 +125,23 @@ func buildAppointmentConfirmationHTML(appointment models.Appointment, service mo
 		Total:             appointment.Total,
 		AppointmentID:     appointment.ID,
 		ShowOfficeAddress: appointment.Type == models.ConsultationPresentiel,
+		OfficeAddress:     officeAddress,
+		Rescheduled:       rescheduled,
+		Intake:            intakeAnswerLines(appointment.Intake),
+	}
+	if appointment.Location != nil {
+		data.OfficeAddress = appointment.Location.Address
+		data.LocationName = appointment.Location.Name
+		data.RoomName = appointment.Location.RoomName
+		data.Directions = appointment.Location.Directions
+		data.MapURL = appointment.Location.MapURL
+	}
+	if appointment.Meeting != nil {
+		data.MeetingURL = appointment.Meeting.URL
+		data.MeetingPasscode = appointment.Meeting.Passcode
 	}
 	var buf bytes.Buffer
-	if err := appointmentConfirmationTmpl.Execute(&buf, data); err != nil {
+	if err := tmpl.Execute(&buf, data); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
@@ -56,3 +56,37 @@ func TestBuildAppointmentConfirmationHTMLOmitsOfficeAddressForOnline(t *testing.
 		t.Fatalf("did not expect office address in online confirmation email, got %q", html)
 	}
 }
+
+func TestBuildAppointmentConfirmationHTMLUsesAppointmentLocation(t *testing.T) {
+	appointment := models.Appointment{
+		ID:            "RDV-003",
+		Name:          "Paul",
+		Type:          models.ConsultationPresentiel,
+		Date:          "2026-04-23",
+		Time:          "10:00",
+		Duration:      45,
+		PaymentMethod: models.PaymentPlace,
+		Location: &models.AppointmentLocation{
+			ID:         "loc-1",
+			Name:       "Agence Gombe",
+			Address:    "Avenue du Commerce 12, Gombe",
+			Directions: "Entrée par la cour, 2e étage",
+			MapURL:     "https://maps.example.com/gombe",
+			RoomID:     "room-1",
+			RoomName:   "Salle Congo",
+		},
+	}
+
+	html, err := buildAppointmentConfirmationHTML(appointment, models.Service{Name: "Consultation"})
+	if err != nil {
+		t.Fatalf("buildAppointmentConfirmationHTML() error = %v", err)
+	}
+	for _, want := range []string{"Agence Gombe, salle Salle Congo", "Avenue du Commerce 12, Gombe", "Entrée par la cour", `href="https://maps.example.com/gombe"`} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("html missing %q:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, officeAddressSnippet) {
+		t.Fatalf("did not expect the default office address, got %q", html)
+	}
+}
@@ -0,0 +1,87 @@
+package notifications
+
+import (
+	"fmt"
+	"time"
+
+	"gbh-backend/internal/calendar"
+	"gbh-backend/internal/models"
+	"gbh-backend/internal/schedule"
+)
+
+const appointmentICSFileName = "rendez-vous.ics"
+
+// AppointmentCalendarEvent describes an appointment as a calendar event. It
+// is shared by email invitations and the staff calendar feed.
+func AppointmentCalendarEvent(appointment models.Appointment, service models.Service, loc *time.Location) (calendar.Event, error) {
+	start, err := schedule.ParseDateTime(appointment.Date, appointment.Time, loc)
+	if err != nil {
+		return calendar.Event{}, err
+	}
+	duration := appointment.Duration
+	if duration <= 0 {
+		duration = schedule.SlotMinutes
+	}
+
+	location := appointmentTypeLabel(appointment.Type)
+	if appointment.Type == models.ConsultationPresentiel {
+		location = officeAddress
+	}
+	place := appointment.Location
+	if place != nil {
+		location = place.Name + ", " + place.Address
+	}
+
+	status := calendar.StatusConfirmed
+	if models.AppointmentCanceled(appointment.Status) {
+		status = calendar.StatusCancelled
+	}
+
+	description := fmt.Sprintf("Rendez-vous %s avec %s.\nID de reservation : %s", service.Name, appointment.Name, appointment.ID)
+	if place != nil {
+		description += "\nSalle : " + place.RoomName
+		if place.Directions != "" {
+			description += "\nComment venir : " + place.Directions
+		}
+	}
+	meetingURL := ""
+	if appointment.Meeting != nil && appointment.Meeting.URL != "" {
+		meetingURL = appointment.Meeting.URL
+		location = meetingURL
+		description += "\nVisioconference : " + meetingURL
+		if appointment.Meeting.Passcode != "" {
+			description += "\nCode d'acces : " + appointment.Meeting.Passcode
+		}
+	}
+
+	return calendar.Event{
+		UID:         calendar.AppointmentUID(appointment.ID),
+		Sequence:    appointment.CalendarSequence,
+		Status:      status,
+		Summary:     fmt.Sprintf("GBH - %s", service.Name),
+		Description: description,
+		Location:    location,
+		URL:         meetingURL,
+		Start:       start,
+		End:         start.Add(time.Duration(duration) * time.Minute),
+	}, nil
+}
+
+func buildAppointmentICS(appointment models.Appointment, service models.Service, method string, organizer calendar.Person, loc *time.Location) (string, error) {
+	event, err := AppointmentCalendarEvent(appointment, service, loc)
+	if err != nil {
+		return "", err
+	}
+	if method == calendar.MethodCancel {
+		event.Status = calendar.StatusCancelled
+	}
+	event.Organizer = &organizer
+	event.Attendees = []calendar.Person{{Name: appointment.Name, Email: appointment.Email}}
+
+	cal := calendar.Calendar{
+		Method:   method,
+		Location: loc,
+		Events:   []calendar.Event{event},
+	}
+	return cal.Render(time.Now()), nil
+}
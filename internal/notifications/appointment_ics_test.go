@@ -0,0 +1,45 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gbh-backend/internal/calendar"
+	"gbh-backend/internal/models"
+)
+
+func TestBuildAppointmentICSCancelKeepsUIDAndSequence(t *testing.T) {
+	loc, err := time.LoadLocation("Africa/Kinshasa")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	appointment := models.Appointment{
+		ID:               "RDV-003",
+		Name:             "Jean",
+		Email:            "jean@example.com",
+		Type:             models.ConsultationPresentiel,
+		Date:             "2026-04-23",
+		Time:             "10:00",
+		Duration:         45,
+		CalendarSequence: 1,
+	}
+	organizer := calendar.Person{Name: "GBH", Email: "contact@gbh.sarl"}
+
+	ics, err := buildAppointmentICS(appointment, models.Service{Name: "Consultation"}, calendar.MethodCancel, organizer, loc)
+	if err != nil {
+		t.Fatalf("buildAppointmentICS() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"METHOD:CANCEL",
+		"UID:" + calendar.AppointmentUID("RDV-003"),
+		"SEQUENCE:1",
+		"STATUS:CANCELLED",
+		"DTSTART;TZID=Africa/Kinshasa:20260423T100000",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Fatalf("expected %q in invite, got %q", want, ics)
+		}
+	}
+}
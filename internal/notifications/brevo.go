@@ -3,6 +3,7 @@ package notifications
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,32 +12,49 @@ import (
 	"strings"
 	"time"
 
+	"gbh-backend/internal/calendar"
 	"gbh-backend/internal/models"
 	"gbh-backend/internal/rfp"
 )
 
 const defaultBrevoEndpoint = "https://api.brevo.com/v3/smtp/email"
 
+// ErrRecipientSuppressed is returned when the recipient previously bounced,
+// complained or unsubscribed.
+var ErrRecipientSuppressed = errors.New("recipient is suppressed")
+
+// SuppressionList reports addresses that must no longer receive email.
+type SuppressionList interface {
+	IsSuppressed(ctx context.Context, email string) (bool, error)
+}
+
 type BrevoClient struct {
-	apiKey      string
-	senderEmail string
-	senderName  string
-	sandbox     bool
-	endpoint    string
-	httpClient  *http.Client
+	apiKey       string
+	senderEmail  string
+	senderName   string
+	sandbox      bool
+	endpoint     string
+	httpClient   *http.Client
+	suppressions SuppressionList
+	location     *time.Location
 }
 
 func (c *BrevoClient) SendEmail(ctx context.Context, toEmail, toName, subject, htmlBody string) (string, error) {
 	return c.sendHTML(ctx, toEmail, toName, subject, htmlBody)
 }
 
-func NewBrevoClient(apiKey, senderEmail, senderName string, sandbox bool) *BrevoClient {
+// NewBrevoClient returns nil when the API key or sender is missing. location
+// is the timezone used for calendar invitations.
+func NewBrevoClient(apiKey, senderEmail, senderName string, sandbox bool, location *time.Location) *BrevoClient {
 	if strings.TrimSpace(apiKey) == "" || strings.TrimSpace(senderEmail) == "" {
 		return nil
 	}
 	if strings.TrimSpace(senderName) == "" {
 		senderName = senderEmail
 	}
+	if location == nil {
+		location = time.UTC
+	}
 	return &BrevoClient{
 		apiKey:      apiKey,
 		senderEmail: senderEmail,
@@ -44,9 +62,18 @@ func NewBrevoClient(apiKey, senderEmail, senderName string, sandbox bool) *Brevo
 		sandbox:     sandbox,
 		endpoint:    defaultBrevoEndpoint,
 		httpClient:  &http.Client{Timeout: 8 * time.Second},
+		location:    location,
 	}
 }
 
+// UseSuppressionList makes every send check the recipient against list first.
+func (c *BrevoClient) UseSuppressionList(list SuppressionList) {
+	if c == nil {
+		return
+	}
+	c.suppressions = list
+}
+
 func (c *BrevoClient) SendAppointmentConfirmation(ctx context.Context, appointment models.Appointment, service models.Service) (string, error) {
 	if c == nil {
 		return "", errors.New("brevo client is nil")
@@ -56,7 +83,46 @@ func (c *BrevoClient) SendAppointmentConfirmation(ctx context.Context, appointme
 	if err != nil {
 		return "", err
 	}
-	return c.sendHTML(ctx, appointment.Email, appointment.Name, subject, htmlBody)
+	return c.sendAppointmentWithInvite(ctx, appointment, service, subject, htmlBody, calendar.MethodRequest)
+}
+
+// SendAppointmentRescheduled sends the new details with an updated invitation.
+// The caller must have incremented appointment.CalendarSequence.
+func (c *BrevoClient) SendAppointmentRescheduled(ctx context.Context, appointment models.Appointment, service models.Service) (string, error) {
+	if c == nil {
+		return "", errors.New("brevo client is nil")
+	}
+	subject := fmt.Sprintf("Modification de votre rendez-vous - %s", service.Name)
+	htmlBody, err := buildAppointmentRescheduledHTML(appointment, service)
+	if err != nil {
+		return "", err
+	}
+	return c.sendAppointmentWithInvite(ctx, appointment, service, subject, htmlBody, calendar.MethodRequest)
+}
+
+func (c *BrevoClient) SendAppointmentCancellation(ctx context.Context, appointment models.Appointment, service models.Service) (string, error) {
+	if c == nil {
+		return "", errors.New("brevo client is nil")
+	}
+	subject := fmt.Sprintf("Annulation de votre rendez-vous - %s", service.Name)
+	htmlBody, err := buildAppointmentCancellationHTML(appointment, service)
+	if err != nil {
+		return "", err
+	}
+	return c.sendAppointmentWithInvite(ctx, appointment, service, subject, htmlBody, calendar.MethodCancel)
+}
+
+func (c *BrevoClient) sendAppointmentWithInvite(ctx context.Context, appointment models.Appointment, service models.Service, subject, htmlBody, method string) (string, error) {
+	organizer := calendar.Person{Name: c.senderName, Email: c.senderEmail}
+	ics, err := buildAppointmentICS(appointment, service, method, organizer, c.location)
+	if err != nil {
+		return "", fmt.Errorf("build calendar invite: %w", err)
+	}
+	attachments := []brevoAttachment{{
+		Name:    appointmentICSFileName,
+		Content: base64.StdEncoding.EncodeToString([]byte(ics)),
+	}}
+	return c.send(ctx, appointment.Email, appointment.Name, subject, htmlBody, attachments)
 }
 
 func (c *BrevoClient) SendRFPLeadNotification(ctx context.Context, lead rfp.Lead) (string, error) {
@@ -89,6 +155,10 @@ func (c *BrevoClient) SendRFPLeadConfirmation(ctx context.Context, lead rfp.Lead
 }
 
 func (c *BrevoClient) sendHTML(ctx context.Context, toEmail, toName, subject, htmlBody string) (string, error) {
+	return c.send(ctx, toEmail, toName, subject, htmlBody, nil)
+}
+
+func (c *BrevoClient) send(ctx context.Context, toEmail, toName, subject, htmlBody string, attachments []brevoAttachment) (string, error) {
 	if c == nil {
 		return "", errors.New("brevo client is nil")
 	}
@@ -101,6 +171,15 @@ func (c *BrevoClient) sendHTML(ctx context.Context, toEmail, toName, subject, ht
 	if strings.TrimSpace(htmlBody) == "" {
 		return "", errors.New("missing html body")
 	}
+	if c.suppressions != nil {
+		suppressed, err := c.suppressions.IsSuppressed(ctx, toEmail)
+		if err != nil {
+			return "", fmt.Errorf("brevo suppression check: %w", err)
+		}
+		if suppressed {
+			return "", ErrRecipientSuppressed
+		}
+	}
 
 	payload := brevoSendRequest{
 		Sender: brevoSender{
@@ -115,6 +194,7 @@ func (c *BrevoClient) sendHTML(ctx context.Context, toEmail, toName, subject, ht
 		},
 		Subject:     subject,
 		HtmlContent: htmlBody,
+		Attachment:  attachments,
 	}
 	if c.sandbox {
 		payload.Headers = map[string]string{
@@ -161,9 +241,15 @@ type brevoSendRequest struct {
 	To          []brevoRecipient  `json:"to"`
 	Subject     string            `json:"subject"`
 	HtmlContent string            `json:"htmlContent,omitempty"`
+	Attachment  []brevoAttachment `json:"attachment,omitempty"`
 	Headers     map[string]string `json:"headers,omitempty"`
 }
 
+type brevoAttachment struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
 type brevoSender struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
@@ -0,0 +1,104 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultBrevoSMSEndpoint = "https://api.brevo.com/v3/transactionalSMS/sms"
+
+// BrevoSMSClient sends transactional SMS through Brevo.
+type BrevoSMSClient struct {
+	apiKey     string
+	sender     string
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewBrevoSMSClient returns nil when the API key or sender is missing.
+func NewBrevoSMSClient(apiKey, sender string) *BrevoSMSClient {
+	if strings.TrimSpace(apiKey) == "" || strings.TrimSpace(sender) == "" {
+		return nil
+	}
+	return &BrevoSMSClient{
+		apiKey:     apiKey,
+		sender:     sender,
+		endpoint:   defaultBrevoSMSEndpoint,
+		httpClient: &http.Client{Timeout: 8 * time.Second},
+	}
+}
+
+// SendSMS sends content to phone, an international number such as
+// "+243810000000".
+func (c *BrevoSMSClient) SendSMS(ctx context.Context, phone, content string) (string, error) {
+	if c == nil {
+		return "", errors.New("brevo sms client is nil")
+	}
+	recipient := strings.TrimPrefix(strings.Map(func(r rune) rune {
+		if r == '+' || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, phone), "+")
+	if recipient == "" {
+		return "", errors.New("missing recipient phone")
+	}
+	if strings.TrimSpace(content) == "" {
+		return "", errors.New("missing sms content")
+	}
+
+	raw, err := json.Marshal(brevoSMSRequest{
+		Sender:    c.sender,
+		Recipient: recipient,
+		Content:   content,
+		Type:      "transactional",
+	})
+	if err != nil {
+		return "", fmt.Errorf("brevo sms marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return "", fmt.Errorf("brevo sms create request: %w", err)
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("api-key", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("brevo sms request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("brevo sms send failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out brevoSMSResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("brevo sms decode response: %w", err)
+	}
+	if out.MessageID.String() == "" {
+		return "", errors.New("brevo sms response missing messageId")
+	}
+	return out.MessageID.String(), nil
+}
+
+type brevoSMSRequest struct {
+	Sender    string `json:"sender"`
+	Recipient string `json:"recipient"`
+	Content   string `json:"content"`
+	Type      string `json:"type"`
+}
+
+type brevoSMSResponse struct {
+	MessageID json.Number `json:"messageId"`
+}
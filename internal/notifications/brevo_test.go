@@ -0,0 +1,47 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type suppressionSet map[string]bool
+
+func (s suppressionSet) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	return s[strings.ToLower(email)], nil
+}
+
+func TestBrevoSkipsSuppressedRecipients(t *testing.T) {
+	var calls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"messageId":"<msg-1>"}`))
+	}))
+	defer api.Close()
+
+	client := NewBrevoClient("key", "noreply@example.com", "GBH", false, time.UTC)
+	client.endpoint = api.URL
+	client.UseSuppressionList(suppressionSet{"jean@example.com": true})
+
+	if _, err := client.SendEmail(context.Background(), "Jean@Example.com", "Jean", "Sujet", "<p>test</p>"); !errors.Is(err, ErrRecipientSuppressed) {
+		t.Fatalf("send to a suppressed address: error = %v, want ErrRecipientSuppressed", err)
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("expected no call to Brevo, got %d", calls.Load())
+	}
+
+	id, err := client.SendEmail(context.Background(), "amina@example.com", "Amina", "Sujet", "<p>test</p>")
+	if err != nil || id != "<msg-1>" {
+		t.Fatalf("send to another address: id %q, error %v", id, err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected one call to Brevo, got %d", calls.Load())
+	}
+}
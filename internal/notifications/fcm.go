@@ -67,18 +67,23 @@ func (c *FCMClient) SendAppointmentConfirmation(ctx context.Context, deviceToken
 	title := "Votre rendez-vous est confirmé"
 	body := fmt.Sprintf("%s le %s à %s", service.Name, appointment.Date, appointment.Time)
 
+	data := map[string]string{
+		"appointmentId": appointment.ID,
+		"serviceId":     appointment.ServiceID,
+		"date":          appointment.Date,
+		"time":          appointment.Time,
+	}
+	if appointment.Meeting != nil && appointment.Meeting.URL != "" {
+		data["meetingUrl"] = appointment.Meeting.URL
+	}
+
 	msg := &messaging.Message{
 		Token: deviceToken,
 		Notification: &messaging.Notification{
 			Title: title,
 			Body:  body,
 		},
-		Data: map[string]string{
-			"appointmentId": appointment.ID,
-			"serviceId":     appointment.ServiceID,
-			"date":          appointment.Date,
-			"time":          appointment.Time,
-		},
+		Data: data,
 	}
 
 	return c.client.Send(ctx, msg)
@@ -0,0 +1,41 @@
+package notifications
+
+import (
+	"bytes"
+	"html/template"
+
+	"gbh-backend/internal/intake"
+	"gbh-backend/internal/models"
+)
+
+const intakeAnswersTemplate = `{{if .}}<p><strong>Questionnaire :</strong></p>
+<ul>
+{{- range .}}
+  <li>{{.Label}} : {{.Value}}</li>
+{{- end}}
+</ul>{{end}}`
+
+var intakeAnswersTmpl = template.Must(template.New("intake_answers").Parse(intakeAnswersTemplate))
+
+type intakeAnswerLine struct {
+	Label string
+	Value string
+}
+
+func intakeAnswerLines(answers []models.IntakeAnswer) []intakeAnswerLine {
+	lines := make([]intakeAnswerLine, 0, len(answers))
+	for _, answer := range answers {
+		lines = append(lines, intakeAnswerLine{Label: answer.Label, Value: intake.DisplayValue(answer)})
+	}
+	return lines
+}
+
+// BuildIntakeAnswersHTML renders questionnaire answers as an HTML list, or
+// nothing when there are none. Uploaded files are listed by name.
+func BuildIntakeAnswersHTML(answers []models.IntakeAnswer) (string, error) {
+	var buf bytes.Buffer
+	if err := intakeAnswersTmpl.Execute(&buf, intakeAnswerLines(answers)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
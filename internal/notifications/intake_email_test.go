@@ -0,0 +1,29 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+
+	"gbh-backend/internal/models"
+)
+
+func TestBuildIntakeAnswersHTML(t *testing.T) {
+	body, err := BuildIntakeAnswersHTML(nil)
+	if err != nil || body != "" {
+		t.Fatalf("BuildIntakeAnswersHTML(nil) = %q, %v", body, err)
+	}
+
+	body, err = BuildIntakeAnswersHTML([]models.IntakeAnswer{
+		{QuestionID: "goal", Label: "Objectif", Type: models.IntakeTypeText, Value: "Creer <une> SARL"},
+		{QuestionID: "topics", Label: "Sujets", Type: models.IntakeTypeChoice, Values: []string{"RH", "Statuts"}},
+		{QuestionID: "statutes", Label: "Statuts", Type: models.IntakeTypeFile, File: &models.IntakeFileRef{ID: "f1", Name: "statuts.pdf"}},
+	})
+	if err != nil {
+		t.Fatalf("BuildIntakeAnswersHTML() error = %v", err)
+	}
+	for _, want := range []string{"Objectif : Creer &lt;une&gt; SARL", "Sujets : RH, Statuts", "Statuts : statuts.pdf"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("html missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package notifications
+
+import (
+	"bytes"
+	"html/template"
+)
+
+const invitationTemplate = `<!DOCTYPE html>
+<html>
+<body>
+  <p>Bonjour,</p>
+  <p>{{if .InvitedBy}}{{.InvitedBy}} vous invite{{else}}Vous etes invite(e){{end}} a rejoindre l'espace d'administration GBH avec le role <strong>{{.Role}}</strong>.</p>
+  <p><a href="{{.AcceptURL}}">Creer mon compte</a></p>
+  <p>Ce lien est valable jusqu'au {{.ExpiresAt}}. Si vous ne vous attendiez pas a cette invitation, ignorez ce message.</p>
+</body>
+</html>`
+
+var invitationTmpl = template.Must(template.New("invitation").Parse(invitationTemplate))
+
+type InvitationEmail struct {
+	InvitedBy string
+	Role      string
+	AcceptURL string
+	ExpiresAt string
+}
+
+func BuildInvitationHTML(email InvitationEmail) (string, error) {
+	var buf bytes.Buffer
+	if err := invitationTmpl.Execute(&buf, email); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,33 @@
+package notifications
+
+import (
+	"bytes"
+	"html/template"
+)
+
+const passwordResetTemplate = `<!DOCTYPE html>
+<html>
+<body>
+  <p>Bonjour {{.Username}},</p>
+  <p>Une reinitialisation du mot de passe de votre compte administrateur a ete demandee.</p>
+  <p><a href="{{.ResetURL}}">Choisir un nouveau mot de passe</a></p>
+  <p>Ce lien est valable {{.ValidMinutes}} minutes et ne peut etre utilise qu'une seule fois.</p>
+  <p>Si vous n'etes pas a l'origine de cette demande, ignorez ce message : votre mot de passe reste inchange.</p>
+</body>
+</html>`
+
+var passwordResetTmpl = template.Must(template.New("password_reset").Parse(passwordResetTemplate))
+
+type PasswordResetEmail struct {
+	Username     string
+	ResetURL     string
+	ValidMinutes int
+}
+
+func BuildPasswordResetHTML(email PasswordResetEmail) (string, error) {
+	var buf bytes.Buffer
+	if err := passwordResetTmpl.Execute(&buf, email); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,53 @@
+package notifications
+
+import (
+	"bytes"
+	"html/template"
+)
+
+const sessionRegistrationTemplate = `<!DOCTYPE html>
+<html>
+<body>
+  <p>Bonjour {{.Name}},</p>
+  {{- if eq .Status "confirmed"}}
+  <p>Votre inscription a <strong>{{.Title}}</strong> est confirmee : {{.Seats}} place(s) le <strong>{{.Date}}</strong> a <strong>{{.Time}}</strong>.</p>
+  <p>Lieu : {{.Location}}</p>
+  {{- if .Total}}
+  <p>Montant : {{.Total}} CDF</p>
+  {{- end}}
+  {{- else if eq .Status "waitlisted"}}
+  <p><strong>{{.Title}}</strong> du {{.Date}} est complet. Vous etes sur la liste d'attente pour {{.Seats}} place(s) : nous vous ecrirons si des places se liberent.</p>
+  {{- else}}
+  <p>Votre inscription a <strong>{{.Title}}</strong> du {{.Date}} a {{.Time}} est annulee.</p>
+  {{- if .Reason}}
+  <p>Motif : {{.Reason}}</p>
+  {{- end}}
+  {{- end}}
+  <p>Reference d'inscription : {{.RegistrationID}}</p>
+</body>
+</html>`
+
+var sessionRegistrationTmpl = template.Must(template.New("session_registration").Parse(sessionRegistrationTemplate))
+
+// SessionRegistrationEmail describes a registration to a group session.
+// Status is the registration status the email announces.
+type SessionRegistrationEmail struct {
+	Name           string
+	Title          string
+	Date           string
+	Time           string
+	Location       string
+	Seats          int
+	Total          int
+	Status         string
+	Reason         string
+	RegistrationID string
+}
+
+func BuildSessionRegistrationHTML(email SessionRegistrationEmail) (string, error) {
+	var buf bytes.Buffer
+	if err := sessionRegistrationTmpl.Execute(&buf, email); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,41 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildSessionRegistrationHTML(t *testing.T) {
+	email := SessionRegistrationEmail{
+		Name:           "Awa",
+		Title:          "Formation Excel",
+		Date:           "2026-06-10",
+		Time:           "09:00",
+		Location:       "Kinshasa, Gombe",
+		Seats:          2,
+		Total:          100000,
+		RegistrationID: "reg-1",
+	}
+	tests := []struct {
+		status string
+		reason string
+		want   []string
+	}{
+		{status: "confirmed", want: []string{"est confirmee", "2 place(s)", "Kinshasa, Gombe", "100000 CDF"}},
+		{status: "waitlisted", want: []string{"liste d'attente", "2 place(s)"}},
+		{status: "canceled", reason: "Formateur absent", want: []string{"est annulee", "Motif : Formateur absent"}},
+	}
+	for _, tt := range tests {
+		email.Status = tt.status
+		email.Reason = tt.reason
+		body, err := BuildSessionRegistrationHTML(email)
+		if err != nil {
+			t.Fatalf("%s: BuildSessionRegistrationHTML() error = %v", tt.status, err)
+		}
+		for _, want := range append(tt.want, "reg-1") {
+			if !strings.Contains(body, want) {
+				t.Fatalf("%s: html missing %q:\n%s", tt.status, want, body)
+			}
+		}
+	}
+}
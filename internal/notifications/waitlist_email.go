@@ -0,0 +1,44 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+)
+
+const waitlistOfferTemplate = `<!DOCTYPE html>
+<html>
+<body>
+  <p>Bonjour {{.Name}},</p>
+  <p>Un creneau vient de se liberer pour <strong>{{.ServiceName}}</strong> : le <strong>{{.Date}}</strong> a <strong>{{.Time}}</strong>.</p>
+  <p><a href="{{.ClaimURL}}">Reserver ce creneau</a></p>
+  <p>Ce lien est valable {{.ValidMinutes}} minutes. Le creneau a aussi pu etre propose a d'autres personnes de la liste d'attente : la premiere reservation l'emporte.</p>
+  <p>Si ce creneau ne vous convient pas, ignorez ce message : vous restez sur la liste d'attente.</p>
+</body>
+</html>`
+
+var waitlistOfferTmpl = template.Must(template.New("waitlist_offer").Parse(waitlistOfferTemplate))
+
+type WaitlistOffer struct {
+	Name         string
+	ServiceName  string
+	Date         string
+	Time         string
+	ClaimURL     string
+	ValidMinutes int
+}
+
+func BuildWaitlistOfferHTML(offer WaitlistOffer) (string, error) {
+	var buf bytes.Buffer
+	if err := waitlistOfferTmpl.Execute(&buf, offer); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// BuildWaitlistOfferSMS returns the SMS version of the offer, kept short
+// enough for a couple of segments.
+func BuildWaitlistOfferSMS(offer WaitlistOffer) string {
+	return fmt.Sprintf("GBH : un creneau %s s'est libere le %s a %s. Reservez avant %d min : %s",
+		offer.ServiceName, offer.Date, offer.Time, offer.ValidMinutes, offer.ClaimURL)
+}
@@ -0,0 +1,31 @@
+package notifications
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildWaitlistOffer(t *testing.T) {
+	offer := WaitlistOffer{
+		Name:         "Awa <script>",
+		ServiceName:  "Coaching",
+		Date:         "2026-05-04",
+		Time:         "10:00",
+		ClaimURL:     "https://gbh.sarl/liste-attente?token=abc",
+		ValidMinutes: 120,
+	}
+	body, err := BuildWaitlistOfferHTML(offer)
+	if err != nil {
+		t.Fatalf("BuildWaitlistOfferHTML() error = %v", err)
+	}
+	for _, want := range []string{"Awa &lt;script&gt;", "2026-05-04", "10:00", `href="https://gbh.sarl/liste-attente?token=abc"`, "120 minutes"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("html missing %q:\n%s", want, body)
+		}
+	}
+
+	sms := BuildWaitlistOfferSMS(offer)
+	if !strings.Contains(sms, offer.ClaimURL) || !strings.Contains(sms, "10:00") {
+		t.Fatalf("sms = %q", sms)
+	}
+}
@@ -0,0 +1,107 @@
+// Package rbac defines the permissions admin routes are guarded by and the
+// built-in roles that bundle them.
+package rbac
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	AppointmentsRead  = "appointments:read"
+	AppointmentsWrite = "appointments:write"
+	AvailabilityWrite = "availability:write"
+	ServicesWrite     = "services:write"
+	ContactsRead      = "contacts:read"
+	ContactsWrite     = "contacts:write"
+	RFPRead           = "rfp:read"
+	RFPWrite          = "rfp:write"
+	ContentRead       = "content:read"
+	ContentWrite      = "content:write"
+	ContentPublish    = "content:publish"
+	EmailRead         = "email:read"
+	EmailWrite        = "email:write"
+	CalendarRead      = "calendar:read"
+	CalendarWrite     = "calendar:write"
+	UsersRead         = "users:read"
+	UsersWrite        = "users:write"
+	RolesWrite        = "roles:write"
+	APIKeysRead       = "apikeys:read"
+	APIKeysWrite      = "apikeys:write"
+
+	// Wildcard grants every permission.
+	Wildcard = "*"
+)
+
+const (
+	RoleAdmin     = "admin"
+	RoleSales     = "sales"
+	RoleEditor    = "editor"
+	RoleReception = "reception"
+)
+
+// Permissions is the catalogue of known permissions, in display order.
+var Permissions = []string{
+	AppointmentsRead, AppointmentsWrite, AvailabilityWrite, ServicesWrite,
+	ContactsRead, ContactsWrite,
+	RFPRead, RFPWrite,
+	ContentRead, ContentWrite, ContentPublish,
+	EmailRead, EmailWrite,
+	CalendarRead, CalendarWrite,
+	UsersRead, UsersWrite, RolesWrite,
+	APIKeysRead, APIKeysWrite,
+}
+
+var builtin = map[string][]string{
+	RoleAdmin:     {Wildcard},
+	RoleSales:     {RFPRead, RFPWrite, ContactsRead, ContactsWrite},
+	RoleEditor:    {ContentRead, ContentWrite, ContentPublish},
+	RoleReception: {AppointmentsRead, AppointmentsWrite, AvailabilityWrite, ContactsRead, ContactsWrite, CalendarRead},
+}
+
+// Builtin returns the permissions of a built-in role.
+func Builtin(role string) ([]string, bool) {
+	perms, ok := builtin[role]
+	if !ok {
+		return nil, false
+	}
+	return append([]string(nil), perms...), true
+}
+
+// BuiltinRoles lists the built-in role names, sorted.
+func BuiltinRoles() []string {
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Known reports whether p is in the catalogue or is a wildcard ("*" or
+// "<area>:*" for an existing area).
+func Known(p string) bool {
+	if p == Wildcard {
+		return true
+	}
+	for _, known := range Permissions {
+		if known == p {
+			return true
+		}
+		if area, ok := strings.CutSuffix(p, ":*"); ok && strings.HasPrefix(known, area+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether the granted permissions cover p.
+func Allows(granted []string, p string) bool {
+	area, _, _ := strings.Cut(p, ":")
+	for _, g := range granted {
+		if g == Wildcard || g == p || g == area+":*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package rbac
+
+import "testing"
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		granted []string
+		perm    string
+		want    bool
+	}{
+		{granted: []string{Wildcard}, perm: ServicesWrite, want: true},
+		{granted: []string{RFPRead, RFPWrite}, perm: RFPWrite, want: true},
+		{granted: []string{"rfp:*"}, perm: RFPWrite, want: true},
+		{granted: []string{"rfp:*"}, perm: ServicesWrite, want: false},
+		{granted: []string{ContentWrite}, perm: ContentPublish, want: false},
+		{granted: nil, perm: AppointmentsRead, want: false},
+	}
+	for _, tt := range tests {
+		if got := Allows(tt.granted, tt.perm); got != tt.want {
+			t.Errorf("Allows(%v, %q) = %v, want %v", tt.granted, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestKnown(t *testing.T) {
+	for _, p := range []string{Wildcard, RFPWrite, "content:*"} {
+		if !Known(p) {
+			t.Errorf("Known(%q) = false, want true", p)
+		}
+	}
+	for _, p := range []string{"", "rfp", "billing:*", "rfp:delete"} {
+		if Known(p) {
+			t.Errorf("Known(%q) = true, want false", p)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package recurrence expands the subset of RFC 5545 recurrence rules used to
+// book appointment series.
+//
+// Supported parts: FREQ (DAILY, WEEKLY, MONTHLY), INTERVAL, COUNT, UNTIL and
+// BYDAY (plain weekdays, WEEKLY only). A rule must be bounded by COUNT or
+// UNTIL.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+var (
+	ErrInvalidRule = errors.New("invalid recurrence rule")
+	ErrUnbounded   = errors.New("recurrence rule needs COUNT or UNTIL")
+	ErrTooMany     = errors.New("too many occurrences")
+)
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Rule is a parsed recurrence rule. Until is a date (YYYY-MM-DD), inclusive.
+type Rule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    string
+	ByDay    []time.Weekday
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=10". The
+// "RRULE:" prefix is optional.
+func Parse(value string) (Rule, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "RRULE:"), "rrule:")
+	if value == "" {
+		return Rule{}, ErrInvalidRule
+	}
+
+	rule := Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		val = strings.ToUpper(strings.TrimSpace(val))
+		if !ok || key == "" || val == "" {
+			return Rule{}, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+		if seen[key] {
+			return Rule{}, fmt.Errorf("%w: %s repeated", ErrInvalidRule, key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			if val != FreqDaily && val != FreqWeekly && val != FreqMonthly {
+				return Rule{}, fmt.Errorf("%w: FREQ=%s not supported", ErrInvalidRule, val)
+			}
+			rule.Freq = val
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > 12 {
+				return Rule{}, fmt.Errorf("%w: INTERVAL=%s", ErrInvalidRule, val)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return Rule{}, fmt.Errorf("%w: COUNT=%s", ErrInvalidRule, val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			// Date or UTC date-time; only the date matters for day slots.
+			if len(val) < 8 {
+				return Rule{}, fmt.Errorf("%w: UNTIL=%s", ErrInvalidRule, val)
+			}
+			until, err := time.Parse("20060102", val[:8])
+			if err != nil || (len(val) > 8 && !strings.HasPrefix(val[8:], "T")) {
+				return Rule{}, fmt.Errorf("%w: UNTIL=%s", ErrInvalidRule, val)
+			}
+			rule.Until = until.Format("2006-01-02")
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := weekdays[strings.TrimSpace(day)]
+				if !ok {
+					return Rule{}, fmt.Errorf("%w: BYDAY=%s", ErrInvalidRule, day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "WKST":
+			if val != "MO" {
+				return Rule{}, fmt.Errorf("%w: only WKST=MO is supported", ErrInvalidRule)
+			}
+		default:
+			return Rule{}, fmt.Errorf("%w: %s not supported", ErrInvalidRule, key)
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return Rule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	case rule.Count > 0 && rule.Until != "":
+		return Rule{}, fmt.Errorf("%w: COUNT and UNTIL are exclusive", ErrInvalidRule)
+	case rule.Count == 0 && rule.Until == "":
+		return Rule{}, ErrUnbounded
+	case len(rule.ByDay) > 0 && rule.Freq != FreqWeekly:
+		return Rule{}, fmt.Errorf("%w: BYDAY needs FREQ=WEEKLY", ErrInvalidRule)
+	}
+	sort.Slice(rule.ByDay, func(i, j int) bool {
+		return mondayFirst(rule.ByDay[i]) < mondayFirst(rule.ByDay[j])
+	})
+	return rule, nil
+}
+
+// Dates returns the occurrence dates (YYYY-MM-DD) of rule starting on start,
+// which is the first occurrence when it matches the rule. It fails with
+// ErrTooMany rather than returning more than max dates. Monthly rules skip
+// months that do not have the start's day.
+func (r Rule) Dates(start string, max int) ([]string, error) {
+	first, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return nil, fmt.Errorf("%w: start date %q", ErrInvalidRule, start)
+	}
+	if r.Until != "" && r.Until < start {
+		return nil, fmt.Errorf("%w: UNTIL before start", ErrInvalidRule)
+	}
+
+	var dates []string
+	// add reports whether the expansion should go on.
+	add := func(day time.Time) (bool, error) {
+		date := day.Format("2006-01-02")
+		if r.Until != "" && date > r.Until {
+			return false, nil
+		}
+		if len(dates) == max {
+			return false, ErrTooMany
+		}
+		dates = append(dates, date)
+		return r.Count == 0 || len(dates) < r.Count, nil
+	}
+
+	switch r.Freq {
+	case FreqDaily:
+		for day := first; ; day = day.AddDate(0, 0, r.Interval) {
+			if more, err := add(day); err != nil || !more {
+				return dates, err
+			}
+		}
+	case FreqWeekly:
+		byDay := r.ByDay
+		if len(byDay) == 0 {
+			byDay = []time.Weekday{first.Weekday()}
+		}
+		monday := first.AddDate(0, 0, -mondayFirst(first.Weekday()))
+		for week := monday; ; week = week.AddDate(0, 0, 7*r.Interval) {
+			for _, weekday := range byDay {
+				day := week.AddDate(0, 0, mondayFirst(weekday))
+				if day.Before(first) {
+					continue
+				}
+				if more, err := add(day); err != nil || !more {
+					return dates, err
+				}
+			}
+		}
+	case FreqMonthly:
+		// Give up on a day that no month has within a few years.
+		for i, misses := 0, 0; misses < 48; i += r.Interval {
+			year, month, _ := first.Date()
+			day := time.Date(year, month+time.Month(i), first.Day(), 0, 0, 0, 0, time.UTC)
+			if day.Day() != first.Day() {
+				misses++
+				continue
+			}
+			if more, err := add(day); err != nil || !more {
+				return dates, err
+			}
+		}
+		return dates, nil
+	}
+	return nil, fmt.Errorf("%w: FREQ=%s not supported", ErrInvalidRule, r.Freq)
+}
+
+func mondayFirst(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
@@ -0,0 +1,92 @@
+package recurrence
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRuleDates(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		want  []string
+	}{
+		{
+			name:  "weekly on the start day",
+			rule:  "FREQ=WEEKLY;COUNT=3",
+			start: "2026-05-05",
+			want:  []string{"2026-05-05", "2026-05-12", "2026-05-19"},
+		},
+		{
+			name:  "weekly by day skips days before start",
+			rule:  "RRULE:FREQ=WEEKLY;BYDAY=TH,TU;COUNT=4",
+			start: "2026-05-06",
+			want:  []string{"2026-05-07", "2026-05-12", "2026-05-14", "2026-05-19"},
+		},
+		{
+			name:  "every other week until",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;UNTIL=20260602",
+			start: "2026-05-05",
+			want:  []string{"2026-05-05", "2026-05-19", "2026-06-02"},
+		},
+		{
+			name:  "daily with utc until",
+			rule:  "FREQ=DAILY;UNTIL=20260507T235959Z",
+			start: "2026-05-05",
+			want:  []string{"2026-05-05", "2026-05-06", "2026-05-07"},
+		},
+		{
+			name:  "monthly skips short months",
+			rule:  "FREQ=MONTHLY;COUNT=3",
+			start: "2026-01-31",
+			want:  []string{"2026-01-31", "2026-03-31", "2026-05-31"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.rule, err)
+			}
+			got, err := rule.Dates(tt.start, 52)
+			if err != nil {
+				t.Fatalf("Dates() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Dates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		rule string
+		want error
+	}{
+		{rule: "FREQ=WEEKLY", want: ErrUnbounded},
+		{rule: "FREQ=YEARLY;COUNT=2", want: ErrInvalidRule},
+		{rule: "FREQ=DAILY;BYDAY=MO;COUNT=2", want: ErrInvalidRule},
+		{rule: "FREQ=WEEKLY;BYDAY=1MO;COUNT=2", want: ErrInvalidRule},
+		{rule: "FREQ=WEEKLY;COUNT=2;UNTIL=20260601", want: ErrInvalidRule},
+		{rule: "FREQ=WEEKLY;BYHOUR=9;COUNT=2", want: ErrInvalidRule},
+		{rule: "", want: ErrInvalidRule},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.rule); !errors.Is(err, tt.want) {
+			t.Fatalf("Parse(%q) error = %v, want %v", tt.rule, err, tt.want)
+		}
+	}
+}
+
+func TestRuleDatesTooMany(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY;COUNT=30")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, err := rule.Dates("2026-05-05", 10); !errors.Is(err, ErrTooMany) {
+		t.Fatalf("Dates() error = %v, want ErrTooMany", err)
+	}
+}
@@ -55,12 +55,10 @@ func (s *Service) Create(ctx context.Context, req UpsertRequest) (Reference, err
 	return item, nil
 }
 
+// Update replaces the reference fields. Visibility only changes when
+// is_public is sent.
 func (s *Service) Update(ctx context.Context, id string, req UpsertRequest) (Reference, error) {
 	id = strings.TrimSpace(id)
-	isPublic := true
-	if req.IsPublic != nil {
-		isPublic = *req.IsPublic
-	}
 	sortOrder := 0
 	if req.SortOrder != nil {
 		sortOrder = *req.SortOrder
@@ -72,10 +70,12 @@ func (s *Service) Update(ctx context.Context, id string, req UpsertRequest) (Ref
 		"summary":     strings.TrimSpace(req.Summary),
 		"location":    strings.TrimSpace(req.Location),
 		"logo_url":    strings.TrimSpace(req.LogoURL),
-		"is_public":   isPublic,
 		"sort_order":  sortOrder,
 		"updated_at":  time.Now().In(s.location),
 	}
+	if req.IsPublic != nil {
+		set["is_public"] = *req.IsPublic
+	}
 
 	updated, err := s.repo.Update(ctx, id, set)
 	if err != nil {
@@ -39,20 +39,21 @@ func IsValidSource(value string) bool {
 }
 
 type Lead struct {
-	ID           string    `bson:"_id,omitempty" json:"id"`
-	Organization string    `bson:"organization" json:"organization"`
-	Sector       string    `bson:"sector,omitempty" json:"sector,omitempty"`
-	Domain       string    `bson:"domain" json:"domain"`
-	Deadline     string    `bson:"deadline,omitempty" json:"deadline,omitempty"`
-	BudgetRange  string    `bson:"budget_range,omitempty" json:"budget_range,omitempty"`
-	ContactName  string    `bson:"contact_name,omitempty" json:"contact_name,omitempty"`
-	Phone        string    `bson:"phone" json:"phone"`
-	Email        string    `bson:"email,omitempty" json:"email,omitempty"`
-	Description  string    `bson:"description" json:"description"`
-	Status       string    `bson:"status" json:"status"`
-	Source       string    `bson:"source" json:"source"`
-	CreatedAt    time.Time `bson:"created_at" json:"created_at"`
-	UpdatedAt    time.Time `bson:"updated_at" json:"updated_at"`
+	ID             string     `bson:"_id,omitempty" json:"id"`
+	Organization   string     `bson:"organization" json:"organization"`
+	Sector         string     `bson:"sector,omitempty" json:"sector,omitempty"`
+	Domain         string     `bson:"domain" json:"domain"`
+	Deadline       string     `bson:"deadline,omitempty" json:"deadline,omitempty"`
+	BudgetRange    string     `bson:"budget_range,omitempty" json:"budget_range,omitempty"`
+	ContactName    string     `bson:"contact_name,omitempty" json:"contact_name,omitempty"`
+	Phone          string     `bson:"phone" json:"phone"`
+	Email          string     `bson:"email,omitempty" json:"email,omitempty"`
+	Description    string     `bson:"description" json:"description"`
+	Status         string     `bson:"status" json:"status"`
+	Source         string     `bson:"source" json:"source"`
+	EmailBouncedAt *time.Time `bson:"email_bounced_at,omitempty" json:"email_bounced_at,omitempty"`
+	CreatedAt      time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time  `bson:"updated_at" json:"updated_at"`
 }
 
 type CreateRequest struct {
@@ -99,7 +99,19 @@ func GenerateSlotsWithDuration(dateStr string, duration int, loc *time.Location)
 		return nil, ErrInvalidDuration
 	}
 
-	ranges := dayRanges(date.Weekday())
+	return generateSlots(dayRanges(date.Weekday()), duration)
+}
+
+// GenerateSlotsInRanges lists the slots of duration minutes fitting in
+// ranges, for places with their own opening hours.
+func GenerateSlotsInRanges(ranges []TimeRange, duration int) ([]string, error) {
+	if duration <= 0 {
+		return nil, ErrInvalidDuration
+	}
+	return generateSlots(ranges, duration)
+}
+
+func generateSlots(ranges []TimeRange, duration int) ([]string, error) {
 	if len(ranges) == 0 {
 		return []string{}, nil
 	}
@@ -66,6 +66,19 @@ func TestGenerateSlotsSundayClosed(t *testing.T) {
 	}
 }
 
+func TestGenerateSlotsInRanges(t *testing.T) {
+	slots, err := GenerateSlotsInRanges([]TimeRange{{Start: "08:00", End: "10:00"}, {Start: "15:00", End: "16:00"}}, 60)
+	if err != nil {
+		t.Fatalf("GenerateSlotsInRanges error: %v", err)
+	}
+	if len(slots) != 3 || slots[0] != "08:00" || slots[2] != "15:00" {
+		t.Fatalf("unexpected slots: %v", slots)
+	}
+	if _, err := GenerateSlotsInRanges(nil, 0); err != ErrInvalidDuration {
+		t.Fatalf("expected ErrInvalidDuration, got %v", err)
+	}
+}
+
 func TestIsDatePast(t *testing.T) {
 	loc := mustLoadLoc(t)
 	now := time.Date(2026, 2, 4, 10, 0, 0, 0, loc)